@@ -9,6 +9,7 @@ import (
 
 	"github.com/elasticphphq/agent/internal/config"
 	"github.com/elasticphphq/agent/internal/logging"
+	"github.com/elasticphphq/agent/internal/redact"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -62,6 +63,20 @@ var rootCmd = &cobra.Command{
 						site.Path = val
 					case "appinfo":
 						site.EnableAppInfo = val == "true"
+					case "queue_backend":
+						site.QueueBackend = val
+					case "discover":
+						site.DiscoverQueues = val == "true"
+					case "horizon":
+						site.EnableHorizon = val == "true"
+					case "workers":
+						site.MonitorWorkers = val == "true"
+					case "schedule":
+						site.MonitorSchedule = val == "true"
+					case "logs":
+						site.MonitorLogs = val == "true"
+					case "exceptions":
+						site.MonitorExceptions = val == "true"
 					case "connection":
 						lastConnection = val
 						if _, ok := site.Queues[lastConnection]; !ok {
@@ -104,6 +119,10 @@ var rootCmd = &cobra.Command{
 		logging.L().Debug("ElasticPHP-agent Logging initialized", "level", Config.Logging.Level)
 		logging.L().Debug("ElasticPHP-agent Loaded config", "config", Config)
 
+		if err := redact.Configure(Config.Redaction); err != nil {
+			return fmt.Errorf("invalid redaction config: %w", err)
+		}
+
 		// phpfpm autodiscover
 		if Config.PHPFpm.Enabled && Config.PHPFpm.Autodiscover {
 			var discovered []phpfpm.DiscoveredFPM
@@ -127,17 +146,23 @@ var rootCmd = &cobra.Command{
 				logging.L().Debug("ElasticPHP-agent Discovered PHP-FPM Processes", "pools", discovered)
 				for _, d := range discovered {
 					Config.PHPFpm.Pools = append(Config.PHPFpm.Pools, config.FPMPoolConfig{
+						Name:         d.Pool,
 						Socket:       d.Socket,
 						StatusSocket: d.StatusSocket,
 						StatusPath:   d.StatusPath,
 						ConfigPath:   d.ConfigPath,
 						Binary:       d.Binary,
 						CliBinary:    d.CliBinary,
+						PingPath:     d.PingPath,
+						PingResponse: d.PingResponse,
 					})
 				}
 			}
 		}
 
+		// Discovered pools may repeat configured ones, keep the configured entry
+		Config.PHPFpm.Pools = phpfpm.DedupePools(Config.PHPFpm.Pools)
+
 		return nil
 	},
 }
@@ -154,7 +179,7 @@ func init() {
 	rootCmd.PersistentFlags().String("config", "", "config file path")
 	rootCmd.PersistentFlags().Bool("autodiscover", true, "Autodiscover php-fpm pools")
 	rootCmd.PersistentFlags().String("log-level", "", "Override log level (e.g. debug, info, warn)")
-	rootCmd.PersistentFlags().StringArrayVar(&laravelFlags, "laravel", nil, "Laravel site config: name=...,path=...")
+	rootCmd.PersistentFlags().StringArrayVar(&laravelFlags, "laravel", nil, "Laravel site config: name=...,path=...,discover=true,horizon=true,workers=true,schedule=true,logs=true,exceptions=true or connection=...,queues=a|b")
 	_ = viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 	_ = viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
 	_ = viper.BindPFlag("phpfpm.autodiscover", rootCmd.PersistentFlags().Lookup("autodiscover"))
@@ -150,6 +150,29 @@ func TestRootCommand_LaravelFlagParsing(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name:         "queue discovery",
+			laravelFlags: []string{"path=/tmp/test,queue_backend=native,discover=true"},
+			expectedErr:  "",
+			validate: func(cfg *config.Config) error {
+				site := cfg.Laravel[0]
+				if !site.DiscoverQueues || site.QueueBackend != "native" {
+					return fmt.Errorf("expected native backend with discovery, got %+v", site)
+				}
+				return nil
+			},
+		},
+		{
+			name:         "horizon, workers, schedule, logs and exceptions",
+			laravelFlags: []string{"path=/tmp/test,horizon=true,workers=true,schedule=true,logs=true,exceptions=true"},
+			expectedErr:  "",
+			validate: func(cfg *config.Config) error {
+				if site := cfg.Laravel[0]; !site.EnableHorizon || !site.MonitorWorkers || !site.MonitorSchedule || !site.MonitorLogs || !site.MonitorExceptions {
+					return fmt.Errorf("expected Horizon, worker, schedule, log and exception monitoring to be enabled, got %+v", site)
+				}
+				return nil
+			},
+		},
 		{
 			name:         "missing path",
 			laravelFlags: []string{"name=testsite"},
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
+
 	"github.com/elasticphphq/agent/internal/logging"
+	"github.com/elasticphphq/agent/internal/metrics"
 
 	"github.com/elasticphphq/agent/internal/serve"
 	"github.com/spf13/cobra"
@@ -12,6 +15,7 @@ var serveCmd = &cobra.Command{
 	Short: "Start agent HTTP server with metrics and control endpoints",
 	Run: func(cmd *cobra.Command, args []string) {
 		logging.L().Info("ElasticPHP-agent Starting")
+		metrics.StartBackground(context.Background(), Config)
 		serve.StartPrometheusServer(Config)
 	},
 }
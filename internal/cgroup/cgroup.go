@@ -0,0 +1,413 @@
+// Package cgroup reads the resource limits and accounting of the cgroup the
+// agent runs in, for both cgroup v1 and the unified v2 hierarchy. CPU
+// throttling, memory events and pressure stall information are what explain
+// FPM slowdowns inside containers.
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrUnavailable is returned when no cgroup hierarchy is mounted, e.g. on macOS.
+var ErrUnavailable = errors.New("cgroup filesystem not available")
+
+var (
+	// root and procRoot are variables so tests can point them at fixtures.
+	root     = "/sys/fs/cgroup"
+	procRoot = "/proc"
+)
+
+// v1 memory limits at or above this value mean "unlimited" (PAGE_COUNTER_MAX).
+const v1UnlimitedMemory = int64(1) << 62
+
+type Stats struct {
+	Version  int                  `json:"version"`
+	CPU      *CPUStats            `json:"cpu,omitempty"`
+	Memory   *MemoryStats         `json:"memory,omitempty"`
+	IO       *IOStats             `json:"io,omitempty"`
+	Pressure map[string]*Pressure `json:"pressure,omitempty"` // Keyed by resource: cpu, memory, io
+}
+
+type CPUStats struct {
+	Limit            float64 `json:"limit"` // Fractional cores, 0 when unlimited
+	UsageSeconds     float64 `json:"usage_seconds"`
+	Periods          uint64  `json:"nr_periods"`
+	ThrottledPeriods uint64  `json:"nr_throttled"`
+	ThrottledSeconds float64 `json:"throttled_seconds"`
+}
+
+type MemoryStats struct {
+	Limit   int64             `json:"limit"` // Bytes, 0 when unlimited
+	High    int64             `json:"high"`  // v2 memory.high throttling threshold, 0 when unset
+	Current uint64            `json:"current"`
+	Peak    uint64            `json:"peak"` // 0 when the kernel does not track it
+	Events  map[string]uint64 `json:"events,omitempty"`
+}
+
+// IOStats sums the v2 io.stat counters over all devices.
+type IOStats struct {
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+}
+
+// Pressure is one PSI file. Averages are percentages of wall time in which some
+// or all tasks were stalled on the resource, totals are cumulative seconds.
+type Pressure struct {
+	Some *PressureLine `json:"some,omitempty"`
+	Full *PressureLine `json:"full,omitempty"`
+}
+
+type PressureLine struct {
+	Avg10        float64 `json:"avg10"`
+	Avg60        float64 `json:"avg60"`
+	Avg300       float64 `json:"avg300"`
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+// Read returns the stats of the cgroup the agent runs in. Controllers that are
+// not mounted or not readable are left out.
+func Read() (*Stats, error) {
+	if _, err := os.Stat(root); err != nil {
+		return nil, ErrUnavailable
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return readV2(), nil
+	}
+	return readV1(), nil
+}
+
+// ReadPath returns the stats of a v2 cgroup by its path in the hierarchy, as
+// listed in /proc/<pid>/cgroup. v1 hierarchies are not supported.
+func ReadPath(path string) (*Stats, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, ErrUnavailable
+	}
+
+	dir := filepath.Join(root, path)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	return readV2Dir(dir), nil
+}
+
+// ProcessPath returns the v2 cgroup path of a process.
+func ProcessPath(pid int) (string, error) {
+	path := pidPath(strconv.Itoa(pid), "")
+	if path == "" {
+		return "", fmt.Errorf("no cgroup v2 entry for pid %d", pid)
+	}
+	return path, nil
+}
+
+// Procs returns the PIDs that are direct members of a v2 cgroup.
+func Procs(path string) ([]int, error) {
+	data, err := os.ReadFile(filepath.Join(root, path, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, field := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func readV2() *Stats {
+	return readV2Dir(ownDir(root, selfPath("")))
+}
+
+func readV2Dir(dir string) *Stats {
+	stats := &Stats{Version: 2, Pressure: map[string]*Pressure{}}
+
+	if cpuStat, err := readKeyValues(filepath.Join(dir, "cpu.stat")); err == nil {
+		cpu := &CPUStats{
+			UsageSeconds:     float64(cpuStat["usage_usec"]) / 1e6,
+			Periods:          cpuStat["nr_periods"],
+			ThrottledPeriods: cpuStat["nr_throttled"],
+			ThrottledSeconds: float64(cpuStat["throttled_usec"]) / 1e6,
+		}
+		if fields := readFields(filepath.Join(dir, "cpu.max")); len(fields) == 2 && fields[0] != "max" {
+			cpu.Limit = quotaCores(fields[0], fields[1])
+		}
+		stats.CPU = cpu
+	}
+
+	if current, err := readUint(filepath.Join(dir, "memory.current")); err == nil {
+		memory := &MemoryStats{Current: current}
+		memory.Limit, _ = readLimit(filepath.Join(dir, "memory.max"))
+		memory.High, _ = readLimit(filepath.Join(dir, "memory.high"))
+		memory.Peak, _ = readUint(filepath.Join(dir, "memory.peak"))
+		if events, err := readKeyValues(filepath.Join(dir, "memory.events")); err == nil {
+			memory.Events = events
+		}
+		stats.Memory = memory
+	}
+
+	if ioStat, err := os.ReadFile(filepath.Join(dir, "io.stat")); err == nil {
+		stats.IO = parseIOStat(string(ioStat))
+	}
+
+	for _, resource := range []string{"cpu", "memory", "io"} {
+		if p, err := readPressure(filepath.Join(dir, resource+".pressure")); err == nil {
+			stats.Pressure[resource] = p
+		}
+	}
+
+	return stats
+}
+
+func readV1() *Stats {
+	stats := &Stats{Version: 1, Pressure: map[string]*Pressure{}}
+
+	if base := v1Mount("cpu", "cpu,cpuacct", "cpuacct,cpu"); base != "" {
+		dir := ownDir(base, selfPath("cpu"))
+		if cpuStat, err := readKeyValues(filepath.Join(dir, "cpu.stat")); err == nil {
+			cpu := &CPUStats{
+				Periods:          cpuStat["nr_periods"],
+				ThrottledPeriods: cpuStat["nr_throttled"],
+				ThrottledSeconds: float64(cpuStat["throttled_time"]) / 1e9,
+			}
+			quota := readFields(filepath.Join(dir, "cpu.cfs_quota_us"))
+			period := readFields(filepath.Join(dir, "cpu.cfs_period_us"))
+			if len(quota) == 1 && len(period) == 1 {
+				cpu.Limit = quotaCores(quota[0], period[0])
+			}
+			if usage, err := readUint(filepath.Join(dir, "cpuacct.usage")); err == nil {
+				cpu.UsageSeconds = float64(usage) / 1e9
+			}
+			stats.CPU = cpu
+		}
+	}
+
+	if base := v1Mount("memory"); base != "" {
+		dir := ownDir(base, selfPath("memory"))
+		if current, err := readUint(filepath.Join(dir, "memory.usage_in_bytes")); err == nil {
+			memory := &MemoryStats{Current: current, Events: map[string]uint64{}}
+			if limit, err := readLimit(filepath.Join(dir, "memory.limit_in_bytes")); err == nil && limit < v1UnlimitedMemory {
+				memory.Limit = limit
+			}
+			memory.Peak, _ = readUint(filepath.Join(dir, "memory.max_usage_in_bytes"))
+			// failcnt counts how often usage hit the limit, like "max" in v2
+			if failcnt, err := readUint(filepath.Join(dir, "memory.failcnt")); err == nil {
+				memory.Events["max"] = failcnt
+			}
+			if oom, err := readKeyValues(filepath.Join(dir, "memory.oom_control")); err == nil {
+				if kills, ok := oom["oom_kill"]; ok {
+					memory.Events["oom_kill"] = kills
+				}
+			}
+			stats.Memory = memory
+		}
+	}
+
+	// v1 has no per-cgroup PSI, the system-wide files are the closest signal
+	for _, resource := range []string{"cpu", "memory", "io"} {
+		if p, err := readPressure(filepath.Join(procRoot, "pressure", resource)); err == nil {
+			stats.Pressure[resource] = p
+		}
+	}
+
+	return stats
+}
+
+// selfPath returns the cgroup path of the agent from /proc/self/cgroup, for a
+// v1 controller or, with an empty controller, for the v2 hierarchy.
+func selfPath(controller string) string {
+	if path := pidPath("self", controller); path != "" {
+		return path
+	}
+	return "/"
+}
+
+// pidPath reads the cgroup path of a process from /proc/<pid>/cgroup.
+func pidPath(pid, controller string) string {
+	file, err := os.Open(filepath.Join(procRoot, pid, "cgroup"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if controller == "" {
+			if parts[0] == "0" && parts[1] == "" {
+				return parts[2]
+			}
+			continue
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if c == controller {
+				return parts[2]
+			}
+		}
+	}
+	return ""
+}
+
+// ownDir resolves a cgroup path below a mount. Inside a container with its own
+// cgroup namespace the mount already is the container's cgroup and the host
+// path does not exist below it.
+func ownDir(base, path string) string {
+	dir := filepath.Join(base, path)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir
+	}
+	return base
+}
+
+func v1Mount(names ...string) string {
+	for _, name := range names {
+		dir := filepath.Join(root, name)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// quotaCores converts a CFS quota and period to fractional cores, so a quota
+// of 150000 per 100000 is 1.5 CPUs. A negative quota means unlimited.
+func quotaCores(quota, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// readPressure parses a PSI file such as cpu.pressure or /proc/pressure/io.
+func readPressure(path string) (*Pressure, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pressure := &Pressure{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		parsed := &PressureLine{}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "avg10":
+				parsed.Avg10 = f
+			case "avg60":
+				parsed.Avg60 = f
+			case "avg300":
+				parsed.Avg300 = f
+			case "total":
+				parsed.TotalSeconds = f / 1e6
+			}
+		}
+
+		switch fields[0] {
+		case "some":
+			pressure.Some = parsed
+		case "full":
+			pressure.Full = parsed
+		}
+	}
+	return pressure, nil
+}
+
+// parseIOStat sums rbytes and wbytes of io.stat lines such as
+// "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0".
+func parseIOStat(data string) *IOStats {
+	stats := &IOStats{}
+	for _, line := range strings.Split(data, "\n") {
+		for _, field := range strings.Fields(line) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				stats.ReadBytes += v
+			case "wbytes":
+				stats.WriteBytes += v
+			}
+		}
+	}
+	return stats
+}
+
+// readKeyValues parses flat keyed files such as cpu.stat and memory.events.
+func readKeyValues(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]uint64{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values, nil
+}
+
+// readLimit parses a memory limit file, returning 0 for "max".
+func readLimit(path string) (int64, error) {
+	fields := readFields(path)
+	if len(fields) != 1 {
+		return 0, os.ErrNotExist
+	}
+	if fields[0] == "max" {
+		return 0, nil
+	}
+	return strconv.ParseInt(fields[0], 10, 64)
+}
+
+func readUint(path string) (uint64, error) {
+	fields := readFields(path)
+	if len(fields) != 1 {
+		return 0, os.ErrNotExist
+	}
+	return strconv.ParseUint(fields[0], 10, 64)
+}
+
+func readFields(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
@@ -0,0 +1,243 @@
+package cgroup
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create fixture dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write fixture: %v", err)
+		}
+	}
+	return dir
+}
+
+func useFixture(t *testing.T, cgroupRoot, proc string) {
+	t.Helper()
+
+	origRoot, origProc := root, procRoot
+	root, procRoot = cgroupRoot, proc
+	t.Cleanup(func() {
+		root, procRoot = origRoot, origProc
+	})
+}
+
+func TestRead_V2(t *testing.T) {
+	cgroupRoot := writeFixture(t, map[string]string{
+		"cgroup.controllers":           "cpu memory io\n",
+		"cpu.max":                      "max 100000\n",
+		"kubepods/pod1/cpu.max":        "150000 100000\n",
+		"kubepods/pod1/cpu.stat":       "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\nnr_periods 120\nnr_throttled 30\nthrottled_usec 4500000\n",
+		"kubepods/pod1/memory.current": "104857600\n",
+		"kubepods/pod1/memory.max":     "268435456\n",
+		"kubepods/pod1/memory.high":    "max\n",
+		"kubepods/pod1/memory.peak":    "209715200\n",
+		"kubepods/pod1/memory.events":  "low 0\nhigh 12\nmax 3\noom 2\noom_kill 1\n",
+		"kubepods/pod1/cpu.pressure":   "some avg10=12.50 avg60=5.00 avg300=1.25 total=3000000\nfull avg10=2.00 avg60=1.00 avg300=0.50 total=1000000\n",
+		"kubepods/pod1/memory.pressure": "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n" +
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+	})
+	proc := writeFixture(t, map[string]string{
+		"self/cgroup": "0::/kubepods/pod1\n",
+	})
+	useFixture(t, cgroupRoot, proc)
+
+	stats, err := Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.Version != 2 {
+		t.Errorf("Expected cgroup v2, got %d", stats.Version)
+	}
+
+	cpu := stats.CPU
+	if cpu == nil {
+		t.Fatalf("Expected CPU stats")
+	}
+	if cpu.Limit != 1.5 {
+		t.Errorf("Expected a fractional limit of 1.5 cores, got %v", cpu.Limit)
+	}
+	if cpu.UsageSeconds != 2.5 || cpu.Periods != 120 || cpu.ThrottledPeriods != 30 || cpu.ThrottledSeconds != 4.5 {
+		t.Errorf("Unexpected CPU stats %+v", cpu)
+	}
+
+	mem := stats.Memory
+	if mem == nil {
+		t.Fatalf("Expected memory stats")
+	}
+	if mem.Limit != 268435456 || mem.High != 0 || mem.Current != 104857600 || mem.Peak != 209715200 {
+		t.Errorf("Unexpected memory stats %+v", mem)
+	}
+	if mem.Events["high"] != 12 || mem.Events["oom"] != 2 || mem.Events["oom_kill"] != 1 {
+		t.Errorf("Unexpected memory events %v", mem.Events)
+	}
+
+	cpuPressure := stats.Pressure["cpu"]
+	if cpuPressure == nil || cpuPressure.Some == nil || cpuPressure.Full == nil {
+		t.Fatalf("Expected CPU pressure, got %+v", cpuPressure)
+	}
+	if cpuPressure.Some.Avg10 != 12.5 || cpuPressure.Some.TotalSeconds != 3 || cpuPressure.Full.Avg300 != 0.5 {
+		t.Errorf("Unexpected CPU pressure some=%+v full=%+v", cpuPressure.Some, cpuPressure.Full)
+	}
+	if _, ok := stats.Pressure["io"]; ok {
+		t.Errorf("Expected missing pressure files to be skipped")
+	}
+}
+
+func TestRead_V2_Namespaced(t *testing.T) {
+	// With a cgroup namespace the host path does not exist below the mount
+	cgroupRoot := writeFixture(t, map[string]string{
+		"cgroup.controllers": "cpu memory\n",
+		"cpu.max":            "50000 100000\n",
+		"cpu.stat":           "usage_usec 100\nnr_periods 0\nnr_throttled 0\nthrottled_usec 0\n",
+		"memory.current":     "1024\n",
+		"memory.max":         "max\n",
+	})
+	proc := writeFixture(t, map[string]string{
+		"self/cgroup": "0::/system.slice/docker-abc.scope\n",
+	})
+	useFixture(t, cgroupRoot, proc)
+
+	stats, err := Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.CPU == nil || stats.CPU.Limit != 0.5 {
+		t.Errorf("Expected 0.5 cores from the mount root, got %+v", stats.CPU)
+	}
+	if stats.Memory == nil || stats.Memory.Limit != 0 || stats.Memory.Peak != 0 {
+		t.Errorf("Expected an unlimited memory cgroup without peak tracking, got %+v", stats.Memory)
+	}
+}
+
+func TestRead_V1(t *testing.T) {
+	cgroupRoot := writeFixture(t, map[string]string{
+		"cpu,cpuacct/cpu.cfs_quota_us":     "250000\n",
+		"cpu,cpuacct/cpu.cfs_period_us":    "100000\n",
+		"cpu,cpuacct/cpu.stat":             "nr_periods 10\nnr_throttled 4\nthrottled_time 2000000000\n",
+		"cpu,cpuacct/cpuacct.usage":        "7500000000\n",
+		"memory/memory.usage_in_bytes":     "52428800\n",
+		"memory/memory.limit_in_bytes":     "9223372036854771712\n",
+		"memory/memory.max_usage_in_bytes": "62914560\n",
+		"memory/memory.failcnt":            "7\n",
+		"memory/memory.oom_control":        "oom_kill_disable 0\nunder_oom 0\noom_kill 2\n",
+	})
+	proc := writeFixture(t, map[string]string{
+		"self/cgroup":     "12:memory:/\n4:cpu,cpuacct:/docker/missing\n",
+		"pressure/memory": "some avg10=1.00 avg60=0.50 avg300=0.10 total=42\n",
+	})
+	useFixture(t, cgroupRoot, proc)
+
+	stats, err := Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.Version != 1 {
+		t.Errorf("Expected cgroup v1, got %d", stats.Version)
+	}
+
+	cpu := stats.CPU
+	if cpu == nil {
+		t.Fatalf("Expected CPU stats")
+	}
+	if cpu.Limit != 2.5 || cpu.UsageSeconds != 7.5 || cpu.ThrottledPeriods != 4 || cpu.ThrottledSeconds != 2 {
+		t.Errorf("Unexpected CPU stats %+v", cpu)
+	}
+
+	mem := stats.Memory
+	if mem == nil {
+		t.Fatalf("Expected memory stats")
+	}
+	if mem.Limit != 0 {
+		t.Errorf("Expected the v1 unlimited sentinel to be reported as no limit, got %d", mem.Limit)
+	}
+	if mem.Current != 52428800 || mem.Peak != 62914560 || mem.Events["max"] != 7 || mem.Events["oom_kill"] != 2 {
+		t.Errorf("Unexpected memory stats %+v", mem)
+	}
+
+	if p := stats.Pressure["memory"]; p == nil || p.Some == nil || math.Abs(p.Some.TotalSeconds-0.000042) > 1e-12 {
+		t.Errorf("Expected system-wide memory pressure, got %+v", p)
+	}
+}
+
+func TestRead_Unavailable(t *testing.T) {
+	useFixture(t, filepath.Join(t.TempDir(), "missing"), t.TempDir())
+
+	if _, err := Read(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestQuotaCores(t *testing.T) {
+	tests := []struct {
+		quota, period string
+		expected      float64
+	}{
+		{"150000", "100000", 1.5},
+		{"200000", "100000", 2},
+		{"-1", "100000", 0},
+		{"max", "100000", 0},
+		{"50000", "0", 0},
+	}
+
+	for _, tt := range tests {
+		if got := quotaCores(tt.quota, tt.period); got != tt.expected {
+			t.Errorf("quotaCores(%q, %q) = %v, expected %v", tt.quota, tt.period, got, tt.expected)
+		}
+	}
+}
+
+func TestReadPath(t *testing.T) {
+	cgroupRoot := writeFixture(t, map[string]string{
+		"cgroup.controllers":                          "cpu memory io\n",
+		"system.slice/php-fpm.service/cgroup.procs":   "1\n10\n11\n",
+		"system.slice/php-fpm.service/cpu.stat":       "usage_usec 1500000\nnr_periods 0\nnr_throttled 0\nthrottled_usec 0\n",
+		"system.slice/php-fpm.service/memory.current": "4096\n",
+		"system.slice/php-fpm.service/io.stat":        "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0\n259:0 rbytes=1 wbytes=2 rios=1 wios=1\n",
+	})
+	proc := writeFixture(t, map[string]string{
+		"10/cgroup": "0::/system.slice/php-fpm.service\n",
+		"12/cgroup": "5:memory:/legacy\n",
+	})
+	useFixture(t, cgroupRoot, proc)
+
+	path, err := ProcessPath(10)
+	if err != nil || path != "/system.slice/php-fpm.service" {
+		t.Fatalf("Expected the v2 path of pid 10, got %q (%v)", path, err)
+	}
+	if _, err := ProcessPath(12); err == nil {
+		t.Errorf("Expected an error for a process without a v2 entry")
+	}
+
+	pids, err := Procs(path)
+	if err != nil || len(pids) != 3 || pids[2] != 11 {
+		t.Errorf("Expected members 1, 10 and 11, got %v (%v)", pids, err)
+	}
+
+	stats, err := ReadPath(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.CPU == nil || stats.CPU.UsageSeconds != 1.5 || stats.Memory == nil || stats.Memory.Current != 4096 {
+		t.Errorf("Unexpected cgroup stats %+v", stats)
+	}
+	if stats.IO == nil || stats.IO.ReadBytes != 1025 || stats.IO.WriteBytes != 2050 {
+		t.Errorf("Expected io.stat to be summed over devices, got %+v", stats.IO)
+	}
+
+	if _, err := ReadPath("/missing.slice"); err == nil {
+		t.Errorf("Expected an error for a missing cgroup")
+	}
+}
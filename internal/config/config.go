@@ -12,6 +12,40 @@ type Config struct {
 	PHP     PHPConfig       `mapstructure:"php"`
 	Monitor MonitorConfig   `mapstructure:"monitor"`
 	Laravel []LaravelConfig `mapstructure:"laravel"`
+
+	Redaction  RedactionConfig  `mapstructure:"redaction"`
+	Host       HostConfig       `mapstructure:"host"`
+	Kubernetes KubernetesConfig `mapstructure:"kubernetes"`
+}
+
+// KubernetesConfig controls the pod metadata read from the downward API.
+type KubernetesConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	PodInfoPath string   `mapstructure:"podinfo_path"` // Downward API volume with the labels and annotations files
+	ConstLabels []string `mapstructure:"const_labels"` // Added to every series: pod, namespace, node, label:<name> or annotation:<name>
+}
+
+// HostConfig controls the host resource collector. Disable it where
+// node_exporter already reports the host.
+type HostConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Paths       []string      `mapstructure:"paths"`        // Extra paths for disk usage, next to Laravel app paths and FPM socket dirs
+	CPUInterval time.Duration `mapstructure:"cpu_interval"` // Interval CPU utilisation is sampled over
+}
+
+// RedactionConfig controls how request URIs and other request-derived strings
+// are scrubbed before they leave the agent.
+type RedactionConfig struct {
+	Enabled        bool            `mapstructure:"enabled"`
+	DropQuery      bool            `mapstructure:"drop_query"`       // Remove query parameters that are not allow-listed
+	AllowQueryKeys []string        `mapstructure:"allow_query_keys"` // Query keys kept when drop_query is enabled
+	Scrubbers      []string        `mapstructure:"scrubbers"`        // Built-in scrubbers: email, uuid, numeric_id, token
+	Patterns       []RedactPattern `mapstructure:"patterns"`         // Additional regex scrubbers
+}
+
+type RedactPattern struct {
+	Match   string `mapstructure:"match"`
+	Replace string `mapstructure:"replace"`
 }
 
 type LoggingBlock struct {
@@ -33,26 +67,101 @@ type FPMConfig struct {
 	RetryDelay   int             `mapstructure:"retry_delay"`
 	Pools        []FPMPoolConfig `mapstructure:"pools"`
 	PollInterval time.Duration   `mapstructure:"poll_interval"`
+	PingInterval time.Duration   `mapstructure:"ping_interval"` // How often pools with a ping path are probed, 0 disables
+
+	StuckThreshold   time.Duration `mapstructure:"stuck_threshold"`    // Time in Reading headers/Finishing before a worker counts as stuck
+	StuckLogInterval time.Duration `mapstructure:"stuck_log_interval"` // Minimum time between stuck worker logs for the same PID
+	RecycleThreshold float64       `mapstructure:"recycle_threshold"`  // Fraction of pm.max_requests at which a worker is close to recycling
+
+	Sampler       SamplerConfig       `mapstructure:"sampler"`
+	LeakDetection LeakDetectionConfig `mapstructure:"leak_detection"`
+}
+
+// LeakDetectionConfig controls the per-worker memory trend analysis fed by
+// background status snapshots.
+type LeakDetectionConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	MinSamples     int     `mapstructure:"min_samples"`     // Requests observed per worker before its trend is judged
+	SlopeThreshold float64 `mapstructure:"slope_threshold"` // Steady growth in bytes per request that counts as a leak
+	TopScripts     int     `mapstructure:"top_scripts"`     // Scripts reported per pool by associated growth
+}
+
+// SamplerConfig controls sampling of in-flight requests from the full status
+// to rank the endpoints that use the most worker time.
+type SamplerConfig struct {
+	Enabled bool            `mapstructure:"enabled"`
+	Windows []time.Duration `mapstructure:"windows"` // Sliding windows to report, e.g. 1m, 5m, 15m
+	TopN    int             `mapstructure:"top_n"`   // Endpoints reported per window and pool
+	Rules   []NormalizeRule `mapstructure:"rules"`   // Applied in order after the built-in ID/UUID rules
+}
+
+type NormalizeRule struct {
+	Match   string `mapstructure:"match"`   // Regular expression matched against the request URI
+	Replace string `mapstructure:"replace"` // Replacement, may reference groups as $1
 }
 
 type FPMPoolConfig struct {
-	Socket            string        `mapstructure:"socket"`
-	StatusSocket      string        `mapstructure:"status_socket"`
-	StatusPath        string        `mapstructure:"status_path"`
-	StatusPathEnabled bool          `mapstructure:"status_path_enabled"`
-	ConfigPath        string        `mapstructure:"config_path"`
-	Binary            string        `mapstructure:"binary"`
-	CliBinary         string        `mapstructure:"cli_binary"`
-	PollInterval      time.Duration `mapstructure:"poll_interval"`
-	Timeout           time.Duration `mapstructure:"timeout"`
+	Name              string            `mapstructure:"name"`   // Overrides the pool label, defaults to the name reported by FPM
+	Labels            map[string]string `mapstructure:"labels"` // Extra labels exposed on phpfpm_pool_info
+	Socket            string            `mapstructure:"socket"`
+	StatusSocket      string            `mapstructure:"status_socket"`
+	StatusPath        string            `mapstructure:"status_path"`
+	StatusPathEnabled bool              `mapstructure:"status_path_enabled"`
+	StatusFormat      string            `mapstructure:"status_format"` // json (default), openmetrics or auto
+	ConfigPath        string            `mapstructure:"config_path"`
+	Binary            string            `mapstructure:"binary"`
+	CliBinary         string            `mapstructure:"cli_binary"`
+	PollInterval      time.Duration     `mapstructure:"poll_interval"`
+	Timeout           time.Duration     `mapstructure:"timeout"`
+	PingPath          string            `mapstructure:"ping_path"`     // FPM ping.path, probes are skipped when empty
+	PingResponse      string            `mapstructure:"ping_response"` // FPM ping.response, defaults to "pong"
+	PingInterval      time.Duration     `mapstructure:"ping_interval"` // Overrides phpfpm.ping_interval for this pool
+	HTTP              FPMHTTPConfig     `mapstructure:"http"`          // Used when status_socket is an http:// or https:// URL
+}
+
+type FPMHTTPConfig struct {
+	Username string            `mapstructure:"username"`
+	Password string            `mapstructure:"password"`
+	Headers  map[string]string `mapstructure:"headers"`
+	TLS      TLSConfig         `mapstructure:"tls"`
+}
+
+type TLSConfig struct {
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
 }
 
 type LaravelConfig struct {
 	Name          string              `mapstructure:"name"` // Optional name for identification
 	Path          string              `mapstructure:"path"` // Root path to Laravel app
 	EnableAppInfo bool                `mapstructure:"enable_app_info"`
-	PHPConfig     *PHPConfig          `mapstructure:"php_config"` // Optional override of global PHP config
-	Queues        map[string][]string `mapstructure:"queues"`     // Map of connection name to list of queue names
+	PHPConfig     *PHPConfig          `mapstructure:"php_config"`    // Optional override of global PHP config
+	Queues        map[string][]string `mapstructure:"queues"`        // Map of connection name to list of queue names
+	QueueBackend  string              `mapstructure:"queue_backend"` // artisan (default) or native, native reads Redis and database queues without PHP
+	QueueDrivers  map[string]string   `mapstructure:"queue_drivers"` // Driver per connection for the native backend, defaults to the connection name
+
+	JobsTable       string `mapstructure:"jobs_table"`        // Native database backend, defaults to DB_QUEUE_TABLE or jobs
+	FailedJobsTable string `mapstructure:"failed_jobs_table"` // Native backends, defaults to failed_jobs
+
+	DiscoverQueues   bool          `mapstructure:"discover_queues"`   // Add queues found in the app's config, jobs tables, Redis and Horizon
+	DiscoverInterval time.Duration `mapstructure:"discover_interval"` // How often discovery runs again, defaults to 5m
+
+	EnableHorizon  bool `mapstructure:"enable_horizon"`  // Read Horizon's supervisors, workload and metrics from Redis
+	MonitorWorkers bool `mapstructure:"monitor_workers"` // Find queue:work, queue:listen and horizon:work processes running in the app
+
+	MonitorSchedule   bool          `mapstructure:"monitor_schedule"`   // List scheduled tasks and detect missed runs
+	ScheduleHeartbeat string        `mapstructure:"schedule_heartbeat"` // File the schedule touches every minute, the only firing signal, defaults to storage/framework/schedule-heartbeat
+	ScheduleTolerance time.Duration `mapstructure:"schedule_tolerance"` // Delay before a due run counts as missed, defaults to 1m
+
+	MonitorLogs bool     `mapstructure:"monitor_logs"` // Follow the app's log files and count entries by level, channel and exception
+	LogFiles    []string `mapstructure:"log_files"`    // Globs relative to the app path, defaults to storage/logs/laravel*.log
+
+	MonitorExceptions   bool          `mapstructure:"monitor_exceptions"`    // Group the exceptions in the app's log files by fingerprint
+	ExceptionsState     string        `mapstructure:"exceptions_state"`      // File keeping the groups across restarts, defaults to storage/framework/elasticphp-exceptions.json
+	ExceptionsNewWindow time.Duration `mapstructure:"exceptions_new_window"` // How long a group counts as new after it is first seen, defaults to 1h
 }
 
 type MonitorConfig struct {
@@ -68,6 +177,17 @@ func Load() (*Config, error) {
 	viper.SetDefault("phpfpm.retries", 5)
 	viper.SetDefault("phpfpm.retry_delay", 2)
 	viper.SetDefault("phpfpm.poll_interval", "1s")
+	viper.SetDefault("phpfpm.ping_interval", "5s")
+	viper.SetDefault("phpfpm.stuck_threshold", "30s")
+	viper.SetDefault("phpfpm.stuck_log_interval", "5m")
+	viper.SetDefault("phpfpm.recycle_threshold", 0.9)
+	viper.SetDefault("phpfpm.sampler.enabled", false)
+	viper.SetDefault("phpfpm.sampler.windows", []string{"1m", "5m", "15m"})
+	viper.SetDefault("phpfpm.sampler.top_n", 10)
+	viper.SetDefault("phpfpm.leak_detection.enabled", false)
+	viper.SetDefault("phpfpm.leak_detection.min_samples", 10)
+	viper.SetDefault("phpfpm.leak_detection.slope_threshold", 65536)
+	viper.SetDefault("phpfpm.leak_detection.top_scripts", 5)
 	viper.SetDefault("phpfpm.pools", []FPMPoolConfig{})
 
 	viper.SetDefault("php.enabled", true)
@@ -81,8 +201,22 @@ func Load() (*Config, error) {
 	viper.SetDefault("logging.color", true)
 
 	viper.SetDefault("laravel", []LaravelConfig{})
+
 	// No default queue config, expected to be provided per site if needed
 
+	viper.SetDefault("redaction.enabled", true)
+	viper.SetDefault("redaction.drop_query", true)
+	viper.SetDefault("redaction.allow_query_keys", []string{})
+	viper.SetDefault("redaction.scrubbers", []string{"email", "uuid", "numeric_id", "token"})
+
+	viper.SetDefault("host.enabled", false)
+	viper.SetDefault("host.paths", []string{})
+	viper.SetDefault("host.cpu_interval", "15s")
+
+	viper.SetDefault("kubernetes.enabled", true)
+	viper.SetDefault("kubernetes.podinfo_path", "/etc/podinfo")
+	viper.SetDefault("kubernetes.const_labels", []string{})
+
 	viper.SetEnvPrefix("ELASTICPHP")
 	viper.AutomaticEnv()
 
@@ -181,6 +181,41 @@ func TestLoad_Defaults(t *testing.T) {
 		t.Errorf("Expected phpfpm.poll_interval default to be 1s, got %v", config.PHPFpm.PollInterval)
 	}
 
+	if config.PHPFpm.PingInterval != 5*time.Second {
+		t.Errorf("Expected phpfpm.ping_interval default to be 5s, got %v", config.PHPFpm.PingInterval)
+	}
+
+	if config.PHPFpm.StuckThreshold != 30*time.Second {
+		t.Errorf("Expected phpfpm.stuck_threshold default to be 30s, got %v", config.PHPFpm.StuckThreshold)
+	}
+
+	if config.PHPFpm.StuckLogInterval != 5*time.Minute {
+		t.Errorf("Expected phpfpm.stuck_log_interval default to be 5m, got %v", config.PHPFpm.StuckLogInterval)
+	}
+
+	if config.PHPFpm.RecycleThreshold != 0.9 {
+		t.Errorf("Expected phpfpm.recycle_threshold default to be 0.9, got %v", config.PHPFpm.RecycleThreshold)
+	}
+
+	if config.PHPFpm.Sampler.Enabled || config.PHPFpm.Sampler.TopN != 10 {
+		t.Errorf("Expected sampler to be opt-in with top_n 10, got %+v", config.PHPFpm.Sampler)
+	}
+
+	leaks := config.PHPFpm.LeakDetection
+	if leaks.Enabled || leaks.MinSamples != 10 || leaks.SlopeThreshold != 65536 || leaks.TopScripts != 5 {
+		t.Errorf("Unexpected leak detection defaults: %+v", leaks)
+	}
+
+	expectedWindows := []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+	if len(config.PHPFpm.Sampler.Windows) != len(expectedWindows) {
+		t.Fatalf("Expected sampler windows %v, got %v", expectedWindows, config.PHPFpm.Sampler.Windows)
+	}
+	for i, w := range expectedWindows {
+		if config.PHPFpm.Sampler.Windows[i] != w {
+			t.Errorf("Expected sampler window %d to be %v, got %v", i, w, config.PHPFpm.Sampler.Windows[i])
+		}
+	}
+
 	if config.PHP.Enabled != true {
 		t.Errorf("Expected php.enabled default to be true, got %v", config.PHP.Enabled)
 	}
@@ -213,6 +248,22 @@ func TestLoad_Defaults(t *testing.T) {
 		t.Errorf("Expected laravel default to be empty slice, got %v", config.Laravel)
 	}
 
+	if !config.Redaction.Enabled || !config.Redaction.DropQuery {
+		t.Errorf("Expected redaction to drop query strings by default, got %+v", config.Redaction)
+	}
+
+	if len(config.Redaction.Scrubbers) != 4 {
+		t.Errorf("Expected the built-in scrubbers by default, got %v", config.Redaction.Scrubbers)
+	}
+
+	if config.Host.Enabled || len(config.Host.Paths) != 0 || config.Host.CPUInterval != 15*time.Second {
+		t.Errorf("Expected the host collector to be disabled without extra paths by default, got %+v", config.Host)
+	}
+
+	if !config.Kubernetes.Enabled || config.Kubernetes.PodInfoPath != "/etc/podinfo" || len(config.Kubernetes.ConstLabels) != 0 {
+		t.Errorf("Expected pod metadata without constant labels by default, got %+v", config.Kubernetes)
+	}
+
 	if len(config.PHPFpm.Pools) != 0 {
 		t.Errorf("Expected phpfpm.pools default to be empty slice, got %v", config.PHPFpm.Pools)
 	}
@@ -2,12 +2,22 @@ package laravel
 
 import (
 	"context"
+	"path/filepath"
+	"time"
+
 	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/laravel/siteconfig"
 )
 
 type LaravelMetrics struct {
-	Queues *QueueSizes `json:"queues"`
-	Info   *AppInfo    `json:"app_info"`
+	Queues     *QueueSizes       `json:"queues"`
+	Info       *AppInfo          `json:"app_info"`
+	Discovery  *QueueDiscovery   `json:"queue_discovery,omitempty"`
+	Horizon    *HorizonMetrics   `json:"horizon,omitempty"`
+	Workers    *QueueWorkers     `json:"queue_workers,omitempty"`
+	Schedule   *ScheduleMetrics  `json:"schedule,omitempty"`
+	Logs       *LogMetrics       `json:"logs,omitempty"`
+	Exceptions *ExceptionMetrics `json:"exceptions,omitempty"`
 }
 
 // Collect gathers Laravel queue metrics for all configured sites.
@@ -15,16 +25,37 @@ func Collect(ctx context.Context, cfg *config.Config) (map[string]LaravelMetrics
 	result := make(map[string]LaravelMetrics)
 	errors := make(map[string]string)
 
+	// The process table is scanned once for all sites
+	var procs []workerProcess
+	var procsErr error
+	scanned := false
+
 	for _, site := range cfg.Laravel {
 		php := cfg.PHP.Binary
 		if site.PHPConfig != nil && site.PHPConfig.Binary != "" {
 			php = site.PHPConfig.Binary
 		}
 
-		queues, err := GetQueueSizes(site.Path, php, site.Queues)
+		var sc *siteconfig.Config
+		if site.QueueBackend == QueueBackendNative || site.DiscoverQueues || site.EnableHorizon || site.MonitorWorkers || site.MonitorLogs || site.MonitorExceptions {
+			var err error
+			if sc, err = siteconfig.Load(ctx, site.Path, php); err != nil {
+				errors["laravel:"+site.Name+":config"] = err.Error()
+			} else if sc.ConfigErr != nil {
+				errors["laravel:"+site.Name+":config"] = sc.ConfigErr.Error()
+			}
+		}
+
+		var discovery *QueueDiscovery
+		if site.DiscoverQueues && sc != nil {
+			discovery = discoveredQueues(ctx, site, sc)
+			site.Queues = mergeQueues(site.Queues, discovery.Queues)
+		}
+
+		// A failed queue read leaves the other features to report on their own
+		queues, err := collectQueues(ctx, site, php, sc)
 		if err != nil {
 			errors["laravel:"+site.Name] = err.Error()
-			continue
 		}
 
 		info, err := GetAppInfo(site, php)
@@ -32,17 +63,145 @@ func Collect(ctx context.Context, cfg *config.Config) (map[string]LaravelMetrics
 			errors["laravel:"+site.Name+":info"] = err.Error()
 		}
 
+		var horizon *HorizonMetrics
+		if site.EnableHorizon && sc != nil {
+			if horizon, err = GetHorizonMetrics(ctx, sc); err != nil {
+				errors["laravel:"+site.Name+":horizon"] = err.Error()
+			}
+		}
+
+		var workers *QueueWorkers
+		if site.MonitorWorkers {
+			if !scanned {
+				procs, procsErr = listProcesses()
+				scanned = true
+			}
+			if procsErr != nil {
+				errors["laravel:"+site.Name+":workers"] = procsErr.Error()
+			} else {
+				workers = siteWorkers(filepath.Clean(site.Path), site.Path, sc, procs, time.Now())
+			}
+		}
+
+		var schedule *ScheduleMetrics
+		if site.MonitorSchedule {
+			if schedule, err = GetScheduleMetrics(site, php, time.Now()); err != nil {
+				errors["laravel:"+site.Name+":schedule"] = err.Error()
+			}
+		}
+
+		// Exceptions are grouped from the entries the log tailer reads
+		var logs *LogMetrics
+		var exceptions *ExceptionMetrics
+		if site.MonitorLogs || site.MonitorExceptions {
+			loc := time.UTC
+			if sc != nil {
+				loc = sc.Location()
+			}
+			if logs, err = GetLogMetrics(site.Path, site.LogFiles, loc); err != nil {
+				errors["laravel:"+site.Name+":logs"] = err.Error()
+			} else if site.MonitorExceptions {
+				if exceptions, err = GetExceptionMetrics(site, logs, time.Now()); err != nil {
+					errors["laravel:"+site.Name+":exceptions"] = err.Error()
+				}
+			}
+			if !site.MonitorLogs {
+				logs = nil
+			}
+		}
+
 		if info != nil {
 			result[site.Name] = LaravelMetrics{
-				Queues: queues,
-				Info:   info,
+				Queues:     queues,
+				Info:       info,
+				Discovery:  discovery,
+				Horizon:    horizon,
+				Workers:    workers,
+				Schedule:   schedule,
+				Logs:       logs,
+				Exceptions: exceptions,
 			}
 		} else {
 			result[site.Name] = LaravelMetrics{
-				Queues: queues,
+				Queues:     queues,
+				Discovery:  discovery,
+				Horizon:    horizon,
+				Workers:    workers,
+				Schedule:   schedule,
+				Logs:       logs,
+				Exceptions: exceptions,
 			}
 		}
 	}
 
 	return result, errors
 }
+
+// collectQueues reads the site's queues through artisan, or with the native
+// backend reads the connections of supported drivers directly and only the
+// remaining ones through artisan. A failing artisan read then only marks its
+// own connections, the natively read sizes are kept.
+func collectQueues(ctx context.Context, site config.LaravelConfig, php string, sc *siteconfig.Config) (*QueueSizes, error) {
+	// Without the site config everything goes through artisan
+	if site.QueueBackend != QueueBackendNative || sc == nil {
+		return GetQueueSizes(site.Path, php, site.Queues)
+	}
+
+	redis := map[string][]string{}
+	database := map[string][]string{}
+	artisan := map[string][]string{}
+	for conn, queues := range site.Queues {
+		switch queueDriver(site, sc, conn) {
+		case QueueDriverRedis:
+			redis[conn] = queues
+		case QueueDriverDatabase:
+			database[conn] = queues
+		default:
+			artisan[conn] = queues
+		}
+	}
+
+	result := GetRedisQueueSizes(ctx, sc, redis)
+	FillFailedJobs(ctx, site, sc, result)
+
+	if len(database) > 0 {
+		sizes, unsupported := GetDatabaseQueueSizes(ctx, site, sc, database)
+		// e.g. SQL Server, artisan knows how to read it
+		for conn, queues := range unsupported {
+			artisan[conn] = queues
+		}
+		for conn, queues := range sizes {
+			result[conn] = queues
+		}
+	}
+
+	if len(artisan) > 0 {
+		sizes, err := GetQueueSizes(site.Path, php, artisan)
+		if err != nil {
+			for conn, queues := range artisan {
+				result[conn] = map[string]QueueMetrics{}
+				for _, q := range queues {
+					result[conn][q] = QueueMetrics{ParseError: err.Error()}
+				}
+			}
+			return &result, nil
+		}
+		for conn, queues := range *sizes {
+			result[conn] = queues
+		}
+	}
+	return &result, nil
+}
+
+// queueDriver returns the driver of a queue connection set in the agent
+// config, or else in the site's config/queue.php. Unknown connections are
+// assumed to be named after their driver.
+func queueDriver(site config.LaravelConfig, sc *siteconfig.Config, connection string) string {
+	if driver, ok := site.QueueDrivers[connection]; ok {
+		return driver
+	}
+	if driver := sc.QueueDriver(connection); driver != "" {
+		return driver
+	}
+	return connection
+}
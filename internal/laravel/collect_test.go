@@ -2,9 +2,13 @@ package laravel
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
 )
 
 func TestCollect(t *testing.T) {
@@ -38,7 +42,7 @@ func TestCollect(t *testing.T) {
 					},
 				},
 			},
-			expectedSites:  0, // No sites added when queue fails
+			expectedSites:  1, // Sites are added when the queue read fails
 			expectedErrors: 1, // Will fail without Laravel app
 		},
 		{
@@ -59,8 +63,8 @@ func TestCollect(t *testing.T) {
 					},
 				},
 			},
-			expectedSites:  0, // No sites added when queue fails
-			expectedErrors: 1, // Queue error only (app info not called if queue fails)
+			expectedSites:  1,
+			expectedErrors: 2, // Queue and app info errors
 		},
 		{
 			name: "multiple sites",
@@ -85,11 +89,13 @@ func TestCollect(t *testing.T) {
 					},
 				},
 			},
-			expectedSites:  0, // No sites added when queues fail
-			expectedErrors: 2, // Both sites queue errors
+			expectedSites:  2,
+			expectedErrors: 3, // Both sites queue errors, site2 app info error
 		},
 	}
 
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
@@ -112,12 +118,11 @@ func TestCollect(t *testing.T) {
 				}
 			}
 
-			// Verify LaravelMetrics structure
+			// Queues are nil when they failed, app info when not enabled or failed
 			for siteName, metrics := range result {
-				if metrics.Queues == nil {
-					t.Errorf("Site %s has nil Queues", siteName)
+				if metrics.Queues != nil {
+					t.Errorf("Site %s has Queues despite the failure", siteName)
 				}
-				// App info can be nil if not enabled or if it failed
 			}
 		})
 	}
@@ -184,12 +189,89 @@ func TestCollect_PHPBinarySelection(t *testing.T) {
 	ctx := context.Background()
 	result, errors := Collect(ctx, cfg)
 
-	// Should have 0 sites and 2 errors (queue failures prevent site addition)
-	if len(result) != 0 {
-		t.Errorf("Expected 0 sites, got %d", len(result))
+	// Should have 2 sites and 2 errors, one queue failure each
+	if len(result) != 2 {
+		t.Errorf("Expected 2 sites, got %d", len(result))
 	}
 
 	if len(errors) != 2 {
 		t.Errorf("Expected 2 errors, got %d", len(errors))
 	}
 }
+
+func TestCollect_QueueFailure(t *testing.T) {
+	_, port := startFakeRedis(t, "")
+	original := listProcesses
+	t.Cleanup(func() { listProcesses = original })
+
+	tests := []struct {
+		name  string
+		site  func(cfg *config.LaravelConfig)
+		check func(m LaravelMetrics) bool
+	}{
+		{
+			name: "horizon",
+			site: func(cfg *config.LaravelConfig) {
+				appendLog(t, filepath.Join(cfg.Path, ".env"), fmt.Sprintf("REDIS_PORT=%d\n", port))
+				writeHorizonConfig(t, cfg.Path)
+				cfg.EnableHorizon = true
+			},
+			check: func(m LaravelMetrics) bool { return m.Horizon != nil },
+		},
+		{
+			name: "workers",
+			site: func(cfg *config.LaravelConfig) {
+				listProcesses = func() ([]workerProcess, error) {
+					return []workerProcess{{pid: 1, appDir: cfg.Path, args: []string{"queue:work"}, startedAt: time.Now()}}, nil
+				}
+				cfg.MonitorWorkers = true
+			},
+			check: func(m LaravelMetrics) bool { return m.Workers != nil && len(m.Workers.Workers) == 1 },
+		},
+		{
+			name:  "schedule",
+			site:  func(cfg *config.LaravelConfig) { cfg.MonitorSchedule = true },
+			check: func(m LaravelMetrics) bool { return m.Schedule != nil && len(m.Schedule.Tasks) == 4 },
+		},
+		{
+			name: "logs",
+			site: func(cfg *config.LaravelConfig) {
+				appendLog(t, filepath.Join(cfg.Path, "storage", "logs", "laravel.log"), "")
+				cfg.MonitorLogs = true
+			},
+			check: func(m LaravelMetrics) bool { return m.Logs != nil && len(m.Logs.Files) == 1 },
+		},
+		{
+			name: "exceptions",
+			site: func(cfg *config.LaravelConfig) {
+				appendLog(t, filepath.Join(cfg.Path, "storage", "logs", "laravel.log"), "")
+				cfg.MonitorExceptions = true
+			},
+			check: func(m LaravelMetrics) bool { return m.Exceptions != nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The mock php prints the schedule, which isn't queue sizes
+			site, php := writeScheduleSite(t)
+			cfg := &config.Config{
+				PHP:     config.PHPConfig{Binary: php},
+				Laravel: []config.LaravelConfig{{Name: "App", Path: site, Queues: map[string][]string{"sqs": {"default"}}}},
+			}
+			tt.site(&cfg.Laravel[0])
+
+			result, errs := Collect(context.Background(), cfg)
+			if errs["laravel:App"] == "" {
+				t.Fatalf("Expected a queue error, got %v", errs)
+			}
+			m, ok := result["App"]
+			if !ok || m.Queues != nil {
+				t.Fatalf("Expected the site without queues in the result, got %+v", result)
+			}
+			if !tt.check(m) {
+				t.Errorf("Expected the %s metrics despite the queue error, got %+v", tt.name, m)
+			}
+		})
+	}
+}
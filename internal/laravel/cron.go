@@ -0,0 +1,230 @@
+package laravel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit bounds how far Next and Prev look for a due time, an
+// expression such as 0 0 30 2 * never matches.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronNames = map[string]string{
+	"jan": "1", "feb": "2", "mar": "3", "apr": "4", "may": "5", "jun": "6",
+	"jul": "7", "aug": "8", "sep": "9", "oct": "10", "nov": "11", "dec": "12",
+	"sun": "0", "mon": "1", "tue": "2", "wed": "3", "thu": "4", "fri": "5", "sat": "6",
+}
+
+// Cron is a five field cron expression as Laravel's scheduler evaluates it,
+// with names, ranges, steps, L for the last day of the month and n#k for the
+// k-th weekday n of the month.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	lastDOM                       bool
+	nthDOW                        map[int]map[int]bool // Weekday to the weeks of the month
+}
+
+// ParseCron parses a cron expression or one of the @daily style macros.
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron expression %q", expr)
+	}
+
+	c := &Cron{nthDOW: map[int]map[int]bool{}}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+
+	dom := fields[2]
+	c.domStar = dom == "*" || dom == "?"
+	var parts []string
+	for _, part := range strings.Split(dom, ",") {
+		if strings.EqualFold(part, "L") {
+			c.lastDOM = true
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) > 0 {
+		if c.dom, err = parseCronField(strings.Join(parts, ","), 1, 31); err != nil {
+			return nil, fmt.Errorf("day of month: %w", err)
+		}
+	}
+
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+
+	dow := fields[4]
+	c.dowStar = dow == "*" || dow == "?"
+	parts = nil
+	for _, part := range strings.Split(dow, ",") {
+		day, week, ok := strings.Cut(part, "#")
+		if !ok {
+			parts = append(parts, part)
+			continue
+		}
+		d, err1 := strconv.Atoi(cronName(day))
+		w, err2 := strconv.Atoi(week)
+		if err1 != nil || err2 != nil || d < 0 || d > 7 || w < 1 || w > 5 {
+			return nil, fmt.Errorf("day of week: invalid %q", part)
+		}
+		d %= 7
+		if c.nthDOW[d] == nil {
+			c.nthDOW[d] = map[int]bool{}
+		}
+		c.nthDOW[d][w] = true
+	}
+	if len(parts) > 0 {
+		if c.dow, err = parseCronField(strings.Join(parts, ","), 0, 7); err != nil {
+			return nil, fmt.Errorf("day of week: %w", err)
+		}
+		// 7 is Sunday too
+		if c.dow&(1<<7) != 0 {
+			c.dow |= 1
+		}
+	}
+	return c, nil
+}
+
+func cronName(s string) string {
+	if n, ok := cronNames[strings.ToLower(s)]; ok {
+		return n
+	}
+	return s
+}
+
+// parseCronField parses a comma separated list of *, values, ranges and
+// steps into a bit set.
+func parseCronField(field string, low, high int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		start, end := low, high
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err1, err2 error
+			start, err1 = strconv.Atoi(cronName(from))
+			end, err2 = strconv.Atoi(cronName(to))
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(cronName(rng))
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			start = n
+			// 5/10 means from 5 to the end in steps of 10
+			if !hasStep {
+				end = n
+			}
+		}
+		if start < low || end > high || start > end {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, low, high)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+	return bits, nil
+}
+
+// Matches reports whether the expression is due in the minute of t.
+func (c *Cron) Matches(t time.Time) bool {
+	return c.minute&(1<<t.Minute()) != 0 && c.hour&(1<<t.Hour()) != 0 &&
+		c.month&(1<<int(t.Month())) != 0 && c.dayMatches(t)
+}
+
+// dayMatches applies cron's rule that a day matches either of the day of
+// month and day of week fields when both are restricted.
+func (c *Cron) dayMatches(t time.Time) bool {
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	dom := c.dom&(1<<t.Day()) != 0 || c.lastDOM && t.Day() == lastDay
+	dow := c.dow&(1<<int(t.Weekday())) != 0 || c.nthDOW[int(t.Weekday())][(t.Day()-1)/7+1]
+	switch {
+	case c.domStar && c.dowStar:
+		return true
+	case c.domStar:
+		return dow
+	case c.dowStar:
+		return dom
+	}
+	return dom || dow
+}
+
+// Next returns the first due time after t, or the zero time when there is
+// none within five years.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Add(time.Hour)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Prev returns the last due time at or before t, or the zero time when there
+// is none within five years.
+func (c *Cron) Prev(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+	limit := t.Add(-cronSearchLimit)
+	for t.After(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Add(-time.Minute)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(-time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,81 @@
+package laravel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * * 1#6", "x * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+}
+
+func TestCron_NextPrev(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatalf("Invalid time %q: %v", s, err)
+		}
+		return v
+	}
+	// 2025-03-12 10:17 is a Wednesday
+	now := at("2025-03-12 10:17").Add(30 * time.Second)
+
+	tests := []struct {
+		expr, prev, next string
+	}{
+		{"* * * * *", "2025-03-12 10:17", "2025-03-12 10:18"},
+		{"*/5 * * * *", "2025-03-12 10:15", "2025-03-12 10:20"},
+		{"@hourly", "2025-03-12 10:00", "2025-03-12 11:00"},
+		{"30 2 * * *", "2025-03-12 02:30", "2025-03-13 02:30"},
+		{"0 9-17/4 * * mon-fri", "2025-03-12 09:00", "2025-03-12 13:00"},
+		{"0 0 * * 0", "2025-03-09 00:00", "2025-03-16 00:00"},
+		{"0 0 * * 7", "2025-03-09 00:00", "2025-03-16 00:00"},
+		{"0 0 1 jan,jul *", "2025-01-01 00:00", "2025-07-01 00:00"},
+		{"0 0 L * *", "2025-02-28 00:00", "2025-03-31 00:00"},
+		{"0 0 * * 1#2", "2025-03-10 00:00", "2025-04-14 00:00"},
+		// Both day fields restricted: either one matches
+		{"0 0 15 * 1", "2025-03-10 00:00", "2025-03-15 00:00"},
+		{"0 0 29 2 *", "2024-02-29 00:00", "2028-02-29 00:00"},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		if prev := c.Prev(now); !prev.Equal(at(tt.prev)) {
+			t.Errorf("%q: expected previous %s, got %s", tt.expr, tt.prev, prev)
+		}
+		if next := c.Next(now); !next.Equal(at(tt.next)) {
+			t.Errorf("%q: expected next %s, got %s", tt.expr, tt.next, next)
+		}
+		if !c.Matches(at(tt.next)) {
+			t.Errorf("%q: expected a match at %s", tt.expr, tt.next)
+		}
+	}
+
+	never, _ := ParseCron("0 0 30 2 *")
+	if !never.Next(now).IsZero() || !never.Prev(now).IsZero() {
+		t.Errorf("Expected no due time for February 30th")
+	}
+}
+
+func TestCron_Timezone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("No tz data: %v", err)
+	}
+	c, _ := ParseCron("0 * * * *")
+	now := time.Date(2025, 3, 12, 10, 17, 0, 0, loc)
+	if prev := c.Prev(now); !prev.Equal(time.Date(2025, 3, 12, 10, 0, 0, 0, loc)) {
+		t.Errorf("Expected the local hour, got %s", prev)
+	}
+	if next := c.Next(now); !next.Equal(time.Date(2025, 3, 12, 11, 0, 0, 0, loc)) {
+		t.Errorf("Expected the next local hour, got %s", next)
+	}
+}
@@ -0,0 +1,264 @@
+package laravel
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/laravel/siteconfig"
+)
+
+const (
+	defaultDiscoverInterval = 5 * time.Minute
+
+	maxScanCalls = 100
+)
+
+var (
+	discoveries     = map[string]*QueueDiscovery{}
+	discoveriesLock sync.Mutex
+
+	// validQueueName is what a discovered queue name may look like, the
+	// names come from data any job or Redis client can write.
+	validQueueName = regexp.MustCompile(`^[A-Za-z0-9_.:@{}\-]{1,255}$`)
+
+	// Drivers without a backlog of their own to monitor
+	ignoredQueueDrivers = map[string]bool{"sync": true, "null": true, "deferred": true, "background": true, "failover": true}
+)
+
+// QueueDiscovery is the set of queues found for a site, by connection.
+type QueueDiscovery struct {
+	Queues       map[string][]string `json:"queues"`
+	DiscoveredAt time.Time           `json:"discovered_at"`
+	Errors       []string            `json:"errors,omitempty"`
+}
+
+// discoveredQueues returns the site's discovered queues, running discovery
+// again once the site's discover interval has passed.
+func discoveredQueues(ctx context.Context, site config.LaravelConfig, sc *siteconfig.Config) *QueueDiscovery {
+	interval := site.DiscoverInterval
+	if interval <= 0 {
+		interval = defaultDiscoverInterval
+	}
+
+	discoveriesLock.Lock()
+	d, ok := discoveries[sc.Path]
+	discoveriesLock.Unlock()
+	if ok && time.Since(d.DiscoveredAt) < interval {
+		return d
+	}
+
+	d = DiscoverQueues(ctx, site, sc)
+	discoveriesLock.Lock()
+	discoveries[sc.Path] = d
+	discoveriesLock.Unlock()
+	return d
+}
+
+// DiscoverQueues lists the connections of the site's queue config and finds
+// queue names in the data: distinct queues in the jobs and failed_jobs
+// tables, queues:* keys in Redis and the queues of Horizon's supervisors.
+// Laravel's stock config lists drivers an app may not use, so connections
+// other than the default one are only added when they hold jobs.
+func DiscoverQueues(ctx context.Context, site config.LaravelConfig, sc *siteconfig.Config) *QueueDiscovery {
+	found := map[string]map[string]bool{}
+	var errs []string
+	add := func(conn, queue string) {
+		if queue == "" {
+			return
+		}
+		if !validQueueName.MatchString(conn) || !validQueueName.MatchString(queue) {
+			errs = append(errs, fmt.Sprintf("ignored invalid queue %q on connection %q", queue, conn))
+			return
+		}
+		if found[conn] == nil {
+			found[conn] = map[string]bool{}
+		}
+		found[conn][queue] = true
+	}
+	fail := func(source string, err error) {
+		errs = append(errs, fmt.Sprintf("%s: %v", source, err))
+	}
+
+	defaultConn := sc.Queue.Default.String()
+	for conn, qc := range sc.Queue.Connections {
+		driver := queueDriver(site, sc, conn)
+		if ignoredQueueDrivers[driver] {
+			continue
+		}
+		if conn == defaultConn {
+			add(conn, qc.Queue.Or("default"))
+		}
+
+		var queues []string
+		var err error
+		switch driver {
+		case QueueDriverDatabase:
+			queues, err = discoverDatabaseQueues(ctx, site, sc, conn)
+		case QueueDriverRedis:
+			queues, err = discoverRedisQueues(ctx, sc, conn)
+		}
+		// An unreachable backend of an unused connection is expected
+		if err != nil && conn == defaultConn {
+			fail(conn, err)
+		}
+		for _, q := range queues {
+			add(conn, q)
+		}
+	}
+
+	failed, err := discoverFailedQueues(ctx, site, sc)
+	if err != nil {
+		fail("failed_jobs", err)
+	}
+	for conn, queues := range failed {
+		// Skip failures of connections that were removed since
+		if _, ok := sc.Queue.Connections[conn]; !ok || ignoredQueueDrivers[queueDriver(site, sc, conn)] {
+			continue
+		}
+		for _, q := range queues {
+			add(conn, q)
+		}
+	}
+
+	if sc.Horizon != nil {
+		if sc.HorizonErr != nil {
+			fail("horizon", sc.HorizonErr)
+		}
+		for _, s := range sc.Horizon.Supervisors(sc.App.Env.String()) {
+			conn := s.Connection.Or(QueueDriverRedis)
+			for _, q := range s.Queue {
+				add(conn, q)
+			}
+		}
+	}
+
+	d := &QueueDiscovery{Queues: map[string][]string{}, DiscoveredAt: time.Now(), Errors: errs}
+	for conn, queues := range found {
+		for q := range queues {
+			d.Queues[conn] = append(d.Queues[conn], q)
+		}
+		sort.Strings(d.Queues[conn])
+	}
+	sort.Strings(d.Errors)
+	return d
+}
+
+func discoverDatabaseQueues(ctx context.Context, site config.LaravelConfig, sc *siteconfig.Config, conn string) ([]string, error) {
+	qc := sc.Queue.Connections[conn]
+	sdb, err := openSiteDatabase(sc, qc.Connection.Or(sc.Database.Default.String()))
+	if err != nil {
+		return nil, err
+	}
+	table, err := sdb.table(site.JobsTable, qc.Table.Or("jobs"))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultDBTimeout)
+	defer cancel()
+
+	rows, err := sdb.db.QueryContext(ctx, "SELECT DISTINCT queue FROM "+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queues []string
+	for rows.Next() {
+		var q string
+		if err := rows.Scan(&q); err != nil {
+			return nil, err
+		}
+		queues = append(queues, q)
+	}
+	return queues, rows.Err()
+}
+
+func discoverFailedQueues(ctx context.Context, site config.LaravelConfig, sc *siteconfig.Config) (map[string][]string, error) {
+	failed := sc.Queue.Failed
+	if !strings.HasPrefix(failed.Driver.String(), "database") {
+		return nil, nil
+	}
+	sdb, err := openSiteDatabase(sc, failed.Database.Or(sc.Database.Default.String()))
+	if err != nil {
+		return nil, err
+	}
+	table, err := sdb.table(site.FailedJobsTable, failed.Table.Or("failed_jobs"))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultDBTimeout)
+	defer cancel()
+
+	rows, err := sdb.db.QueryContext(ctx, "SELECT DISTINCT connection, queue FROM "+table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queues := map[string][]string{}
+	for rows.Next() {
+		var conn, q string
+		if err := rows.Scan(&conn, &q); err != nil {
+			return nil, err
+		}
+		queues[conn] = append(queues[conn], q)
+	}
+	return queues, rows.Err()
+}
+
+// discoverRedisQueues finds the queues:<name> keys of a Redis connection,
+// including queues that only hold delayed or reserved jobs.
+func discoverRedisQueues(ctx context.Context, sc *siteconfig.Config, conn string) ([]string, error) {
+	opts, err := redisOptionsFor(sc, sc.Queue.Connections[conn].Connection.Or("default"))
+	if err != nil {
+		return nil, err
+	}
+	client, err := dialRedis(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("redis connect: %w", err)
+	}
+	defer client.Close()
+
+	prefix := opts.Prefix + "queues:"
+	keys, err := client.scan(globEscape(prefix)+"*", maxScanCalls)
+	if err != nil {
+		return nil, err
+	}
+
+	var queues []string
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, prefix)
+		for _, suffix := range []string{":delayed", ":reserved", ":notify"} {
+			name = strings.TrimSuffix(name, suffix)
+		}
+		queues = append(queues, name)
+	}
+	return queues, nil
+}
+
+// mergeQueues returns the configured queues with the discovered ones added.
+func mergeQueues(configured, discovered map[string][]string) map[string][]string {
+	merged := map[string][]string{}
+	for _, queueMap := range []map[string][]string{configured, discovered} {
+		for conn, queues := range queueMap {
+			if _, ok := merged[conn]; !ok {
+				merged[conn] = []string{}
+			}
+			for _, q := range queues {
+				if !slices.Contains(merged[conn], q) {
+					merged[conn] = append(merged[conn], q)
+				}
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,154 @@
+package laravel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+// writeDiscoverySite creates a site whose default queue connection is the
+// database, with jobs in SQLite, queues in the fake Redis and a Horizon
+// supervisor read through a mock php binary.
+func writeDiscoverySite(t *testing.T) (string, string) {
+	t.Helper()
+
+	fake, port := startFakeRedis(t, "")
+	fake.list(0, "laravel_database_queues:high", `{"uuid":"a"}`)
+	fake.zset(0, "laravel_database_queues:low:delayed", 1)
+	fake.zset(0, "laravel_database_queues:low:reserved", 1)
+	fake.list(0, "laravel_database_cache:users", "x")
+
+	site := writeSQLiteSite(t, fmt.Sprintf("QUEUE_CONNECTION=database\nREDIS_PORT=%d\n", port),
+		"INSERT INTO jobs (queue, payload, attempts, available_at, created_at) VALUES ('default', '{}', 0, 0, 0), ('emails', '{}', 0, 0, 0), ('emails', '{}', 0, 0, 0)",
+		"INSERT INTO failed_jobs (uuid, connection, queue, payload, exception) VALUES"+
+			" ('a', 'database', 'reports', '{}', 'E'), ('b', 'redis', 'critical', '{}', 'E'), ('c', 'removed', 'old', '{}', 'E'), ('d', 'sync', 'now', '{}', 'E')",
+	)
+
+	writeHorizonConfig(t, site)
+	php := filepath.Join(t.TempDir(), "php")
+	script := `#!/bin/sh
+echo '{"horizon":{"defaults":{"supervisor-1":{"connection":"redis","queue":{"0":"notifications"}}},"environments":{"*":{"supervisor-1":{}}}}}'
+`
+	if err := os.WriteFile(php, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write mock php: %v", err)
+	}
+	return site, php
+}
+
+func TestDiscoverQueues(t *testing.T) {
+	site, php := writeDiscoverySite(t)
+	cfg := config.LaravelConfig{Path: site}
+
+	d := DiscoverQueues(context.Background(), cfg, loadSiteWith(t, site, php))
+	if len(d.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", d.Errors)
+	}
+
+	expected := map[string][]string{
+		"database": {"default", "emails", "reports"},
+		"redis":    {"critical", "high", "low", "notifications"},
+	}
+	if !reflect.DeepEqual(d.Queues, expected) {
+		t.Errorf("Expected %v, got %v", expected, d.Queues)
+	}
+	if time.Since(d.DiscoveredAt) > time.Minute {
+		t.Errorf("Unexpected discovery time %v", d.DiscoveredAt)
+	}
+}
+
+func TestDiscoverQueues_Errors(t *testing.T) {
+	site := writeSite(t, "QUEUE_CONNECTION=redis\nREDIS_PORT=1\nQUEUE_FAILED_DRIVER=null\n")
+
+	d := DiscoverQueues(context.Background(), config.LaravelConfig{Path: site}, loadSite(t, site))
+	if len(d.Errors) != 1 {
+		t.Errorf("Expected only the default connection's error, got %v", d.Errors)
+	}
+	if !reflect.DeepEqual(d.Queues, map[string][]string{"redis": {"default"}}) {
+		t.Errorf("Expected the default queue of the default connection, got %v", d.Queues)
+	}
+}
+
+func TestDiscoverQueues_HostileNames(t *testing.T) {
+	hostile := `x'];system('id');//`
+	fake, port := startFakeRedis(t, "")
+	fake.list(0, "laravel_database_queues:x'];system('id');#", `{"uuid":"a"}`)
+	fake.list(0, "laravel_database_queues:bulk", `{"uuid":"b"}`)
+	site := writeSQLiteSite(t, fmt.Sprintf("QUEUE_CONNECTION=database\nREDIS_PORT=%d\n", port),
+		"INSERT INTO jobs (queue, payload, attempts, available_at, created_at) VALUES ('"+strings.ReplaceAll(hostile, "'", "''")+"', '{}', 0, 0, 0), ('emails', '{}', 0, 0, 0)",
+		"INSERT INTO failed_jobs (uuid, connection, queue, payload, exception) VALUES ('a', 'redis\"];', 'q', '{}', 'E')",
+	)
+
+	d := DiscoverQueues(context.Background(), config.LaravelConfig{Path: site}, loadSite(t, site))
+	expected := map[string][]string{"database": {"default", "emails"}, "redis": {"bulk"}}
+	if !reflect.DeepEqual(d.Queues, expected) {
+		t.Errorf("Expected %v, got %v", expected, d.Queues)
+	}
+	if len(d.Errors) != 2 || !strings.Contains(strings.Join(d.Errors, "\n"), `connection "redis"`) {
+		t.Errorf("Expected the hostile names to be reported, got %v", d.Errors)
+	}
+}
+
+func TestDiscoveredQueues_Refresh(t *testing.T) {
+	site := writeSite(t, "QUEUE_CONNECTION=sqs\n")
+	cfg := config.LaravelConfig{Path: site}
+	sc := loadSite(t, site)
+
+	first := discoveredQueues(context.Background(), cfg, sc)
+	if again := discoveredQueues(context.Background(), cfg, sc); again != first {
+		t.Errorf("Expected the discovery to be reused within the interval")
+	}
+
+	cfg.DiscoverInterval = time.Nanosecond
+	if again := discoveredQueues(context.Background(), cfg, sc); again == first {
+		t.Errorf("Expected discovery to run again after the interval")
+	}
+}
+
+func TestMergeQueues(t *testing.T) {
+	configured := map[string][]string{"redis": {"high"}, "sqs": {}}
+	discovered := map[string][]string{"redis": {"default", "high"}, "database": {"default"}}
+
+	merged := mergeQueues(configured, discovered)
+	expected := map[string][]string{"redis": {"high", "default"}, "sqs": {}, "database": {"default"}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected %v, got %v", expected, merged)
+	}
+	if len(configured["redis"]) != 1 {
+		t.Errorf("Expected the configured queues to be left unchanged")
+	}
+}
+
+func TestCollect_DiscoverQueues(t *testing.T) {
+	site, php := writeDiscoverySite(t)
+	cfg := &config.Config{
+		PHP: config.PHPConfig{Binary: php},
+		Laravel: []config.LaravelConfig{{
+			Name:           "App",
+			Path:           site,
+			QueueBackend:   QueueBackendNative,
+			DiscoverQueues: true,
+		}},
+	}
+
+	result, errs := Collect(context.Background(), cfg)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	m := result["App"]
+	if m.Discovery == nil || len(m.Discovery.Queues["redis"]) != 4 {
+		t.Fatalf("Expected the discovery in the result, got %+v", m.Discovery)
+	}
+	if q := (*m.Queues)["database"]["emails"]; q.Pending == nil || *q.Pending != 2 {
+		t.Errorf("Expected the discovered queue to be collected, got %+v", q)
+	}
+	if _, ok := (*m.Queues)["redis"]["notifications"]; !ok {
+		t.Errorf("Expected the Horizon queue to be collected")
+	}
+}
@@ -0,0 +1,296 @@
+package laravel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/redact"
+)
+
+const (
+	DefaultExceptionsState = "storage/framework/elasticphp-exceptions.json"
+
+	defaultExceptionsNewWindow = time.Hour
+	// maxExceptionGroups bounds the state, the least recently seen groups
+	// are dropped first.
+	maxExceptionGroups = 1000
+	// fingerprintFrames is the number of app frames in a fingerprint.
+	fingerprintFrames = 3
+
+	exceptionsStateVersion = 1
+)
+
+var (
+	exceptionTrackers     = map[string]*exceptionTracker{}
+	exceptionTrackersLock sync.Mutex
+
+	// Values that differ between occurrences of the same error, in the
+	// order they are replaced
+	messageScrubbers = []struct {
+		match   *regexp.Regexp
+		replace string
+	}{
+		{redact.UUIDPattern, ":uuid"},
+		{redact.EmailPattern, ":email"},
+		{regexp.MustCompile(`'[^']*'|"[^"]*"`), ":str"},
+		{regexp.MustCompile(`\b\d+(\.\d+)?\b`), ":n"},
+		{regexp.MustCompile(`\b(0x[0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`), ":hex"},
+	}
+
+	// Zero downtime deploys run each release from its own directory
+	releaseDir = regexp.MustCompile(`/releases/[^/]+/`)
+)
+
+// ExceptionMetrics groups a site's logged exceptions by fingerprint, with
+// the groups kept across agent restarts in a state file.
+type ExceptionMetrics struct {
+	Groups           []ExceptionGroup `json:"groups"` // Most recently seen first
+	New              int              `json:"new"`    // Groups first seen within the window
+	NewWindowSeconds float64          `json:"new_window_seconds"`
+}
+
+// ExceptionGroup is the occurrences of an exception sharing a class, a
+// message once identifiers and values are scrubbed, and the top app frames.
+type ExceptionGroup struct {
+	Fingerprint string    `json:"fingerprint"`
+	Class       string    `json:"class"`
+	Message     string    `json:"message"`
+	Frames      []string  `json:"frames"` // Files relative to the app path, vendor frames left out
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Count       uint64    `json:"count"`
+}
+
+type exceptionTracker struct {
+	mu     sync.Mutex
+	path   string
+	loaded bool
+	groups map[string]*ExceptionGroup
+}
+
+type exceptionsState struct {
+	Version int              `json:"version"`
+	Groups  []ExceptionGroup `json:"groups"`
+}
+
+// GetExceptionMetrics adds the exceptions read from the site's logs by the
+// last collection to their groups and saves the state when it changed.
+func GetExceptionMetrics(site config.LaravelConfig, logs *LogMetrics, now time.Time) (*ExceptionMetrics, error) {
+	path := site.ExceptionsState
+	if path == "" {
+		path = DefaultExceptionsState
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(site.Path, path)
+	}
+
+	key := filepath.Clean(site.Path)
+	exceptionTrackersLock.Lock()
+	t, ok := exceptionTrackers[key]
+	if !ok || t.path != path {
+		t = &exceptionTracker{path: path, groups: map[string]*ExceptionGroup{}}
+		exceptionTrackers[key] = t
+	}
+	exceptionTrackersLock.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// An unreadable state is not overwritten, it is loaded again next time
+	if !t.loaded {
+		if err := t.load(); err != nil {
+			return nil, err
+		}
+		t.loaded = true
+	}
+
+	roots := []string{filepath.Clean(site.Path)}
+	if resolved, err := filepath.EvalSymlinks(site.Path); err == nil && resolved != roots[0] {
+		roots = append(roots, resolved)
+	}
+	if logs != nil && len(logs.exceptions) > 0 {
+		for _, e := range logs.exceptions {
+			t.add(e, roots, now)
+		}
+		t.evict()
+		if err := t.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	window := site.ExceptionsNewWindow
+	if window <= 0 {
+		window = defaultExceptionsNewWindow
+	}
+	m := &ExceptionMetrics{Groups: make([]ExceptionGroup, 0, len(t.groups)), NewWindowSeconds: window.Seconds()}
+	for _, g := range t.groups {
+		m.Groups = append(m.Groups, *g)
+		if !g.FirstSeen.Before(now.Add(-window)) {
+			m.New++
+		}
+	}
+	sort.Slice(m.Groups, func(i, j int) bool {
+		if !m.Groups[i].LastSeen.Equal(m.Groups[j].LastSeen) {
+			return m.Groups[i].LastSeen.After(m.Groups[j].LastSeen)
+		}
+		return m.Groups[i].Fingerprint < m.Groups[j].Fingerprint
+	})
+	return m, nil
+}
+
+func (t *exceptionTracker) add(e LogEntry, roots []string, now time.Time) {
+	seen := e.Time
+	if seen.IsZero() {
+		seen = now
+	}
+
+	g := &ExceptionGroup{Class: e.Exception, Message: normaliseMessage(e.ExceptionMessage), Frames: []string{}}
+	for _, frame := range e.Frames {
+		if len(g.Frames) >= fingerprintFrames {
+			break
+		}
+		if file, ok := appFrame(frame, roots); ok {
+			g.Frames = append(g.Frames, file)
+		}
+	}
+	g.Fingerprint = fingerprint(g.Class, g.Message, g.Frames)
+
+	if existing, ok := t.groups[g.Fingerprint]; ok {
+		g = existing
+	} else {
+		g.FirstSeen = seen
+		t.groups[g.Fingerprint] = g
+	}
+	g.Count++
+	if seen.After(g.LastSeen) {
+		g.LastSeen = seen
+	}
+	// Entries logged out of order, e.g. by another file
+	if seen.Before(g.FirstSeen) {
+		g.FirstSeen = seen
+	}
+}
+
+// evict drops the least recently seen groups beyond maxExceptionGroups.
+func (t *exceptionTracker) evict() {
+	if len(t.groups) <= maxExceptionGroups {
+		return
+	}
+	groups := make([]*ExceptionGroup, 0, len(t.groups))
+	for _, g := range t.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].LastSeen.Before(groups[j].LastSeen) })
+	for _, g := range groups[:len(groups)-maxExceptionGroups] {
+		delete(t.groups, g.Fingerprint)
+	}
+}
+
+func (t *exceptionTracker) load() error {
+	data, err := os.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read exceptions state: %w", err)
+	}
+
+	var state exceptionsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse exceptions state %s: %w", t.path, err)
+	}
+	if state.Version != exceptionsStateVersion {
+		return fmt.Errorf("unsupported exceptions state version %d in %s", state.Version, t.path)
+	}
+	for i := range state.Groups {
+		g := state.Groups[i]
+		t.groups[g.Fingerprint] = &g
+	}
+	return nil
+}
+
+// save writes the state to a temporary file renamed over the previous one,
+// so a crash never leaves it half written.
+func (t *exceptionTracker) save() error {
+	state := exceptionsState{Version: exceptionsStateVersion, Groups: make([]ExceptionGroup, 0, len(t.groups))}
+	for _, g := range t.groups {
+		state.Groups = append(state.Groups, *g)
+	}
+	sort.Slice(state.Groups, func(i, j int) bool { return state.Groups[i].Fingerprint < state.Groups[j].Fingerprint })
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create exceptions state dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(t.path), filepath.Base(t.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write exceptions state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write exceptions state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write exceptions state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), t.path); err != nil {
+		return fmt.Errorf("failed to write exceptions state: %w", err)
+	}
+	return nil
+}
+
+// normaliseMessage scrubs the identifiers and values from an exception
+// message, e.g. No query results for model [App\Models\User] :n.
+func normaliseMessage(message string) string {
+	for _, s := range messageScrubbers {
+		message = s.match.ReplaceAllString(message, s.replace)
+	}
+	if len(message) > maxLogMessageLength {
+		message = strings.ToValidUTF8(message[:maxLogMessageLength], "")
+	}
+	return message
+}
+
+// appFrame returns the file of a file:line frame relative to the app path,
+// or false for frames in vendor. Line numbers are left out as they move with
+// every change to the file.
+func appFrame(frame string, roots []string) (string, bool) {
+	file := frame
+	if i := strings.LastIndexByte(frame, ':'); i > 0 {
+		if _, err := strconv.Atoi(frame[i+1:]); err == nil {
+			file = frame[:i]
+		}
+	}
+	if strings.Contains(file, "/vendor/") || strings.HasPrefix(file, "vendor/") {
+		return "", false
+	}
+	for _, root := range roots {
+		if rel, ok := strings.CutPrefix(file, root+"/"); ok {
+			return rel, true
+		}
+	}
+	if loc := releaseDir.FindStringIndex(file); loc != nil {
+		return file[loc[1]:], true
+	}
+	return file, true
+}
+
+func fingerprint(class, message string, frames []string) string {
+	sum := sha256.Sum256([]byte(class + "\n" + message + "\n" + strings.Join(frames, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,190 @@
+package laravel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+func thrownEntry(site string, at time.Time, class, message string, frames ...string) LogEntry {
+	for i, f := range frames {
+		frames[i] = strings.ReplaceAll(f, "{site}", site)
+	}
+	return LogEntry{Time: at, Level: "error", Exception: class, ExceptionMessage: message, Frames: frames}
+}
+
+func TestGetExceptionMetrics(t *testing.T) {
+	site := t.TempDir()
+	now := time.Date(2025, 3, 12, 10, 0, 0, 0, time.UTC)
+	cfg := config.LaravelConfig{Name: "App", Path: site}
+
+	logs := &LogMetrics{exceptions: []LogEntry{
+		thrownEntry(site, now.Add(-2*time.Hour), `Illuminate\Database\Eloquent\ModelNotFoundException`, "No query results for model [App\\Models\\User] 12",
+			"{site}/vendor/laravel/framework/src/Builder.php:620", "{site}/app/Http/Controllers/UserController.php:31", "{site}/routes/web.php:8"),
+		thrownEntry(site, now.Add(-time.Hour), `Illuminate\Database\Eloquent\ModelNotFoundException`, "No query results for model [App\\Models\\User] 407",
+			"{site}/vendor/laravel/framework/src/Builder.php:633", "{site}/app/Http/Controllers/UserController.php:35", "{site}/routes/web.php:8"),
+		thrownEntry(site, now.Add(-10*time.Minute), `App\Exceptions\PaymentFailed`, "Card 'visa' declined for order 9f8e7d6c5b4a3f2e",
+			"/home/forge/releases/20250312/app/Services/Pay.php:42"),
+	}}
+
+	m, err := GetExceptionMetrics(cfg, logs, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(m.Groups) != 2 || m.New != 1 || m.NewWindowSeconds != 3600 {
+		t.Fatalf("Expected 2 groups, 1 first seen in the last hour, got %+v", m)
+	}
+
+	payment := m.Groups[0]
+	if payment.Message != "Card :str declined for order :hex" || !reflect.DeepEqual(payment.Frames, []string{"app/Services/Pay.php"}) {
+		t.Errorf("Unexpected group %+v", payment)
+	}
+	model := m.Groups[1]
+	expected := ExceptionGroup{
+		Fingerprint: model.Fingerprint,
+		Class:       `Illuminate\Database\Eloquent\ModelNotFoundException`,
+		Message:     "No query results for model [App\\Models\\User] :n",
+		Frames:      []string{"app/Http/Controllers/UserController.php", "routes/web.php"},
+		FirstSeen:   now.Add(-2 * time.Hour),
+		LastSeen:    now.Add(-time.Hour),
+		Count:       2,
+	}
+	if !reflect.DeepEqual(model, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, model)
+	}
+	if len(model.Fingerprint) != 16 || model.Fingerprint == payment.Fingerprint {
+		t.Errorf("Unexpected fingerprints %q and %q", model.Fingerprint, payment.Fingerprint)
+	}
+
+	// The groups survive a restart
+	delete(exceptionTrackers, filepath.Clean(site))
+	later := now.Add(24 * time.Hour)
+	logs = &LogMetrics{exceptions: []LogEntry{
+		thrownEntry(site, later, `Illuminate\Database\Eloquent\ModelNotFoundException`, "No query results for model [App\\Models\\User] 5",
+			"{site}/app/Http/Controllers/UserController.php:40", "{site}/routes/web.php:8"),
+	}}
+	m, err = GetExceptionMetrics(cfg, logs, later)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(m.Groups) != 2 || m.New != 0 {
+		t.Fatalf("Expected the known groups only, got %+v", m)
+	}
+	if g := m.Groups[0]; g.Fingerprint != model.Fingerprint || g.Count != 3 || !g.FirstSeen.Equal(expected.FirstSeen) || !g.LastSeen.Equal(later) {
+		t.Errorf("Expected the persisted group to be updated, got %+v", g)
+	}
+}
+
+func TestGetExceptionMetrics_UnreadableState(t *testing.T) {
+	site := t.TempDir()
+	state := filepath.Join(site, "state.json")
+	if err := os.WriteFile(state, []byte("{"), 0o644); err != nil {
+		t.Fatalf("Failed to write state: %v", err)
+	}
+	cfg := config.LaravelConfig{Path: site, ExceptionsState: "state.json"}
+	logs := &LogMetrics{exceptions: []LogEntry{thrownEntry(site, time.Now(), "RuntimeException", "Boom")}}
+
+	if _, err := GetExceptionMetrics(cfg, logs, time.Now()); err == nil || !strings.Contains(err.Error(), "failed to parse exceptions state") {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+	if data, _ := os.ReadFile(state); string(data) != "{" {
+		t.Errorf("Expected the state to be left alone, got %s", data)
+	}
+}
+
+func TestExceptionTracker_Evict(t *testing.T) {
+	tracker := &exceptionTracker{groups: map[string]*ExceptionGroup{}}
+	start := time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)
+	for i := range maxExceptionGroups + 5 {
+		tracker.add(LogEntry{Time: start.Add(time.Duration(i) * time.Second), Exception: fmt.Sprintf("E%d", i)}, nil, start)
+	}
+	tracker.evict()
+
+	if len(tracker.groups) != maxExceptionGroups {
+		t.Fatalf("Expected %d groups, got %d", maxExceptionGroups, len(tracker.groups))
+	}
+	for _, g := range tracker.groups {
+		if g.LastSeen.Before(start.Add(5 * time.Second)) {
+			t.Errorf("Expected the least recently seen groups to be dropped, found %v", g.LastSeen)
+		}
+	}
+}
+
+func TestNormaliseMessage(t *testing.T) {
+	tests := map[string]string{
+		"Undefined array key \"email\"":                            "Undefined array key :str",
+		"Job 3f2b8c1e-0d4a-4b7e-9a6f-2c1d0e9b8a7f has timed out":   "Job :uuid has timed out",
+		"User jane@example.com not found":                          "User :email not found",
+		"Allowed memory size of 134217728 bytes exhausted":         "Allowed memory size of :n bytes exhausted",
+		"SQLSTATE[HY000] [2002] Connection refused":                "SQLSTATE[HY000] [:n] Connection refused",
+		"Call to a member function name() on null":                 "Call to a member function name() on null",
+		"Object of class Closure at 0x7f3a could not be converted": "Object of class Closure at :hex could not be converted",
+	}
+	for message, expected := range tests {
+		if got := normaliseMessage(message); got != expected {
+			t.Errorf("normaliseMessage(%q) = %q, expected %q", message, got, expected)
+		}
+	}
+}
+
+func TestAppFrame(t *testing.T) {
+	roots := []string{"/var/www/current", "/var/www/releases/7"}
+	tests := []struct {
+		frame    string
+		expected string
+		ok       bool
+	}{
+		{"/var/www/current/app/Models/User.php:12", "app/Models/User.php", true},
+		{"/var/www/releases/7/routes/web.php:3", "routes/web.php", true},
+		{"/srv/releases/8/app/Jobs/Sync.php:90", "app/Jobs/Sync.php", true},
+		{"/var/www/current/vendor/laravel/framework/src/Foundation/Application.php:1", "", false},
+		{"/opt/other/script.php", "/opt/other/script.php", true},
+	}
+	for _, tt := range tests {
+		got, ok := appFrame(tt.frame, roots)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("appFrame(%q) = %q, %v, expected %q, %v", tt.frame, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestCollect_Exceptions(t *testing.T) {
+	site, php := writeScheduleSite(t)
+	log := filepath.Join(site, "storage", "logs", "laravel.log")
+	appendLog(t, log, "")
+	cfg := &config.Config{
+		PHP:     config.PHPConfig{Binary: php},
+		Laravel: []config.LaravelConfig{{Name: "App", Path: site, QueueBackend: QueueBackendNative, MonitorExceptions: true}},
+	}
+
+	Collect(context.Background(), cfg)
+	appendLog(t, log, lineLogEntries)
+	result, errs := Collect(context.Background(), cfg)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if result["App"].Logs != nil {
+		t.Errorf("Expected no log metrics without monitor_logs, got %+v", result["App"].Logs)
+	}
+	e := result["App"].Exceptions
+	if e == nil || len(e.Groups) != 1 || e.Groups[0].Class != `App\Exceptions\PaymentFailed` {
+		t.Fatalf("Expected the logged exception in the result, got %+v", e)
+	}
+	if _, err := os.Stat(filepath.Join(site, DefaultExceptionsState)); err != nil {
+		t.Errorf("Expected the state to be saved: %v", err)
+	}
+
+	cfg.Laravel[0].ExceptionsState = "/dev/null/state.json"
+	delete(exceptionTrackers, filepath.Clean(site))
+	appendLog(t, log, lineLogEntries)
+	if _, errs := Collect(context.Background(), cfg); errs["laravel:App:exceptions"] == "" {
+		t.Errorf("Expected an exceptions error, got %v", errs)
+	}
+}
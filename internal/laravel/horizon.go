@@ -0,0 +1,389 @@
+package laravel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/laravel/siteconfig"
+)
+
+const (
+	HorizonRunning  = "running"
+	HorizonPaused   = "paused"
+	HorizonInactive = "inactive"
+
+	// Horizon drops masters and supervisors that stopped reporting for longer
+	horizonMasterTTL     = 14 * time.Second
+	horizonSupervisorTTL = 29 * time.Second
+)
+
+var errHorizonNotInstalled = errors.New("horizon is not installed")
+
+// HorizonMetrics is what Horizon's dashboard shows, read from its Redis keys.
+type HorizonMetrics struct {
+	Status        string              `json:"status"` // running, paused or inactive
+	Masters       []HorizonMaster     `json:"masters"`
+	Supervisors   []HorizonSupervisor `json:"supervisors"`
+	Queues        []HorizonQueue      `json:"queues"`
+	Jobs          HorizonJobCounts    `json:"jobs"`
+	JobsPerMinute *float64            `json:"jobs_per_minute"`
+	JobStats      []HorizonStat       `json:"job_stats"`   // Latest snapshot per job class
+	QueueStats    []HorizonStat       `json:"queue_stats"` // Latest snapshot per queue
+}
+
+type HorizonMaster struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"`
+	Status      string `json:"status"`
+	PID         int    `json:"pid"`
+}
+
+type HorizonSupervisor struct {
+	Name      string         `json:"name"`
+	Master    string         `json:"master"`
+	Status    string         `json:"status"`
+	PID       int            `json:"pid"`
+	Processes map[string]int `json:"processes"` // By connection:queue
+}
+
+// HorizonQueue is a queue's workload: jobs ready to run, the processes
+// working it and the time they need to clear it.
+type HorizonQueue struct {
+	Connection  string   `json:"connection"`
+	Queue       string   `json:"queue"`
+	Length      *int     `json:"length"`
+	Processes   int      `json:"processes"`
+	WaitSeconds *float64 `json:"wait_seconds"`
+}
+
+type HorizonJobCounts struct {
+	Recent       int `json:"recent"`
+	Pending      int `json:"pending"`
+	Completed    int `json:"completed"`
+	Failed       int `json:"failed"`
+	RecentFailed int `json:"recent_failed"`
+}
+
+// HorizonStat is a metrics snapshot: the jobs processed in the snapshot
+// interval and their average runtime.
+type HorizonStat struct {
+	Name           string    `json:"name"`
+	Throughput     float64   `json:"throughput"`
+	RuntimeSeconds float64   `json:"runtime_seconds"`
+	Time           time.Time `json:"time"`
+}
+
+// GetHorizonMetrics reads the state of a site's Horizon from the Redis
+// connection Horizon uses, the way its dashboard does.
+func GetHorizonMetrics(ctx context.Context, sc *siteconfig.Config) (*HorizonMetrics, error) {
+	if sc.Horizon == nil {
+		return nil, errHorizonNotInstalled
+	}
+
+	opts, err := redisOptionsFor(sc, sc.Horizon.Use.Or("default"))
+	if err != nil {
+		return nil, err
+	}
+	opts.Prefix = sc.Horizon.Prefix.Or("horizon:")
+	client, err := dialRedis(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("redis connect: %w", err)
+	}
+	defer client.Close()
+
+	h := &horizonReader{c: client, prefix: opts.Prefix, now: time.Now()}
+	m := &HorizonMetrics{}
+
+	if m.Masters, err = h.masters(); err != nil {
+		return nil, err
+	}
+	m.Status = horizonStatus(m.Masters)
+	if m.Supervisors, err = h.supervisors(); err != nil {
+		return nil, err
+	}
+	if m.Jobs, err = h.jobCounts(); err != nil {
+		return nil, err
+	}
+	if m.JobsPerMinute, err = h.jobsPerMinute(); err != nil {
+		return nil, err
+	}
+	if m.JobStats, err = h.snapshots("measured_jobs", "snapshot:job:"); err != nil {
+		return nil, err
+	}
+	if m.QueueStats, err = h.snapshots("measured_queues", "snapshot:queue:"); err != nil {
+		return nil, err
+	}
+	m.Queues = h.workload(ctx, sc, m.Supervisors)
+	return m, nil
+}
+
+// horizonStatus is inactive without masters and paused when all are paused.
+func horizonStatus(masters []HorizonMaster) string {
+	if len(masters) == 0 {
+		return HorizonInactive
+	}
+	for _, m := range masters {
+		if m.Status != HorizonPaused {
+			return HorizonRunning
+		}
+	}
+	return HorizonPaused
+}
+
+type horizonReader struct {
+	c      *respClient
+	prefix string
+	now    time.Time
+}
+
+func (h *horizonReader) key(name string) string {
+	return h.prefix + name
+}
+
+// members returns the members of a sorted set updated after since.
+func (h *horizonReader) members(key string, since time.Time) ([]string, error) {
+	reply, err := h.c.do("ZRANGEBYSCORE", h.key(key), strconv.FormatInt(since.Unix(), 10), "+inf")
+	if err != nil {
+		return nil, err
+	}
+	return stringList(reply), nil
+}
+
+func (h *horizonReader) hash(key string) (map[string]string, error) {
+	reply, err := h.c.do("HGETALL", h.key(key))
+	if err != nil {
+		return nil, err
+	}
+	list := stringList(reply)
+	fields := make(map[string]string, len(list)/2)
+	for i := 0; i+1 < len(list); i += 2 {
+		fields[list[i]] = list[i+1]
+	}
+	return fields, nil
+}
+
+func (h *horizonReader) masters() ([]HorizonMaster, error) {
+	names, err := h.members("masters", h.now.Add(-horizonMasterTTL))
+	if err != nil {
+		return nil, err
+	}
+	masters := []HorizonMaster{}
+	for _, name := range names {
+		fields, err := h.hash("master:" + name)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		pid, _ := strconv.Atoi(fields["pid"])
+		masters = append(masters, HorizonMaster{Name: name, Environment: fields["environment"], Status: fields["status"], PID: pid})
+	}
+	return masters, nil
+}
+
+func (h *horizonReader) supervisors() ([]HorizonSupervisor, error) {
+	names, err := h.members("supervisors", h.now.Add(-horizonSupervisorTTL))
+	if err != nil {
+		return nil, err
+	}
+	supervisors := []HorizonSupervisor{}
+	for _, name := range names {
+		fields, err := h.hash("supervisor:" + name)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		s := HorizonSupervisor{Name: name, Master: fields["master"], Status: fields["status"], Processes: map[string]int{}}
+		s.PID, _ = strconv.Atoi(fields["pid"])
+		// An empty PHP array is encoded as a list
+		if raw := fields["processes"]; raw != "" && raw != "[]" {
+			if err := json.Unmarshal([]byte(raw), &s.Processes); err != nil {
+				return nil, fmt.Errorf("supervisor %s processes: %w", name, err)
+			}
+		}
+		supervisors = append(supervisors, s)
+	}
+	return supervisors, nil
+}
+
+func (h *horizonReader) jobCounts() (HorizonJobCounts, error) {
+	var counts HorizonJobCounts
+	for key, target := range map[string]*int{
+		"recent_jobs":        &counts.Recent,
+		"pending_jobs":       &counts.Pending,
+		"completed_jobs":     &counts.Completed,
+		"failed_jobs":        &counts.Failed,
+		"recent_failed_jobs": &counts.RecentFailed,
+	} {
+		n, err := h.c.doInt("ZCARD", h.key(key))
+		if err != nil {
+			return counts, err
+		}
+		*target = n
+	}
+	return counts, nil
+}
+
+// jobsPerMinute divides the throughput of all queues since the last snapshot
+// by the minutes since, at least one like Horizon's dashboard, so a read
+// shortly after a snapshot isn't inflated.
+func (h *horizonReader) jobsPerMinute() (*float64, error) {
+	reply, err := h.c.do("GET", h.key("last_snapshot_at"))
+	if err != nil {
+		return nil, err
+	}
+	raw, _ := reply.([]byte)
+	last, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		// No snapshot taken yet
+		return nil, nil
+	}
+	minutes := max(h.now.Sub(time.Unix(last, 0)).Minutes(), 1)
+
+	reply, err = h.c.do("SMEMBERS", h.key("measured_queues"))
+	if err != nil {
+		return nil, err
+	}
+	total := 0.0
+	for _, q := range stringList(reply) {
+		reply, err := h.c.do("HGET", h.key("queue:"+q), "throughput")
+		if err != nil {
+			return nil, err
+		}
+		raw, _ := reply.([]byte)
+		n, _ := strconv.ParseFloat(string(raw), 64)
+		total += n
+	}
+	perMinute := total / minutes
+	return &perMinute, nil
+}
+
+// snapshots returns the latest snapshot of every measured job or queue.
+func (h *horizonReader) snapshots(set, keyPrefix string) ([]HorizonStat, error) {
+	reply, err := h.c.do("SMEMBERS", h.key(set))
+	if err != nil {
+		return nil, err
+	}
+	names := stringList(reply)
+	sort.Strings(names)
+
+	stats := []HorizonStat{}
+	for _, name := range names {
+		reply, err := h.c.do("ZRANGE", h.key(keyPrefix+name), "-1", "-1")
+		if err != nil {
+			return nil, err
+		}
+		latest := stringList(reply)
+		if len(latest) == 0 {
+			continue
+		}
+		var snapshot struct {
+			Throughput float64 `json:"throughput"`
+			Runtime    float64 `json:"runtime"` // Milliseconds
+			Time       int64   `json:"time"`
+		}
+		if err := json.Unmarshal([]byte(latest[0]), &snapshot); err != nil {
+			continue
+		}
+		stats = append(stats, HorizonStat{
+			Name:           name,
+			Throughput:     snapshot.Throughput,
+			RuntimeSeconds: snapshot.Runtime / 1000,
+			Time:           time.Unix(snapshot.Time, 0),
+		})
+	}
+	return stats, nil
+}
+
+// workload sums the supervisors' processes per queue and estimates the time
+// to clear each queue from its length and average runtime, like Horizon's
+// WaitTimeCalculator. Lengths are read for Redis queue connections only.
+func (h *horizonReader) workload(ctx context.Context, sc *siteconfig.Config, supervisors []HorizonSupervisor) []HorizonQueue {
+	processes := map[string]int{}
+	for _, s := range supervisors {
+		for key, n := range s.Processes {
+			processes[key] += n
+		}
+	}
+
+	clients := map[string]*respClient{}
+	defer func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}()
+
+	queues := []HorizonQueue{}
+	for key, n := range processes {
+		conn, name, ok := strings.Cut(key, ":")
+		if !ok {
+			continue
+		}
+		q := HorizonQueue{Connection: conn, Queue: name, Processes: n}
+
+		if length, ok := h.queueLength(ctx, sc, clients, conn, name); ok {
+			q.Length = &length
+
+			reply, err := h.c.do("HGET", h.key("queue:"+name), "runtime")
+			if err == nil {
+				raw, _ := reply.([]byte)
+				runtime, _ := strconv.ParseFloat(string(raw), 64)
+				wait := float64(length) * runtime / 1000
+				if n > 0 {
+					wait /= float64(n)
+				}
+				q.WaitSeconds = &wait
+			}
+		}
+		queues = append(queues, q)
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		if queues[i].Connection != queues[j].Connection {
+			return queues[i].Connection < queues[j].Connection
+		}
+		return queues[i].Queue < queues[j].Queue
+	})
+	return queues
+}
+
+// queueLength returns the jobs ready to run on a Redis queue.
+func (h *horizonReader) queueLength(ctx context.Context, sc *siteconfig.Config, clients map[string]*respClient, conn, queue string) (int, bool) {
+	if sc.QueueDriver(conn) != QueueDriverRedis {
+		return 0, false
+	}
+	redisConn := sc.Queue.Connections[conn].Connection.Or("default")
+	opts, err := redisOptionsFor(sc, redisConn)
+	if err != nil {
+		return 0, false
+	}
+	client := clients[redisConn]
+	if client == nil {
+		if client, err = dialRedis(ctx, opts); err != nil {
+			return 0, false
+		}
+		clients[redisConn] = client
+	}
+	n, err := client.doInt("LLEN", opts.Prefix+"queues:"+queue)
+	return n, err == nil
+}
+
+// stringList converts an array reply of bulk strings.
+func stringList(reply any) []string {
+	items, _ := reply.([]any)
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if b, ok := item.([]byte); ok {
+			list = append(list, string(b))
+		}
+	}
+	return list
+}
@@ -0,0 +1,177 @@
+package laravel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+func TestGetHorizonMetrics(t *testing.T) {
+	fake, port := startFakeRedis(t, "")
+	now := time.Now()
+	score := float64(now.Unix())
+
+	fake.zadd(0, "shop_horizon:masters", score, "host-1")
+	fake.zadd(0, "shop_horizon:masters", score-60, "host-gone")
+	fake.hset(0, "shop_horizon:master:host-1", map[string]string{"environment": "production", "status": "running", "pid": "100"})
+	fake.hset(0, "shop_horizon:master:host-gone", map[string]string{"status": "running"})
+	fake.zadd(0, "shop_horizon:supervisors", score, "host-1:supervisor-1")
+	fake.hset(0, "shop_horizon:supervisor:host-1:supervisor-1", map[string]string{
+		"master": "host-1", "status": "running", "pid": "101", "processes": `{"redis:default":3,"redis:emails":1}`,
+	})
+
+	fake.list(0, "shop_database_queues:default", "a", "b", "c", "d", "e", "f")
+	fake.hset(0, "shop_horizon:queue:default", map[string]string{"throughput": "30", "runtime": "500"})
+	fake.hset(0, "shop_horizon:queue:emails", map[string]string{"throughput": "10"})
+	fake.sadd(0, "shop_horizon:measured_queues", "default", "emails")
+	fake.set(0, "shop_horizon:last_snapshot_at", fmt.Sprint(now.Unix()-120))
+
+	fake.zset(0, "shop_horizon:recent_jobs", 10)
+	fake.zset(0, "shop_horizon:failed_jobs", 2)
+	fake.sadd(0, "shop_horizon:measured_jobs", `App\Jobs\Send`)
+	fake.zadd(0, `shop_horizon:snapshot:job:App\Jobs\Send`, score-300, `{"throughput":5,"runtime":100,"time":1}`)
+	fake.zadd(0, `shop_horizon:snapshot:job:App\Jobs\Send`, score, fmt.Sprintf(`{"throughput":12,"runtime":250.5,"time":%d}`, now.Unix()))
+
+	site := writeSite(t, fmt.Sprintf("APP_NAME=Shop\nQUEUE_CONNECTION=redis\nREDIS_PORT=%d\n", port))
+	writeHorizonConfig(t, site)
+
+	h, err := GetHorizonMetrics(context.Background(), loadSite(t, site))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if h.Status != HorizonRunning || len(h.Masters) != 1 || h.Masters[0].PID != 100 {
+		t.Errorf("Expected one running master, got %s %+v", h.Status, h.Masters)
+	}
+	if len(h.Supervisors) != 1 || h.Supervisors[0].Processes["redis:default"] != 3 {
+		t.Fatalf("Unexpected supervisors %+v", h.Supervisors)
+	}
+
+	if len(h.Queues) != 2 {
+		t.Fatalf("Expected 2 queues, got %+v", h.Queues)
+	}
+	q := h.Queues[0]
+	if q.Queue != "default" || q.Processes != 3 || q.Length == nil || *q.Length != 6 {
+		t.Errorf("Unexpected workload %+v", q)
+	}
+	if q.WaitSeconds == nil || *q.WaitSeconds != 1 {
+		t.Errorf("Expected 6 jobs of 500ms on 3 processes to take 1s, got %v", q.WaitSeconds)
+	}
+	if q := h.Queues[1]; q.Queue != "emails" || q.WaitSeconds == nil || *q.WaitSeconds != 0 {
+		t.Errorf("Expected an empty emails queue, got %+v", q)
+	}
+
+	if h.Jobs.Recent != 10 || h.Jobs.Failed != 2 || h.Jobs.Pending != 0 {
+		t.Errorf("Unexpected job counts %+v", h.Jobs)
+	}
+	if h.JobsPerMinute == nil || math.Abs(*h.JobsPerMinute-20) > 0.5 {
+		t.Errorf("Expected 40 jobs in 2 minutes to be 20 per minute, got %v", h.JobsPerMinute)
+	}
+
+	if len(h.JobStats) != 1 {
+		t.Fatalf("Expected one job class, got %+v", h.JobStats)
+	}
+	if s := h.JobStats[0]; s.Name != `App\Jobs\Send` || s.Throughput != 12 || s.RuntimeSeconds != 0.2505 {
+		t.Errorf("Expected the latest snapshot, got %+v", s)
+	}
+	if len(h.QueueStats) != 0 {
+		t.Errorf("Expected no queue snapshots, got %+v", h.QueueStats)
+	}
+}
+
+func TestGetHorizonMetrics_RecentSnapshot(t *testing.T) {
+	fake, port := startFakeRedis(t, "")
+	fake.hset(0, "shop_horizon:queue:default", map[string]string{"throughput": "30"})
+	fake.sadd(0, "shop_horizon:measured_queues", "default")
+	fake.set(0, "shop_horizon:last_snapshot_at", fmt.Sprint(time.Now().Unix()-5))
+
+	site := writeSite(t, fmt.Sprintf("APP_NAME=Shop\nREDIS_PORT=%d\n", port))
+	writeHorizonConfig(t, site)
+
+	h, err := GetHorizonMetrics(context.Background(), loadSite(t, site))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if h.JobsPerMinute == nil || *h.JobsPerMinute != 30 {
+		t.Errorf("Expected 30 jobs seconds after a snapshot to count over one minute, got %v", h.JobsPerMinute)
+	}
+}
+
+func TestGetHorizonMetrics_Inactive(t *testing.T) {
+	_, port := startFakeRedis(t, "")
+	site := writeSite(t, fmt.Sprintf("REDIS_PORT=%d\nHORIZON_PREFIX=hz:\n", port))
+	writeHorizonConfig(t, site)
+
+	h, err := GetHorizonMetrics(context.Background(), loadSite(t, site))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if h.Status != HorizonInactive || len(h.Queues) != 0 || h.JobsPerMinute != nil {
+		t.Errorf("Expected an inactive Horizon without data, got %+v", h)
+	}
+}
+
+func TestGetHorizonMetrics_NotInstalled(t *testing.T) {
+	site := writeSite(t, "")
+	if _, err := GetHorizonMetrics(context.Background(), loadSite(t, site)); !errors.Is(err, errHorizonNotInstalled) {
+		t.Errorf("Expected errHorizonNotInstalled, got %v", err)
+	}
+}
+
+func TestCollect_Horizon(t *testing.T) {
+	_, port := startFakeRedis(t, "")
+	site := writeSite(t, fmt.Sprintf("REDIS_PORT=%d\n", port))
+	writeHorizonConfig(t, site)
+	cfg := &config.Config{
+		Laravel: []config.LaravelConfig{
+			{Name: "App", Path: site, QueueBackend: QueueBackendNative, EnableHorizon: true},
+			{Name: "NoHorizon", Path: writeSite(t, ""), QueueBackend: QueueBackendNative, EnableHorizon: true},
+		},
+	}
+
+	result, errs := Collect(context.Background(), cfg)
+	if h := result["App"].Horizon; h == nil || h.Status != HorizonInactive {
+		t.Errorf("Expected Horizon metrics in the result, got %+v", h)
+	}
+	if _, ok := errs["laravel:NoHorizon:horizon"]; !ok || len(errs) != 1 {
+		t.Errorf("Expected only the missing Horizon to be reported, got %v", errs)
+	}
+}
+
+func TestHorizonStatus(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		expected string
+	}{
+		{nil, HorizonInactive},
+		{[]string{"paused", "paused"}, HorizonPaused},
+		{[]string{"paused", "running"}, HorizonRunning},
+	}
+	for _, tt := range tests {
+		var masters []HorizonMaster
+		for _, s := range tt.statuses {
+			masters = append(masters, HorizonMaster{Status: s})
+		}
+		if got := horizonStatus(masters); got != tt.expected {
+			t.Errorf("horizonStatus(%v) = %s, expected %s", tt.statuses, got, tt.expected)
+		}
+	}
+}
+
+func writeHorizonConfig(t *testing.T, site string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(site, "config"), 0o755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(site, "config", "horizon.php"), []byte("<?php return [];"), 0o644); err != nil {
+		t.Fatalf("Failed to write horizon config: %v", err)
+	}
+}
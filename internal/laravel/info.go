@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -116,13 +115,7 @@ func GetAppInfo(site config.LaravelConfig, phpBinary string) (*AppInfo, error) {
 	cmd := exec.Command(phpBinary, "-d", "error_reporting=E_ALL & ~E_DEPRECATED", "artisan", "about", "--json")
 
 	// disable monitoring on scraping to prevent exhausting monitoring tools
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "NIGHTWATCH_ENABLED=false")
-	cmd.Env = append(cmd.Env, "TELESCOPE_ENABLED=false")
-	cmd.Env = append(cmd.Env, "NEW_RELIC_ENABLED=false")
-	cmd.Env = append(cmd.Env, "BUGSNAG_API_KEY=null")
-	cmd.Env = append(cmd.Env, "SENTRY_LARAVEL_DSN=null")
-	cmd.Env = append(cmd.Env, "ROLLBAR_TOKEN=null")
+	cmd.Env = artisanEnv()
 
 	cmd.Dir = cacheKey
 
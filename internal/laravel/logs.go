@@ -0,0 +1,397 @@
+package laravel
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/redact"
+)
+
+const (
+	DefaultLogFiles = "storage/logs/laravel*.log"
+
+	// logReadLimit bounds what is read from a file per collection, the rest
+	// is read by the next ones.
+	logReadLimit = 4 << 20
+	// recentErrorsSize is the number of errors kept for /json.
+	recentErrorsSize = 50
+	// Label values beyond these limits are counted as "other".
+	maxLogChannels         = 20
+	maxLogExceptionClasses = 100
+	// maxLogMessageLength truncates messages kept in the recent errors.
+	maxLogMessageLength = 1000
+	// maxLogFrames bounds the stack frames kept per entry.
+	maxLogFrames = 20
+
+	logOther = "other"
+)
+
+var (
+	logTailers     = map[string]*logTailer{}
+	logTailersLock sync.Mutex
+
+	logLinePattern  = regexp.MustCompile(`^\[([^\]]+)\] (\S+)\.([A-Z]+): (.*)$`)
+	exceptionObject = regexp.MustCompile(`\[object\] \(([^\s(]+)\(code: -?\d+\): (.*?) at (\S+):(\d+)\)`)
+	stackFrame      = regexp.MustCompile(`^#\d+ (.+)\((\d+)\): `)
+
+	logTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05.999999Z07:00", "2006-01-02 15:04:05.999999", "2006-01-02T15:04:05Z07:00"}
+
+	errorLevels = map[string]bool{"error": true, "critical": true, "alert": true, "emergency": true}
+)
+
+// LogMetrics are counts of the entries written to a site's log files since
+// the agent started, and the most recent errors.
+type LogMetrics struct {
+	Files        []string          `json:"files"`
+	Entries      []LogCount        `json:"entries"`
+	Exceptions   map[string]uint64 `json:"exceptions"` // By exception class
+	RecentErrors []LogEntry        `json:"recent_errors"`
+
+	// exceptions are the entries with an exception read by this collection.
+	exceptions []LogEntry
+}
+
+type LogCount struct {
+	Channel string `json:"channel"`
+	Level   string `json:"level"`
+	Count   uint64 `json:"count"`
+}
+
+type LogEntry struct {
+	Time             time.Time `json:"time"`
+	File             string    `json:"file"`
+	Channel          string    `json:"channel"`
+	Level            string    `json:"level"`
+	Message          string    `json:"message"`
+	Exception        string    `json:"exception,omitempty"`
+	ExceptionMessage string    `json:"exception_message,omitempty"`
+	Frames           []string  `json:"frames,omitempty"` // file:line, where the exception was thrown first
+}
+
+// logTailer follows the log files of a site. Files present when it starts
+// are read from their end, files appearing later, such as the next daily
+// log, from their start.
+type logTailer struct {
+	mu         sync.Mutex
+	started    bool
+	files      map[string]*tailedFile
+	counts     map[[2]string]uint64
+	channels   map[string]bool
+	exceptions map[string]uint64
+	recent     []LogEntry
+	next       int        // Ring buffer position in recent
+	thrown     []LogEntry // Entries with an exception since the last collection
+}
+
+type tailedFile struct {
+	info    os.FileInfo
+	offset  int64
+	pending []string // Lines of an entry that may continue
+}
+
+// GetLogMetrics reads what was written to the site's log files since the
+// previous collection. Patterns are globs relative to the app path.
+func GetLogMetrics(appPath string, patterns []string, loc *time.Location) (*LogMetrics, error) {
+	if len(patterns) == 0 {
+		patterns = []string{DefaultLogFiles}
+	}
+
+	key := filepath.Clean(appPath)
+	logTailersLock.Lock()
+	t, ok := logTailers[key]
+	if !ok {
+		t = newLogTailer()
+		logTailers[key] = t
+	}
+	logTailersLock.Unlock()
+
+	var files []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(appPath, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	return t.collect(files, loc), nil
+}
+
+func newLogTailer() *logTailer {
+	return &logTailer{
+		files:      map[string]*tailedFile{},
+		counts:     map[[2]string]uint64{},
+		channels:   map[string]bool{},
+		exceptions: map[string]uint64{},
+	}
+}
+
+func (t *logTailer) collect(files []string, loc *time.Location) *LogMetrics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := map[string]bool{}
+	for _, path := range files {
+		seen[path] = true
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		f, ok := t.files[path]
+		switch {
+		case !ok && !t.started:
+			f = &tailedFile{info: info, offset: info.Size()}
+			t.files[path] = f
+		case !ok:
+			f = &tailedFile{info: info}
+			t.files[path] = f
+		case !os.SameFile(f.info, info) || info.Size() < f.offset:
+			// Rotated or truncated, the new content starts at 0
+			t.flush(path, f, loc)
+			f.offset = 0
+		}
+		f.info = info
+		t.read(path, f, loc)
+	}
+	for path, f := range t.files {
+		if !seen[path] {
+			t.flush(path, f, loc)
+			delete(t.files, path)
+		}
+	}
+	t.started = true
+
+	m := &LogMetrics{Files: files, Entries: []LogCount{}, Exceptions: map[string]uint64{}, RecentErrors: []LogEntry{}}
+	if m.Files == nil {
+		m.Files = []string{}
+	}
+	for k, n := range t.counts {
+		m.Entries = append(m.Entries, LogCount{Channel: k[0], Level: k[1], Count: n})
+	}
+	sort.Slice(m.Entries, func(i, j int) bool {
+		if m.Entries[i].Channel != m.Entries[j].Channel {
+			return m.Entries[i].Channel < m.Entries[j].Channel
+		}
+		return m.Entries[i].Level < m.Entries[j].Level
+	})
+	for class, n := range t.exceptions {
+		m.Exceptions[class] = n
+	}
+	m.exceptions, t.thrown = t.thrown, nil
+	// Oldest first
+	for i := range t.recent {
+		m.RecentErrors = append(m.RecentErrors, t.recent[(t.next+i)%len(t.recent)])
+	}
+	return m
+}
+
+// read parses the complete lines written since the offset. An entry's last
+// line may be followed by stack trace lines, so it is kept pending until the
+// next entry starts or nothing more was written.
+func (t *logTailer) read(path string, f *tailedFile, loc *time.Location) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, logReadLimit))
+	if err != nil {
+		return
+	}
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		if len(data) < logReadLimit {
+			t.flush(path, f, loc)
+			return
+		}
+		// A single line longer than the limit is skipped
+		f.offset += int64(len(data))
+		return
+	}
+	f.offset += int64(end + 1)
+
+	for _, line := range strings.Split(string(data[:end]), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, "{") || logLinePattern.MatchString(line) {
+			t.flush(path, f, loc)
+		}
+		if len(f.pending) > 0 || line != "" {
+			f.pending = append(f.pending, line)
+		}
+	}
+}
+
+// flush records the pending entry of a file.
+func (t *logTailer) flush(path string, f *tailedFile, loc *time.Location) {
+	if len(f.pending) == 0 {
+		return
+	}
+	lines := f.pending
+	f.pending = nil
+
+	var e *LogEntry
+	if strings.HasPrefix(lines[0], "{") {
+		e = parseJSONLogEntry(lines[0])
+	} else {
+		e = parseLineLogEntry(lines, loc)
+	}
+	if e == nil {
+		return
+	}
+	e.File = filepath.Base(path)
+	t.record(*e)
+}
+
+func (t *logTailer) record(e LogEntry) {
+	channel := e.Channel
+	if !t.channels[channel] {
+		if len(t.channels) >= maxLogChannels {
+			channel = logOther
+		} else {
+			t.channels[channel] = true
+		}
+	}
+	t.counts[[2]string{channel, e.Level}]++
+
+	if e.Exception != "" {
+		t.thrown = append(t.thrown, e)
+		class := e.Exception
+		if _, ok := t.exceptions[class]; !ok && len(t.exceptions) >= maxLogExceptionClasses {
+			class = logOther
+		}
+		t.exceptions[class]++
+	}
+
+	// Messages often carry user data, the recent errors are exposed as is
+	if errorLevels[e.Level] {
+		r := redact.Shared()
+		e.Message = r.Text(e.Message)
+		e.ExceptionMessage = r.Text(e.ExceptionMessage)
+		if len(e.Message) > maxLogMessageLength {
+			e.Message = strings.ToValidUTF8(e.Message[:maxLogMessageLength], "")
+		}
+		if len(t.recent) < recentErrorsSize {
+			t.recent = append(t.recent, e)
+		} else {
+			t.recent[t.next] = e
+			t.next = (t.next + 1) % recentErrorsSize
+		}
+	}
+}
+
+// parseLineLogEntry parses Monolog's line format as Laravel writes it:
+// [2025-03-12 10:17:30] production.ERROR: Message {"exception":"[object] ..."}
+// with the exception's stack trace on the following lines.
+func parseLineLogEntry(lines []string, loc *time.Location) *LogEntry {
+	m := logLinePattern.FindStringSubmatch(lines[0])
+	if m == nil {
+		return nil
+	}
+	e := &LogEntry{Time: parseLogTime(m[1], loc), Channel: m[2], Level: strings.ToLower(m[3])}
+
+	message := m[4]
+	if i := strings.Index(message, ` {"exception":`); i >= 0 {
+		message = message[:i]
+	}
+	for _, suffix := range []string{" [] []", " {} []", " [] {}", " {} {}"} {
+		message = strings.TrimSuffix(message, suffix)
+	}
+	e.Message = message
+
+	if ex := exceptionObject.FindStringSubmatch(strings.Join(lines, "\n")); ex != nil {
+		// The context is JSON encoded, namespace separators are escaped
+		e.Exception = unescapeJSON(ex[1])
+		e.ExceptionMessage = unescapeJSON(ex[2])
+		e.Frames = append(e.Frames, unescapeJSON(ex[3])+":"+ex[4])
+		for _, line := range lines[1:] {
+			if len(e.Frames) >= maxLogFrames {
+				break
+			}
+			// #1 [internal function] and #2 {main} have no file
+			if f := stackFrame.FindStringSubmatch(line); f != nil && !strings.HasPrefix(f[1], "[") {
+				e.Frames = append(e.Frames, unescapeJSON(f[1])+":"+f[2])
+			}
+		}
+	}
+	return e
+}
+
+// unescapeJSON decodes the escapes of a string taken from JSON text.
+func unescapeJSON(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var decoded string
+	if err := json.Unmarshal([]byte(`"`+s+`"`), &decoded); err == nil {
+		return decoded
+	}
+	return strings.ReplaceAll(s, `\\`, `\`)
+}
+
+// parseJSONLogEntry parses a record of Monolog's JsonFormatter.
+func parseJSONLogEntry(line string) *LogEntry {
+	var record struct {
+		Message   string `json:"message"`
+		Channel   string `json:"channel"`
+		LevelName string `json:"level_name"`
+		Datetime  string `json:"datetime"`
+		Context   struct {
+			Exception json.RawMessage `json:"exception"`
+		} `json:"context"`
+	}
+	if err := json.Unmarshal([]byte(line), &record); err != nil || record.LevelName == "" {
+		return nil
+	}
+	e := &LogEntry{
+		Time:    parseLogTime(record.Datetime, time.UTC),
+		Channel: record.Channel,
+		Level:   strings.ToLower(record.LevelName),
+		Message: record.Message,
+	}
+
+	// Monolog normalises exceptions with a trace of file:line frames
+	var exception struct {
+		Class   string   `json:"class"`
+		Message string   `json:"message"`
+		File    string   `json:"file"`
+		Trace   []string `json:"trace"`
+	}
+	if len(record.Context.Exception) > 0 && json.Unmarshal(record.Context.Exception, &exception) == nil {
+		e.Exception = exception.Class
+		e.ExceptionMessage = exception.Message
+		if exception.File != "" {
+			e.Frames = append(e.Frames, exception.File)
+		}
+		e.Frames = append(e.Frames, exception.Trace[:min(len(exception.Trace), maxLogFrames-len(e.Frames))]...)
+	}
+	return e
+}
+
+func parseLogTime(s string, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range logTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
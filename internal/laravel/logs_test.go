@@ -0,0 +1,182 @@
+package laravel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+const lineLogEntries = `[2025-03-12 10:17:30] production.ERROR: Order 12 failed {"exception":"[object] (App\\Exceptions\\PaymentFailed(code: 0): Card declined at /var/www/app/Services/Pay.php:42)
+[stacktrace]
+#0 /var/www/app/Jobs/Charge.php(20): App\\Services\\Pay->charge()
+#1 {main}
+"} 
+[2025-03-12 10:17:31] production.INFO: Order 13 paid [] []
+`
+
+const jsonLogEntry = `{"message":"Connection refused","context":{"exception":{"class":"Illuminate\\Database\\QueryException","message":"Connection refused","code":2002,"file":"/var/www/vendor/x.php:10"}},"level":500,"level_name":"CRITICAL","channel":"worker","datetime":"2025-03-12T10:17:32.123456+00:00","extra":{}}
+`
+
+func appendLog(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create log dir: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestGetLogMetrics(t *testing.T) {
+	site := t.TempDir()
+	log := filepath.Join(site, "storage", "logs", "laravel.log")
+	appendLog(t, log, "[2025-03-12 09:00:00] production.ERROR: Before the agent started\n")
+
+	m, err := GetLogMetrics(site, nil, time.UTC)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(m.Files) != 1 || len(m.Entries) != 0 {
+		t.Fatalf("Expected existing content to be skipped, got %+v", m)
+	}
+
+	appendLog(t, log, lineLogEntries+jsonLogEntry)
+	m, _ = GetLogMetrics(site, nil, time.UTC)
+	expected := []LogCount{{Channel: "production", Level: "error", Count: 1}, {Channel: "production", Level: "info", Count: 1}}
+	if !reflect.DeepEqual(m.Entries, expected) {
+		t.Errorf("Expected the last entry to be pending, got %+v", m.Entries)
+	}
+
+	// Nothing more was written, the pending entry is complete
+	m, _ = GetLogMetrics(site, nil, time.UTC)
+	expected = append(expected, LogCount{Channel: "worker", Level: "critical", Count: 1})
+	if !reflect.DeepEqual(m.Entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, m.Entries)
+	}
+	if !reflect.DeepEqual(m.Exceptions, map[string]uint64{`App\Exceptions\PaymentFailed`: 1, `Illuminate\Database\QueryException`: 1}) {
+		t.Errorf("Unexpected exceptions %v", m.Exceptions)
+	}
+
+	if len(m.RecentErrors) != 2 {
+		t.Fatalf("Expected 2 recent errors, got %+v", m.RecentErrors)
+	}
+	e := m.RecentErrors[0]
+	if e.Message != "Order 12 failed" || e.Exception != `App\Exceptions\PaymentFailed` || e.File != "laravel.log" ||
+		!e.Time.Equal(time.Date(2025, 3, 12, 10, 17, 30, 0, time.UTC)) {
+		t.Errorf("Unexpected recent error %+v", e)
+	}
+	if e.ExceptionMessage != "Card declined" || !reflect.DeepEqual(e.Frames, []string{"/var/www/app/Services/Pay.php:42", "/var/www/app/Jobs/Charge.php:20"}) {
+		t.Errorf("Unexpected exception message %q and frames %v", e.ExceptionMessage, e.Frames)
+	}
+	if len(m.exceptions) != 1 || m.exceptions[0].Exception != `Illuminate\Database\QueryException` || m.exceptions[0].Frames[0] != "/var/www/vendor/x.php:10" {
+		t.Errorf("Expected the exceptions read by this collection, got %+v", m.exceptions)
+	}
+	if e := m.RecentErrors[1]; e.Level != "critical" || e.Time.Nanosecond() != 123456000 {
+		t.Errorf("Unexpected JSON entry %+v", e)
+	}
+}
+
+func TestGetLogMetrics_Rotation(t *testing.T) {
+	site := t.TempDir()
+	logs := filepath.Join(site, "storage", "logs")
+	daily := filepath.Join(logs, "laravel-2025-03-12.log")
+	appendLog(t, daily, "")
+	if _, err := GetLogMetrics(site, nil, time.UTC); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The next day's file is read from its start
+	appendLog(t, filepath.Join(logs, "laravel-2025-03-13.log"), "[2025-03-13 00:00:01] local.WARNING: New day\n")
+	// A file replaced by a smaller one is read from its start
+	appendLog(t, daily, strings.Repeat("[2025-03-12 23:59:59] local.DEBUG: Late\n", 3))
+	GetLogMetrics(site, nil, time.UTC)
+	if err := os.Remove(daily); err != nil {
+		t.Fatalf("Failed to remove log: %v", err)
+	}
+	appendLog(t, daily, "[2025-03-12 23:59:59] local.DEBUG: Replaced\n")
+
+	m, _ := GetLogMetrics(site, nil, time.UTC)
+	m, _ = GetLogMetrics(site, nil, time.UTC)
+	expected := []LogCount{{Channel: "local", Level: "debug", Count: 4}, {Channel: "local", Level: "warning", Count: 1}}
+	if !reflect.DeepEqual(m.Entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, m.Entries)
+	}
+}
+
+func TestLogTailer_Bounds(t *testing.T) {
+	tailer := newLogTailer()
+	for i := range maxLogExceptionClasses + 10 {
+		tailer.record(LogEntry{Channel: fmt.Sprintf("c%d", i), Level: "error", Message: strings.Repeat("x", 2000), Exception: fmt.Sprintf("E%d", i)})
+	}
+
+	m := tailer.collect(nil, time.UTC)
+	if len(m.Exceptions) != maxLogExceptionClasses+1 || m.Exceptions[logOther] != 10 {
+		t.Errorf("Expected %d classes and 10 others, got %d and %d", maxLogExceptionClasses, len(m.Exceptions), m.Exceptions[logOther])
+	}
+	if len(m.Entries) != maxLogChannels+1 {
+		t.Errorf("Expected %d channels and other, got %d", maxLogChannels, len(m.Entries))
+	}
+	if len(m.RecentErrors) != recentErrorsSize || m.RecentErrors[0].Exception != "E60" || m.RecentErrors[recentErrorsSize-1].Exception != "E109" {
+		t.Errorf("Expected the last %d errors oldest first, got %s to %s", recentErrorsSize, m.RecentErrors[0].Exception, m.RecentErrors[len(m.RecentErrors)-1].Exception)
+	}
+	if len(m.RecentErrors[0].Message) != maxLogMessageLength {
+		t.Errorf("Expected messages truncated to %d, got %d", maxLogMessageLength, len(m.RecentErrors[0].Message))
+	}
+}
+
+func TestLogTailer_RedactsRecentErrors(t *testing.T) {
+	tailer := newLogTailer()
+	tailer.record(LogEntry{Level: "error", Message: "Reset for jane@example.com failed", Exception: "RuntimeException",
+		ExceptionMessage: "Job 3f2b8c1e-0d4a-4b7e-9a6f-2c1d0e9b8a7f for jane@example.com failed"})
+
+	m := tailer.collect(nil, time.UTC)
+	e := m.RecentErrors[0]
+	if e.Message != "Reset for :email failed" || e.ExceptionMessage != "Job :uuid for :email failed" {
+		t.Errorf("Expected the recent error to be redacted, got %q and %q", e.Message, e.ExceptionMessage)
+	}
+	if thrown := m.exceptions[0]; !strings.Contains(thrown.ExceptionMessage, "jane@example.com") {
+		t.Errorf("Expected exception grouping to see the message as logged, got %q", thrown.ExceptionMessage)
+	}
+}
+
+func TestParseLineLogEntry(t *testing.T) {
+	e := parseLineLogEntry([]string{"[2025-03-12T10:17:30.5+01:00] testing.NOTICE: Hello {} []"}, time.UTC)
+	if e == nil || e.Channel != "testing" || e.Level != "notice" || e.Message != "Hello" || e.Time.Hour() != 10 {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if e := parseLineLogEntry([]string{"#0 {main}"}, time.UTC); e != nil {
+		t.Errorf("Expected no entry for a stack trace line, got %+v", e)
+	}
+}
+
+func TestCollect_Logs(t *testing.T) {
+	site, php := writeScheduleSite(t)
+	appendLog(t, filepath.Join(site, "logs", "app.log"), "")
+	cfg := &config.Config{
+		PHP:     config.PHPConfig{Binary: php},
+		Laravel: []config.LaravelConfig{{Name: "App", Path: site, QueueBackend: QueueBackendNative, MonitorLogs: true, LogFiles: []string{"logs/*.log"}}},
+	}
+
+	result, _ := Collect(context.Background(), cfg)
+	if l := result["App"].Logs; l == nil || len(l.Files) != 1 {
+		t.Errorf("Expected the logs in the result, got %+v", l)
+	}
+
+	cfg.Laravel[0].LogFiles = []string{"logs/[.log"}
+	if _, errs := Collect(context.Background(), cfg); errs["laravel:App:logs"] == "" {
+		t.Errorf("Expected a logs error, got %v", errs)
+	}
+}
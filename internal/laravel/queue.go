@@ -7,7 +7,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+)
+
+const (
+	QueueBackendArtisan = "artisan" // Tinker script, works for every driver
+	QueueBackendNative  = "native"  // Reads supported drivers directly, the rest through artisan
+
+	QueueDriverRedis = "redis"
 )
 
 type QueueMetrics struct {
@@ -31,156 +37,150 @@ type QueueMetrics struct {
 
 type QueueSizes map[string]map[string]QueueMetrics
 
-func GetQueueSizes(appPath string, phpBinary string, queueMap map[string][]string) (*QueueSizes, error) {
-	if len(queueMap) == 0 {
-		return &QueueSizes{}, nil
-	}
-
-	script := `use Illuminate\Queue\QueueManager;
+// queueSizesScript reads the queues of the ELASTICPHP_QUEUES JSON map of
+// connection to queue names.
+const queueSizesScript = `use Illuminate\Queue\QueueManager;
 use Illuminate\Queue\Failed\FailedJobProviderInterface;
 use Carbon\Carbon;
 
 $manager = app(QueueManager::class);
 $failedJobsProvider = app(FailedJobProviderInterface::class);
 $now = now();
-$sizes = [];`
+$sizes = [];
+$queueMap = json_decode(getenv('ELASTICPHP_QUEUES'), true, 512, JSON_THROW_ON_ERROR);
 
-	for conn, queues := range queueMap {
-		quoted := make([]string, len(queues))
-		for i, q := range queues {
-			quoted[i] = fmt.Sprintf(`'%[1]s'`, q)
-		}
-		queueList := fmt.Sprintf("[%[1]s]", strings.Join(quoted, ", "))
-
-		script += fmt.Sprintf(`
-foreach (%[2]s as $q) {
-	try {
-		$sizes["%[1]s"][$q] = ['size' => null, 'pending' => null, 'delayed' => null, 'oldest_pending' => null, 'failed' => null, 'failed_rate' => null, 'failed_avg_time' => null];
-		$connection = $manager->connection("%[1]s");
-		if ($connection instanceof Illuminate\Queue\DatabaseQueue) {
-			$sizes["%[1]s"][$q]['driver'] = "database";
-			try {
-				$db = $connection->getDatabase();
-				$reflection = new ReflectionClass($connection);
-				$property = $reflection->getProperty('table');
-				$property->setAccessible(true);
-				$table = $property->getValue($connection);
-				$oldestPending = $db->table($table)->where("queue", $q)->whereNull("reserved_at")->orderBy("created_at")->value("created_at");
-
-				$sizes["%[1]s"][$q]['pending'] = $db->table($table)->where("queue", $q)->whereNull("reserved_at")->where("available_at", "<=", $now->timestamp)->count();
-				$sizes["%[1]s"][$q]['scheduled'] = $db->table($table)->where("queue", $q)->where("available_at", ">", $now->timestamp)->count();
-				$sizes["%[1]s"][$q]['reserved'] = $db->table($table)->where("queue", $q)->whereNotNull("reserved_at")->count();
-				$sizes["%[1]s"][$q]['oldest_pending'] = $oldestPending ? (int) now()->diffInSeconds(Carbon::createFromTimestamp($oldestPending), true) : null;
-			} catch (\Throwable $e) {
-				$sizes["%[1]s"][$q]['error'] = $e->getMessage();
+foreach ($queueMap as $conn => $queues) {
+	foreach ($queues ?? [] as $q) {
+		try {
+			$sizes[$conn][$q] = ['size' => null, 'pending' => null, 'delayed' => null, 'oldest_pending' => null, 'failed' => null, 'failed_rate' => null, 'failed_avg_time' => null];
+			$connection = $manager->connection($conn);
+			if ($connection instanceof Illuminate\Queue\DatabaseQueue) {
+				$sizes[$conn][$q]['driver'] = "database";
+				try {
+					$db = $connection->getDatabase();
+					$reflection = new ReflectionClass($connection);
+					$property = $reflection->getProperty('table');
+					$property->setAccessible(true);
+					$table = $property->getValue($connection);
+					$oldestPending = $db->table($table)->where("queue", $q)->whereNull("reserved_at")->orderBy("created_at")->value("created_at");
+
+					$sizes[$conn][$q]['pending'] = $db->table($table)->where("queue", $q)->whereNull("reserved_at")->where("available_at", "<=", $now->timestamp)->count();
+					$sizes[$conn][$q]['scheduled'] = $db->table($table)->where("queue", $q)->where("available_at", ">", $now->timestamp)->count();
+					$sizes[$conn][$q]['reserved'] = $db->table($table)->where("queue", $q)->whereNotNull("reserved_at")->count();
+					$sizes[$conn][$q]['oldest_pending'] = $oldestPending ? (int) now()->diffInSeconds(Carbon::createFromTimestamp($oldestPending), true) : null;
+				} catch (\Throwable $e) {
+					$sizes[$conn][$q]['error'] = $e->getMessage();
+				}
 			}
-		}
-		if ($connection instanceof Illuminate\Queue\RedisQueue) {
-			$sizes["%[1]s"][$q]['driver'] = "redis";
-			try {
-				$redis = $connection->getConnection();
-				$queueKey = $connection->getQueue($q);
+			if ($connection instanceof Illuminate\Queue\RedisQueue) {
+				$sizes[$conn][$q]['driver'] = "redis";
+				try {
+					$redis = $connection->getConnection();
+					$queueKey = $connection->getQueue($q);
 	
-				$sizes["%[1]s"][$q]['size'] = $redis->llen($queueKey);
-				$sizes["%[1]s"][$q]['pending'] = $redis->llen($queueKey);
-				$sizes["%[1]s"][$q]['scheduled'] = $redis->zcard($queueKey.':delayed');
-				$sizes["%[1]s"][$q]['reserved'] = $redis->zcard($queueKey.':reserved');
+					$sizes[$conn][$q]['size'] = $redis->llen($queueKey);
+					$sizes[$conn][$q]['pending'] = $redis->llen($queueKey);
+					$sizes[$conn][$q]['scheduled'] = $redis->zcard($queueKey.':delayed');
+					$sizes[$conn][$q]['reserved'] = $redis->zcard($queueKey.':reserved');
 	
-				$oldestRaw = $redis->lindex($queueKey, 0);
-				if ($oldestRaw) {
-					$decoded = json_decode($oldestRaw, true);
-					if (isset($decoded['createdAt'])) {
-						$sizes["%[1]s"][$q]['oldest_pending'] = $decoded['createdAt'] ? (int) Carbon::createFromTimestamp($decoded['createdAt'])->diffInSeconds($now, true) : null;
+					$oldestRaw = $redis->lindex($queueKey, 0);
+					if ($oldestRaw) {
+						$decoded = json_decode($oldestRaw, true);
+						if (isset($decoded['createdAt'])) {
+							$sizes[$conn][$q]['oldest_pending'] = $decoded['createdAt'] ? (int) Carbon::createFromTimestamp($decoded['createdAt'])->diffInSeconds($now, true) : null;
+						}
 					}
+				} catch (\Throwable $e) {
+					$sizes[$conn][$q]['error'] = $e->getMessage();
 				}
-			} catch (\Throwable $e) {
-				$sizes["%[1]s"][$q]['error'] = $e->getMessage();
 			}
-		}
-		$sizes["%[1]s"][$q]['size'] = $manager->connection("%[1]s")->size($q);
+			$sizes[$conn][$q]['size'] = $manager->connection($conn)->size($q);
 
-		try {
-			if ($failedJobsProvider instanceof Illuminate\Queue\Failed\DatabaseFailedJobProvider
-				|| $failedJobsProvider instanceof Illuminate\Queue\Failed\DatabaseUuidFailedJobProvider) {
+			try {
+				if ($failedJobsProvider instanceof Illuminate\Queue\Failed\DatabaseFailedJobProvider
+					|| $failedJobsProvider instanceof Illuminate\Queue\Failed\DatabaseUuidFailedJobProvider) {
 			
-				$failedProviderReflection = new ReflectionClass($failedJobsProvider);
-				$method = $failedProviderReflection->getMethod('getTable');
-				$method->setAccessible(true);
-				$query = $method->invoke($failedJobsProvider);
-
-				$minutes = [1, 5, 10];
-				$failed = [];
-				$failedRates = [];
+					$failedProviderReflection = new ReflectionClass($failedJobsProvider);
+					$method = $failedProviderReflection->getMethod('getTable');
+					$method->setAccessible(true);
+					$query = $method->invoke($failedJobsProvider);
+
+					$minutes = [1, 5, 10];
+					$failed = [];
+					$failedRates = [];
 			
-				$baseQuery = $query->where('connection', '%[1]s')->where('queue', $q);;
+					$baseQuery = $query->where('connection', $conn)->where('queue', $q);;
 	
-				foreach ($minutes as $min) {
-					$from = now()->subMinutes($min);
+					foreach ($minutes as $min) {
+						$from = now()->subMinutes($min);
 			
-					$count = (clone $baseQuery)
-						->where('failed_at', '>=', $from)
-						->count();
+						$count = (clone $baseQuery)
+							->where('failed_at', '>=', $from)
+							->count();
 			
-					$failed[$min] = $count;
-					$failedRates[$min] = round($count / $min, 2);
-				}
+						$failed[$min] = $count;
+						$failedRates[$min] = round($count / $min, 2);
+					}
 	
-				$oldestFailed = (clone $baseQuery)
-					->whereNotNull('failed_at')
-					->orderBy('failed_at', 'asc')
-					->value('failed_at');
+					$oldestFailed = (clone $baseQuery)
+						->whereNotNull('failed_at')
+						->orderBy('failed_at', 'asc')
+						->value('failed_at');
 			
-				$newestFailed = (clone $baseQuery)
-					->whereNotNull('failed_at')
-					->orderBy('failed_at', 'desc')
-					->value('failed_at');
+					$newestFailed = (clone $baseQuery)
+						->whereNotNull('failed_at')
+						->orderBy('failed_at', 'desc')
+						->value('failed_at');
 			
-				$sizes["%[1]s"][$q]['failed'] = (clone $baseQuery)->count() ?? null;
-				$sizes["%[1]s"][$q]['failed_rate_1m'] = $failedRates[1] ?? null;
-				$sizes["%[1]s"][$q]['failed_rate_5m'] = $failedRates[5] ?? null;
-				$sizes["%[1]s"][$q]['failed_rate_10m'] = $failedRates[10] ?? null;
-				$sizes["%[1]s"][$q]['failed_1m'] = $failed[1] ?? null;
-				$sizes["%[1]s"][$q]['failed_5m'] = $failed[5] ?? null;
-				$sizes["%[1]s"][$q]['failed_10m'] = $failed[10] ?? null;
-				$sizes["%[1]s"][$q]['oldest_failed'] = $oldestFailed ? (int) Carbon::parse($oldestFailed)->diffInSeconds($now, true) : null;
-				$sizes["%[1]s"][$q]['newest_failed'] = $newestFailed ? (int) Carbon::parse($newestFailed)->diffInSeconds($now, true) : null;
+					$sizes[$conn][$q]['failed'] = (clone $baseQuery)->count() ?? null;
+					$sizes[$conn][$q]['failed_rate_1m'] = $failedRates[1] ?? null;
+					$sizes[$conn][$q]['failed_rate_5m'] = $failedRates[5] ?? null;
+					$sizes[$conn][$q]['failed_rate_10m'] = $failedRates[10] ?? null;
+					$sizes[$conn][$q]['failed_1m'] = $failed[1] ?? null;
+					$sizes[$conn][$q]['failed_5m'] = $failed[5] ?? null;
+					$sizes[$conn][$q]['failed_10m'] = $failed[10] ?? null;
+					$sizes[$conn][$q]['oldest_failed'] = $oldestFailed ? (int) Carbon::parse($oldestFailed)->diffInSeconds($now, true) : null;
+					$sizes[$conn][$q]['newest_failed'] = $newestFailed ? (int) Carbon::parse($newestFailed)->diffInSeconds($now, true) : null;
 			
-			} else {
-				$sizes["%[1]s"][$q]['error'] = "Unknown class ". $failedJobsProvider;
+				} else {
+					$sizes[$conn][$q]['error'] = "Unknown class ". $failedJobsProvider;
+				}
+			} catch (\Throwable $e) {
+				$sizes[$conn][$q]['error'] = $e->getMessage();
 			}
+		
 		} catch (\Throwable $e) {
-			$sizes["%[1]s"][$q]['error'] = $e->getMessage();
+			$sizes[$conn][$q]['size'] = null;
+			$sizes[$conn][$q]['error'] = $e->getMessage();
 		}
-		
-	} catch (\Throwable $e) {
-		$sizes["%[1]s"][$q]['size'] = null;
-		$sizes["%[1]s"][$q]['error'] = $e->getMessage();
 	}
-}`, conn, queueList)
-	}
-
-	script += `
+}
 
 echo json_encode($sizes);`
 
-	cmd := exec.Command(phpBinary, "-d", "error_reporting=E_ALL & ~E_DEPRECATED", "artisan", "tinker", "--execute", script)
+func GetQueueSizes(appPath string, phpBinary string, queueMap map[string][]string) (*QueueSizes, error) {
+	if len(queueMap) == 0 {
+		return &QueueSizes{}, nil
+	}
+
+	// Queue names may come from discovery, i.e. from job payloads, so they
+	// are passed as data and never become part of the script
+	queues, err := json.Marshal(queueMap)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(phpBinary, "-d", "error_reporting=E_ALL & ~E_DEPRECATED", "artisan", "tinker", "--execute", queueSizesScript)
 	cmd.Dir = filepath.Clean(appPath)
 
 	// disable monitoring on scraping to prevent exhausting monitoring tools
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "NIGHTWATCH_ENABLED=false")
-	cmd.Env = append(cmd.Env, "TELESCOPE_ENABLED=false")
-	cmd.Env = append(cmd.Env, "NEW_RELIC_ENABLED=false")
-	cmd.Env = append(cmd.Env, "BUGSNAG_API_KEY=null")
-	cmd.Env = append(cmd.Env, "SENTRY_LARAVEL_DSN=null")
-	cmd.Env = append(cmd.Env, "ROLLBAR_TOKEN=null")
+	cmd.Env = artisanEnv("ELASTICPHP_QUEUES=" + string(queues))
 
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
 
-	err := cmd.Run()
-	if err != nil {
+	if err := cmd.Run(); err != nil {
 		return nil, fmt.Errorf("artisan tinker failed: %w\nOutput: %s", err, out.String())
 	}
 
@@ -191,3 +191,17 @@ echo json_encode($sizes);`
 
 	return &result, nil
 }
+
+// artisanEnv returns the environment for artisan commands run by the agent,
+// with the monitoring and error reporting tools disabled, followed by extra.
+func artisanEnv(extra ...string) []string {
+	env := append(os.Environ(),
+		"NIGHTWATCH_ENABLED=false",
+		"TELESCOPE_ENABLED=false",
+		"NEW_RELIC_ENABLED=false",
+		"BUGSNAG_API_KEY=null",
+		"SENTRY_LARAVEL_DSN=null",
+		"ROLLBAR_TOKEN=null",
+	)
+	return append(env, extra...)
+}
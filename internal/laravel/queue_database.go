@@ -0,0 +1,365 @@
+package laravel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/laravel/siteconfig"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	QueueDriverDatabase = "database"
+
+	defaultDBTimeout = 2 * time.Second
+)
+
+var (
+	errUnsupportedDatabase = errors.New("unsupported database connection")
+
+	siteDatabases     = map[string]*siteDB{}
+	siteDatabasesLock sync.Mutex
+
+	tableNamePattern   = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)?$`)
+	tablePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+
+	failedWindows = []int{1, 5, 10}
+)
+
+// siteDB is an open database connection of a site.
+type siteDB struct {
+	db     *sql.DB
+	driver string // database/sql driver name: mysql, pgx or sqlite
+	loc    *time.Location
+	prefix string // Table prefix of the connection
+	config *siteconfig.Config
+}
+
+// GetDatabaseQueueSizes runs the pending, scheduled, reserved and oldest
+// pending queries of Laravel's DatabaseQueue directly against the database
+// of each queue connection, plus the failed job counts. A connection that
+// fails has the error set on its queues. Connections that can't be read
+// natively, e.g. SQL Server, are left out and returned separately, their
+// queues should then go through artisan.
+func GetDatabaseQueueSizes(ctx context.Context, site config.LaravelConfig, sc *siteconfig.Config, queueMap map[string][]string) (QueueSizes, map[string][]string) {
+	now := time.Now()
+	sizes := QueueSizes{}
+	unsupported := map[string][]string{}
+	for conn, queues := range queueMap {
+		qc := sc.Queue.Connections[conn]
+		sdb, err := openSiteDatabase(sc, qc.Connection.Or(sc.Database.Default.String()))
+		if errors.Is(err, errUnsupportedDatabase) {
+			unsupported[conn] = queues
+			continue
+		}
+		var table string
+		if err == nil {
+			table, err = sdb.table(site.JobsTable, qc.Table.Or("jobs"))
+		}
+
+		sizes[conn] = map[string]QueueMetrics{}
+		for _, q := range queues {
+			if err != nil {
+				sizes[conn][q] = QueueMetrics{Driver: strPtr(QueueDriverDatabase), ParseError: err.Error()}
+				continue
+			}
+			sizes[conn][q] = sdb.queueMetrics(ctx, table, q, now)
+		}
+	}
+
+	FillFailedJobs(ctx, site, sc, sizes)
+	return sizes, unsupported
+}
+
+// FillFailedJobs adds the failed job counts from the failed_jobs table to
+// natively read queues. Queues are left as they are when failed jobs are not
+// stored in a database the agent can read.
+func FillFailedJobs(ctx context.Context, site config.LaravelConfig, sc *siteconfig.Config, sizes QueueSizes) {
+	failed := sc.Queue.Failed
+	if !strings.HasPrefix(failed.Driver.String(), "database") {
+		return
+	}
+	sdb, err := openSiteDatabase(sc, failed.Database.Or(sc.Database.Default.String()))
+	if err != nil {
+		return
+	}
+	table, err := sdb.table(site.FailedJobsTable, failed.Table.Or("failed_jobs"))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for conn, queues := range sizes {
+		for q, m := range queues {
+			if m.ParseError == nil {
+				sdb.failedMetrics(ctx, table, conn, q, now, &m)
+				queues[q] = m
+			}
+		}
+	}
+}
+
+func (s *siteDB) queueMetrics(ctx context.Context, table, queue string, now time.Time) QueueMetrics {
+	m := QueueMetrics{Driver: strPtr(QueueDriverDatabase)}
+	ts := now.Unix()
+
+	counts := []struct {
+		target **int
+		where  string
+		args   []any
+	}{
+		{&m.Size, "queue = ?", []any{queue}},
+		{&m.Pending, "queue = ? AND reserved_at IS NULL AND available_at <= ?", []any{queue, ts}},
+		{&m.Scheduled, "queue = ? AND available_at > ?", []any{queue, ts}},
+		{&m.Reserved, "queue = ? AND reserved_at IS NOT NULL", []any{queue}},
+	}
+	for _, c := range counts {
+		n, err := s.count(ctx, table, c.where, c.args...)
+		if err != nil {
+			m.ParseError = err.Error()
+			return m
+		}
+		*c.target = &n
+	}
+
+	var oldest sql.NullInt64
+	query := "SELECT MIN(created_at) FROM " + table + " WHERE queue = ? AND reserved_at IS NULL"
+	if err := s.db.QueryRowContext(ctx, s.rebind(query), queue).Scan(&oldest); err != nil {
+		m.ParseError = err.Error()
+		return m
+	}
+	if oldest.Valid {
+		age := int(now.Sub(time.Unix(oldest.Int64, 0)).Abs().Seconds())
+		m.OldestPending = &age
+	}
+	return m
+}
+
+func (s *siteDB) failedMetrics(ctx context.Context, table, connection, queue string, now time.Time, m *QueueMetrics) {
+	where := "connection = ? AND queue = ?"
+
+	failed, err := s.count(ctx, table, where, connection, queue)
+	if err != nil {
+		m.ParseError = err.Error()
+		return
+	}
+	m.Failed = &failed
+
+	for _, minutes := range failedWindows {
+		// failed_at is written in the app timezone
+		from := now.Add(-time.Duration(minutes) * time.Minute).In(s.loc).Format(time.DateTime)
+		n, err := s.count(ctx, table, where+" AND failed_at >= ?", connection, queue, from)
+		if err != nil {
+			m.ParseError = err.Error()
+			return
+		}
+		rate := float32(math.Round(float64(n)/float64(minutes)*100) / 100)
+		switch minutes {
+		case 1:
+			m.Failed1Min, m.FailedRate1Min = &n, &rate
+		case 5:
+			m.Failed5Min, m.FailedRate5Min = &n, &rate
+		case 10:
+			m.Failed10Min, m.FailedRate10Min = &n, &rate
+		}
+	}
+
+	var oldest, newest any
+	query := "SELECT MIN(failed_at), MAX(failed_at) FROM " + table + " WHERE " + where
+	if err := s.db.QueryRowContext(ctx, s.rebind(query), connection, queue).Scan(&oldest, &newest); err != nil {
+		m.ParseError = err.Error()
+		return
+	}
+	m.OldestFailed = s.ageOf(oldest, now)
+	m.NewestFailed = s.ageOf(newest, now)
+}
+
+func (s *siteDB) count(ctx context.Context, table, where string, args ...any) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, s.rebind("SELECT COUNT(*) FROM "+table+" WHERE "+where), args...).Scan(&n)
+	return n, err
+}
+
+// rebind switches ? placeholders to the $n form Postgres expects.
+func (s *siteDB) rebind(query string) string {
+	if s.driver != "pgx" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ageOf returns the seconds since a timestamp column value, which drivers
+// return as time.Time, string or []byte depending on the column type.
+func (s *siteDB) ageOf(v any, now time.Time) *int {
+	var t time.Time
+	switch val := v.(type) {
+	case time.Time:
+		// Drivers read timestamps without zone as UTC, they are in the app timezone
+		t = time.Date(val.Year(), val.Month(), val.Day(), val.Hour(), val.Minute(), val.Second(), val.Nanosecond(), s.loc)
+	case []byte:
+		return s.ageOf(string(val), now)
+	case string:
+		var err error
+		for _, layout := range []string{time.DateTime, "2006-01-02 15:04:05.999999", time.RFC3339Nano} {
+			if t, err = time.ParseInLocation(layout, val, s.loc); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	age := int(now.Sub(t).Abs().Seconds())
+	return &age
+}
+
+// openSiteDatabase opens a database connection of a site once and keeps the
+// pool for later scrapes, until the site config changes.
+func openSiteDatabase(sc *siteconfig.Config, name string) (*siteDB, error) {
+	siteDatabasesLock.Lock()
+	defer siteDatabasesLock.Unlock()
+
+	key := sc.Path + "|" + name
+	if sdb, ok := siteDatabases[key]; ok {
+		if sdb.config == sc {
+			return sdb, nil
+		}
+		sdb.db.Close()
+		delete(siteDatabases, key)
+	}
+
+	conn, ok := sc.Database.Connections[name]
+	if !ok {
+		return nil, fmt.Errorf("database connection [%s] not configured", name)
+	}
+	driver, dsn, err := databaseDSN(sc.Path, conn)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxIdleTime(time.Minute)
+
+	sdb := &siteDB{db: db, driver: driver, loc: sc.Location(), prefix: conn.Prefix.String(), config: sc}
+	siteDatabases[key] = sdb
+	return sdb, nil
+}
+
+// databaseDSN builds the database/sql driver name and DSN of a connection
+// from config/database.php, where a url takes precedence over the separate
+// settings.
+func databaseDSN(appPath string, conn siteconfig.DatabaseConnection) (string, string, error) {
+	var u *url.URL
+	if raw := conn.URL.String(); raw != "" {
+		var err error
+		if u, err = url.Parse(raw); err != nil {
+			return "", "", fmt.Errorf("invalid database url: %w", err)
+		}
+	}
+	host, port := conn.Host.Or("127.0.0.1"), conn.Port.String()
+	user, pass := conn.Username.String(), conn.Password.String()
+	database := conn.Database.String()
+	if u != nil {
+		if u.Hostname() != "" {
+			host = u.Hostname()
+		}
+		if u.Port() != "" {
+			port = u.Port()
+		}
+		if u.User != nil {
+			user = u.User.Username()
+			pass, _ = u.User.Password()
+		}
+		if db := strings.Trim(u.Path, "/"); db != "" {
+			database = db
+		}
+	}
+
+	switch driver := conn.Driver.String(); driver {
+	case "mysql", "mariadb":
+		cfg := mysql.NewConfig()
+		cfg.User, cfg.Passwd, cfg.DBName = user, pass, database
+		cfg.Net, cfg.Addr = "tcp", net.JoinHostPort(host, firstNonEmpty(port, "3306"))
+		if socket := conn.Socket.String(); socket != "" {
+			cfg.Net, cfg.Addr = "unix", socket
+		}
+		cfg.Timeout = defaultDBTimeout
+		return "mysql", cfg.FormatDSN(), nil
+	case "pgsql":
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(user, pass),
+			Host:     net.JoinHostPort(host, firstNonEmpty(port, "5432")),
+			Path:     "/" + database,
+			RawQuery: url.Values{"sslmode": {conn.SSLMode.Or("prefer")}, "connect_timeout": {"2"}}.Encode(),
+		}
+		return "pgx", dsn.String(), nil
+	case "sqlite":
+		path := firstNonEmpty(conn.Database.String(), filepath.Join("database", "database.sqlite"))
+		if u != nil {
+			path = u.Path
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(appPath, path)
+		}
+		return "sqlite", "file:" + path + "?mode=ro&_pragma=busy_timeout(2000)", nil
+	default:
+		return "", "", fmt.Errorf("%w: %s", errUnsupportedDatabase, driver)
+	}
+}
+
+// table returns the configured table name, or the one from the site config,
+// with the connection's prefix added the way Laravel does: to the table and
+// not the schema of a schema-qualified name. Anything that isn't a plain
+// identifier is rejected.
+func (s *siteDB) table(configured, fallback string) (string, error) {
+	name := firstNonEmpty(configured, fallback)
+	if !tableNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid table name %q", name)
+	}
+	if !tablePrefixPattern.MatchString(s.prefix) {
+		return "", fmt.Errorf("invalid table prefix %q", s.prefix)
+	}
+	if schema, table, ok := strings.Cut(name, "."); ok {
+		return schema + "." + s.prefix + table, nil
+	}
+	return s.prefix + name, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,286 @@
+package laravel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/laravel/siteconfig"
+)
+
+// writeSQLiteSite creates a site using SQLite with Laravel's jobs and
+// failed_jobs tables.
+func writeSQLiteSite(t *testing.T, env string, statements ...string) string {
+	t.Helper()
+
+	site := writeSite(t, "DB_CONNECTION=sqlite\n"+env)
+	if err := os.MkdirAll(filepath.Join(site, "database"), 0o755); err != nil {
+		t.Fatalf("Failed to create database dir: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(site, "database", "database.sqlite"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schema := []string{
+		`CREATE TABLE jobs (id INTEGER PRIMARY KEY, queue VARCHAR NOT NULL, payload TEXT NOT NULL, attempts INTEGER NOT NULL,
+			reserved_at INTEGER, available_at INTEGER NOT NULL, created_at INTEGER NOT NULL)`,
+		`CREATE TABLE failed_jobs (id INTEGER PRIMARY KEY, uuid VARCHAR NOT NULL, connection TEXT NOT NULL, queue TEXT NOT NULL,
+			payload TEXT NOT NULL, exception TEXT NOT NULL, failed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+	}
+	for _, stmt := range append(schema, statements...) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to run %q: %v", stmt, err)
+		}
+	}
+	return site
+}
+
+func TestGetDatabaseQueueSizes(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) string { return now.Add(-d).UTC().Format(time.DateTime) }
+	unix := func(d time.Duration) string { return strconv.FormatInt(now.Add(d).Unix(), 10) }
+
+	site := writeSQLiteSite(t, "",
+		"INSERT INTO jobs (queue, payload, attempts, reserved_at, available_at, created_at) VALUES"+
+			" ('default', '{}', 0, NULL, "+unix(-2*time.Minute)+", "+unix(-2*time.Minute)+"),"+
+			" ('default', '{}', 0, NULL, "+unix(-30*time.Second)+", "+unix(-30*time.Second)+"),"+
+			" ('default', '{}', 0, NULL, "+unix(10*time.Minute)+", "+unix(-time.Minute)+"),"+
+			" ('default', '{}', 1, "+unix(-5*time.Second)+", "+unix(-time.Hour)+", "+unix(-time.Hour)+"),"+
+			" ('emails', '{}', 0, NULL, "+unix(-time.Minute)+", "+unix(-time.Minute)+")",
+		"INSERT INTO failed_jobs (uuid, connection, queue, payload, exception, failed_at) VALUES"+
+			" ('a', 'database', 'default', '{}', 'E', '"+ago(30*time.Second)+"'),"+
+			" ('b', 'database', 'default', '{}', 'E', '"+ago(4*time.Minute)+"'),"+
+			" ('c', 'database', 'default', '{}', 'E', '"+ago(2*time.Hour)+"'),"+
+			" ('d', 'redis', 'default', '{}', 'E', '"+ago(time.Second)+"')",
+	)
+
+	sizes, unsupported := GetDatabaseQueueSizes(context.Background(), config.LaravelConfig{Path: site}, loadSite(t, site), map[string][]string{"database": {"default"}})
+	if len(unsupported) > 0 {
+		t.Fatalf("Unexpected unsupported connections: %v", unsupported)
+	}
+
+	m := sizes["database"]["default"]
+	if m.ParseError != nil {
+		t.Fatalf("Unexpected error: %v", m.ParseError)
+	}
+	if *m.Driver != "database" || *m.Size != 4 || *m.Pending != 2 || *m.Scheduled != 1 || *m.Reserved != 1 {
+		t.Errorf("Unexpected queue metrics size=%d pending=%d scheduled=%d reserved=%d", *m.Size, *m.Pending, *m.Scheduled, *m.Reserved)
+	}
+	if m.OldestPending == nil || *m.OldestPending < 119 || *m.OldestPending > 125 {
+		t.Errorf("Expected the oldest pending job to be about 2m old, got %v", m.OldestPending)
+	}
+
+	if *m.Failed != 3 || *m.Failed1Min != 1 || *m.Failed5Min != 2 || *m.Failed10Min != 2 {
+		t.Errorf("Unexpected failed counts total=%d 1m=%d 5m=%d 10m=%d", *m.Failed, *m.Failed1Min, *m.Failed5Min, *m.Failed10Min)
+	}
+	if *m.FailedRate1Min != 1 || *m.FailedRate5Min != 0.4 || *m.FailedRate10Min != 0.2 {
+		t.Errorf("Unexpected failed rates %v %v %v", *m.FailedRate1Min, *m.FailedRate5Min, *m.FailedRate10Min)
+	}
+	if m.OldestFailed == nil || *m.OldestFailed < 7199 || *m.OldestFailed > 7205 {
+		t.Errorf("Expected the oldest failure to be about 2h old, got %v", m.OldestFailed)
+	}
+	if m.NewestFailed == nil || *m.NewestFailed < 29 || *m.NewestFailed > 35 {
+		t.Errorf("Expected the newest failure to be about 30s old, got %v", m.NewestFailed)
+	}
+}
+
+func TestGetDatabaseQueueSizes_Tables(t *testing.T) {
+	site := writeSQLiteSite(t, "",
+		"CREATE TABLE queue_jobs AS SELECT * FROM jobs",
+		"INSERT INTO queue_jobs (queue, payload, attempts, available_at, created_at) VALUES ('default', '{}', 0, 0, 0)",
+	)
+
+	sc := loadSite(t, site)
+	cfg := config.LaravelConfig{Path: site, JobsTable: "queue_jobs", FailedJobsTable: "missing_failed_jobs"}
+	sizes, _ := GetDatabaseQueueSizes(context.Background(), cfg, sc, map[string][]string{"database": {"default"}})
+	m := sizes["database"]["default"]
+	if m.Size == nil || *m.Size != 1 {
+		t.Errorf("Expected the configured jobs table to be read, got %+v", m)
+	}
+	if m.ParseError == nil || !strings.Contains(m.ParseError.(string), "missing_failed_jobs") {
+		t.Errorf("Expected an error for the missing failed jobs table, got %v", m.ParseError)
+	}
+
+	cfg.JobsTable = "jobs; DROP TABLE jobs"
+	sizes, _ = GetDatabaseQueueSizes(context.Background(), cfg, sc, map[string][]string{"database": {"default"}})
+	if m := sizes["database"]["default"]; m.ParseError == nil || !strings.Contains(m.ParseError.(string), "invalid table name") {
+		t.Errorf("Expected an invalid table name to be rejected, got %+v", m)
+	}
+}
+
+func TestGetDatabaseQueueSizes_Prefix(t *testing.T) {
+	site := writeSQLiteSite(t, "",
+		"CREATE TABLE app_jobs AS SELECT * FROM jobs",
+		"CREATE TABLE app_failed_jobs AS SELECT * FROM failed_jobs",
+		"INSERT INTO app_jobs (queue, payload, attempts, available_at, created_at) VALUES ('default', '{}', 0, 0, 0), ('default', '{}', 0, 0, 0)",
+		"INSERT INTO app_failed_jobs (uuid, connection, queue, payload, exception) VALUES ('a', 'database', 'default', '{}', 'E')",
+	)
+	sc := loadSite(t, site)
+	conn := sc.Database.Connections["sqlite"]
+	conn.Prefix = "app_"
+	sc.Database.Connections["sqlite"] = conn
+
+	sizes, _ := GetDatabaseQueueSizes(context.Background(), config.LaravelConfig{Path: site}, sc, map[string][]string{"database": {"default"}})
+	m := sizes["database"]["default"]
+	if m.ParseError != nil {
+		t.Fatalf("Unexpected error: %v", m.ParseError)
+	}
+	if *m.Size != 2 || *m.Failed != 1 {
+		t.Errorf("Expected the prefixed tables to be read, got size=%d failed=%d", *m.Size, *m.Failed)
+	}
+}
+
+func TestGetDatabaseQueueSizes_Connections(t *testing.T) {
+	site := writeSQLiteSite(t, "",
+		"INSERT INTO jobs (queue, payload, attempts, available_at, created_at) VALUES ('default', '{}', 0, 0, 0)",
+	)
+	sc := loadSite(t, site)
+	sc.Queue.Connections["mssql"] = siteconfig.QueueConnection{Driver: "database", Connection: "sqlsrv"}
+	sc.Queue.Connections["broken"] = siteconfig.QueueConnection{Driver: "database", Connection: "missing"}
+
+	queueMap := map[string][]string{"database": {"default"}, "mssql": {"default"}, "broken": {"default", "emails"}}
+	sizes, unsupported := GetDatabaseQueueSizes(context.Background(), config.LaravelConfig{Path: site}, sc, queueMap)
+	if !reflect.DeepEqual(unsupported, map[string][]string{"mssql": {"default"}}) {
+		t.Errorf("Expected only SQL Server to be unsupported, got %v", unsupported)
+	}
+	if _, ok := sizes["mssql"]; ok {
+		t.Errorf("Expected the unsupported connection to be left out")
+	}
+	if m := sizes["database"]["default"]; m.ParseError != nil || *m.Size != 1 {
+		t.Errorf("Expected the working connection to be read, got %+v", m)
+	}
+	for _, q := range []string{"default", "emails"} {
+		if m := sizes["broken"][q]; m.ParseError == nil || !strings.Contains(m.ParseError.(string), "missing") {
+			t.Errorf("Expected the connection error on %s, got %+v", q, m)
+		}
+	}
+}
+
+func TestSiteDB_Table(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		name     string
+		expected string
+	}{
+		{"", "jobs", "jobs"},
+		{"app_", "jobs", "app_jobs"},
+		{"app_", "queue.jobs", "queue.app_jobs"},
+		{"app_", "jobs; DROP TABLE jobs", ""},
+		{"app_; --", "jobs", ""},
+	}
+	for _, tt := range tests {
+		got, err := (&siteDB{prefix: tt.prefix}).table("", tt.name)
+		if got != tt.expected || (err != nil) != (tt.expected == "") {
+			t.Errorf("table(%q) with prefix %q = %q, %v, expected %q", tt.name, tt.prefix, got, err, tt.expected)
+		}
+	}
+}
+
+func TestDatabaseDSN(t *testing.T) {
+	tests := []struct {
+		name   string
+		conn   siteconfig.DatabaseConnection
+		driver string
+		dsn    string
+	}{
+		{
+			name:   "mysql",
+			conn:   siteconfig.DatabaseConnection{Driver: "mysql", Host: "db", Port: "3306", Database: "shop", Username: "shop", Password: "secret"},
+			driver: "mysql",
+			dsn:    "shop:secret@tcp(db:3306)/shop?timeout=2s",
+		},
+		{
+			name:   "mariadb over a socket",
+			conn:   siteconfig.DatabaseConnection{Driver: "mariadb", Database: "laravel", Username: "root", Socket: "/run/mysqld/mysqld.sock"},
+			driver: "mysql",
+			dsn:    "root@unix(/run/mysqld/mysqld.sock)/laravel?timeout=2s",
+		},
+		{
+			name:   "postgres from url",
+			conn:   siteconfig.DatabaseConnection{Driver: "pgsql", URL: "postgres://app:pw@pg.internal:6432/shop", Host: "ignored", SSLMode: "require"},
+			driver: "pgx",
+			dsn:    "postgres://app:pw@pg.internal:6432/shop?connect_timeout=2&sslmode=require",
+		},
+		{
+			name:   "sqlite relative path",
+			conn:   siteconfig.DatabaseConnection{Driver: "sqlite", Database: "storage/app.sqlite"},
+			driver: "sqlite",
+			dsn:    "file:/srv/app/storage/app.sqlite?mode=ro&_pragma=busy_timeout(2000)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver, dsn, err := databaseDSN("/srv/app", tt.conn)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if driver != tt.driver || dsn != tt.dsn {
+				t.Errorf("Expected %s %s, got %s %s", tt.driver, tt.dsn, driver, dsn)
+			}
+		})
+	}
+
+	if _, _, err := databaseDSN("/srv/app", siteconfig.DatabaseConnection{Driver: "sqlsrv"}); !errors.Is(err, errUnsupportedDatabase) {
+		t.Errorf("Expected SQL Server to be unsupported, got %v", err)
+	}
+}
+
+func TestOpenSiteDatabase(t *testing.T) {
+	site := writeSQLiteSite(t, "DB_QUEUE_CONNECTION=mysql\n")
+	sc := loadSite(t, site)
+
+	sdb, err := openSiteDatabase(sc, sc.Queue.Connections["database"].Connection.String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sdb.driver != "mysql" {
+		t.Errorf("Expected the queue connection's database to be used, got %s", sdb.driver)
+	}
+	if again, _ := openSiteDatabase(sc, "mysql"); again != sdb {
+		t.Errorf("Expected the connection to be reused")
+	}
+
+	if _, err := openSiteDatabase(sc, "missing"); err == nil {
+		t.Errorf("Expected an error for an unknown connection")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT COUNT(*) FROM jobs WHERE queue = ? AND available_at <= ?"
+
+	if got := (&siteDB{driver: "pgx"}).rebind(query); got != "SELECT COUNT(*) FROM jobs WHERE queue = $1 AND available_at <= $2" {
+		t.Errorf("Unexpected Postgres query %q", got)
+	}
+	if got := (&siteDB{driver: "mysql"}).rebind(query); got != query {
+		t.Errorf("Expected the query to be unchanged for MySQL, got %q", got)
+	}
+}
+
+func TestCollectQueues_DatabaseFallback(t *testing.T) {
+	site := config.LaravelConfig{
+		Path:         writeSite(t, "DB_CONNECTION=sqlsrv\n"),
+		Queues:       map[string][]string{"database": {"default"}},
+		QueueBackend: QueueBackendNative,
+	}
+
+	// Unsupported connections go through artisan, which fails without an app
+	sizes, err := collectQueues(context.Background(), site, "php", loadSite(t, site.Path))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m := (*sizes)["database"]["default"]; m.ParseError == nil || !strings.Contains(m.ParseError.(string), "artisan") {
+		t.Errorf("Expected the artisan fallback to be used, got %+v", m)
+	}
+}
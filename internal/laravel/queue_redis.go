@@ -0,0 +1,160 @@
+package laravel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/laravel/siteconfig"
+)
+
+// redisOptions are the settings of the Redis connection a site's queues use.
+type redisOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	DB       int
+	TLS      bool
+	Prefix   string // Key prefix the PHP client adds, e.g. laravel_database_
+}
+
+// GetRedisQueueSizes reads Redis queues directly with LLEN, ZCARD and LINDEX
+// on the keys Laravel's RedisQueue uses, without booting the framework. The
+// Redis connection of each queue connection comes from the site config.
+// Failed job counts live in the failed_jobs table, see FillFailedJobs.
+func GetRedisQueueSizes(ctx context.Context, site *siteconfig.Config, queueMap map[string][]string) QueueSizes {
+	sizes := QueueSizes{}
+	clients := map[string]*respClient{}
+	defer func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}()
+
+	for conn, queues := range queueMap {
+		sizes[conn] = map[string]QueueMetrics{}
+
+		name := site.Queue.Connections[conn].Connection.Or("default")
+		opts, err := redisOptionsFor(site, name)
+		client := clients[name]
+		if err == nil && client == nil {
+			if client, err = dialRedis(ctx, opts); err == nil {
+				clients[name] = client
+			} else {
+				err = fmt.Errorf("redis connect: %w", err)
+			}
+		}
+
+		for _, q := range queues {
+			if err != nil {
+				sizes[conn][q] = QueueMetrics{Driver: strPtr(QueueDriverRedis), ParseError: err.Error()}
+				continue
+			}
+			sizes[conn][q] = redisQueueMetrics(client, opts.Prefix, q, time.Now())
+		}
+	}
+	return sizes
+}
+
+func redisQueueMetrics(c *respClient, prefix, queue string, now time.Time) QueueMetrics {
+	m := QueueMetrics{Driver: strPtr(QueueDriverRedis)}
+	key := prefix + "queues:" + queue
+
+	pending, err := c.doInt("LLEN", key)
+	if err != nil {
+		m.ParseError = err.Error()
+		return m
+	}
+	delayed, err := c.doInt("ZCARD", key+":delayed")
+	if err != nil {
+		m.ParseError = err.Error()
+		return m
+	}
+	reserved, err := c.doInt("ZCARD", key+":reserved")
+	if err != nil {
+		m.ParseError = err.Error()
+		return m
+	}
+
+	// Same as RedisQueue::size()
+	size := pending + delayed + reserved
+	m.Size, m.Pending, m.Scheduled, m.Reserved = &size, &pending, &delayed, &reserved
+
+	// Jobs are pushed with RPUSH and popped from the head, so index 0 is the oldest
+	if raw, err := c.do("LINDEX", key, "0"); err == nil {
+		if payload, ok := raw.([]byte); ok {
+			var job struct {
+				CreatedAt int64 `json:"createdAt"`
+			}
+			if json.Unmarshal(payload, &job) == nil && job.CreatedAt > 0 {
+				age := int(now.Sub(time.Unix(job.CreatedAt, 0)).Abs().Seconds())
+				m.OldestPending = &age
+			}
+		}
+	}
+	return m
+}
+
+// redisOptionsFor resolves a connection of the redis array in
+// config/database.php, where a url takes precedence over the separate
+// settings.
+func redisOptionsFor(site *siteconfig.Config, name string) (redisOptions, error) {
+	conn, ok := site.Database.Redis.Connections[name]
+	if !ok {
+		return redisOptions{}, fmt.Errorf("redis connection [%s] not configured", name)
+	}
+
+	opts := redisOptions{
+		Host:     conn.Host.Or("127.0.0.1"),
+		Username: conn.Username.String(),
+		Password: conn.Password.String(),
+		TLS:      strings.EqualFold(conn.Scheme.String(), "tls"),
+		Prefix:   site.Database.Redis.Prefix.String(),
+	}
+
+	var err error
+	if opts.Port, err = strconv.Atoi(conn.Port.Or("6379")); err != nil {
+		return opts, fmt.Errorf("invalid redis port: %w", err)
+	}
+	if opts.DB, err = strconv.Atoi(conn.Database.Or("0")); err != nil {
+		return opts, fmt.Errorf("invalid redis database: %w", err)
+	}
+
+	if raw := conn.URL.String(); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid redis url: %w", err)
+		}
+		opts.TLS = opts.TLS || u.Scheme == "rediss" || u.Scheme == "tls"
+		if host := u.Hostname(); host != "" {
+			opts.Host = host
+		}
+		if port := u.Port(); port != "" {
+			if opts.Port, err = strconv.Atoi(port); err != nil {
+				return opts, fmt.Errorf("invalid redis url port: %w", err)
+			}
+		}
+		if u.User != nil {
+			if pass, ok := u.User.Password(); ok {
+				opts.Username, opts.Password = u.User.Username(), pass
+			} else {
+				opts.Password = u.User.Username()
+			}
+		}
+		if db := strings.Trim(u.Path, "/"); db != "" {
+			if opts.DB, err = strconv.Atoi(db); err != nil {
+				return opts, fmt.Errorf("invalid redis url database: %w", err)
+			}
+		}
+	}
+	return opts, nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -1,7 +1,10 @@
 package laravel
 
 import (
+	"encoding/json"
 	"os"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -145,6 +148,37 @@ fi`
 	}
 }
 
+func TestGetQueueSizes_QueueNamesAsData(t *testing.T) {
+	tempDir := t.TempDir()
+	hostile := `x'];system('touch pwned');//`
+
+	// The mock fails when the name made it into the script
+	mockPhpScript := `#!/bin/sh
+case "$6" in *pwned*) echo "queue name in script" >&2; exit 1;; esac
+echo "$ELASTICPHP_QUEUES" > ` + tempDir + `/queues.json
+echo '{"redis":{"default":{"size":0}}}'`
+	mockPhpPath := tempDir + "/mock-php"
+	if err := os.WriteFile(mockPhpPath, []byte(mockPhpScript), 0755); err != nil {
+		t.Fatalf("Failed to create mock PHP script: %v", err)
+	}
+
+	if _, err := GetQueueSizes(tempDir, mockPhpPath, map[string][]string{"redis": {"default", hostile}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(tempDir + "/queues.json")
+	if err != nil {
+		t.Fatalf("Failed to read the passed queues: %v", err)
+	}
+	var passed map[string][]string
+	if err := json.Unmarshal(data, &passed); err != nil {
+		t.Fatalf("Expected the queues as JSON, got %s", data)
+	}
+	if !reflect.DeepEqual(passed, map[string][]string{"redis": {"default", hostile}}) {
+		t.Errorf("Unexpected queues %v", passed)
+	}
+}
+
 func containsArtisanError(errMsg string) bool {
 	indicators := []string{
 		"artisan tinker failed",
@@ -207,3 +241,16 @@ func intPtr(i int) *int {
 func float32Ptr(f float32) *float32 {
 	return &f
 }
+
+func TestArtisanEnv(t *testing.T) {
+	env := artisanEnv("ELASTICPHP_QUEUES={}")
+	for _, v := range []string{"NIGHTWATCH_ENABLED=false", "TELESCOPE_ENABLED=false", "NEW_RELIC_ENABLED=false",
+		"BUGSNAG_API_KEY=null", "SENTRY_LARAVEL_DSN=null", "ROLLBAR_TOKEN=null"} {
+		if !slices.Contains(env, v) {
+			t.Errorf("Expected %s in the artisan environment", v)
+		}
+	}
+	if env[len(env)-1] != "ELASTICPHP_QUEUES={}" {
+		t.Errorf("Expected the extra variables last, got %s", env[len(env)-1])
+	}
+}
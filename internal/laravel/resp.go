@@ -0,0 +1,206 @@
+package laravel
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultRedisTimeout = 2 * time.Second
+
+// respError is an error reply sent by the Redis server.
+type respError string
+
+func (e respError) Error() string { return string(e) }
+
+// respClient is a minimal Redis client speaking RESP2 over a single
+// connection, enough for the read-only commands the queue readers need.
+type respClient struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// dialRedis connects, authenticates and selects the database. The context
+// deadline applies to all later commands on the client.
+func dialRedis(ctx context.Context, opts redisOptions) (*respClient, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultRedisTimeout)
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+
+	var conn net.Conn
+	var err error
+	if opts.TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: opts.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c := &respClient{conn: conn, rd: bufio.NewReader(conn)}
+	if opts.Password != "" {
+		args := []string{"AUTH", opts.Password}
+		if opts.Username != "" {
+			args = []string{"AUTH", opts.Username, opts.Password}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if opts.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(opts.DB)); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("redis select %d: %w", opts.DB, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *respClient) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a command and returns its reply: string for simple strings, int64
+// for integers, []byte or nil for bulk strings and []any for arrays.
+func (c *respClient) do(args ...string) (any, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	reply, err := readReply(c.rd)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(respError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// doInt runs a command with an integer reply such as LLEN or ZCARD.
+func (c *respClient) doInt(args ...string) (int, error) {
+	reply, err := c.do(args...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected reply %T to %s", reply, args[0])
+	}
+	return int(n), nil
+}
+
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("malformed redis reply")
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return respError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unknown redis reply type %q", kind)
+	}
+}
+
+// scan returns the keys matching pattern, iterating SCAN at most maxCalls
+// times so huge keyspaces don't stall a scrape.
+func (c *respClient) scan(pattern string, maxCalls int) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for i := 0; i < maxCalls; i++ {
+		reply, err := c.do("SCAN", cursor, "MATCH", pattern, "COUNT", "1000")
+		if err != nil {
+			return keys, err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return keys, fmt.Errorf("unexpected reply %T to SCAN", reply)
+		}
+		next, _ := parts[0].([]byte)
+		batch, _ := parts[1].([]any)
+		for _, k := range batch {
+			if key, ok := k.([]byte); ok {
+				keys = append(keys, string(key))
+			}
+		}
+		if cursor = string(next); cursor == "0" {
+			break
+		}
+	}
+	return keys, nil
+}
+
+// globEscape escapes the glob characters of a literal key prefix.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
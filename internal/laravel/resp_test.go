@@ -0,0 +1,330 @@
+package laravel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis is a RESP server holding lists, sorted sets (as sizes or scored
+// members), hashes, sets and strings per database.
+type fakeRedis struct {
+	mu       sync.Mutex
+	password string
+	lists    map[int]map[string][]string
+	zsets    map[int]map[string]int
+	members  map[int]map[string]map[string]float64
+	hashes   map[int]map[string]map[string]string
+	sets     map[int]map[string][]string
+	strings  map[int]map[string]string
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, int) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeRedis{
+		password: password,
+		lists:    map[int]map[string][]string{},
+		zsets:    map[int]map[string]int{},
+		members:  map[int]map[string]map[string]float64{},
+		hashes:   map[int]map[string]map[string]string{},
+		sets:     map[int]map[string][]string{},
+		strings:  map[int]map[string]string{},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, listener.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeRedis) list(db int, key string, items ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lists[db] == nil {
+		f.lists[db] = map[string][]string{}
+	}
+	f.lists[db][key] = items
+}
+
+func (f *fakeRedis) zset(db int, key string, size int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.zsets[db] == nil {
+		f.zsets[db] = map[string]int{}
+	}
+	f.zsets[db][key] = size
+}
+
+func (f *fakeRedis) zadd(db int, key string, score float64, member string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.members[db] == nil {
+		f.members[db] = map[string]map[string]float64{}
+	}
+	if f.members[db][key] == nil {
+		f.members[db][key] = map[string]float64{}
+	}
+	f.members[db][key][member] = score
+}
+
+func (f *fakeRedis) hset(db int, key string, fields map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.hashes[db] == nil {
+		f.hashes[db] = map[string]map[string]string{}
+	}
+	f.hashes[db][key] = fields
+}
+
+func (f *fakeRedis) sadd(db int, key string, members ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sets[db] == nil {
+		f.sets[db] = map[string][]string{}
+	}
+	f.sets[db][key] = members
+}
+
+func (f *fakeRedis) set(db int, key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.strings[db] == nil {
+		f.strings[db] = map[string]string{}
+	}
+	f.strings[db][key] = value
+}
+
+// sorted returns the members of a sorted set with a score of at least from,
+// ordered by score.
+func (f *fakeRedis) sorted(db int, key string, from float64) []string {
+	var members []string
+	for m, score := range f.members[db][key] {
+		if score >= from {
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return f.members[db][key][members[i]] < f.members[db][key][members[j]]
+	})
+	return members
+}
+
+func bulkArray(items []string) string {
+	reply := fmt.Sprintf("*%d\r\n", len(items))
+	for _, item := range items {
+		reply += fmt.Sprintf("$%d\r\n%s\r\n", len(item), item)
+	}
+	return reply
+}
+
+func bulkString(s string, ok bool) string {
+	if !ok {
+		return "$-1\r\n"
+	}
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	db, authed := 0, f.password == ""
+
+	for {
+		req, err := readReply(rd)
+		if err != nil {
+			return
+		}
+		parts, _ := req.([]any)
+		args := make([]string, len(parts))
+		for i, p := range parts {
+			args[i] = string(p.([]byte))
+		}
+
+		f.mu.Lock()
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] == f.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			db, _ = strconv.Atoi(args[1])
+			reply = "+OK\r\n"
+		case args[0] == "LLEN":
+			reply = fmt.Sprintf(":%d\r\n", len(f.lists[db][args[1]]))
+		case args[0] == "ZCARD":
+			reply = fmt.Sprintf(":%d\r\n", f.zsets[db][args[1]]+len(f.members[db][args[1]]))
+		case args[0] == "ZRANGEBYSCORE":
+			from, _ := strconv.ParseFloat(args[2], 64)
+			reply = bulkArray(f.sorted(db, args[1], from))
+		case args[0] == "ZRANGE":
+			members := f.sorted(db, args[1], math.Inf(-1))
+			start, _ := strconv.Atoi(args[2])
+			stop, _ := strconv.Atoi(args[3])
+			if start < 0 {
+				start = max(len(members)+start, 0)
+			}
+			if stop < 0 {
+				stop = len(members) + stop
+			}
+			if start > stop || start >= len(members) {
+				members = nil
+			} else {
+				members = members[start:min(stop+1, len(members))]
+			}
+			reply = bulkArray(members)
+		case args[0] == "HGETALL":
+			var fields []string
+			for k, v := range f.hashes[db][args[1]] {
+				fields = append(fields, k, v)
+			}
+			reply = bulkArray(fields)
+		case args[0] == "HGET":
+			v, ok := f.hashes[db][args[1]][args[2]]
+			reply = bulkString(v, ok)
+		case args[0] == "SMEMBERS":
+			reply = bulkArray(f.sets[db][args[1]])
+		case args[0] == "GET":
+			v, ok := f.strings[db][args[1]]
+			reply = bulkString(v, ok)
+		case args[0] == "LINDEX":
+			i, _ := strconv.Atoi(args[2])
+			if items := f.lists[db][args[1]]; i < len(items) {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(items[i]), items[i])
+			} else {
+				reply = "$-1\r\n"
+			}
+		case args[0] == "SCAN":
+			reply = f.scan(db, args[1], args[3])
+		default:
+			reply = "-ERR unknown command '" + args[0] + "'\r\n"
+		}
+		f.mu.Unlock()
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// scan replies to SCAN cursor MATCH pattern with two keys per page, the
+// cursor being the index of the next page's first key.
+func (f *fakeRedis) scan(db int, cursor, pattern string) string {
+	var keys []string
+	for key := range f.lists[db] {
+		keys = append(keys, key)
+	}
+	for key := range f.zsets[db] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var matched []string
+	for _, key := range keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			matched = append(matched, key)
+		}
+	}
+
+	start, _ := strconv.Atoi(cursor)
+	end, next := min(start+2, len(matched)), "0"
+	if end < len(matched) {
+		next = strconv.Itoa(end)
+	}
+	reply := fmt.Sprintf("*2\r\n$%d\r\n%s\r\n*%d\r\n", len(next), next, end-start)
+	for _, key := range matched[start:end] {
+		reply += fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
+	}
+	return reply
+}
+
+func TestRespClient(t *testing.T) {
+	fake, port := startFakeRedis(t, "secret")
+	fake.list(2, "jobs", "first", "second")
+
+	if _, err := dialRedis(context.Background(), redisOptions{Host: "127.0.0.1", Port: port, Password: "wrong"}); err == nil {
+		t.Errorf("Expected an auth error")
+	}
+
+	c, err := dialRedis(context.Background(), redisOptions{Host: "127.0.0.1", Port: port, Password: "secret", DB: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer c.Close()
+
+	if n, err := c.doInt("LLEN", "jobs"); err != nil || n != 2 {
+		t.Errorf("Expected 2 items, got %d (%v)", n, err)
+	}
+	if reply, err := c.do("LINDEX", "jobs", "0"); err != nil || string(reply.([]byte)) != "first" {
+		t.Errorf("Expected the first item, got %v (%v)", reply, err)
+	}
+	if reply, err := c.do("LINDEX", "jobs", "5"); err != nil || reply != nil {
+		t.Errorf("Expected a nil bulk string, got %v (%v)", reply, err)
+	}
+
+	fake.list(2, "jobs:a", "x")
+	fake.zset(2, "jobs:b", 1)
+	fake.zset(2, "other", 1)
+	if keys, err := c.scan("jobs*", 10); err != nil || strings.Join(keys, ",") != "jobs,jobs:a,jobs:b" {
+		t.Errorf("Expected all matching keys over several pages, got %v (%v)", keys, err)
+	}
+	if keys, _ := c.scan("jobs*", 1); len(keys) != 2 {
+		t.Errorf("Expected scanning to stop after one call, got %v", keys)
+	}
+
+	var respErr respError
+	if _, err := c.do("FLUSHALL"); !errors.As(err, &respErr) {
+		t.Errorf("Expected a server error reply, got %v", err)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	rd := bufio.NewReader(strings.NewReader("*3\r\n+OK\r\n:-4\r\n$5\r\nhe\r\nl\r\n"))
+	reply, err := readReply(rd)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	items := reply.([]any)
+	if items[0] != "OK" || items[1] != int64(-4) || string(items[2].([]byte)) != "he\r\nl" {
+		t.Errorf("Unexpected array %v", items)
+	}
+
+	if _, err := readReply(bufio.NewReader(strings.NewReader("?1\r\n"))); err == nil {
+		t.Errorf("Expected an error for an unknown reply type")
+	}
+	if _, err := readReply(bufio.NewReader(strings.NewReader("+OK\n"))); err == nil {
+		t.Errorf("Expected an error for a reply without CRLF")
+	}
+}
+
+func TestGlobEscape(t *testing.T) {
+	if got := globEscape(`app*[1]?_\queues:`); got != `app\*\[1\]\?_\\queues:` {
+		t.Errorf("Unexpected escaped pattern %q", got)
+	}
+}
@@ -0,0 +1,239 @@
+package laravel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+const (
+	DefaultScheduleHeartbeat = "storage/framework/schedule-heartbeat"
+
+	defaultScheduleTolerance = time.Minute
+	// The task list only changes with a deploy
+	scheduleListInterval = 5 * time.Minute
+	// missedRunsLimit bounds the count for tasks that stopped long ago
+	missedRunsLimit = 10000
+
+	LastRunSourceHeartbeat = "heartbeat"
+	LastRunSourceOutput    = "output"
+)
+
+var (
+	scheduleLists     = map[string]*scheduleList{}
+	scheduleListsLock sync.Mutex
+)
+
+// ScheduleMetrics is the state of a site's scheduler. Laravel keeps no run
+// history, so schedule:run is known to fire from a heartbeat file the
+// schedule touches every minute, and a task is assumed to have run at every
+// due time up to the last heartbeat. Tasks writing their output to a file
+// tell their last run from its modification time. The schedule's mutex
+// cache keys are not read: they only exist while an overlapping-protected
+// task runs and say nothing about the tasks without one.
+type ScheduleMetrics struct {
+	Tasks      []ScheduledTask `json:"tasks"`
+	Heartbeat  *time.Time      `json:"heartbeat"` // Last time schedule:run fired
+	Firing     *bool           `json:"firing"`    // Unknown without a heartbeat file
+	MissedRuns int             `json:"missed_runs"`
+	ListedAt   time.Time       `json:"listed_at"`
+}
+
+type ScheduledTask struct {
+	Name               string     `json:"name"` // Description, command or Closure
+	Command            string     `json:"command,omitempty"`
+	Expression         string     `json:"expression"`
+	Timezone           string     `json:"timezone"`
+	WithoutOverlapping bool       `json:"without_overlapping"`
+	OnOneServer        bool       `json:"on_one_server"`
+	Output             string     `json:"output,omitempty"`
+	PreviousDue        *time.Time `json:"previous_due"`
+	NextDue            *time.Time `json:"next_due"`
+	LastRun            *time.Time `json:"last_run"`
+	LastRunSource      string     `json:"last_run_source,omitempty"` // heartbeat or output
+	MissedRuns         *int       `json:"missed_runs"`               // Due times since the last run, unknown without one
+	Error              string     `json:"error,omitempty"`
+}
+
+type scheduleList struct {
+	tasks    []ScheduledTask
+	listedAt time.Time
+}
+
+const scheduleScript = `$schedule = app(Illuminate\Console\Scheduling\Schedule::class);
+$env = app()->environment();
+$tasks = [];
+foreach ($schedule->events() as $event) {
+	if (! $event->runsInEnvironment($env)) {
+		continue;
+	}
+	$command = $event->command ? trim(str_replace(
+		[Illuminate\Console\Application::phpBinary(), Illuminate\Console\Application::artisanBinary()],
+		['php', 'artisan'],
+		$event->command
+	)) : null;
+	$timezone = $event->timezone ?: config('app.timezone');
+	$output = in_array($event->output, ['/dev/null', 'NUL'], true) ? null : $event->output;
+	$tasks[] = [
+		'name' => $event->description ?: ($command ?: 'Closure'),
+		'command' => $command,
+		'expression' => $event->expression,
+		'timezone' => $timezone instanceof DateTimeZone ? $timezone->getName() : (string) $timezone,
+		'without_overlapping' => (bool) $event->withoutOverlapping,
+		'on_one_server' => (bool) $event->onOneServer,
+		'output' => $output,
+	];
+}
+echo json_encode($tasks);`
+
+// GetScheduleMetrics lists the site's scheduled tasks through artisan, at
+// most every few minutes, and works out their due times and last runs.
+func GetScheduleMetrics(site config.LaravelConfig, phpBinary string, now time.Time) (*ScheduleMetrics, error) {
+	list, err := listSchedule(site.Path, phpBinary, now)
+	if err != nil {
+		return nil, err
+	}
+
+	tolerance := site.ScheduleTolerance
+	if tolerance <= 0 {
+		tolerance = defaultScheduleTolerance
+	}
+
+	m := &ScheduleMetrics{Tasks: make([]ScheduledTask, 0, len(list.tasks)), ListedAt: list.listedAt}
+	heartbeat := site.ScheduleHeartbeat
+	if heartbeat == "" {
+		heartbeat = DefaultScheduleHeartbeat
+	}
+	if !filepath.IsAbs(heartbeat) {
+		heartbeat = filepath.Join(site.Path, heartbeat)
+	}
+	if fi, err := os.Stat(heartbeat); err == nil {
+		beat := fi.ModTime()
+		firing := now.Sub(beat) <= time.Minute+tolerance
+		m.Heartbeat, m.Firing = &beat, &firing
+	}
+
+	for _, task := range list.tasks {
+		m.Tasks = append(m.Tasks, scheduleTask(task, site.Path, m.Heartbeat, tolerance, now))
+		if n := m.Tasks[len(m.Tasks)-1].MissedRuns; n != nil {
+			m.MissedRuns += *n
+		}
+	}
+	return m, nil
+}
+
+// scheduleTask works out a task's due times, its last run from its output
+// file or the heartbeat and the due times missed since, allowing tolerance
+// for schedule:run to start and the task to write output.
+func scheduleTask(task ScheduledTask, appPath string, heartbeat *time.Time, tolerance time.Duration, now time.Time) ScheduledTask {
+	cron, err := ParseCron(task.Expression)
+	if err != nil {
+		task.Error = err.Error()
+		return task
+	}
+	loc, err := time.LoadLocation(task.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+
+	if prev := cron.Prev(local); !prev.IsZero() {
+		task.PreviousDue = &prev
+	}
+	if next := cron.Next(local); !next.IsZero() {
+		task.NextDue = &next
+	}
+
+	// An output file is written by the task itself, the heartbeat only
+	// tells that schedule:run fired
+	var last time.Time
+	if task.Output != "" {
+		output := task.Output
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(appPath, output)
+		}
+		if fi, err := os.Stat(output); err == nil {
+			last, task.LastRunSource = fi.ModTime(), LastRunSourceOutput
+		}
+	}
+	if last.IsZero() && heartbeat != nil {
+		if prev := cron.Prev(heartbeat.In(loc)); !prev.IsZero() {
+			last, task.LastRunSource = prev, LastRunSourceHeartbeat
+		}
+	}
+	if last.IsZero() {
+		return task
+	}
+	task.LastRun = &last
+
+	missed := 0
+	deadline := local.Add(-tolerance)
+	for due := cron.Next(last.In(loc)); !due.IsZero() && !due.After(deadline) && missed < missedRunsLimit; due = cron.Next(due) {
+		missed++
+	}
+	task.MissedRuns = &missed
+	return task
+}
+
+// listSchedule returns the site's scheduled tasks, listed again once
+// scheduleListInterval has passed.
+func listSchedule(appPath, phpBinary string, now time.Time) (*scheduleList, error) {
+	key := filepath.Clean(appPath)
+
+	scheduleListsLock.Lock()
+	list, ok := scheduleLists[key]
+	scheduleListsLock.Unlock()
+	if ok && now.Sub(list.listedAt) < scheduleListInterval {
+		return list, nil
+	}
+
+	tasks, err := readSchedule(appPath, phpBinary)
+	if err != nil {
+		return nil, err
+	}
+	list = &scheduleList{tasks: tasks, listedAt: now}
+
+	scheduleListsLock.Lock()
+	scheduleLists[key] = list
+	scheduleListsLock.Unlock()
+	return list, nil
+}
+
+func readSchedule(appPath, phpBinary string) ([]ScheduledTask, error) {
+	cmd := exec.Command(phpBinary, "-d", "error_reporting=E_ALL & ~E_DEPRECATED", "artisan", "tinker", "--execute", scheduleScript)
+	cmd.Dir = filepath.Clean(appPath)
+
+	// disable monitoring on scraping to prevent exhausting monitoring tools
+	cmd.Env = artisanEnv()
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("artisan tinker failed: %w\nOutput: %s", err, out.String())
+	}
+
+	var tasks []ScheduledTask
+	if err := json.Unmarshal(out.Bytes(), &tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse output: %w\nOutput: %s", err, out.String())
+	}
+
+	// Tasks are told apart by name in the metrics
+	seen := map[string]int{}
+	for i := range tasks {
+		seen[tasks[i].Name]++
+		if n := seen[tasks[i].Name]; n > 1 {
+			tasks[i].Name += " #" + strconv.Itoa(n)
+		}
+	}
+	return tasks, nil
+}
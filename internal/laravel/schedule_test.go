@@ -0,0 +1,166 @@
+package laravel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+const scheduleOutput = `[
+{"name":"php artisan inspire","command":"php artisan inspire","expression":"* * * * *","timezone":"UTC","without_overlapping":false,"on_one_server":false,"output":null},
+{"name":"Prune reports","command":null,"expression":"0 * * * *","timezone":"UTC","without_overlapping":true,"on_one_server":true,"output":"storage/logs/prune.log"},
+{"name":"Closure","command":null,"expression":"*/10 * * * *","timezone":"Europe/Amsterdam","without_overlapping":false,"on_one_server":false,"output":null},
+{"name":"Closure","command":null,"expression":"not cron","timezone":"UTC","without_overlapping":false,"on_one_server":false,"output":null}
+]`
+
+// writeScheduleSite creates a site with a mock php binary listing the
+// scheduled tasks of scheduleOutput.
+func writeScheduleSite(t *testing.T) (string, string) {
+	t.Helper()
+
+	site := writeSite(t, "")
+	if err := os.MkdirAll(filepath.Join(site, "storage", "framework"), 0o755); err != nil {
+		t.Fatalf("Failed to create storage dir: %v", err)
+	}
+	php := filepath.Join(t.TempDir(), "php")
+	script := "#!/bin/sh\ncat <<'EOF'\n" + scheduleOutput + "\nEOF\n"
+	if err := os.WriteFile(php, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write mock php: %v", err)
+	}
+	return site, php
+}
+
+func touch(t *testing.T, path string, at time.Time) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatalf("Failed to set times of %s: %v", path, err)
+	}
+}
+
+func TestGetScheduleMetrics(t *testing.T) {
+	site, php := writeScheduleSite(t)
+	now := time.Date(2025, 3, 12, 10, 17, 30, 0, time.UTC)
+
+	// schedule:run stopped firing at 10:04, the prune task last wrote output at 9:00
+	touch(t, filepath.Join(site, DefaultScheduleHeartbeat), time.Date(2025, 3, 12, 10, 4, 10, 0, time.UTC))
+	touch(t, filepath.Join(site, "storage", "logs", "prune.log"), time.Date(2025, 3, 12, 9, 0, 20, 0, time.UTC))
+
+	m, err := GetScheduleMetrics(config.LaravelConfig{Path: site}, php, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m.Firing == nil || *m.Firing {
+		t.Errorf("Expected schedule:run not to be firing, got %v", m.Firing)
+	}
+	if len(m.Tasks) != 4 {
+		t.Fatalf("Expected 4 tasks, got %+v", m.Tasks)
+	}
+
+	inspire := m.Tasks[0]
+	if inspire.LastRunSource != LastRunSourceHeartbeat || !inspire.LastRun.Equal(time.Date(2025, 3, 12, 10, 4, 0, 0, time.UTC)) {
+		t.Errorf("Expected the last run at the heartbeat, got %v from %s", inspire.LastRun, inspire.LastRunSource)
+	}
+	// 10:05 to 10:16, 10:17 is within the tolerance
+	if inspire.MissedRuns == nil || *inspire.MissedRuns != 12 {
+		t.Errorf("Expected 12 missed runs, got %v", inspire.MissedRuns)
+	}
+	if !inspire.NextDue.Equal(time.Date(2025, 3, 12, 10, 18, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected next due time %v", inspire.NextDue)
+	}
+
+	prune := m.Tasks[1]
+	if prune.LastRunSource != LastRunSourceOutput || prune.MissedRuns == nil || *prune.MissedRuns != 1 {
+		t.Errorf("Expected the 10:00 run missed after the 9:00 output, got %+v", prune)
+	}
+
+	closure := m.Tasks[2]
+	if closure.Name != "Closure" || !closure.PreviousDue.Equal(time.Date(2025, 3, 12, 10, 10, 0, 0, time.UTC)) {
+		t.Errorf("Expected the due times in the task's timezone, got %+v", closure)
+	}
+	if closure.MissedRuns == nil || *closure.MissedRuns != 1 {
+		t.Errorf("Expected 10:10 missed, got %v", closure.MissedRuns)
+	}
+
+	if invalid := m.Tasks[3]; invalid.Name != "Closure #2" || invalid.Error == "" || invalid.MissedRuns != nil {
+		t.Errorf("Expected an invalid expression error, got %+v", invalid)
+	}
+	if m.MissedRuns != 14 {
+		t.Errorf("Expected 14 missed runs in total, got %d", m.MissedRuns)
+	}
+}
+
+func TestGetScheduleMetrics_Firing(t *testing.T) {
+	site, php := writeScheduleSite(t)
+	now := time.Now()
+	heartbeat := filepath.Join(t.TempDir(), "beat")
+	touch(t, heartbeat, now.Add(-40*time.Second))
+
+	m, err := GetScheduleMetrics(config.LaravelConfig{Path: site, ScheduleHeartbeat: heartbeat}, php, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m.Firing == nil || !*m.Firing || m.MissedRuns != 0 {
+		t.Errorf("Expected schedule:run firing without missed runs, got %v %d", m.Firing, m.MissedRuns)
+	}
+}
+
+func TestGetScheduleMetrics_NoHeartbeat(t *testing.T) {
+	site, php := writeScheduleSite(t)
+
+	m, err := GetScheduleMetrics(config.LaravelConfig{Path: site}, php, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m.Firing != nil || m.Tasks[0].LastRun != nil || m.Tasks[0].MissedRuns != nil {
+		t.Errorf("Expected unknown runs without a heartbeat, got %+v", m)
+	}
+}
+
+func TestListSchedule_Refresh(t *testing.T) {
+	site, php := writeScheduleSite(t)
+	now := time.Now()
+
+	first, err := listSchedule(site, php, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again, _ := listSchedule(site, php, now.Add(time.Minute)); again != first {
+		t.Errorf("Expected the task list to be reused")
+	}
+	if again, _ := listSchedule(site, php, now.Add(scheduleListInterval)); again == first {
+		t.Errorf("Expected the task list to be read again after the interval")
+	}
+}
+
+func TestCollect_Schedule(t *testing.T) {
+	site, php := writeScheduleSite(t)
+	cfg := &config.Config{
+		PHP:     config.PHPConfig{Binary: php},
+		Laravel: []config.LaravelConfig{{Name: "App", Path: site, QueueBackend: QueueBackendNative, MonitorSchedule: true}},
+	}
+
+	result, errs := Collect(context.Background(), cfg)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if s := result["App"].Schedule; s == nil || len(s.Tasks) != 4 {
+		t.Errorf("Expected the schedule in the result, got %+v", s)
+	}
+
+	cfg.Laravel[0].Path = t.TempDir()
+	cfg.PHP.Binary = "/nonexistent/php"
+	if _, errs := Collect(context.Background(), cfg); errs["laravel:App:schedule"] == "" {
+		t.Errorf("Expected a schedule error, got %v", errs)
+	}
+}
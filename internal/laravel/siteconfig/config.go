@@ -0,0 +1,542 @@
+// Package siteconfig reads the configuration of a Laravel application from
+// its .env file, or from bootstrap/cache/config.php when the config is
+// cached, without booting the framework.
+package siteconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EnvFile       = ".env"
+	CachedConfig  = "bootstrap/cache/config.php"
+	HorizonConfig = "config/horizon.php"
+	AppConfigDir  = "config"
+
+	phpTimeout = 10 * time.Second
+)
+
+var (
+	sites     = map[string]*entry{}
+	sitesLock sync.Mutex
+
+	slugInvalid   = regexp.MustCompile(`[^a-z0-9_\s]+`)
+	slugSeparator = regexp.MustCompile(`[_\s]+`)
+
+	// The config files evaluated when the config isn't cached
+	appConfigFiles = []string{"config/app.php", "config/database.php", "config/queue.php"}
+)
+
+// Config is the part of a Laravel application's configuration the agent
+// uses, keyed like the arrays in the config directory.
+type Config struct {
+	App      AppConfig      `json:"app"`
+	Database DatabaseConfig `json:"database"`
+	Queue    QueueConfig    `json:"queue"`
+	Horizon  *Horizon       `json:"horizon"` // Nil when Horizon is not installed
+
+	Path       string            `json:"-"` // Application root
+	Cached     bool              `json:"-"` // Read from bootstrap/cache/config.php
+	Env        map[string]string `json:"-"` // Raw .env values, empty when missing
+	HorizonErr error             `json:"-"` // Set when config/horizon.php could not be evaluated
+	ConfigErr  error             `json:"-"` // Set when the config files could not be evaluated, the config is then guessed from .env
+}
+
+type AppConfig struct {
+	Name     Value `json:"name"`
+	Env      Value `json:"env"`
+	Debug    Flag  `json:"debug"`
+	Timezone Value `json:"timezone"`
+}
+
+type DatabaseConfig struct {
+	Default     Value                         `json:"default"`
+	Connections map[string]DatabaseConnection `json:"connections"`
+	Redis       RedisConfig                   `json:"redis"`
+}
+
+type DatabaseConnection struct {
+	Driver   Value `json:"driver"`
+	URL      Value `json:"url"`
+	Host     Value `json:"host"`
+	Port     Value `json:"port"`
+	Database Value `json:"database"`
+	Username Value `json:"username"`
+	Password Value `json:"password"`
+	Socket   Value `json:"unix_socket"`
+	SSLMode  Value `json:"sslmode"`
+	Prefix   Value `json:"prefix"`
+}
+
+// RedisConfig holds the client options next to the named connections, which
+// share one array in config/database.php.
+type RedisConfig struct {
+	Client      Value
+	Prefix      Value
+	Connections map[string]RedisConnection
+}
+
+type RedisConnection struct {
+	URL      Value `json:"url"`
+	Scheme   Value `json:"scheme"`
+	Host     Value `json:"host"`
+	Port     Value `json:"port"`
+	Username Value `json:"username"`
+	Password Value `json:"password"`
+	Database Value `json:"database"`
+}
+
+type QueueConfig struct {
+	Default     Value                      `json:"default"`
+	Connections map[string]QueueConnection `json:"connections"`
+	Failed      FailedQueueConfig          `json:"failed"`
+}
+
+type QueueConnection struct {
+	Driver     Value `json:"driver"`
+	Connection Value `json:"connection"` // Database or Redis connection name
+	Table      Value `json:"table"`
+	Queue      Value `json:"queue"`
+}
+
+type FailedQueueConfig struct {
+	Driver   Value `json:"driver"`
+	Database Value `json:"database"`
+	Table    Value `json:"table"`
+}
+
+// Value is a config value read as a string. PHP config arrays mix strings,
+// numbers and null for the same setting, e.g. ports.
+type Value string
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = Value(s)
+		return nil
+	}
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch val := raw.(type) {
+	case nil:
+		*v = ""
+	case bool:
+		if val {
+			*v = "1"
+		} else {
+			*v = ""
+		}
+	case float64:
+		*v = Value(strconv.FormatFloat(val, 'f', -1, 64))
+	default:
+		return fmt.Errorf("invalid config value: %s", string(data))
+	}
+	return nil
+}
+
+func (v Value) String() string {
+	return string(v)
+}
+
+// Or returns the value, or fallback when it's empty.
+func (v Value) Or(fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return string(v)
+}
+
+// Flag is a boolean config value that may still be a string from .env.
+type Flag bool
+
+func (f *Flag) UnmarshalJSON(data []byte) error {
+	var v Value
+	if err := v.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	switch strings.ToLower(string(v)) {
+	case "1", "true", "on", "yes":
+		*f = true
+	default:
+		*f = false
+	}
+	return nil
+}
+
+func (r *RedisConfig) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.Connections = map[string]RedisConnection{}
+	for name, value := range raw {
+		switch name {
+		case "client":
+			if err := json.Unmarshal(value, &r.Client); err != nil {
+				return err
+			}
+		case "options":
+			var options struct {
+				Prefix Value `json:"prefix"`
+			}
+			if err := json.Unmarshal(value, &options); err != nil {
+				return err
+			}
+			r.Prefix = options.Prefix
+		default:
+			var conn RedisConnection
+			if json.Unmarshal(value, &conn) == nil {
+				r.Connections[name] = conn
+			}
+		}
+	}
+	return nil
+}
+
+func (r RedisConfig) MarshalJSON() ([]byte, error) {
+	raw := map[string]any{"client": r.Client, "options": map[string]any{"prefix": r.Prefix}}
+	for name, conn := range r.Connections {
+		raw[name] = conn
+	}
+	return json.Marshal(raw)
+}
+
+// QueueDriver returns the driver of a queue connection, or an empty string
+// when the connection is not configured.
+func (c *Config) QueueDriver(connection string) string {
+	return c.Queue.Connections[connection].Driver.String()
+}
+
+// Location returns the application timezone, UTC when it is invalid.
+func (c *Config) Location() *time.Location {
+	loc, err := time.LoadLocation(c.App.Timezone.Or("UTC"))
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+type entry struct {
+	config  *Config
+	version string
+}
+
+// Load returns the configuration of the application at appPath. It is read
+// once and again only when .env, the cached config or one of the config
+// files changes. PHP files are evaluated with the PHP CLI.
+func Load(ctx context.Context, appPath, phpBinary string) (*Config, error) {
+	appPath = filepath.Clean(appPath)
+	version := fileVersion(filepath.Join(appPath, EnvFile)) + "|" +
+		fileVersion(filepath.Join(appPath, CachedConfig)) + "|" +
+		fileVersion(filepath.Join(appPath, HorizonConfig))
+	for _, file := range appConfigFiles {
+		version += "|" + fileVersion(filepath.Join(appPath, file))
+	}
+
+	sitesLock.Lock()
+	defer sitesLock.Unlock()
+
+	if e, ok := sites[appPath]; ok && e.version == version {
+		return e.config, nil
+	}
+
+	cfg, err := read(ctx, appPath, phpBinary)
+	if err != nil {
+		return nil, err
+	}
+	sites[appPath] = &entry{config: cfg, version: version}
+	return cfg, nil
+}
+
+func read(ctx context.Context, appPath, phpBinary string) (*Config, error) {
+	env, err := ReadDotEnv(filepath.Join(appPath, EnvFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if env == nil {
+		env = map[string]string{}
+	}
+
+	cfg := &Config{Path: appPath, Env: env}
+	if _, err := os.Stat(filepath.Join(appPath, CachedConfig)); err == nil {
+		raw, err := evalPHP(ctx, appPath, phpBinary, cachedConfigScript, CachedConfig)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+		cfg.Cached = true
+		return cfg, nil
+	}
+
+	raw, err := json.Marshal(defaults(appPath, env))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	// The defaults only match the config files of a new application
+	if hasAppConfig(appPath) {
+		raw, err := evalPHP(ctx, appPath, phpBinary, appConfigScript, AppConfigDir)
+		if err == nil {
+			err = cfg.override(raw)
+		}
+		cfg.ConfigErr = err
+	}
+
+	// Supervisors are only known from the file itself
+	if cfg.Horizon != nil {
+		raw, err := evalPHP(ctx, appPath, phpBinary, horizonConfigScript, HorizonConfig)
+		if err == nil {
+			err = json.Unmarshal(raw, cfg)
+		}
+		cfg.HorizonErr = err
+	}
+	return cfg, nil
+}
+
+// Only the sections the agent reads are encoded, the rest may hold secrets.
+const (
+	horizonKeys        = `array_flip(['prefix', 'use', 'defaults', 'environments'])`
+	appKeys            = `['app' => ['name', 'env', 'debug', 'timezone'], 'database' => ['default', 'connections', 'redis'], 'queue' => ['default', 'connections', 'failed']]`
+	cachedConfigScript = `$c = require $argv[1];
+echo json_encode([
+	'app' => $c['app'] ?? [],
+	'database' => $c['database'] ?? [],
+	'queue' => $c['queue'] ?? [],
+	'horizon' => isset($c['horizon']) ? array_intersect_key($c['horizon'], ` + horizonKeys + `) : null,
+], JSON_FORCE_OBJECT | JSON_INVALID_UTF8_SUBSTITUTE);`
+
+	// config/horizon.php only needs env() and Str, which work without booting
+	horizonConfigScript = `require 'vendor/autoload.php';
+if (class_exists(Dotenv\Dotenv::class)) {
+	Dotenv\Dotenv::createImmutable(getcwd())->safeLoad();
+}
+echo json_encode([
+	'horizon' => array_intersect_key(require $argv[1], ` + horizonKeys + `),
+], JSON_FORCE_OBJECT | JSON_INVALID_UTF8_SUBSTITUTE);`
+
+	// Helpers such as database_path() need the application instance, not a
+	// booted framework
+	appConfigScript = `require 'vendor/autoload.php';
+if (class_exists(Illuminate\Foundation\Application::class)) {
+	new Illuminate\Foundation\Application(getcwd());
+}
+if (class_exists(Dotenv\Dotenv::class)) {
+	Dotenv\Dotenv::createImmutable(getcwd())->safeLoad();
+}
+$c = [];
+foreach (` + appKeys + ` as $name => $keys) {
+	if (is_file($argv[1]."/$name.php")) {
+		$c[$name] = array_intersect_key(require $argv[1]."/$name.php", array_flip($keys));
+	}
+}
+echo json_encode($c, JSON_FORCE_OBJECT | JSON_INVALID_UTF8_SUBSTITUTE);`
+)
+
+func hasAppConfig(appPath string) bool {
+	for _, file := range appConfigFiles {
+		if _, err := os.Stat(filepath.Join(appPath, file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// override replaces the sections read from the config files, connections
+// the application removed must not linger from the defaults.
+func (c *Config) override(raw []byte) error {
+	var sections struct {
+		App      *AppConfig      `json:"app"`
+		Database *DatabaseConfig `json:"database"`
+		Queue    *QueueConfig    `json:"queue"`
+	}
+	if err := json.Unmarshal(raw, &sections); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if sections.App != nil {
+		c.App = *sections.App
+	}
+	if sections.Database != nil {
+		c.Database = *sections.Database
+	}
+	if sections.Queue != nil {
+		c.Queue = *sections.Queue
+	}
+	return nil
+}
+
+// evalPHP runs script with the PHP CLI in the application root, passing file
+// as the first argument, and returns its output.
+func evalPHP(ctx context.Context, appPath, phpBinary, script, file string) ([]byte, error) {
+	if phpBinary == "" {
+		return nil, fmt.Errorf("php binary is required to read %s", file)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, phpTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, phpBinary, "-d", "error_reporting=0", "-r", script, "--", file)
+	cmd.Dir = appPath
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("reading %s failed: %w\nOutput: %s", file, err, stderr.String())
+	}
+	return out.Bytes(), nil
+}
+
+// defaults builds the config arrays from .env the way the config files of a
+// new Laravel application do.
+func defaults(appPath string, env map[string]string) map[string]any {
+	e := func(key string, fallback any) any {
+		return Env(env, key, fallback)
+	}
+	redis := func(db string, dbFallback string) map[string]any {
+		return map[string]any{
+			"url":      e("REDIS_URL", nil),
+			"scheme":   e("REDIS_SCHEME", "tcp"),
+			"host":     e("REDIS_HOST", "127.0.0.1"),
+			"username": e("REDIS_USERNAME", nil),
+			"password": e("REDIS_PASSWORD", nil),
+			"port":     e("REDIS_PORT", "6379"),
+			"database": e(db, dbFallback),
+		}
+	}
+	slug := ""
+	if name, ok := e("APP_NAME", "laravel").(string); ok {
+		slug = Slug(name)
+	}
+	sql := func(driver, port, username string) map[string]any {
+		return map[string]any{
+			"driver":      driver,
+			"url":         e("DB_URL", nil),
+			"host":        e("DB_HOST", "127.0.0.1"),
+			"port":        e("DB_PORT", port),
+			"database":    e("DB_DATABASE", "laravel"),
+			"username":    e("DB_USERNAME", username),
+			"password":    e("DB_PASSWORD", ""),
+			"unix_socket": e("DB_SOCKET", ""),
+			"sslmode":     "prefer",
+			"prefix":      "",
+		}
+	}
+	cfg := map[string]any{
+		"app": map[string]any{
+			"name":     e("APP_NAME", "Laravel"),
+			"env":      e("APP_ENV", "production"),
+			"debug":    e("APP_DEBUG", false),
+			"timezone": e("APP_TIMEZONE", "UTC"),
+		},
+		"database": map[string]any{
+			"default": e("DB_CONNECTION", "sqlite"),
+			"connections": map[string]any{
+				"sqlite": map[string]any{
+					"driver":   "sqlite",
+					"url":      e("DB_URL", nil),
+					"database": e("DB_DATABASE", filepath.Join(appPath, "database", "database.sqlite")),
+					"prefix":   "",
+				},
+				"mysql":   sql("mysql", "3306", "root"),
+				"mariadb": sql("mariadb", "3306", "root"),
+				"pgsql":   sql("pgsql", "5432", "root"),
+				"sqlsrv":  sql("sqlsrv", "1433", "root"),
+			},
+			"redis": map[string]any{
+				"client":  e("REDIS_CLIENT", "phpredis"),
+				"options": map[string]any{"prefix": e("REDIS_PREFIX", slug+"_database_")},
+				"default": redis("REDIS_DB", "0"),
+				"cache":   redis("REDIS_CACHE_DB", "1"),
+			},
+		},
+		"queue": map[string]any{
+			"default": e("QUEUE_CONNECTION", "database"),
+			"connections": map[string]any{
+				"sync": map[string]any{"driver": "sync"},
+				"database": map[string]any{
+					"driver":     "database",
+					"connection": e("DB_QUEUE_CONNECTION", nil),
+					"table":      e("DB_QUEUE_TABLE", "jobs"),
+					"queue":      e("DB_QUEUE", "default"),
+				},
+				"beanstalkd": map[string]any{"driver": "beanstalkd", "queue": e("BEANSTALKD_QUEUE", "default")},
+				"sqs":        map[string]any{"driver": "sqs", "queue": e("SQS_QUEUE", "default")},
+				"redis": map[string]any{
+					"driver":     "redis",
+					"connection": e("REDIS_QUEUE_CONNECTION", "default"),
+					"queue":      e("REDIS_QUEUE", "default"),
+				},
+			},
+			"failed": map[string]any{
+				"driver":   e("QUEUE_FAILED_DRIVER", "database-uuids"),
+				"database": e("DB_CONNECTION", "sqlite"),
+				"table":    "failed_jobs",
+			},
+		},
+	}
+	if _, err := os.Stat(filepath.Join(appPath, HorizonConfig)); err == nil {
+		cfg["horizon"] = map[string]any{
+			"prefix": e("HORIZON_PREFIX", slug+"_horizon:"),
+			"use":    "default",
+		}
+	}
+	return cfg
+}
+
+// Env returns the value of key like Laravel's env() helper: fallback when
+// the key is unset, booleans for true and false, nil for null and an empty
+// string for empty.
+func Env(env map[string]string, key string, fallback any) any {
+	v, ok := env[key]
+	if !ok {
+		return fallback
+	}
+	switch strings.ToLower(v) {
+	case "true", "(true)":
+		return true
+	case "false", "(false)":
+		return false
+	case "null", "(null)":
+		return nil
+	case "empty", "(empty)":
+		return ""
+	}
+	return v
+}
+
+// Slug mirrors Str::slug($value, '_') for ASCII input.
+func Slug(s string) string {
+	s = strings.ToLower(s)
+	s = strings.ReplaceAll(s, "-", "_")
+	s = strings.ReplaceAll(s, "@", "_at_")
+	s = slugInvalid.ReplaceAllString(s, "")
+	s = slugSeparator.ReplaceAllString(s, "_")
+	return strings.Trim(s, "_")
+}
+
+// fileVersion identifies a file's content by size and modification time.
+func fileVersion(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "-"
+	}
+	return strconv.FormatInt(info.Size(), 10) + "@" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+}
@@ -0,0 +1,257 @@
+package siteconfig
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeApp(t *testing.T, env string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, EnvFile), []byte(env), 0o644); err != nil {
+		t.Fatalf("Failed to write .env: %v", err)
+	}
+	return dir
+}
+
+// writeMockPHP writes a php binary that prints output whatever it runs.
+func writeMockPHP(t *testing.T, output string) string {
+	t.Helper()
+
+	php := filepath.Join(t.TempDir(), "php")
+	script := "#!/bin/sh\ncat <<'JSON'\n" + output + "\nJSON\n"
+	if err := os.WriteFile(php, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write mock php: %v", err)
+	}
+	return php
+}
+
+func writeCachedApp(t *testing.T, env string) string {
+	t.Helper()
+
+	dir := writeApp(t, env)
+	if err := os.MkdirAll(filepath.Join(dir, "bootstrap", "cache"), 0o755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, CachedConfig), []byte("<?php return [];"), 0o644); err != nil {
+		t.Fatalf("Failed to write cached config: %v", err)
+	}
+	return dir
+}
+
+func TestLoad_DotEnv(t *testing.T) {
+	dir := writeApp(t, `APP_NAME="Shop API"
+APP_ENV=staging
+APP_DEBUG=true
+APP_TIMEZONE=Europe/Berlin
+QUEUE_CONNECTION=redis
+REDIS_QUEUE_CONNECTION=cache
+REDIS_PASSWORD=null
+DB_CONNECTION=pgsql
+DB_PORT=6432
+`)
+
+	cfg, err := Load(context.Background(), dir, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.Cached || cfg.Path != dir || cfg.Env["APP_ENV"] != "staging" {
+		t.Errorf("Unexpected site %+v", cfg)
+	}
+	if cfg.App.Name != "Shop API" || cfg.App.Env != "staging" || !cfg.App.Debug || cfg.Location().String() != "Europe/Berlin" {
+		t.Errorf("Unexpected app config %+v", cfg.App)
+	}
+
+	if cfg.Queue.Default != "redis" || cfg.QueueDriver("redis") != "redis" || cfg.QueueDriver("missing") != "" {
+		t.Errorf("Unexpected queue config %+v", cfg.Queue)
+	}
+	if conn := cfg.Queue.Connections["redis"].Connection; conn != "cache" {
+		t.Errorf("Expected the redis queue to use the cache connection, got %q", conn)
+	}
+	if cfg.Queue.Failed.Driver != "database-uuids" || cfg.Queue.Failed.Database != "pgsql" || cfg.Queue.Failed.Table != "failed_jobs" {
+		t.Errorf("Unexpected failed jobs config %+v", cfg.Queue.Failed)
+	}
+
+	db := cfg.Database.Connections["pgsql"]
+	if cfg.Database.Default != "pgsql" || db.Port != "6432" || db.Host != "127.0.0.1" || db.Username != "root" {
+		t.Errorf("Unexpected database config %+v", db)
+	}
+	if sqlite := cfg.Database.Connections["sqlite"].Database; sqlite.String() != filepath.Join(dir, "database", "database.sqlite") {
+		t.Errorf("Expected the sqlite database in the app, got %q", sqlite)
+	}
+
+	redis := cfg.Database.Redis
+	if redis.Prefix != "shop_api_database_" || redis.Client != "phpredis" {
+		t.Errorf("Unexpected redis options %+v", redis)
+	}
+	if c := redis.Connections["cache"]; c.Database != "1" || c.Password != "" || c.Port != "6379" {
+		t.Errorf("Unexpected redis cache connection %+v", c)
+	}
+}
+
+func TestLoad_Refresh(t *testing.T) {
+	dir := writeApp(t, "APP_ENV=local\n")
+
+	first, err := Load(context.Background(), dir, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again, _ := Load(context.Background(), dir, ""); again != first {
+		t.Errorf("Expected the config to be reused while .env is unchanged")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, EnvFile), []byte("APP_ENV=production\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write .env: %v", err)
+	}
+	changed, err := Load(context.Background(), dir, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if changed == first || changed.App.Env != "production" {
+		t.Errorf("Expected the config to be read again, got env %q", changed.App.Env)
+	}
+}
+
+func TestLoad_MissingDotEnv(t *testing.T) {
+	cfg, err := Load(context.Background(), t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.App.Env != "production" || cfg.Database.Default != "sqlite" || cfg.Queue.Default != "database" {
+		t.Errorf("Expected Laravel's defaults, got %+v", cfg)
+	}
+}
+
+func TestLoad_CachedConfig(t *testing.T) {
+	dir := writeCachedApp(t, "APP_ENV=local\n")
+
+	php := writeMockPHP(t, `{"app":{"name":"Shop","env":"production","debug":false,"timezone":"UTC"},
+ "database":{"default":"mysql","connections":{"mysql":{"driver":"mysql","host":"db","port":3306,"password":null}},
+  "redis":{"client":"phpredis","options":{"prefix":"shop_"},"default":{"host":"redis","port":6379,"database":"0"}}},
+ "queue":{"default":"redis","connections":{"redis":{"driver":"redis","connection":"default","queue":"default"}},"failed":{"driver":"null"}},
+ "horizon":null}`)
+
+	cfg, err := Load(context.Background(), dir, php)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cfg.Cached || cfg.App.Env != "production" || cfg.Env["APP_ENV"] != "local" {
+		t.Errorf("Expected the cached config to win over .env, got %+v", cfg.App)
+	}
+	if db := cfg.Database.Connections["mysql"]; db.Port != "3306" || db.Password != "" || db.Host != "db" {
+		t.Errorf("Unexpected database connection %+v", db)
+	}
+	if r := cfg.Database.Redis; r.Prefix != "shop_" || r.Connections["default"].Host != "redis" {
+		t.Errorf("Unexpected redis config %+v", r)
+	}
+	if cfg.QueueDriver("redis") != "redis" || cfg.Queue.Failed.Driver != "null" || cfg.Horizon != nil {
+		t.Errorf("Unexpected queue config %+v", cfg.Queue)
+	}
+
+	if _, err := Load(context.Background(), writeCachedApp(t, ""), "/nonexistent/php"); err == nil {
+		t.Errorf("Expected an error when php can't be run")
+	}
+}
+
+func TestLoad_ConfigFiles(t *testing.T) {
+	dir := writeApp(t, "APP_NAME=Shop\nQUEUE_CONNECTION=database\n")
+	if err := os.MkdirAll(filepath.Join(dir, AppConfigDir), 0o755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	for _, file := range []string{"config/database.php", "config/queue.php"} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte("<?php return [];"), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+
+	php := writeMockPHP(t, `{"database":{"default":"pgsql","connections":{"pgsql":{"driver":"pgsql","host":"pg","prefix":"shop_"}},"redis":{"options":{"prefix":""}}},
+ "queue":{"default":"jobs","connections":{"jobs":{"driver":"database","connection":"pgsql","table":"queue_jobs"}},"failed":{"driver":"null"}}}`)
+
+	cfg, err := Load(context.Background(), dir, php)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.ConfigErr != nil || cfg.Cached {
+		t.Fatalf("Unexpected config error %v", cfg.ConfigErr)
+	}
+	if cfg.Queue.Default != "jobs" || cfg.QueueDriver("jobs") != "database" || cfg.Queue.Connections["jobs"].Table != "queue_jobs" {
+		t.Errorf("Expected the queue config from config/queue.php, got %+v", cfg.Queue)
+	}
+	if _, ok := cfg.Queue.Connections["database"]; ok {
+		t.Errorf("Expected the default connections to be replaced, got %+v", cfg.Queue.Connections)
+	}
+	if db := cfg.Database.Connections["pgsql"]; cfg.Database.Default != "pgsql" || db.Host != "pg" || db.Prefix != "shop_" {
+		t.Errorf("Expected the database config from config/database.php, got %+v", cfg.Database)
+	}
+	if cfg.App.Name != "Shop" {
+		t.Errorf("Expected the app config from .env without config/app.php, got %+v", cfg.App)
+	}
+
+	// Without a usable PHP the config is a guess from .env, and reported as such
+	if err := os.WriteFile(filepath.Join(dir, "config/queue.php"), []byte("<?php return ['default' => 'x'];"), 0o644); err != nil {
+		t.Fatalf("Failed to write config/queue.php: %v", err)
+	}
+	failing, err := Load(context.Background(), dir, "/nonexistent/php")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if failing == cfg || failing.ConfigErr == nil || failing.Queue.Default != "database" {
+		t.Errorf("Expected the config to be read again with an error, got %v, %+v", failing.ConfigErr, failing.Queue)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	env := map[string]string{"SET": "value", "NULL": "null", "EMPTY": "(empty)", "TRUE": "true", "FALSE": "(false)", "BLANK": ""}
+
+	tests := map[string]any{"SET": "value", "NULL": nil, "EMPTY": "", "TRUE": true, "FALSE": false, "BLANK": "", "MISSING": "fallback"}
+	for key, expected := range tests {
+		if got := Env(env, key, "fallback"); got != expected {
+			t.Errorf("Env(%s) = %v, expected %v", key, got, expected)
+		}
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{"Laravel": "laravel", "My Shop-API": "my_shop_api", "Shop & Co.": "shop_co", "a@b": "a_at_b"}
+	for in, expected := range tests {
+		if got := Slug(in); got != expected {
+			t.Errorf("Slug(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+func TestValue_UnmarshalJSON(t *testing.T) {
+	var v struct {
+		Port  Value `json:"port"`
+		Null  Value `json:"null"`
+		True  Value `json:"true"`
+		Debug Flag  `json:"debug"`
+		Off   Flag  `json:"off"`
+	}
+	if err := json.Unmarshal([]byte(`{"port":6379,"null":null,"true":true,"debug":"true","off":"0"}`), &v); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v.Port != "6379" || v.Null != "" || v.True != "1" || !v.Debug || v.Off {
+		t.Errorf("Unexpected values %+v", v)
+	}
+	if v.Null.Or("fallback") != "fallback" || v.Port.Or("fallback") != "6379" {
+		t.Errorf("Unexpected fallbacks")
+	}
+
+	if err := json.Unmarshal([]byte(`{"port":[1]}`), &v); err == nil {
+		t.Errorf("Expected an error for a list")
+	}
+}
+
+func TestLocation_Invalid(t *testing.T) {
+	cfg := &Config{App: AppConfig{Timezone: "Mars/Olympus"}}
+	if cfg.Location() != time.UTC {
+		t.Errorf("Expected UTC for an invalid timezone")
+	}
+}
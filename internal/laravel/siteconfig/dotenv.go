@@ -0,0 +1,224 @@
+package siteconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadDotEnv reads a Laravel .env file, see ParseDotEnv.
+func ReadDotEnv(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDotEnv(string(data))
+}
+
+// ParseDotEnv parses .env content the way phpdotenv, which Laravel uses,
+// does: an optional export prefix, literal single quoted values, double
+// quoted values with escape sequences, quoted values spanning several lines,
+// inline comments after unquoted values and ${VAR} interpolation of earlier
+// variables in unquoted and double quoted values.
+func ParseDotEnv(data string) (map[string]string, error) {
+	p := &dotenvParser{data: data, env: map[string]string{}, line: 1}
+	for !p.eof() {
+		if err := p.entry(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+	}
+	return p.env, nil
+}
+
+type dotenvParser struct {
+	data string
+	pos  int
+	line int
+	env  map[string]string
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.data[p.pos]
+}
+
+func (p *dotenvParser) next() byte {
+	c := p.data[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *dotenvParser) skipBlanks() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) skipLine() {
+	for !p.eof() && p.next() != '\n' {
+	}
+}
+
+func (p *dotenvParser) entry() error {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		p.next()
+	}
+	if p.eof() {
+		return nil
+	}
+	if p.peek() == '#' {
+		p.skipLine()
+		return nil
+	}
+
+	end := strings.IndexByte(p.data[p.pos:], '\n')
+	if end < 0 {
+		end = len(p.data) - p.pos
+	}
+	rest := p.data[p.pos : p.pos+end]
+	if after, ok := strings.CutPrefix(rest, "export"); ok && after != "" && (after[0] == ' ' || after[0] == '\t') {
+		p.pos += len("export")
+		p.skipBlanks()
+		rest = strings.TrimLeft(after, " \t")
+	}
+
+	eq := strings.IndexByte(rest, '=')
+	if eq < 0 {
+		// A bare name without a value sets nothing
+		p.skipLine()
+		return nil
+	}
+	key := strings.TrimSpace(rest[:eq])
+	if !validKey(key) {
+		return fmt.Errorf("invalid variable name %q", key)
+	}
+	p.pos += eq + 1
+	p.skipBlanks()
+
+	var value string
+	var err error
+	switch {
+	case p.eof():
+	case p.peek() == '\'':
+		value, err = p.singleQuoted()
+	case p.peek() == '"':
+		value, err = p.doubleQuoted()
+	default:
+		value = p.unquoted()
+	}
+	if err != nil {
+		return err
+	}
+	p.env[key] = value
+	return nil
+}
+
+func (p *dotenvParser) singleQuoted() (string, error) {
+	p.next()
+	end := strings.IndexByte(p.data[p.pos:], '\'')
+	if end < 0 {
+		return "", fmt.Errorf("missing closing single quote")
+	}
+	value := p.data[p.pos : p.pos+end]
+	p.line += strings.Count(value, "\n")
+	p.pos += end + 1
+	p.skipLine()
+	return value, nil
+}
+
+func (p *dotenvParser) doubleQuoted() (string, error) {
+	p.next()
+	var b strings.Builder
+	for !p.eof() {
+		c := p.next()
+		switch c {
+		case '"':
+			p.skipLine()
+			return b.String(), nil
+		case '\\':
+			if p.eof() {
+				continue
+			}
+			esc := p.next()
+			switch esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'f':
+				b.WriteByte('\f')
+			case 'v':
+				b.WriteByte('\v')
+			case '"', '\\', '$':
+				b.WriteByte(esc)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(esc)
+			}
+		case '$':
+			p.interpolate(&b)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("missing closing double quote")
+}
+
+func (p *dotenvParser) unquoted() string {
+	var b strings.Builder
+	for !p.eof() && p.peek() != '\n' {
+		c := p.next()
+		if c == '#' && (b.Len() == 0 || strings.HasSuffix(b.String(), " ") || strings.HasSuffix(b.String(), "\t")) {
+			p.skipLine()
+			break
+		}
+		if c == '$' {
+			p.interpolate(&b)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// interpolate writes the value of a ${VAR} reference following a $. Unknown
+// variables are kept as they are, like phpdotenv does.
+func (p *dotenvParser) interpolate(b *strings.Builder) {
+	rest := p.data[p.pos:]
+	if !strings.HasPrefix(rest, "{") {
+		b.WriteByte('$')
+		return
+	}
+	end := strings.IndexByte(rest, '}')
+	if end < 0 || !validKey(rest[1:end]) {
+		b.WriteByte('$')
+		return
+	}
+	name := rest[1:end]
+	p.pos += end + 1
+	if v, ok := p.env[name]; ok {
+		b.WriteString(v)
+		return
+	}
+	b.WriteString("${" + name + "}")
+}
+
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r == '_' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
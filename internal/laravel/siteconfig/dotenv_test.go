@@ -0,0 +1,88 @@
+package siteconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	env, err := ParseDotEnv(`# Application
+APP_NAME="My Shop"
+export APP_ENV=production
+APP_KEY='base64:abc=#123'
+APP_URL=https://${APP_HOST}/shop
+DB_HOST=db # the primary
+DB_PASSWORD=pa#ss
+REDIS_PASSWORD=null
+MAIL_FROM_NAME="${APP_NAME} Mailer"
+LITERAL='${APP_NAME}'
+ESCAPED="cost \$5\t\"net\""
+MULTILINE="first
+second"
+EMPTY=
+COMMENTED= # nothing
+not a variable
+`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"APP_NAME":       "My Shop",
+		"APP_ENV":        "production",
+		"APP_KEY":        "base64:abc=#123",
+		"APP_URL":        "https://${APP_HOST}/shop",
+		"DB_HOST":        "db",
+		"DB_PASSWORD":    "pa#ss",
+		"REDIS_PASSWORD": "null",
+		"MAIL_FROM_NAME": "My Shop Mailer",
+		"LITERAL":        "${APP_NAME}",
+		"ESCAPED":        "cost $5\t\"net\"",
+		"MULTILINE":      "first\nsecond",
+		"EMPTY":          "",
+		"COMMENTED":      "",
+	}
+	if len(env) != len(expected) {
+		t.Errorf("Expected %d variables, got %v", len(expected), env)
+	}
+	for k, v := range expected {
+		if env[k] != v {
+			t.Errorf("Expected %s=%q, got %q", k, v, env[k])
+		}
+	}
+}
+
+func TestParseDotEnv_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unclosed double quote": "A=\"open\nB=1\n",
+		"unclosed single quote": "A='open\n",
+		"invalid name":          "MY VAR=1\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseDotEnv(data); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestReadDotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("APP_ENV=local\r\nAPP_DEBUG=true\r\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write .env: %v", err)
+	}
+
+	env, err := ReadDotEnv(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if env["APP_ENV"] != "local" || env["APP_DEBUG"] != "true" {
+		t.Errorf("Unexpected values %v", env)
+	}
+
+	if _, err := ReadDotEnv(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,83 @@
+package siteconfig
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Horizon is the part of config/horizon.php the agent uses.
+type Horizon struct {
+	Prefix       Value                                   `json:"prefix"` // Key prefix of Horizon's own Redis keys
+	Use          Value                                   `json:"use"`    // Redis connection Horizon stores its data in
+	Defaults     map[string]HorizonSupervisor            `json:"defaults"`
+	Environments map[string]map[string]HorizonSupervisor `json:"environments"`
+}
+
+type HorizonSupervisor struct {
+	Connection Value  `json:"connection"`
+	Queue      Values `json:"queue"`
+}
+
+// Supervisors returns the supervisors Horizon runs in env: the defaults
+// merged with the environment's settings, or those of the "*" environment.
+func (h *Horizon) Supervisors(env string) map[string]HorizonSupervisor {
+	supervisors := map[string]HorizonSupervisor{}
+	overrides, ok := h.Environments[env]
+	if !ok {
+		overrides = h.Environments["*"]
+	}
+	for name, s := range overrides {
+		if d, ok := h.Defaults[name]; ok {
+			if s.Connection == "" {
+				s.Connection = d.Connection
+			}
+			if len(s.Queue) == 0 {
+				s.Queue = d.Queue
+			}
+		}
+		supervisors[name] = s
+	}
+	return supervisors
+}
+
+// Values is a config value holding one or more strings. Lists are encoded as
+// objects keyed by index when the whole config is forced to objects.
+type Values []string
+
+func (v *Values) UnmarshalJSON(data []byte) error {
+	var single Value
+	if err := single.UnmarshalJSON(data); err == nil {
+		*v = nil
+		if single != "" {
+			*v = Values{single.String()}
+		}
+		return nil
+	}
+	var list []Value
+	if err := json.Unmarshal(data, &list); err == nil {
+		*v = make(Values, len(list))
+		for i, s := range list {
+			(*v)[i] = s.String()
+		}
+		return nil
+	}
+	var indexed map[string]Value
+	if err := json.Unmarshal(data, &indexed); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(indexed))
+	for k := range indexed {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	*v = make(Values, len(keys))
+	for i, k := range keys {
+		(*v)[i] = indexed[k].String()
+	}
+	return nil
+}
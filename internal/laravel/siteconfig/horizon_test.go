@@ -0,0 +1,99 @@
+package siteconfig
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeHorizonApp(t *testing.T, env string) string {
+	t.Helper()
+
+	dir := writeApp(t, env)
+	if err := os.MkdirAll(filepath.Join(dir, "config"), 0o755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, HorizonConfig), []byte("<?php return [];"), 0o644); err != nil {
+		t.Fatalf("Failed to write horizon config: %v", err)
+	}
+	return dir
+}
+
+func TestLoad_Horizon(t *testing.T) {
+	dir := writeHorizonApp(t, "APP_NAME=Shop\nAPP_ENV=production\n")
+	php := writeMockPHP(t, `{"horizon":{"use":"horizon","defaults":{"supervisor-1":{"connection":"redis","queue":{"0":"default","1":"emails"}}},
+ "environments":{"production":{"supervisor-1":{"maxProcesses":10}},"local":{"supervisor-1":{"queue":"default"}}}}}`)
+
+	cfg, err := Load(context.Background(), dir, php)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.HorizonErr != nil {
+		t.Fatalf("Unexpected Horizon error: %v", cfg.HorizonErr)
+	}
+	if cfg.Horizon == nil || cfg.Horizon.Prefix != "shop_horizon:" || cfg.Horizon.Use != "horizon" {
+		t.Fatalf("Unexpected Horizon config %+v", cfg.Horizon)
+	}
+
+	supervisors := cfg.Horizon.Supervisors(cfg.App.Env.String())
+	expected := map[string]HorizonSupervisor{"supervisor-1": {Connection: "redis", Queue: Values{"default", "emails"}}}
+	if !reflect.DeepEqual(supervisors, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, supervisors)
+	}
+
+	// Without a usable PHP the prefix from .env is still known
+	failing, err := Load(context.Background(), writeHorizonApp(t, ""), "/nonexistent/php")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if failing.HorizonErr == nil || failing.Horizon == nil || failing.Horizon.Prefix != "laravel_horizon:" {
+		t.Errorf("Expected the Horizon error next to the defaults, got %+v", failing.Horizon)
+	}
+
+	plain, _ := Load(context.Background(), writeApp(t, ""), php)
+	if plain.Horizon != nil {
+		t.Errorf("Expected no Horizon config without config/horizon.php")
+	}
+}
+
+func TestHorizon_Supervisors(t *testing.T) {
+	h := &Horizon{
+		Defaults: map[string]HorizonSupervisor{
+			"supervisor-1": {Connection: "redis", Queue: Values{"default"}},
+			"unused":       {Connection: "redis", Queue: Values{"never"}},
+		},
+		Environments: map[string]map[string]HorizonSupervisor{
+			"*":     {"supervisor-1": {}},
+			"local": {"supervisor-1": {Queue: Values{"local"}}, "extra": {Connection: "sqs", Queue: Values{"reports"}}},
+		},
+	}
+
+	if got := h.Supervisors("staging"); !reflect.DeepEqual(got, map[string]HorizonSupervisor{"supervisor-1": {Connection: "redis", Queue: Values{"default"}}}) {
+		t.Errorf("Expected the wildcard environment, got %+v", got)
+	}
+	local := h.Supervisors("local")
+	if len(local) != 2 || local["supervisor-1"].Queue[0] != "local" || local["supervisor-1"].Connection != "redis" || local["extra"].Connection != "sqs" {
+		t.Errorf("Unexpected local supervisors %+v", local)
+	}
+}
+
+func TestValues_UnmarshalJSON(t *testing.T) {
+	tests := map[string]Values{
+		`"default"`:                          {"default"},
+		`["high","low"]`:                     {"high", "low"},
+		`{"0":"a","1":"b","10":"k","2":"c"}`: {"a", "b", "c", "k"},
+		`null`:                               nil,
+	}
+	for data, expected := range tests {
+		var v Values
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", data, err)
+		}
+		if !reflect.DeepEqual(v, expected) {
+			t.Errorf("Unmarshal(%s) = %v, expected %v", data, v, expected)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package laravel
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/laravel/siteconfig"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	WorkerCommandWork    = "queue:work"
+	WorkerCommandListen  = "queue:listen"
+	WorkerCommandHorizon = "horizon:work"
+
+	// Defaults of the queue:work options
+	defaultWorkerMemoryMB = 128
+	defaultWorkerTimeout  = 60
+)
+
+var (
+	workerTracker     = map[string]*workerCounters{}
+	workerTrackerLock sync.Mutex
+
+	// listProcesses is a variable so tests can fake the process table.
+	listProcesses = scanProcesses
+)
+
+// QueueWorker is a running queue:work, queue:listen or horizon:work process.
+type QueueWorker struct {
+	PID           int32     `json:"pid"`
+	Command       string    `json:"command"`
+	Connection    string    `json:"connection"`
+	Queues        []string  `json:"queues"`
+	MemoryLimitMB int       `json:"memory_limit_mb"`
+	Timeout       int       `json:"timeout"`
+	Supervisor    string    `json:"supervisor,omitempty"` // Horizon supervisor
+	RSSBytes      uint64    `json:"rss_bytes"`
+	StartedAt     time.Time `json:"started_at"`
+}
+
+// WorkerGroup aggregates the workers processing a queue. A worker processing
+// several queues counts for each of them.
+type WorkerGroup struct {
+	Connection       string  `json:"connection"`
+	Queue            string  `json:"queue"`
+	Workers          int     `json:"workers"`
+	RSSBytes         uint64  `json:"rss_bytes"`
+	MaxRSSBytes      uint64  `json:"max_rss_bytes"`
+	MemoryLimitBytes uint64  `json:"memory_limit_bytes"` // Lowest --memory of the workers
+	MinUptimeSeconds float64 `json:"min_uptime_seconds"`
+	MaxUptimeSeconds float64 `json:"max_uptime_seconds"`
+	// Restarts counts workers that exited since the agent started, process
+	// managers start a new one in their place.
+	Restarts uint64 `json:"restarts"`
+}
+
+type QueueWorkers struct {
+	Workers []QueueWorker `json:"workers"`
+	Queues  []WorkerGroup `json:"queues"`
+}
+
+// workerProcess is a process running an artisan queue worker command.
+type workerProcess struct {
+	pid       int32
+	ppid      int32
+	appDir    string
+	args      []string // After the artisan script: the command and its options
+	rss       uint64
+	startedAt time.Time
+}
+
+// workerID tells a worker apart from a later one reusing its PID.
+type workerID struct {
+	pid       int32
+	startedAt int64
+}
+
+type workerCounters struct {
+	workers  map[workerID]bool
+	restarts uint64
+}
+
+// scanProcesses lists the processes running artisan worker commands.
+func scanProcesses() ([]workerProcess, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	var found []workerProcess
+	for _, p := range procs {
+		cmdline, err := p.CmdlineSlice()
+		if err != nil {
+			continue
+		}
+		artisan, args := splitArtisan(cmdline)
+		if artisan == "" || len(args) == 0 || workerCommand(args[0]) == "" {
+			continue
+		}
+
+		appDir := filepath.Dir(artisan)
+		if !filepath.IsAbs(artisan) {
+			cwd, err := p.Cwd()
+			if err != nil {
+				continue
+			}
+			appDir = filepath.Join(cwd, appDir)
+		}
+
+		wp := workerProcess{pid: p.Pid, appDir: appDir, args: args}
+		wp.ppid, _ = p.Ppid()
+		if mem, err := p.MemoryInfo(); err == nil {
+			wp.rss = mem.RSS
+		}
+		if created, err := p.CreateTime(); err == nil {
+			wp.startedAt = time.UnixMilli(created)
+		}
+		found = append(found, wp)
+	}
+	return found, nil
+}
+
+// splitArtisan finds the artisan script in a command line, e.g.
+// php -d memory_limit=-1 /var/www/artisan queue:work, and returns it with the
+// arguments following it.
+func splitArtisan(cmdline []string) (string, []string) {
+	for i, arg := range cmdline {
+		if filepath.Base(arg) == "artisan" {
+			return arg, cmdline[i+1:]
+		}
+	}
+	return "", nil
+}
+
+func workerCommand(command string) string {
+	switch command {
+	case WorkerCommandWork, WorkerCommandListen, WorkerCommandHorizon:
+		return command
+	}
+	return ""
+}
+
+// parseWorker reads a worker's connection argument and options. Workers
+// without a connection use the site's default one and its default queue.
+func parseWorker(args []string, sc *siteconfig.Config) QueueWorker {
+	w := QueueWorker{Command: args[0], MemoryLimitMB: defaultWorkerMemoryMB, Timeout: defaultWorkerTimeout}
+
+	options := map[string]string{}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		name, ok := strings.CutPrefix(arg, "--")
+		if !ok {
+			if w.Connection == "" {
+				w.Connection = arg
+			}
+			continue
+		}
+		if key, value, ok := strings.Cut(name, "="); ok {
+			options[key] = value
+			continue
+		}
+		// --queue high takes the next argument, flags such as --once do not
+		if valueOptions[name] && i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			options[name] = args[i+1]
+			i++
+			continue
+		}
+		options[name] = ""
+	}
+
+	if w.Connection == "" && sc != nil {
+		w.Connection = sc.Queue.Default.String()
+	}
+	if w.Connection == "" {
+		w.Connection = "default"
+	}
+	if queues := options["queue"]; queues != "" {
+		w.Queues = strings.Split(queues, ",")
+	} else if sc != nil {
+		w.Queues = []string{sc.Queue.Connections[w.Connection].Queue.Or("default")}
+	} else {
+		w.Queues = []string{"default"}
+	}
+	if n, err := strconv.Atoi(options["memory"]); err == nil {
+		w.MemoryLimitMB = n
+	}
+	if n, err := strconv.Atoi(options["timeout"]); err == nil {
+		w.Timeout = n
+	}
+	w.Supervisor = options["supervisor"]
+	return w
+}
+
+// valueOptions are the worker options that take a value.
+var valueOptions = map[string]bool{
+	"name": true, "queue": true, "delay": true, "backoff": true, "max-jobs": true, "max-time": true,
+	"memory": true, "sleep": true, "rest": true, "timeout": true, "tries": true, "supervisor": true,
+}
+
+// siteWorkers returns the workers running in the site's directory, leaving
+// out the one-off queue:work processes queue:listen starts for every job.
+func siteWorkers(key, appPath string, sc *siteconfig.Config, procs []workerProcess, now time.Time) *QueueWorkers {
+	dir := filepath.Clean(appPath)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	listeners := map[int32]bool{}
+	for _, p := range procs {
+		if p.args[0] == WorkerCommandListen {
+			listeners[p.pid] = true
+		}
+	}
+
+	result := &QueueWorkers{Workers: []QueueWorker{}, Queues: []WorkerGroup{}}
+	groups := map[string]*WorkerGroup{}
+	current := map[string]map[workerID]bool{}
+	for _, p := range procs {
+		if listeners[p.ppid] {
+			continue
+		}
+		appDir := p.appDir
+		if resolved, err := filepath.EvalSymlinks(appDir); err == nil {
+			appDir = resolved
+		}
+		if appDir != dir {
+			continue
+		}
+
+		w := parseWorker(p.args, sc)
+		w.PID = p.pid
+		w.RSSBytes = p.rss
+		w.StartedAt = p.startedAt
+		result.Workers = append(result.Workers, w)
+
+		uptime := now.Sub(p.startedAt).Seconds()
+		limit := uint64(w.MemoryLimitMB) * 1024 * 1024
+		for _, q := range w.Queues {
+			groupKey := w.Connection + ":" + q
+			g, ok := groups[groupKey]
+			if !ok {
+				g = &WorkerGroup{Connection: w.Connection, Queue: q, MemoryLimitBytes: limit, MinUptimeSeconds: uptime}
+				groups[groupKey] = g
+				current[groupKey] = map[workerID]bool{}
+			}
+			g.Workers++
+			g.RSSBytes += p.rss
+			g.MaxRSSBytes = max(g.MaxRSSBytes, p.rss)
+			g.MemoryLimitBytes = min(g.MemoryLimitBytes, limit)
+			g.MinUptimeSeconds = min(g.MinUptimeSeconds, uptime)
+			g.MaxUptimeSeconds = max(g.MaxUptimeSeconds, uptime)
+			current[groupKey][workerID{p.pid, p.startedAt.UnixMilli()}] = true
+		}
+	}
+
+	restarts := trackWorkers(key, current)
+	for groupKey, g := range groups {
+		g.Restarts = restarts[groupKey]
+		result.Queues = append(result.Queues, *g)
+	}
+	// Queues whose workers are all gone keep reporting their restarts
+	for groupKey, n := range restarts {
+		if _, ok := groups[groupKey]; !ok {
+			conn, q, _ := strings.Cut(groupKey, ":")
+			result.Queues = append(result.Queues, WorkerGroup{Connection: conn, Queue: q, Restarts: n})
+		}
+	}
+
+	sort.Slice(result.Workers, func(i, j int) bool { return result.Workers[i].PID < result.Workers[j].PID })
+	sort.Slice(result.Queues, func(i, j int) bool {
+		if result.Queues[i].Connection != result.Queues[j].Connection {
+			return result.Queues[i].Connection < result.Queues[j].Connection
+		}
+		return result.Queues[i].Queue < result.Queues[j].Queue
+	})
+	return result
+}
+
+// trackWorkers counts the workers of each queue that are gone since the
+// previous collection and returns the restart totals per queue.
+func trackWorkers(key string, current map[string]map[workerID]bool) map[string]uint64 {
+	workerTrackerLock.Lock()
+	defer workerTrackerLock.Unlock()
+
+	restarts := map[string]uint64{}
+	for groupKey, workers := range current {
+		trackerKey := key + "|" + groupKey
+		wc, ok := workerTracker[trackerKey]
+		if !ok {
+			wc = &workerCounters{}
+			workerTracker[trackerKey] = wc
+		}
+		for id := range wc.workers {
+			if !workers[id] {
+				wc.restarts++
+			}
+		}
+		wc.workers = workers
+	}
+
+	prefix := key + "|"
+	for trackerKey, wc := range workerTracker {
+		groupKey, ok := strings.CutPrefix(trackerKey, prefix)
+		if !ok {
+			continue
+		}
+		if _, ok := current[groupKey]; !ok {
+			wc.restarts += uint64(len(wc.workers))
+			wc.workers = nil
+		}
+		if wc.restarts > 0 || current[groupKey] != nil {
+			restarts[groupKey] = wc.restarts
+		}
+	}
+	return restarts
+}
@@ -0,0 +1,165 @@
+package laravel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+func TestParseWorker(t *testing.T) {
+	sc := loadSite(t, writeSite(t, "QUEUE_CONNECTION=redis\nREDIS_QUEUE=jobs\n"))
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected QueueWorker
+	}{
+		{
+			name:     "defaults from the site config",
+			args:     []string{"queue:work"},
+			expected: QueueWorker{Command: "queue:work", Connection: "redis", Queues: []string{"jobs"}, MemoryLimitMB: 128, Timeout: 60},
+		},
+		{
+			name:     "connection and options",
+			args:     []string{"queue:work", "database", "--queue=high,low", "--memory", "256", "--once", "--timeout=90"},
+			expected: QueueWorker{Command: "queue:work", Connection: "database", Queues: []string{"high", "low"}, MemoryLimitMB: 256, Timeout: 90},
+		},
+		{
+			name: "horizon",
+			args: []string{"horizon:work", "redis", "--name=default", "--supervisor=host-1:supervisor-1", "--queue=default", "--memory=512"},
+			expected: QueueWorker{Command: "horizon:work", Connection: "redis", Queues: []string{"default"}, MemoryLimitMB: 512, Timeout: 60,
+				Supervisor: "host-1:supervisor-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseWorker(tt.args, sc); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+
+	if got := parseWorker([]string{"queue:listen"}, nil); got.Connection != "default" || !reflect.DeepEqual(got.Queues, []string{"default"}) {
+		t.Errorf("Expected the default connection and queue without a site config, got %+v", got)
+	}
+}
+
+func TestSplitArtisan(t *testing.T) {
+	artisan, args := splitArtisan([]string{"/usr/bin/php8.3", "-d", "memory_limit=-1", "/var/www/artisan", "queue:work", "redis"})
+	if artisan != "/var/www/artisan" || !reflect.DeepEqual(args, []string{"queue:work", "redis"}) {
+		t.Errorf("Unexpected split %q %v", artisan, args)
+	}
+	if artisan, _ := splitArtisan([]string{"php-fpm: pool www"}); artisan != "" {
+		t.Errorf("Expected no artisan script, got %q", artisan)
+	}
+}
+
+func TestSiteWorkers(t *testing.T) {
+	site := writeSite(t, "QUEUE_CONNECTION=redis\n")
+	sc := loadSite(t, site)
+	now := time.Now()
+	started := now.Add(-time.Hour)
+
+	procs := []workerProcess{
+		{pid: 10, appDir: site, args: []string{"queue:work", "--queue=high,default"}, rss: 100, startedAt: started},
+		{pid: 11, appDir: site, args: []string{"queue:work", "--memory=64"}, rss: 300, startedAt: now.Add(-time.Minute)},
+		{pid: 12, appDir: site, args: []string{"queue:listen", "database"}, rss: 50, startedAt: started},
+		{pid: 13, ppid: 12, appDir: site, args: []string{"queue:work", "database", "--once"}, rss: 50, startedAt: now},
+		{pid: 14, appDir: t.TempDir(), args: []string{"queue:work"}, rss: 50, startedAt: started},
+	}
+
+	w := siteWorkers("site-workers", site, sc, procs, now)
+	if len(w.Workers) != 3 {
+		t.Fatalf("Expected 3 workers, got %+v", w.Workers)
+	}
+
+	expected := []WorkerGroup{
+		{Connection: "database", Queue: "default", Workers: 1, RSSBytes: 50, MaxRSSBytes: 50, MemoryLimitBytes: 128 << 20, MinUptimeSeconds: 3600, MaxUptimeSeconds: 3600},
+		{Connection: "redis", Queue: "default", Workers: 2, RSSBytes: 400, MaxRSSBytes: 300, MemoryLimitBytes: 64 << 20, MinUptimeSeconds: 60, MaxUptimeSeconds: 3600},
+		{Connection: "redis", Queue: "high", Workers: 1, RSSBytes: 100, MaxRSSBytes: 100, MemoryLimitBytes: 128 << 20, MinUptimeSeconds: 3600, MaxUptimeSeconds: 3600},
+	}
+	if !reflect.DeepEqual(w.Queues, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, w.Queues)
+	}
+
+	// Worker 11 is replaced and the listener is gone
+	procs[1] = workerProcess{pid: 15, appDir: site, args: []string{"queue:work"}, rss: 100, startedAt: now}
+	w = siteWorkers("site-workers", site, sc, procs[:2], now)
+	restarts := map[string]uint64{}
+	for _, g := range w.Queues {
+		restarts[g.Connection+":"+g.Queue] = g.Restarts
+	}
+	if !reflect.DeepEqual(restarts, map[string]uint64{"redis:default": 1, "redis:high": 0, "database:default": 1}) {
+		t.Errorf("Unexpected restarts %v", restarts)
+	}
+}
+
+func TestScanProcesses(t *testing.T) {
+	site := t.TempDir()
+	if err := os.WriteFile(filepath.Join(site, "artisan"), []byte("sleep 30\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write artisan: %v", err)
+	}
+	cmd := exec.Command("/bin/sh", "artisan", "queue:work", "redis", "--queue=scan-test")
+	cmd.Dir = site
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start worker: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	procs, err := scanProcesses()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	w := siteWorkers("scan-test", site, nil, procs, time.Now())
+	if len(w.Workers) != 1 || w.Workers[0].PID != int32(cmd.Process.Pid) || w.Workers[0].Queues[0] != "scan-test" {
+		t.Errorf("Expected the started worker, got %+v", w.Workers)
+	}
+}
+
+func TestCollect_Workers(t *testing.T) {
+	site := writeSite(t, "QUEUE_CONNECTION=sqs\n")
+	original := listProcesses
+	t.Cleanup(func() { listProcesses = original })
+
+	scans := 0
+	listProcesses = func() ([]workerProcess, error) {
+		scans++
+		return []workerProcess{{pid: 1, appDir: site, args: []string{"queue:work"}, startedAt: time.Now()}}, nil
+	}
+	cfg := &config.Config{
+		Laravel: []config.LaravelConfig{
+			{Name: "App", Path: site, QueueBackend: QueueBackendNative, MonitorWorkers: true},
+			{Name: "Other", Path: t.TempDir(), QueueBackend: QueueBackendNative, MonitorWorkers: true},
+		},
+	}
+
+	result, errs := Collect(context.Background(), cfg)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if scans != 1 {
+		t.Errorf("Expected one process scan for all sites, got %d", scans)
+	}
+	if w := result["App"].Workers; w == nil || len(w.Queues) != 1 || w.Queues[0].Connection != "sqs" {
+		t.Errorf("Expected an sqs worker, got %+v", w)
+	}
+	if w := result["Other"].Workers; w == nil || len(w.Workers) != 0 {
+		t.Errorf("Expected no workers for the other site, got %+v", w)
+	}
+
+	listProcesses = func() ([]workerProcess, error) { return nil, errors.New("denied") }
+	if _, errs := Collect(context.Background(), cfg); errs["laravel:App:workers"] != "denied" {
+		t.Errorf("Expected the scan error, got %v", errs)
+	}
+}
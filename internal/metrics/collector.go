@@ -2,8 +2,12 @@ package metrics
 
 import (
 	"context"
+	"github.com/elasticphphq/agent/internal/cgroup"
 	"github.com/elasticphphq/agent/internal/laravel"
+	"github.com/elasticphphq/agent/internal/logging"
 	"github.com/elasticphphq/agent/internal/server"
+	"log/slog"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -13,20 +17,25 @@ import (
 
 type Listener func(*Metrics)
 
+// PoolListener receives every successful per-pool status snapshot.
+type PoolListener func(config.FPMPoolConfig, *phpfpm.Result)
+
 type Collector struct {
-	cfg       *config.Config
-	interval  time.Duration
-	listeners []Listener
-	mu        sync.Mutex
-	results   map[string]*phpfpm.Result
+	cfg           *config.Config
+	interval      time.Duration
+	listeners     []Listener
+	poolListeners []PoolListener
+	mu            sync.Mutex
+	results       map[string]*phpfpm.Result
 }
 
 func NewCollector(cfg *config.Config, interval time.Duration) *Collector {
 	return &Collector{
-		cfg:       cfg,
-		interval:  interval,
-		listeners: make([]Listener, 0),
-		results:   make(map[string]*phpfpm.Result),
+		cfg:           cfg,
+		interval:      interval,
+		listeners:     make([]Listener, 0),
+		poolListeners: make([]PoolListener, 0),
+		results:       make(map[string]*phpfpm.Result),
 	}
 }
 
@@ -44,6 +53,22 @@ func (c *Collector) notify(m *Metrics) {
 	}
 }
 
+func (c *Collector) AddPoolListener(fn PoolListener) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.poolListeners = append(c.poolListeners, fn)
+}
+
+func (c *Collector) notifyPool(poolCfg config.FPMPoolConfig, result *phpfpm.Result) {
+	c.mu.Lock()
+	listeners := append([]PoolListener{}, c.poolListeners...)
+	c.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(poolCfg, result)
+	}
+}
+
 func (c *Collector) Run(ctx context.Context) {
 	ticker := time.NewTicker(c.interval)
 	defer ticker.Stop()
@@ -89,21 +114,65 @@ func (c *Collector) RunPerPoolCollector(ctx context.Context) {
 
 					c.mu.Lock()
 					if err == nil {
-						c.results[poolCfg.Socket] = result
+						c.results[phpfpm.PoolKey(poolCfg)] = result
 					} else {
-						c.results[poolCfg.Socket] = &phpfpm.Result{
+						c.results[phpfpm.PoolKey(poolCfg)] = &phpfpm.Result{
 							Timestamp: time.Now(),
+							Identity:  phpfpm.NewPoolIdentity(poolCfg, ""),
 							Pools:     nil,
 							Global:    nil,
 						}
 					}
 					c.mu.Unlock()
+
+					if err == nil {
+						c.notifyPool(poolCfg, result)
+					}
 				}
 			}
 		}(pool)
 	}
 }
 
+// StartBackground starts the collectors that run on their own schedule,
+// independent of scrapes, until ctx is cancelled.
+func StartBackground(ctx context.Context, cfg *config.Config) {
+	if cfg.Host.Enabled {
+		server.StartCPUSampler(ctx, cfg.Host.CPUInterval)
+	}
+	if cfg.PHPFpm.Enabled {
+		phpfpm.StartPingProbes(ctx, cfg)
+		startPoolSnapshots(ctx, cfg)
+	}
+}
+
+// startPoolSnapshots polls the full status of each pool every poll interval
+// for the request sampler and the leak detector. Nothing is polled unless
+// one of them is enabled, the poller is returned when it runs.
+func startPoolSnapshots(ctx context.Context, cfg *config.Config) *Collector {
+	poller := NewCollector(cfg, cfg.PHPFpm.PollInterval)
+	listeners := 0
+
+	if err := phpfpm.ConfigureSampler(cfg.PHPFpm.Sampler); err != nil {
+		logging.L().Error("ElasticPHP-agent Invalid request sampler config", slog.Any("err", err))
+	} else if cfg.PHPFpm.Sampler.Enabled {
+		poller.AddPoolListener(phpfpm.ObserveSample)
+		listeners++
+	}
+
+	phpfpm.ConfigureLeakDetection(cfg.PHPFpm.LeakDetection)
+	if cfg.PHPFpm.LeakDetection.Enabled {
+		poller.AddPoolListener(phpfpm.ObserveLeakSample)
+		listeners++
+	}
+
+	if listeners == 0 {
+		return nil
+	}
+	poller.RunPerPoolCollector(ctx)
+	return poller
+}
+
 func GetMetrics(ctx context.Context, cfg *config.Config) (*Metrics, error) {
 	out := &Metrics{
 		Timestamp: time.Now(),
@@ -116,6 +185,23 @@ func GetMetrics(ctx context.Context, cfg *config.Config) (*Metrics, error) {
 		out.Errors[k] = v
 	}
 
+	if cfg.Kubernetes.Enabled {
+		out.Kubernetes = server.DetectKubernetes(cfg.Kubernetes.PodInfoPath)
+	}
+
+	// Read on every call, throttling and memory events are counters
+	if stats, err := cgroup.Read(); err == nil {
+		out.Cgroup = stats
+	}
+
+	if cfg.Host.Enabled {
+		hostData := server.CollectHost(hostPaths(cfg))
+		out.Host = hostData.Host
+		for k, v := range hostData.Errors {
+			out.Errors[k] = v
+		}
+	}
+
 	if cfg.PHPFpm.Enabled {
 		fpmResults, err := phpfpm.GetMetrics(ctx, cfg)
 		if err != nil {
@@ -139,3 +225,18 @@ func GetMetrics(ctx context.Context, cfg *config.Config) (*Metrics, error) {
 
 	return out, nil
 }
+
+// hostPaths lists the paths to report disk usage for: configured paths,
+// Laravel app paths and the directories of unix FPM sockets.
+func hostPaths(cfg *config.Config) []string {
+	paths := append([]string{}, cfg.Host.Paths...)
+	for _, site := range cfg.Laravel {
+		paths = append(paths, site.Path)
+	}
+	for _, pool := range cfg.PHPFpm.Pools {
+		if scheme, address, _, err := phpfpm.ParseAddress(pool.Socket, ""); err == nil && scheme == "unix" && address != "" {
+			paths = append(paths, filepath.Dir(address))
+		}
+	}
+	return paths
+}
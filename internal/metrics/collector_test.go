@@ -2,11 +2,16 @@ package metrics
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"net/http/fcgi"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+	"github.com/elasticphphq/agent/internal/phpfpm"
 )
 
 func TestNewCollector(t *testing.T) {
@@ -241,11 +246,11 @@ func TestCollector_RunPerPoolCollector(t *testing.T) {
 	}
 
 	// Check that both sockets have results
-	if _, exists := collector.results["unix:///tmp/test1.sock"]; !exists {
+	if _, exists := collector.results[phpfpm.PoolKey(cfg.PHPFpm.Pools[0])]; !exists {
 		t.Errorf("Expected result for test1.sock")
 	}
 
-	if _, exists := collector.results["unix:///tmp/test2.sock"]; !exists {
+	if _, exists := collector.results[phpfpm.PoolKey(cfg.PHPFpm.Pools[1])]; !exists {
 		t.Errorf("Expected result for test2.sock")
 	}
 }
@@ -347,4 +352,115 @@ func TestListener_FunctionType(t *testing.T) {
 	}
 
 	listener(testMetrics)
-}
\ No newline at end of file
+}
+
+func TestCollector_AddPoolListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go fcgi.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pool":"www","process manager":"dynamic","processes":[{"pid":1,"state":"Running","request uri":"/checkout"}]}`))
+	}))
+
+	socket := "tcp://" + listener.Addr().String()
+	cfg := &config.Config{
+		PHPFpm: config.FPMConfig{
+			Enabled: true,
+			Pools: []config.FPMPoolConfig{
+				{Socket: socket, StatusSocket: socket, StatusPath: "/status", PollInterval: 20 * time.Millisecond},
+				{Socket: "unix:///tmp/nonexistent-listener.sock", StatusSocket: "unix:///tmp/nonexistent-listener.sock", StatusPath: "/status", PollInterval: 20 * time.Millisecond},
+			},
+		},
+	}
+	collector := NewCollector(cfg, time.Second)
+
+	received := make(chan string, 10)
+	collector.AddPoolListener(func(poolCfg config.FPMPoolConfig, result *phpfpm.Result) {
+		if _, ok := result.Pools["www"]; ok {
+			received <- poolCfg.Socket
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	collector.RunPerPoolCollector(ctx)
+
+	select {
+	case got := <-received:
+		if got != socket {
+			t.Errorf("Expected a snapshot for %s, got %s", socket, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected pool listener to receive a snapshot")
+	}
+}
+
+func TestStartBackground_PingProbes(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	pool := config.FPMPoolConfig{Name: "www", Socket: "tcp://127.0.0.1:1", PingPath: "/ping", Timeout: 50 * time.Millisecond}
+	cfg := &config.Config{PHPFpm: config.FPMConfig{Enabled: true, PingInterval: 10 * time.Millisecond, Pools: []config.FPMPoolConfig{pool}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartBackground(ctx, cfg)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for phpfpm.GetPingStats(pool) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the ping probe to run in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := phpfpm.GetPingStats(pool); stats.Up || stats.Failures == 0 {
+		t.Errorf("Expected failed probes against a closed port, got %+v", stats)
+	}
+}
+
+func TestStartPoolSnapshots(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := &config.Config{PHPFpm: config.FPMConfig{Enabled: true, PollInterval: time.Hour}}
+	if poller := startPoolSnapshots(ctx, cfg); poller != nil {
+		t.Errorf("Expected no polling without the sampler or leak detection")
+	}
+
+	cfg.PHPFpm.Sampler.Enabled = true
+	cfg.PHPFpm.LeakDetection.Enabled = true
+	poller := startPoolSnapshots(ctx, cfg)
+	if poller == nil || len(poller.poolListeners) != 2 {
+		t.Fatalf("Expected the sampler and leak detector to be fed, got %+v", poller)
+	}
+}
+
+func TestHostPaths(t *testing.T) {
+	cfg := &config.Config{
+		Host:    config.HostConfig{Enabled: true, Paths: []string{"/var/log"}},
+		Laravel: []config.LaravelConfig{{Path: "/var/www/app"}},
+		PHPFpm: config.FPMConfig{
+			Pools: []config.FPMPoolConfig{
+				{Socket: "unix:///run/php/php8.3-fpm.sock"},
+				{Socket: "tcp://127.0.0.1:9000"},
+				{Socket: "/var/run/php-fpm/www.sock"},
+			},
+		},
+	}
+
+	paths := hostPaths(cfg)
+	expected := []string{"/var/log", "/var/www/app", "/run/php", "/var/run/php-fpm"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected paths %v, got %v", expected, paths)
+	}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Errorf("Expected path %q at %d, got %q", expected[i], i, paths[i])
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"github.com/elasticphphq/agent/internal/cgroup"
 	"github.com/elasticphphq/agent/internal/laravel"
 	"github.com/elasticphphq/agent/internal/phpfpm"
 	"github.com/elasticphphq/agent/internal/server"
@@ -8,9 +9,12 @@ import (
 )
 
 type Metrics struct {
-	Timestamp time.Time
-	Server    *server.SystemInfo
-	Fpm       map[string]*phpfpm.Result
-	Laravel   map[string]*laravel.LaravelMetrics `json:"laravel,omitempty"`
-	Errors    map[string]string
+	Timestamp  time.Time
+	Server     *server.SystemInfo
+	Kubernetes *server.KubernetesMetadata `json:"kubernetes,omitempty"`
+	Host       *server.HostMetrics        `json:"host,omitempty"`
+	Cgroup     *cgroup.Stats              `json:"cgroup,omitempty"`
+	Fpm        map[string]*phpfpm.Result
+	Laravel    map[string]*laravel.LaravelMetrics `json:"laravel,omitempty"`
+	Errors     map[string]string
 }
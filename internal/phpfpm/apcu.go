@@ -0,0 +1,155 @@
+package phpfpm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/fcgx"
+	"os"
+	"path/filepath"
+)
+
+type ApcuStatus struct {
+	Enabled bool          `json:"apcu_enabled"`
+	Cache   ApcuCacheInfo `json:"cache_info"`
+	Memory  ApcuSmaInfo   `json:"sma_info"`
+}
+
+type ApcuCacheInfo struct {
+	NumSlots   uint64 `json:"num_slots"`
+	NumHits    uint64 `json:"num_hits"`
+	NumMisses  uint64 `json:"num_misses"`
+	NumInserts uint64 `json:"num_inserts"`
+	NumEntries uint64 `json:"num_entries"`
+	Expunges   uint64 `json:"expunges"`
+	StartTime  int64  `json:"start_time"`
+	MemSize    uint64 `json:"mem_size"`
+}
+
+type ApcuSmaInfo struct {
+	NumSeg        uint64  `json:"num_seg"`
+	SegSize       uint64  `json:"seg_size"`
+	AvailMem      uint64  `json:"avail_mem"`
+	Fragmentation float64 `json:"fragmentation"`
+}
+
+// apcuStatusScript reports APCu cache and shared memory statistics. Only the
+// limited variants are sent back, but fragmentation needs the full free block
+// list, which is walked in the worker the same way apc.php does, counting free
+// blocks smaller than 5MB.
+const apcuStatusScript = `<?php
+error_reporting(0);
+ini_set('display_errors', 0);
+header("Status: 200 OK");
+header("Content-Type: application/json");
+if (!function_exists('apcu_cache_info') || !apcu_enabled()) {
+	echo json_encode(['apcu_enabled' => false]);
+	exit;
+}
+$cache = apcu_cache_info(true);
+$sma = apcu_sma_info(true);
+$blocks = apcu_sma_info(false);
+$fragSize = 0;
+$freeTotal = 0;
+if (isset($blocks['block_lists'])) {
+	foreach ($blocks['block_lists'] as $list) {
+		foreach ($list as $block) {
+			if ($block['size'] < 5 * 1024 * 1024) {
+				$fragSize += $block['size'];
+			}
+			$freeTotal += $block['size'];
+		}
+	}
+}
+$sma['fragmentation'] = $freeTotal > 0 ? ($fragSize / $freeTotal) * 100 : 0;
+echo json_encode(['apcu_enabled' => true, 'cache_info' => $cache, 'sma_info' => $sma]);
+exit;`
+
+func GetApcuStatus(ctx context.Context, cfg config.FPMPoolConfig) (*ApcuStatus, error) {
+	tmpPath := "/tmp/elasticphp-apcu-status.php"
+	if err := writeStatusScript(tmpPath, apcuStatusScript); err != nil {
+		return nil, fmt.Errorf("failed to write PHP script: %w", err)
+	}
+
+	scheme, address, _, err := ParseAddress(cfg.StatusSocket, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid socket: %w", err)
+	}
+	if isHTTPScheme(scheme) {
+		return nil, fmt.Errorf("APCu status %w", ErrFastCGIRequired)
+	}
+
+	client, err := fcgx.DialContext(ctx, scheme, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial FPM: %w", err)
+	}
+	defer client.Close()
+
+	scriptPath := tmpPath
+	env := map[string]string{
+		"SCRIPT_FILENAME": scriptPath,
+		"SCRIPT_NAME":     "/" + filepath.Base(scriptPath),
+		"SERVER_SOFTWARE": "elasticphp-agent",
+		"REMOTE_ADDR":     "127.0.0.1",
+	}
+
+	resp, err := client.Get(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("fcgi GET failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := fcgx.ReadBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apcu response: %w", err)
+	}
+
+	var status ApcuStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse apcu JSON: %w", err)
+	}
+
+	return &status, nil
+}
+
+// writeStatusScript replaces the script at path with content on every call,
+// so a stale or tampered file in the shared temp directory is never executed.
+// The new file is renamed over the old one, which fails rather than following
+// a symlink or overwriting a file another user owns in a sticky directory.
+func writeStatusScript(path, content string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if string(written) != content {
+		return fmt.Errorf("%s was modified after writing", path)
+	}
+	return nil
+}
+
+// MemorySize returns the total size of the APCu shared memory across segments.
+func (s ApcuStatus) MemorySize() uint64 {
+	return s.Memory.NumSeg * s.Memory.SegSize
+}
@@ -0,0 +1,173 @@
+package phpfpm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+)
+
+func TestApcuStatus_UnmarshalPHPOutput(t *testing.T) {
+	// Shape of the JSON produced by the injected APCu script
+	payload := `{
+		"apcu_enabled": true,
+		"cache_info": {
+			"num_slots": 4099,
+			"ttl": 0,
+			"num_hits": 1200,
+			"num_misses": 300,
+			"num_inserts": 310,
+			"num_entries": 250,
+			"expunges": 2,
+			"start_time": 1700000000,
+			"mem_size": 1048576,
+			"memory_type": "mmap"
+		},
+		"sma_info": {
+			"num_seg": 1,
+			"seg_size": 33554432,
+			"avail_mem": 30000000,
+			"fragmentation": 12.5
+		}
+	}`
+
+	var status ApcuStatus
+	if err := json.Unmarshal([]byte(payload), &status); err != nil {
+		t.Fatalf("Failed to unmarshal APCu payload: %v", err)
+	}
+
+	if !status.Enabled {
+		t.Errorf("Expected Enabled to be true")
+	}
+	if status.Cache.NumHits != 1200 {
+		t.Errorf("Expected NumHits to be 1200, got %d", status.Cache.NumHits)
+	}
+	if status.Cache.NumMisses != 300 {
+		t.Errorf("Expected NumMisses to be 300, got %d", status.Cache.NumMisses)
+	}
+	if status.Cache.NumInserts != 310 {
+		t.Errorf("Expected NumInserts to be 310, got %d", status.Cache.NumInserts)
+	}
+	if status.Cache.NumEntries != 250 {
+		t.Errorf("Expected NumEntries to be 250, got %d", status.Cache.NumEntries)
+	}
+	if status.Cache.Expunges != 2 {
+		t.Errorf("Expected Expunges to be 2, got %d", status.Cache.Expunges)
+	}
+	if status.Memory.AvailMem != 30000000 {
+		t.Errorf("Expected AvailMem to be 30000000, got %d", status.Memory.AvailMem)
+	}
+	if status.Memory.Fragmentation != 12.5 {
+		t.Errorf("Expected Fragmentation to be 12.5, got %f", status.Memory.Fragmentation)
+	}
+	if status.MemorySize() != 33554432 {
+		t.Errorf("Expected MemorySize to be 33554432, got %d", status.MemorySize())
+	}
+}
+
+func TestApcuStatus_NotLoaded(t *testing.T) {
+	var status ApcuStatus
+	if err := json.Unmarshal([]byte(`{"apcu_enabled": false}`), &status); err != nil {
+		t.Fatalf("Failed to unmarshal APCu payload: %v", err)
+	}
+
+	if status.Enabled {
+		t.Errorf("Expected Enabled to be false when APCu is not loaded")
+	}
+	if status.MemorySize() != 0 {
+		t.Errorf("Expected MemorySize to be 0, got %d", status.MemorySize())
+	}
+}
+
+func TestApcuStatus_MemorySizeMultipleSegments(t *testing.T) {
+	status := ApcuStatus{Memory: ApcuSmaInfo{NumSeg: 4, SegSize: 1024}}
+	if status.MemorySize() != 4096 {
+		t.Errorf("Expected MemorySize to be 4096, got %d", status.MemorySize())
+	}
+}
+
+func TestGetApcuStatus_ErrorHandling(t *testing.T) {
+	// Initialize logging to prevent panic
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	ctx := context.Background()
+
+	cfg := config.FPMPoolConfig{
+		StatusSocket: "invalid://socket/path",
+	}
+
+	status, err := GetApcuStatus(ctx, cfg)
+	if err == nil {
+		t.Errorf("Expected error for invalid socket")
+	}
+	if status != nil {
+		t.Errorf("Expected nil status for invalid socket")
+	}
+
+	cfg.StatusSocket = "unix:///nonexistent/apcu.sock"
+	if _, err := GetApcuStatus(ctx, cfg); err == nil {
+		t.Errorf("Expected error for non-existent socket")
+	}
+}
+
+func TestGetApcuStatus_ScriptCreation(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	tmpPath := "/tmp/elasticphp-apcu-status.php"
+	_ = os.Remove(tmpPath)
+	defer os.Remove(tmpPath)
+
+	cfg := config.FPMPoolConfig{StatusSocket: "unix:///nonexistent/apcu.sock"}
+	_, _ = GetApcuStatus(context.Background(), cfg)
+
+	content, err := os.ReadFile(tmpPath)
+	if err != nil {
+		t.Fatalf("Expected APCu script to be created: %v", err)
+	}
+
+	for _, expected := range []string{"apcu_cache_info(true)", "apcu_sma_info(true)", "apcu_enabled"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected script to contain %q", expected)
+		}
+	}
+}
+
+func TestWriteStatusScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.php")
+	if err := os.WriteFile(path, []byte("<?php echo 'tampered';"), 0666); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	if err := writeStatusScript(path, apcuStatusScript); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != apcuStatusScript {
+		t.Errorf("Expected an existing script to be replaced, got %q", content)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", fi.Mode().Perm())
+	}
+
+	// A symlink is replaced, not followed
+	target := filepath.Join(t.TempDir(), "target")
+	os.Remove(path)
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := writeStatusScript(path, apcuStatusScript); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("Expected the symlink target to be left alone, got %v", err)
+	}
+
+	if err := writeStatusScript(filepath.Join(t.TempDir(), "missing", "status.php"), apcuStatusScript); err == nil {
+		t.Errorf("Expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,227 @@
+package phpfpm
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procRoot is where kernel socket tables and process stats are read from,
+// replaced in tests.
+var procRoot = "/proc"
+
+const (
+	unixFlagAcceptCon   = 0x10000 // __SO_ACCEPTCON, set on listening sockets
+	unixStateConnecting = "02"    // embryo sockets waiting in the accept queue
+	unixStateConnected  = "03"    // accepted connections
+
+	tcpStateEstablished = "01"
+	tcpStateListen      = "0A"
+)
+
+// SocketStats is the kernel's view of a pool's listen socket, independent of
+// the listen queue fields FPM reports (which are often 0 for unix sockets).
+type SocketStats struct {
+	AcceptQueue  int64 `json:"accept_queue"`
+	BacklogLimit int64 `json:"backlog_limit"`
+	Established  int64 `json:"established"`
+}
+
+// GetSocketStats reads the accept queue depth, backlog limit and established
+// connections for a pool's listen address from /proc/net. listenBacklog is the
+// pool's listen.backlog setting, used for unix sockets where the kernel does
+// not expose the limit.
+func GetSocketStats(socket, listenBacklog string) (*SocketStats, error) {
+	scheme, address, _, err := ParseAddress(socket, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid socket: %w", err)
+	}
+
+	switch scheme {
+	case "unix":
+		return unixSocketStats(address, listenBacklog)
+	case "tcp":
+		return tcpSocketStats(address)
+	default:
+		return nil, fmt.Errorf("socket stats are not available for %s sockets", scheme)
+	}
+}
+
+// unixSocketStats scans /proc/net/unix. Connections that are still in the
+// accept queue are listed with the listener's path in the connecting state.
+func unixSocketStats(path, listenBacklog string) (*SocketStats, error) {
+	f, err := os.Open(filepath.Join(procRoot, "net", "unix"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read unix socket table: %w", err)
+	}
+	defer f.Close()
+
+	stats := &SocketStats{}
+	listening := false
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// Num RefCount Protocol Flags Type St Inode Path
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[7] != path {
+			continue
+		}
+
+		flags, _ := strconv.ParseUint(fields[3], 16, 32)
+		switch {
+		case flags&unixFlagAcceptCon != 0:
+			listening = true
+		case fields[5] == unixStateConnecting:
+			stats.AcceptQueue++
+		case fields[5] == unixStateConnected:
+			stats.Established++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan unix socket table: %w", err)
+	}
+	if !listening {
+		return nil, fmt.Errorf("no listening unix socket found for %s", path)
+	}
+
+	stats.BacklogLimit = unixBacklogLimit(listenBacklog)
+
+	return stats, nil
+}
+
+// unixBacklogLimit applies the kernel's somaxconn cap to FPM's listen.backlog,
+// which defaults to 511 and treats -1 as the maximum.
+func unixBacklogLimit(listenBacklog string) int64 {
+	backlog := int64(511)
+	if v, err := strconv.ParseInt(strings.TrimSpace(listenBacklog), 10, 64); err == nil {
+		backlog = v
+	}
+
+	data, err := os.ReadFile(filepath.Join(procRoot, "sys", "net", "core", "somaxconn"))
+	if err != nil {
+		if backlog < 0 {
+			return 0
+		}
+		return backlog
+	}
+	somaxconn, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return backlog
+	}
+
+	if backlog < 0 || backlog > somaxconn {
+		return somaxconn
+	}
+	return backlog
+}
+
+// tcpSocketStats scans /proc/net/tcp and tcp6. For listening sockets the kernel
+// reports the accept queue depth as rx_queue and the backlog limit as tx_queue.
+func tcpSocketStats(address string) (*SocketStats, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tcp address %s: %w", address, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tcp port %s: %w", portStr, err)
+	}
+	ip := net.ParseIP(host)
+
+	stats := &SocketStats{}
+	listening := false
+	read := 0
+
+	for _, table := range []string{"tcp", "tcp6"} {
+		f, err := os.Open(filepath.Join(procRoot, "net", table))
+		if err != nil {
+			continue
+		}
+		read++
+
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx_queue:rx_queue ...
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 5 {
+				continue
+			}
+
+			localIP, localPort, err := parseProcNetAddr(fields[1])
+			if err != nil || localPort != uint16(port) || !tcpAddrMatches(ip, localIP) {
+				continue
+			}
+
+			switch fields[3] {
+			case tcpStateListen:
+				tx, rx, ok := strings.Cut(fields[4], ":")
+				if !ok {
+					continue
+				}
+				queue, _ := strconv.ParseInt(rx, 16, 64)
+				limit, _ := strconv.ParseInt(tx, 16, 64)
+				stats.AcceptQueue += queue
+				if limit > stats.BacklogLimit {
+					stats.BacklogLimit = limit
+				}
+				listening = true
+			case tcpStateEstablished:
+				stats.Established++
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s socket table: %w", table, err)
+		}
+	}
+
+	if read == 0 {
+		return nil, fmt.Errorf("failed to read tcp socket tables from %s", filepath.Join(procRoot, "net"))
+	}
+	if !listening {
+		return nil, fmt.Errorf("no listening tcp socket found for %s", address)
+	}
+
+	return stats, nil
+}
+
+// tcpAddrMatches reports whether a socket bound to local belongs to a pool
+// listening on want. Unspecified or unresolvable pool hosts match by port only.
+func tcpAddrMatches(want, local net.IP) bool {
+	if want == nil || want.IsUnspecified() || local.IsUnspecified() {
+		return true
+	}
+	return want.Equal(local)
+}
+
+// parseProcNetAddr decodes an address such as "0100007F:2328", where the IP is
+// stored as host-endian 32-bit words and the port as big-endian hex.
+func parseProcNetAddr(s string) (net.IP, uint16, error) {
+	hexIP, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port in %q", s)
+	}
+
+	return net.IP(raw), uint16(port), nil
+}
@@ -0,0 +1,152 @@
+package phpfpm
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const procNetUnixFixture = `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 20001 /run/php/www.sock
+0000000000000000: 00000002 00000000 00000000 0001 02 20002 /run/php/www.sock
+0000000000000000: 00000002 00000000 00000000 0001 02 20003 /run/php/www.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 20004 /run/php/www.sock
+0000000000000000: 00000002 00000000 00010000 0001 01 20005 /run/php/api.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 20006
+0000000000000000: 00000002 00000000 00010000 0001 01 20007 @/tmp/.X11-unix/X0
+`
+
+const procNetTCPFixture = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:2328 00000000:0000 0A 000001FF:00000004 00:00000000 00000000    33        0 30001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:2328 0100007F:D431 01 00000000:00000000 00:00000000 00000000    33        0 30002 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:2328 0100007F:D432 01 00000000:00000000 00:00000000 00000000    33        0 30003 1 0000000000000000 20 4 30 10 -1
+   3: 0100007F:D431 0100007F:2328 01 00000000:00000000 00:00000000 00000000     0        0 30004 1 0000000000000000 20 4 30 10 -1
+   4: 00000000:2329 00000000:0000 0A 00000080:00000000 00:00000000 00000000    33        0 30005 1 0000000000000000 100 0 0 10 0
+`
+
+const procNetTCP6Fixture = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:232A 00000000000000000000000000000000:0000 0A 00000200:00000001 00:00000000 00000000    33        0 40001 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000A00000A:232A 0000000000000000FFFF00000B00000A:C001 01 00000000:00000000 00:00000000 00000000    33        0 40002 1 0000000000000000 20 4 30 10 -1
+`
+
+func setupProcFixture(t *testing.T, somaxconn string) {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"net/unix": procNetUnixFixture,
+		"net/tcp":  procNetTCPFixture,
+		"net/tcp6": procNetTCP6Fixture,
+	}
+	if somaxconn != "" {
+		files["sys/net/core/somaxconn"] = somaxconn + "\n"
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	original := procRoot
+	procRoot = root
+	t.Cleanup(func() { procRoot = original })
+}
+
+func TestGetSocketStats(t *testing.T) {
+	setupProcFixture(t, "4096")
+
+	tests := []struct {
+		name          string
+		socket        string
+		listenBacklog string
+		expected      SocketStats
+		expectError   bool
+	}{
+		{"unix socket with pending connections", "unix:///run/php/www.sock", "", SocketStats{AcceptQueue: 2, BacklogLimit: 511, Established: 1}, false},
+		{"unix socket without prefix", "/run/php/api.sock", "1024", SocketStats{BacklogLimit: 1024}, false},
+		{"unix backlog capped by somaxconn", "/run/php/api.sock", "65535", SocketStats{BacklogLimit: 4096}, false},
+		{"unix backlog -1 uses somaxconn", "/run/php/api.sock", "-1", SocketStats{BacklogLimit: 4096}, false},
+		{"tcp loopback listener", "tcp://127.0.0.1:9000", "", SocketStats{AcceptQueue: 4, BacklogLimit: 511, Established: 2}, false},
+		{"tcp wildcard listener", "tcp://127.0.0.1:9001", "", SocketStats{BacklogLimit: 128}, false},
+		{"tcp6 wildcard listener", "tcp://[::]:9002", "", SocketStats{AcceptQueue: 1, BacklogLimit: 512, Established: 1}, false},
+		{"unix socket not listening", "/run/php/missing.sock", "", SocketStats{}, true},
+		{"tcp port not listening", "tcp://127.0.0.1:9100", "", SocketStats{}, true},
+		{"http pool", "https://web.internal/fpm-status", "", SocketStats{}, true},
+		{"invalid socket", "invalid", "", SocketStats{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := GetSocketStats(tt.socket, tt.listenBacklog)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", stats)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if *stats != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *stats)
+			}
+		})
+	}
+}
+
+func TestUnixBacklogLimit_NoSomaxconn(t *testing.T) {
+	setupProcFixture(t, "")
+
+	if got := unixBacklogLimit(""); got != 511 {
+		t.Errorf("Expected FPM default of 511, got %d", got)
+	}
+	if got := unixBacklogLimit("-1"); got != 0 {
+		t.Errorf("Expected 0 for an unknown maximum, got %d", got)
+	}
+}
+
+func TestParseProcNetAddr(t *testing.T) {
+	tests := []struct {
+		input       string
+		ip          string
+		port        uint16
+		expectError bool
+	}{
+		{"0100007F:2328", "127.0.0.1", 9000, false},
+		{"00000000:0050", "0.0.0.0", 80, false},
+		{"0000000000000000FFFF00000100007F:1F90", "127.0.0.1", 8080, false},
+		{"00000000000000000000000001000000:0016", "::1", 22, false},
+		{"0100007F", "", 0, true},
+		{"ZZ00007F:2328", "", 0, true},
+		{"0100007F:XYZ", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ip, port, err := parseProcNetAddr(tt.input)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !ip.Equal(net.ParseIP(tt.ip)) {
+				t.Errorf("Expected IP %s, got %s", tt.ip, ip)
+			}
+			if port != tt.port {
+				t.Errorf("Expected port %d, got %d", tt.port, port)
+			}
+		})
+	}
+}
@@ -15,12 +15,15 @@ import (
 )
 
 type DiscoveredFPM struct {
+	Pool         string
 	ConfigPath   string
 	StatusPath   string
 	Binary       string
 	Socket       string
 	StatusSocket string
 	CliBinary    string
+	PingPath     string
+	PingResponse string
 }
 
 var fpmNamePattern = regexp.MustCompile(`^php[0-9]{0,2}.*fpm.*$`)
@@ -32,6 +35,8 @@ func DiscoverFPMProcesses() ([]DiscoveredFPM, error) {
 	}
 
 	var found []DiscoveredFPM
+	// Masters can be listed more than once, e.g. from a shared PID namespace
+	seen := map[string]bool{}
 
 	for _, p := range procs {
 		name, err := p.Name()
@@ -81,15 +86,24 @@ func DiscoverFPMProcesses() ([]DiscoveredFPM, error) {
 				continue
 			}
 
+			key := config + "|" + statusSocket + "|" + status
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
 			cliBinary, _ := findMatchingCliBinary(exe)
 
 			found = append(found, DiscoveredFPM{
+				Pool:         poolName,
 				ConfigPath:   config,
 				StatusPath:   status,
 				Binary:       exe,
 				Socket:       socket,
 				StatusSocket: statusSocket,
 				CliBinary:    cliBinary,
+				PingPath:     poolConfig["ping.path"],
+				PingResponse: poolConfig["ping.response"],
 			})
 
 			logging.L().Debug("ElasticPHP-agent Discovered php-fpm pool",
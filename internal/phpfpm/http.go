@@ -0,0 +1,141 @@
+package phpfpm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+// ErrFastCGIRequired is returned by features that inject scripts or requests
+// over FastCGI when the pool status is only reachable through a web server.
+var ErrFastCGIRequired = errors.New("requires a FastCGI socket, the pool status is fetched over HTTP")
+
+var (
+	httpClients     = map[string]*poolHTTPClient{}
+	httpClientsLock sync.Mutex
+)
+
+// poolHTTPClient is the client of an http(s) pool, kept across scrapes so
+// connections are reused.
+type poolHTTPClient struct {
+	tls    config.TLSConfig
+	client *http.Client
+}
+
+func isHTTPScheme(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
+
+// isHTTPPool reports whether the pool status is reached through a web server
+// rather than the FPM FastCGI socket.
+func isHTTPPool(cfg config.FPMPoolConfig) bool {
+	scheme, _, _, err := ParseAddress(cfg.StatusSocket, "")
+	return err == nil && isHTTPScheme(scheme)
+}
+
+// fetchHTTPStatus requests the status page through a web server location such
+// as nginx's `location /fpm-status`, applying the pool's auth, headers and TLS options.
+func fetchHTTPStatus(ctx context.Context, poolCfg config.FPMPoolConfig, address, path, query string) ([]byte, error) {
+	client, err := httpClientFor(poolCfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg := poolCfg.HTTP
+
+	target := address + path
+	if query != "" {
+		target += "?" + query
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build status request: %w", err)
+	}
+	req.Header.Set("User-Agent", "elasticphp-agent")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if cfg.Username != "" || cfg.Password != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http status request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read http status response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("http status request returned %s", resp.Status)
+	}
+
+	return body, nil
+}
+
+// httpClientFor returns the pool's client, built once and again only when
+// the pool's TLS options change.
+func httpClientFor(poolCfg config.FPMPoolConfig) (*http.Client, error) {
+	httpClientsLock.Lock()
+	defer httpClientsLock.Unlock()
+
+	key := PoolKey(poolCfg)
+	if c, ok := httpClients[key]; ok {
+		if c.tls == poolCfg.HTTP.TLS {
+			return c.client, nil
+		}
+		c.client.CloseIdleConnections()
+		delete(httpClients, key)
+	}
+
+	client, err := newHTTPClient(poolCfg.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+	httpClients[key] = &poolHTTPClient{tls: poolCfg.HTTP.TLS, client: client}
+	return client, nil
+}
+
+func newHTTPClient(cfg config.TLSConfig) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,225 @@
+package phpfpm
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+)
+
+// newStatusHandler serves the JSON status fixture on /fpm-status the way an
+// nginx location proxying to FPM would, enforcing basic auth and a custom header.
+func newStatusHandler(t *testing.T) http.Handler {
+	t.Helper()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fpm-status" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "monitor" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Status-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.RawQuery != "json&full" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(jsonStatusFixture))
+	})
+}
+
+func httpPoolConfig(statusURL string) config.FPMPoolConfig {
+	return config.FPMPoolConfig{
+		Socket:       statusURL,
+		StatusSocket: statusURL,
+		StatusPath:   "/status",
+		HTTP: config.FPMHTTPConfig{
+			Username: "monitor",
+			Password: "secret",
+			Headers:  map[string]string{"X-Status-Token": "token"},
+		},
+	}
+}
+
+func TestGetMetricsForPool_HTTP(t *testing.T) {
+	server := httptest.NewServer(newStatusHandler(t))
+	defer server.Close()
+
+	result, err := GetMetricsForPool(context.Background(), httpPoolConfig(server.URL+"/fpm-status"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pool, ok := result.Pools["www"]
+	if !ok {
+		t.Fatalf("Expected pool 'www', got %v", result.Pools)
+	}
+	if pool.AcceptedConnections != 42 {
+		t.Errorf("Expected 42 accepted connections, got %d", pool.AcceptedConnections)
+	}
+	if pool.TotalProcesses != 2 || pool.ActiveProcesses != 1 || pool.IdleProcesses != 1 {
+		t.Errorf("Unexpected process counts: total=%d active=%d idle=%d", pool.TotalProcesses, pool.ActiveProcesses, pool.IdleProcesses)
+	}
+}
+
+func TestGetMetricsForPool_HTTPErrors(t *testing.T) {
+	server := httptest.NewServer(newStatusHandler(t))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		modify func(*config.FPMPoolConfig)
+	}{
+		{"missing credentials", func(c *config.FPMPoolConfig) { c.HTTP.Username = "" }},
+		{"missing header", func(c *config.FPMPoolConfig) { c.HTTP.Headers = nil }},
+		{"wrong path", func(c *config.FPMPoolConfig) { c.StatusSocket = server.URL + "/other" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poolCfg := httpPoolConfig(server.URL + "/fpm-status")
+			tt.modify(&poolCfg)
+
+			if _, err := GetMetricsForPool(context.Background(), poolCfg); err == nil {
+				t.Errorf("Expected error")
+			}
+		})
+	}
+}
+
+func TestGetMetricsForPool_HTTPS(t *testing.T) {
+	server := httptest.NewTLSServer(newStatusHandler(t))
+	defer server.Close()
+
+	poolCfg := httpPoolConfig(server.URL + "/fpm-status")
+
+	if _, err := GetMetricsForPool(context.Background(), poolCfg); err == nil {
+		t.Errorf("Expected error for an untrusted certificate")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	poolCfg.HTTP.TLS = config.TLSConfig{CAFile: caFile, ServerName: "example.com"}
+
+	if _, err := GetMetricsForPool(context.Background(), poolCfg); err != nil {
+		t.Errorf("Unexpected error with CA file: %v", err)
+	}
+
+	poolCfg.HTTP.TLS = config.TLSConfig{InsecureSkipVerify: true}
+	if _, err := GetMetricsForPool(context.Background(), poolCfg); err != nil {
+		t.Errorf("Unexpected error with insecure_skip_verify: %v", err)
+	}
+
+	poolCfg.HTTP.TLS = config.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}
+	if _, err := GetMetricsForPool(context.Background(), poolCfg); err == nil {
+		t.Errorf("Expected error for a missing CA file")
+	}
+}
+
+func TestGetMetrics_HTTPDisabledFeatures(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	server := httptest.NewServer(newStatusHandler(t))
+	defer server.Close()
+
+	poolCfg := httpPoolConfig(server.URL + "/fpm-status")
+	poolCfg.PingPath = "/ping"
+
+	results, err := GetMetrics(context.Background(), &config.Config{
+		PHPFpm: config.FPMConfig{Pools: []config.FPMPoolConfig{poolCfg}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, ok := results[PoolKey(poolCfg)]
+	if !ok {
+		t.Fatalf("Expected a result for %s", poolCfg.Socket)
+	}
+	pool := result.Pools["www"]
+
+	if pool.Path != "/fpm-status" {
+		t.Errorf("Expected status path '/fpm-status', got %q", pool.Path)
+	}
+	for _, feature := range []string{"opcache", "apcu", "ping"} {
+		if pool.DisabledFeatures[feature] == "" {
+			t.Errorf("Expected %s to be disabled with a reason, got %v", feature, pool.DisabledFeatures)
+		}
+	}
+}
+
+func TestFastCGIFeatures_HTTPPool(t *testing.T) {
+	poolCfg := httpPoolConfig("https://web.internal/fpm-status")
+	poolCfg.PingPath = "/ping"
+	ctx := context.Background()
+
+	if _, err := GetOpcacheStatus(ctx, poolCfg); !errors.Is(err, ErrFastCGIRequired) {
+		t.Errorf("Expected ErrFastCGIRequired from GetOpcacheStatus, got %v", err)
+	}
+	if _, err := GetApcuStatus(ctx, poolCfg); !errors.Is(err, ErrFastCGIRequired) {
+		t.Errorf("Expected ErrFastCGIRequired from GetApcuStatus, got %v", err)
+	}
+	if _, err := Ping(ctx, poolCfg); !errors.Is(err, ErrFastCGIRequired) {
+		t.Errorf("Expected ErrFastCGIRequired from Ping, got %v", err)
+	}
+	if !isHTTPPool(poolCfg) {
+		t.Errorf("Expected an http pool")
+	}
+	if isHTTPPool(config.FPMPoolConfig{StatusSocket: "unix:///run/php-fpm.sock"}) {
+		t.Errorf("Expected a unix socket not to be an http pool")
+	}
+}
+
+func TestHTTPClientFor(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(newStatusHandler(t))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	poolCfg := httpPoolConfig(server.URL + "/fpm-status")
+	for range 3 {
+		if _, err := GetMetricsForPool(context.Background(), poolCfg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("Expected the connection to be reused across scrapes, got %d connections", n)
+	}
+
+	client, err := httpClientFor(poolCfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	other := poolCfg
+	other.Name = "api"
+	if c, _ := httpClientFor(other); c == client {
+		t.Errorf("Expected another pool to get its own client")
+	}
+	poolCfg.HTTP.TLS.InsecureSkipVerify = true
+	if c, _ := httpClientFor(poolCfg); c == client {
+		t.Errorf("Expected a new client once the TLS options change")
+	}
+}
@@ -0,0 +1,88 @@
+package phpfpm
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+// PoolIdentity tells pools apart across FPM masters. Two masters, e.g. PHP 7.4
+// and PHP 8.3, may both run a "www" pool, so the pool name alone is ambiguous.
+type PoolIdentity struct {
+	ConfigPath string            `json:"fpm_config"`
+	PHPVersion string            `json:"php_version"`
+	Pool       string            `json:"pool"`
+	Socket     string            `json:"socket"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// NewPoolIdentity builds the identity of a configured pool. The configured name
+// takes precedence over the name reported by the FPM status page.
+func NewPoolIdentity(poolCfg config.FPMPoolConfig, statusName string) PoolIdentity {
+	name := poolCfg.Name
+	if name == "" {
+		name = statusName
+	}
+
+	return PoolIdentity{
+		ConfigPath: poolCfg.ConfigPath,
+		PHPVersion: phpVersionLabel(poolCfg.Binary),
+		Pool:       name,
+		Socket:     poolCfg.Socket,
+		Labels:     poolCfg.Labels,
+	}
+}
+
+// PoolKey returns the key results and per-pool state for a configured pool are
+// stored under. It only depends on the config so a pool keeps its key when a
+// scrape fails. Unnamed pools are told apart by their status endpoint.
+func PoolKey(poolCfg config.FPMPoolConfig) string {
+	name := poolCfg.Name
+	if name == "" {
+		name = statusEndpoint(poolCfg)
+	}
+	return poolCfg.ConfigPath + "|" + phpVersionLabel(poolCfg.Binary) + "|" + name
+}
+
+// DedupePools drops pools that point at the same status endpoint as an earlier
+// entry, e.g. a pool both configured by hand and found by discovery.
+func DedupePools(pools []config.FPMPoolConfig) []config.FPMPoolConfig {
+	seen := make(map[string]bool, len(pools))
+	deduped := make([]config.FPMPoolConfig, 0, len(pools))
+
+	for _, pool := range pools {
+		key := statusEndpoint(pool)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, pool)
+	}
+
+	return deduped
+}
+
+// statusEndpoint returns the address and path a pool's status is read from,
+// normalised so /run/php.sock and unix:///run/php.sock are the same endpoint.
+func statusEndpoint(pool config.FPMPoolConfig) string {
+	socket := pool.StatusSocket
+	if socket == "" {
+		socket = pool.Socket
+	}
+	scheme, address, path, err := ParseAddress(socket, pool.StatusPath)
+	if err != nil {
+		return socket + "|" + pool.StatusPath
+	}
+	return scheme + "://" + strings.TrimPrefix(address, scheme+"://") + "|" + path
+}
+
+// phpVersionLabel returns the major.minor version of an FPM binary, which stays
+// stable across patch upgrades.
+func phpVersionLabel(binary string) string {
+	major, minor, ok := fpmVersion(binary)
+	if !ok {
+		return ""
+	}
+	return strconv.Itoa(major) + "." + strconv.Itoa(minor)
+}
@@ -0,0 +1,98 @@
+package phpfpm
+
+import (
+	"testing"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+func TestPoolKey_DistinctMasters(t *testing.T) {
+	fpmVersionCacheLock.Lock()
+	fpmVersionCache["/usr/sbin/php-fpm7.4"] = [2]int{7, 4}
+	fpmVersionCache["/usr/sbin/php-fpm8.3"] = [2]int{8, 3}
+	fpmVersionCacheLock.Unlock()
+
+	php74 := config.FPMPoolConfig{Name: "www", Socket: "unix:///run/php/php7.4-fpm.sock", ConfigPath: "/etc/php/7.4/fpm/php-fpm.conf", Binary: "/usr/sbin/php-fpm7.4"}
+	php83 := config.FPMPoolConfig{Name: "www", Socket: "unix:///run/php/php8.3-fpm.sock", ConfigPath: "/etc/php/8.3/fpm/php-fpm.conf", Binary: "/usr/sbin/php-fpm8.3"}
+
+	if PoolKey(php74) == PoolKey(php83) {
+		t.Fatalf("Expected distinct keys for www pools of different masters, got %q", PoolKey(php74))
+	}
+	if key := PoolKey(php83); key != "/etc/php/8.3/fpm/php-fpm.conf|8.3|www" {
+		t.Errorf("Unexpected pool key %q", key)
+	}
+
+	// Without a configured name the status endpoint keeps the key stable before the first scrape
+	unnamed := config.FPMPoolConfig{Socket: "tcp://127.0.0.1:9000", StatusPath: "/status"}
+	if key := PoolKey(unnamed); key != "||tcp://127.0.0.1:9000|/status" {
+		t.Errorf("Unexpected pool key %q", key)
+	}
+	if PoolKey(unnamed) != PoolKey(config.FPMPoolConfig{StatusSocket: "tcp://127.0.0.1:9000", StatusPath: "/status"}) {
+		t.Errorf("Expected the status socket to be used without a socket")
+	}
+
+	// Hand-configured pools with only a status socket do not collide
+	statusOnly := []config.FPMPoolConfig{
+		{StatusSocket: "/run/php/a.sock", StatusPath: "/status"},
+		{StatusSocket: "unix:///run/php/b.sock", StatusPath: "/status"},
+		{StatusSocket: "https://fpm.internal/status"},
+		{StatusSocket: "https://fpm.internal/other-status"},
+	}
+	seen := map[string]bool{}
+	for _, pool := range statusOnly {
+		if key := PoolKey(pool); seen[key] {
+			t.Errorf("Duplicate pool key %q", key)
+		} else {
+			seen[key] = true
+		}
+	}
+}
+
+func TestNewPoolIdentity(t *testing.T) {
+	fpmVersionCacheLock.Lock()
+	fpmVersionCache["/usr/sbin/php-fpm8.3"] = [2]int{8, 3}
+	fpmVersionCacheLock.Unlock()
+
+	poolCfg := config.FPMPoolConfig{
+		Socket:     "unix:///run/php/php8.3-fpm.sock",
+		ConfigPath: "/etc/php/8.3/fpm/php-fpm.conf",
+		Binary:     "/usr/sbin/php-fpm8.3",
+		Labels:     map[string]string{"team": "checkout"},
+	}
+
+	id := NewPoolIdentity(poolCfg, "www")
+	if id.Pool != "www" || id.PHPVersion != "8.3" || id.ConfigPath != poolCfg.ConfigPath || id.Socket != poolCfg.Socket {
+		t.Errorf("Unexpected identity %+v", id)
+	}
+	if id.Labels["team"] != "checkout" {
+		t.Errorf("Expected configured labels on the identity, got %v", id.Labels)
+	}
+
+	poolCfg.Name = "checkout"
+	if id := NewPoolIdentity(poolCfg, "www"); id.Pool != "checkout" {
+		t.Errorf("Expected the configured name to override the status name, got %q", id.Pool)
+	}
+
+	if id := NewPoolIdentity(config.FPMPoolConfig{}, "www"); id.PHPVersion != "" {
+		t.Errorf("Expected no PHP version without a binary, got %q", id.PHPVersion)
+	}
+}
+
+func TestDedupePools(t *testing.T) {
+	pools := []config.FPMPoolConfig{
+		{Name: "configured", Socket: "unix:///run/php/fpm.sock", StatusPath: "/status"},
+		{Name: "www", Socket: "unix:///run/php/fpm.sock", StatusSocket: "unix:///run/php/fpm.sock", StatusPath: "/status"},
+		{Name: "www", Socket: "unix:///run/php/fpm.sock", StatusSocket: "unix:///run/php/fpm.sock", StatusPath: "/status"},
+		{Name: "api", Socket: "unix:///run/php/api.sock", StatusPath: "/status"},
+		{Name: "www", Socket: "unix:///run/php/fpm.sock", StatusSocket: "tcp://127.0.0.1:9001", StatusPath: "/status"},
+		{Name: "discovered", Socket: "/run/php/api.sock", StatusPath: "/status"},
+	}
+
+	deduped := DedupePools(pools)
+	if len(deduped) != 3 {
+		t.Fatalf("Expected 3 pools, got %d: %+v", len(deduped), deduped)
+	}
+	if deduped[0].Name != "configured" || deduped[1].Name != "api" || deduped[2].StatusSocket != "tcp://127.0.0.1:9001" {
+		t.Errorf("Expected the first entry per status endpoint to be kept in order, got %+v", deduped)
+	}
+}
@@ -92,6 +92,9 @@ func getPHPConfig(ctx context.Context, cfg config.FPMPoolConfig) (map[string]int
 	if err != nil {
 		return nil, fmt.Errorf("invalid FPM socket address: %w", err)
 	}
+	if isHTTPScheme(scheme) {
+		return nil, fmt.Errorf("PHP config %w", ErrFastCGIRequired)
+	}
 
 	client, err := fcgx.DialContext(ctx, scheme, address)
 	if err != nil {
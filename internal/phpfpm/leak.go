@@ -0,0 +1,274 @@
+package phpfpm
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+const (
+	// leakMaxPoints bounds the request history kept per worker.
+	leakMaxPoints = 100
+	// leakMinR2 is the fit a worker's memory trend needs to count as steady
+	// growth rather than noise from requests of different sizes.
+	leakMinR2 = 0.6
+
+	defaultLeakMinSamples     = 10
+	defaultLeakSlopeThreshold = 64 * 1024
+	defaultLeakTopScripts     = 5
+)
+
+var (
+	leakDetector     *LeakDetector
+	leakDetectorLock sync.RWMutex
+)
+
+// LeakReport aggregates the memory trends of a pool's workers.
+type LeakReport struct {
+	WorkersTracked   int            `json:"workers_tracked"`
+	SuspectedWorkers int            `json:"suspected_workers"`
+	MaxSlope         float64        `json:"max_slope_bytes_per_request"`
+	Suspected        []WorkerTrend  `json:"suspected,omitempty"`
+	TopScripts       []ScriptGrowth `json:"top_scripts,omitempty"`
+}
+
+// WorkerTrend is the least-squares fit of a worker's last request memory
+// against its request count.
+type WorkerTrend struct {
+	PID      int     `json:"pid"`
+	Requests int64   `json:"requests"`
+	Samples  int     `json:"samples"`
+	Slope    float64 `json:"slope_bytes_per_request"`
+	R2       float64 `json:"r2"`
+	Memory   float64 `json:"last_request_memory"`
+}
+
+// ScriptGrowth is the memory growth observed on requests served by a script.
+type ScriptGrowth struct {
+	Script      string  `json:"script"`
+	GrowthBytes float64 `json:"growth_bytes"`
+	Requests    int     `json:"requests"`
+}
+
+// LeakDetector tracks per-PID memory across requests from successive status
+// snapshots. Leaky code paths show up as memory climbing steadily from request
+// to request until pm.max_requests recycles the worker.
+type LeakDetector struct {
+	mu             sync.Mutex
+	minSamples     int
+	slopeThreshold float64
+	topScripts     int
+	pools          map[string]map[int]*workerHistory
+}
+
+type workerHistory struct {
+	startSince int64
+	points     []memoryPoint
+}
+
+type memoryPoint struct {
+	requests int64
+	memory   float64
+	script   string
+}
+
+func NewLeakDetector(cfg config.LeakDetectionConfig) *LeakDetector {
+	d := &LeakDetector{
+		minSamples:     cfg.MinSamples,
+		slopeThreshold: cfg.SlopeThreshold,
+		topScripts:     cfg.TopScripts,
+		pools:          make(map[string]map[int]*workerHistory),
+	}
+	if d.minSamples < 3 {
+		d.minSamples = defaultLeakMinSamples
+	}
+	if d.slopeThreshold <= 0 {
+		d.slopeThreshold = defaultLeakSlopeThreshold
+	}
+	if d.topScripts <= 0 {
+		d.topScripts = defaultLeakTopScripts
+	}
+	return d
+}
+
+// ConfigureLeakDetection installs the detector fed by ObserveLeakSample and read
+// by GetLeakReport. A disabled config removes it.
+func ConfigureLeakDetection(cfg config.LeakDetectionConfig) {
+	var d *LeakDetector
+	if cfg.Enabled {
+		d = NewLeakDetector(cfg)
+	}
+
+	leakDetectorLock.Lock()
+	leakDetector = d
+	leakDetectorLock.Unlock()
+}
+
+// ObserveLeakSample feeds a pool snapshot into the configured leak detector.
+func ObserveLeakSample(poolCfg config.FPMPoolConfig, result *Result) {
+	leakDetectorLock.RLock()
+	d := leakDetector
+	leakDetectorLock.RUnlock()
+
+	if d == nil || result == nil {
+		return
+	}
+	for _, pool := range result.Pools {
+		d.Observe(PoolKey(poolCfg)+"|"+pool.Name, pool)
+	}
+}
+
+// GetLeakReport returns the leak report for a pool, or nil when leak detection
+// is disabled or has not seen the pool yet.
+func GetLeakReport(poolCfg config.FPMPoolConfig, poolName string) *LeakReport {
+	leakDetectorLock.RLock()
+	d := leakDetector
+	leakDetectorLock.RUnlock()
+
+	if d == nil {
+		return nil
+	}
+	return d.Report(PoolKey(poolCfg) + "|" + poolName)
+}
+
+// Observe records a point for every worker that finished a request since the
+// previous snapshot and forgets workers that are gone.
+func (d *LeakDetector) Observe(key string, pool Pool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	workers, ok := d.pools[key]
+	if !ok {
+		workers = make(map[int]*workerHistory)
+		d.pools[key] = workers
+	}
+
+	seen := make(map[int]bool, len(pool.Processes))
+	for _, proc := range pool.Processes {
+		seen[proc.PID] = true
+
+		h, ok := workers[proc.PID]
+		// A lower request count or age means the PID now belongs to a new worker
+		if !ok || proc.StartSince < h.startSince || (len(h.points) > 0 && proc.Requests < h.points[len(h.points)-1].requests) {
+			h = &workerHistory{}
+			workers[proc.PID] = h
+		}
+		h.startSince = proc.StartSince
+
+		if proc.Requests == 0 || proc.LastRequestMemory <= 0 {
+			continue
+		}
+		if n := len(h.points); n > 0 && h.points[n-1].requests == proc.Requests {
+			continue
+		}
+
+		h.points = append(h.points, memoryPoint{requests: proc.Requests, memory: proc.LastRequestMemory, script: proc.Script})
+		if len(h.points) > leakMaxPoints {
+			h.points = h.points[len(h.points)-leakMaxPoints:]
+		}
+	}
+
+	for pid := range workers {
+		if !seen[pid] {
+			delete(workers, pid)
+		}
+	}
+}
+
+// Report fits each worker's memory trend and aggregates growth per script.
+func (d *LeakDetector) Report(key string) *LeakReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	workers, ok := d.pools[key]
+	if !ok {
+		return nil
+	}
+
+	report := &LeakReport{WorkersTracked: len(workers)}
+	growth := map[string]*ScriptGrowth{}
+
+	for pid, h := range workers {
+		for i := 1; i < len(h.points); i++ {
+			if delta := h.points[i].memory - h.points[i-1].memory; delta > 0 {
+				g, ok := growth[h.points[i].script]
+				if !ok {
+					g = &ScriptGrowth{Script: h.points[i].script}
+					growth[h.points[i].script] = g
+				}
+				g.GrowthBytes += delta
+				g.Requests++
+			}
+		}
+
+		if len(h.points) < d.minSamples {
+			continue
+		}
+
+		slope, r2 := fitTrend(h.points)
+		if slope > report.MaxSlope {
+			report.MaxSlope = slope
+		}
+		if slope >= d.slopeThreshold && r2 >= leakMinR2 {
+			last := h.points[len(h.points)-1]
+			report.Suspected = append(report.Suspected, WorkerTrend{
+				PID:      pid,
+				Requests: last.requests,
+				Samples:  len(h.points),
+				Slope:    slope,
+				R2:       r2,
+				Memory:   last.memory,
+			})
+		}
+	}
+
+	report.SuspectedWorkers = len(report.Suspected)
+	sort.Slice(report.Suspected, func(i, j int) bool {
+		return report.Suspected[i].Slope > report.Suspected[j].Slope
+	})
+
+	for _, g := range growth {
+		report.TopScripts = append(report.TopScripts, *g)
+	}
+	sort.Slice(report.TopScripts, func(i, j int) bool {
+		if report.TopScripts[i].GrowthBytes != report.TopScripts[j].GrowthBytes {
+			return report.TopScripts[i].GrowthBytes > report.TopScripts[j].GrowthBytes
+		}
+		return report.TopScripts[i].Script < report.TopScripts[j].Script
+	})
+	if len(report.TopScripts) > d.topScripts {
+		report.TopScripts = report.TopScripts[:d.topScripts]
+	}
+
+	return report
+}
+
+// fitTrend returns the least-squares slope of memory over requests and the
+// coefficient of determination of the fit.
+func fitTrend(points []memoryPoint) (slope, r2 float64) {
+	n := float64(len(points))
+	var sumX, sumY float64
+	for _, p := range points {
+		sumX += float64(p.requests)
+		sumY += p.memory
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for _, p := range points {
+		dx, dy := float64(p.requests)-meanX, p.memory-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+
+	slope = sxy / sxx
+	if syy == 0 {
+		return slope, 0
+	}
+	return slope, (sxy * sxy) / (sxx * syy)
+}
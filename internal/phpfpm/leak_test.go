@@ -0,0 +1,143 @@
+package phpfpm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+// leakSnapshots simulates a leaky worker growing 512KiB per request on
+// /var/www/export.php and a healthy worker with noisy but flat memory.
+func leakSnapshots(n int) []Pool {
+	noise := []float64{0, 300000, -200000, 150000, -100000}
+
+	snapshots := make([]Pool, 0, n)
+	for i := 1; i <= n; i++ {
+		snapshots = append(snapshots, Pool{
+			Name: "www",
+			Processes: []PoolProcess{
+				{PID: 10, StartSince: int64(i), Requests: int64(i), LastRequestMemory: 4194304 + float64(i)*524288, Script: "/var/www/export.php"},
+				{PID: 11, StartSince: int64(i), Requests: int64(i * 2), LastRequestMemory: 4194304 + noise[i%len(noise)], Script: "/var/www/index.php"},
+			},
+		})
+	}
+	return snapshots
+}
+
+func TestLeakDetector_Report(t *testing.T) {
+	d := NewLeakDetector(config.LeakDetectionConfig{MinSamples: 10, TopScripts: 1})
+
+	for _, snapshot := range leakSnapshots(20) {
+		d.Observe("pool", snapshot)
+	}
+
+	report := d.Report("pool")
+	if report == nil {
+		t.Fatalf("Expected a report")
+	}
+	if report.WorkersTracked != 2 {
+		t.Errorf("Expected 2 tracked workers, got %d", report.WorkersTracked)
+	}
+	if report.SuspectedWorkers != 1 || report.Suspected[0].PID != 10 {
+		t.Fatalf("Expected only PID 10 to be suspected, got %+v", report.Suspected)
+	}
+
+	trend := report.Suspected[0]
+	if math.Abs(trend.Slope-524288) > 1 || trend.R2 < 0.99 {
+		t.Errorf("Expected a steady 512KiB/request slope, got %v with r2 %v", trend.Slope, trend.R2)
+	}
+	if trend.Samples != 20 || trend.Requests != 20 {
+		t.Errorf("Expected 20 samples up to request 20, got %d samples at %d", trend.Samples, trend.Requests)
+	}
+	if report.MaxSlope != trend.Slope {
+		t.Errorf("Expected max slope %v, got %v", trend.Slope, report.MaxSlope)
+	}
+
+	if len(report.TopScripts) != 1 || report.TopScripts[0].Script != "/var/www/export.php" {
+		t.Fatalf("Expected export.php as the top growth script, got %+v", report.TopScripts)
+	}
+	if report.TopScripts[0].GrowthBytes != 19*524288 {
+		t.Errorf("Expected %d bytes of growth, got %v", 19*524288, report.TopScripts[0].GrowthBytes)
+	}
+
+	if d.Report("unknown") != nil {
+		t.Errorf("Expected no report for an unknown pool")
+	}
+}
+
+func TestLeakDetector_MinSamples(t *testing.T) {
+	d := NewLeakDetector(config.LeakDetectionConfig{MinSamples: 10})
+
+	for _, snapshot := range leakSnapshots(5) {
+		d.Observe("pool", snapshot)
+	}
+
+	if report := d.Report("pool"); report.SuspectedWorkers != 0 {
+		t.Errorf("Expected no suspects before min_samples, got %+v", report.Suspected)
+	}
+}
+
+func TestLeakDetector_WorkerLifecycle(t *testing.T) {
+	d := NewLeakDetector(config.LeakDetectionConfig{})
+
+	snapshots := leakSnapshots(12)
+	for _, snapshot := range snapshots {
+		d.Observe("pool", snapshot)
+	}
+
+	// Repeated snapshots without new requests add no points
+	d.Observe("pool", snapshots[len(snapshots)-1])
+	if n := len(d.pools["pool"][10].points); n != 12 {
+		t.Errorf("Expected 12 points, got %d", n)
+	}
+
+	// A recycled worker reusing the PID starts a new history
+	d.Observe("pool", Pool{Name: "www", Processes: []PoolProcess{
+		{PID: 10, StartSince: 1, Requests: 1, LastRequestMemory: 4194304},
+	}})
+	if n := len(d.pools["pool"][10].points); n != 1 {
+		t.Errorf("Expected the history to restart for a recycled worker, got %d points", n)
+	}
+	if _, ok := d.pools["pool"][11]; ok {
+		t.Errorf("Expected workers missing from the snapshot to be forgotten")
+	}
+}
+
+func TestConfigureLeakDetection(t *testing.T) {
+	defer ConfigureLeakDetection(config.LeakDetectionConfig{})
+
+	poolCfg := config.FPMPoolConfig{Socket: "unix:///run/php/configure-leaks.sock"}
+
+	ConfigureLeakDetection(config.LeakDetectionConfig{Enabled: true})
+	for _, snapshot := range leakSnapshots(12) {
+		ObserveLeakSample(poolCfg, &Result{Pools: map[string]Pool{"www": snapshot}})
+	}
+
+	report := GetLeakReport(poolCfg, "www")
+	if report == nil || report.SuspectedWorkers != 1 {
+		t.Fatalf("Expected one suspected worker with the defaults, got %+v", report)
+	}
+
+	ConfigureLeakDetection(config.LeakDetectionConfig{Enabled: false})
+	if GetLeakReport(poolCfg, "www") != nil {
+		t.Errorf("Expected no report when leak detection is disabled")
+	}
+}
+
+func TestFitTrend(t *testing.T) {
+	slope, r2 := fitTrend([]memoryPoint{{1, 100, ""}, {2, 200, ""}, {3, 300, ""}})
+	if slope != 100 || r2 != 1 {
+		t.Errorf("Expected slope 100 with a perfect fit, got %v and %v", slope, r2)
+	}
+
+	slope, r2 = fitTrend([]memoryPoint{{5, 100, ""}, {5, 200, ""}})
+	if slope != 0 || r2 != 0 {
+		t.Errorf("Expected no trend without request spread, got %v and %v", slope, r2)
+	}
+
+	slope, r2 = fitTrend([]memoryPoint{{1, 100, ""}, {2, 100, ""}, {3, 100, ""}})
+	if slope != 0 || r2 != 0 {
+		t.Errorf("Expected a flat trend, got %v and %v", slope, r2)
+	}
+}
@@ -0,0 +1,176 @@
+package phpfpm
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+)
+
+// WorkerAgeBuckets are the upper bounds, in seconds, of the worker age histogram.
+var WorkerAgeBuckets = []float64{60, 300, 900, 1800, 3600, 4 * 3600, 12 * 3600, 24 * 3600, 7 * 24 * 3600}
+
+const (
+	defaultStuckThreshold   = 30 * time.Second
+	defaultStuckLogInterval = 5 * time.Minute
+	defaultRecycleThreshold = 0.9
+)
+
+var (
+	stuckLogged     = make(map[string]time.Time)
+	stuckLoggedLock sync.Mutex
+)
+
+// WorkerLifecycle is derived from the per-process data of the full status.
+type WorkerLifecycle struct {
+	AgeCount uint64  `json:"age_count"`
+	AgeSum   float64 `json:"age_sum_seconds"`
+	// AgeBuckets holds cumulative worker counts per upper bound.
+	AgeBuckets map[float64]uint64 `json:"-"`
+
+	MaxRequests          int64         `json:"max_requests"`
+	NearRecycle          int64         `json:"near_recycle"`
+	TerminateTimeout     int64         `json:"request_terminate_timeout"`
+	OverTerminateTimeout int64         `json:"over_terminate_timeout"`
+	Stuck                int64         `json:"stuck"`
+	StuckWorkers         []StuckWorker `json:"stuck_workers,omitempty"`
+}
+
+type StuckWorker struct {
+	PID      int     `json:"pid"`
+	State    string  `json:"state"`
+	URI      string  `json:"request_uri"`
+	Script   string  `json:"script"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+// analyzeLifecycle derives worker age, recycle forecasts, stuck workers and
+// requests past request_terminate_timeout from the pool's process list.
+func analyzeLifecycle(cfg config.FPMConfig, pool Pool) *WorkerLifecycle {
+	stuckThreshold := cfg.StuckThreshold
+	if stuckThreshold <= 0 {
+		stuckThreshold = defaultStuckThreshold
+	}
+	recycleThreshold := cfg.RecycleThreshold
+	if recycleThreshold <= 0 || recycleThreshold > 1 {
+		recycleThreshold = defaultRecycleThreshold
+	}
+
+	lc := &WorkerLifecycle{
+		AgeBuckets:       make(map[float64]uint64, len(WorkerAgeBuckets)),
+		MaxRequests:      parseConfigInt(pool.Config["pm.max_requests"]),
+		TerminateTimeout: parseConfigSeconds(pool.Config["request_terminate_timeout"]),
+	}
+
+	for _, proc := range pool.Processes {
+		age := float64(proc.StartSince)
+		lc.AgeCount++
+		lc.AgeSum += age
+		for _, bound := range WorkerAgeBuckets {
+			if age <= bound {
+				lc.AgeBuckets[bound]++
+			}
+		}
+
+		if lc.MaxRequests > 0 && float64(proc.Requests) >= recycleThreshold*float64(lc.MaxRequests) {
+			lc.NearRecycle++
+		}
+
+		// FPM reports the elapsed time of in-flight requests in microseconds
+		if strings.EqualFold(proc.State, "idle") {
+			continue
+		}
+		duration := time.Duration(proc.RequestDuration) * time.Microsecond
+
+		if lc.TerminateTimeout > 0 && duration > time.Duration(lc.TerminateTimeout)*time.Second {
+			lc.OverTerminateTimeout++
+		}
+
+		switch strings.ToLower(proc.State) {
+		case "finishing", "reading headers":
+			if duration > stuckThreshold {
+				lc.Stuck++
+				lc.StuckWorkers = append(lc.StuckWorkers, StuckWorker{
+					PID:      proc.PID,
+					State:    proc.State,
+					URI:      proc.RequestURI,
+					Script:   proc.Script,
+					Duration: duration.Seconds(),
+				})
+			}
+		}
+	}
+
+	return lc
+}
+
+// logStuckWorkers logs each stuck worker at most once per interval per PID.
+func logStuckWorkers(poolCfg config.FPMPoolConfig, poolName string, lc *WorkerLifecycle, interval time.Duration, now time.Time) {
+	if interval <= 0 {
+		interval = defaultStuckLogInterval
+	}
+
+	stuckLoggedLock.Lock()
+	defer stuckLoggedLock.Unlock()
+
+	for key, at := range stuckLogged {
+		if now.Sub(at) >= interval {
+			delete(stuckLogged, key)
+		}
+	}
+
+	for _, w := range lc.StuckWorkers {
+		key := PoolKey(poolCfg) + "|" + strconv.Itoa(w.PID)
+		if _, ok := stuckLogged[key]; ok {
+			continue
+		}
+		stuckLogged[key] = now
+
+		logging.L().Warn("ElasticPHP-agent stuck FPM worker",
+			"pool", poolName,
+			"socket", poolCfg.Socket,
+			"pid", w.PID,
+			"state", w.State,
+			"request_uri", w.URI,
+			"script", w.Script,
+			"duration_seconds", w.Duration,
+		)
+	}
+}
+
+func parseConfigInt(value string) int64 {
+	v, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// parseConfigSeconds reads an FPM time value such as "30", "30s", "5m" or "1h"
+// as seconds.
+func parseConfigSeconds(value string) int64 {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	multiplier := int64(1)
+	switch value[len(value)-1] {
+	case 's':
+		value = value[:len(value)-1]
+	case 'm':
+		multiplier = 60
+		value = value[:len(value)-1]
+	case 'h':
+		multiplier = 3600
+		value = value[:len(value)-1]
+	case 'd':
+		multiplier = 86400
+		value = value[:len(value)-1]
+	}
+
+	return parseConfigInt(value) * multiplier
+}
@@ -0,0 +1,132 @@
+package phpfpm
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+)
+
+func lifecycleFixture() Pool {
+	return Pool{
+		Name: "www",
+		Config: map[string]string{
+			"pm.max_requests":           "500",
+			"request_terminate_timeout": "60s",
+		},
+		Processes: []PoolProcess{
+			{PID: 1, State: "Idle", StartSince: 30, Requests: 10},
+			{PID: 2, State: "Idle", StartSince: 600, Requests: 460},
+			{PID: 3, State: "Running", StartSince: 7200, Requests: 499, RequestDuration: 90_000_000, RequestURI: "/export", Script: "/var/www/export.php"},
+			{PID: 4, State: "Reading headers", StartSince: 120, Requests: 1, RequestDuration: 45_000_000, RequestURI: "/upload", Script: "/var/www/index.php"},
+			{PID: 5, State: "Finishing", StartSince: 120, Requests: 2, RequestDuration: 5_000_000, RequestURI: "/", Script: "/var/www/index.php"},
+		},
+	}
+}
+
+func TestAnalyzeLifecycle(t *testing.T) {
+	lc := analyzeLifecycle(config.FPMConfig{}, lifecycleFixture())
+
+	if lc.AgeCount != 5 || lc.AgeSum != 8070 {
+		t.Errorf("Expected 5 workers with a total age of 8070s, got %d and %v", lc.AgeCount, lc.AgeSum)
+	}
+	if lc.AgeBuckets[60] != 1 || lc.AgeBuckets[300] != 3 || lc.AgeBuckets[900] != 4 || lc.AgeBuckets[4*3600] != 5 {
+		t.Errorf("Unexpected cumulative age buckets: %v", lc.AgeBuckets)
+	}
+	if lc.MaxRequests != 500 {
+		t.Errorf("Expected pm.max_requests 500, got %d", lc.MaxRequests)
+	}
+	if lc.NearRecycle != 2 {
+		t.Errorf("Expected 2 workers near recycling at the default 90%% threshold, got %d", lc.NearRecycle)
+	}
+	if lc.TerminateTimeout != 60 || lc.OverTerminateTimeout != 1 {
+		t.Errorf("Expected 1 worker over a 60s terminate timeout, got %d over %d", lc.OverTerminateTimeout, lc.TerminateTimeout)
+	}
+	if lc.Stuck != 1 || len(lc.StuckWorkers) != 1 {
+		t.Fatalf("Expected 1 stuck worker, got %d: %+v", lc.Stuck, lc.StuckWorkers)
+	}
+
+	stuck := lc.StuckWorkers[0]
+	if stuck.PID != 4 || stuck.URI != "/upload" || stuck.Script != "/var/www/index.php" || stuck.Duration != 45 {
+		t.Errorf("Unexpected stuck worker: %+v", stuck)
+	}
+}
+
+func TestAnalyzeLifecycle_Thresholds(t *testing.T) {
+	lc := analyzeLifecycle(config.FPMConfig{StuckThreshold: 2 * time.Second, RecycleThreshold: 0.99}, lifecycleFixture())
+
+	if lc.Stuck != 2 {
+		t.Errorf("Expected 2 stuck workers with a 2s threshold, got %d", lc.Stuck)
+	}
+	if lc.NearRecycle != 1 {
+		t.Errorf("Expected 1 worker near recycling at 99%%, got %d", lc.NearRecycle)
+	}
+
+	pool := lifecycleFixture()
+	pool.Config = nil
+	lc = analyzeLifecycle(config.FPMConfig{}, pool)
+	if lc.NearRecycle != 0 || lc.OverTerminateTimeout != 0 {
+		t.Errorf("Expected no recycle or terminate data without pool config, got %+v", lc)
+	}
+}
+
+func TestLogStuckWorkers_RateLimited(t *testing.T) {
+	// The logger writes to os.Stdout as it was when Init ran
+	out, err := os.CreateTemp(t.TempDir(), "log")
+	if err != nil {
+		t.Fatalf("Failed to create log file: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = out
+	logging.Init(config.LoggingBlock{Level: "warn", Format: "json"})
+	os.Stdout = stdout
+	defer logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	logged := func() string {
+		data, _ := os.ReadFile(out.Name())
+		return string(data)
+	}
+
+	poolCfg := config.FPMPoolConfig{Socket: "unix:///run/php/stuck-log.sock"}
+	lc := analyzeLifecycle(config.FPMConfig{}, lifecycleFixture())
+	now := time.Now()
+
+	logStuckWorkers(poolCfg, "www", lc, time.Minute, now)
+	logStuckWorkers(poolCfg, "www", lc, time.Minute, now.Add(30*time.Second))
+
+	if got := strings.Count(logged(), "stuck FPM worker"); got != 1 {
+		t.Fatalf("Expected 1 log event within the interval, got %d: %s", got, logged())
+	}
+	for _, want := range []string{`"pid":4`, `"request_uri":"/upload"`, `"script":"/var/www/index.php"`} {
+		if !strings.Contains(logged(), want) {
+			t.Errorf("Expected log to contain %s, got %s", want, logged())
+		}
+	}
+
+	logStuckWorkers(poolCfg, "www", lc, time.Minute, now.Add(2*time.Minute))
+	if got := strings.Count(logged(), "stuck FPM worker"); got != 2 {
+		t.Errorf("Expected a second log event after the interval, got %d", got)
+	}
+}
+
+func TestParseConfigSeconds(t *testing.T) {
+	tests := map[string]int64{
+		"":      0,
+		"0":     0,
+		"30":    30,
+		"30s":   30,
+		"5m":    300,
+		"1h":    3600,
+		"2d":    172800,
+		"bogus": 0,
+	}
+
+	for input, expected := range tests {
+		if got := parseConfigSeconds(input); got != expected {
+			t.Errorf("parseConfigSeconds(%q) = %d, expected %d", input, got, expected)
+		}
+	}
+}
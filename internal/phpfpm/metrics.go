@@ -2,9 +2,11 @@ package phpfpm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/elasticphphq/agent/internal/logging"
 	"log/slog"
+	"net/url"
 	"strings"
 	"time"
 
@@ -50,13 +52,25 @@ type Pool struct {
 	Processes           []PoolProcess     `json:"processes"`
 	ProcessesCpu        *float64          `json:"processes_cpu"`
 	ProcessesMemory     *float64          `json:"processes_memory"`
+	StatusFormat        string            `json:"status_format,omitempty"`
+	NativeMetrics       []NativeMetric    `json:"native_metrics,omitempty"`
 	Config              map[string]string `json:"config,omitempty"`
 	OpcacheStatus       OpcacheStatus     `json:"opcache_status,omitempty"`
+	ApcuStatus          ApcuStatus        `json:"apcu_status,omitempty"`
+	Ping                *PingStats        `json:"ping,omitempty"`
+	Socket              *SocketStats      `json:"socket,omitempty"`
+	Restarts            *RestartStats     `json:"restarts,omitempty"`
+	Lifecycle           *WorkerLifecycle  `json:"lifecycle,omitempty"`
+	HotEndpoints        []EndpointWindow  `json:"hot_endpoints,omitempty"`
+	MemoryLeaks         *LeakReport       `json:"memory_leaks,omitempty"`
+	Resources           *PoolResources    `json:"resources,omitempty"`
+	DisabledFeatures    map[string]string `json:"disabled_features,omitempty"`
 	PhpInfo             Info              `json:"php_info,omitempty"`
 }
 
 type Result struct {
 	Timestamp time.Time
+	Identity  PoolIdentity `json:"identity"`
 	Pools     map[string]Pool
 	Global    map[string]string `json:"global_config,omitempty"`
 }
@@ -64,6 +78,11 @@ type Result struct {
 func GetMetrics(ctx context.Context, cfg *config.Config) (map[string]*Result, error) {
 	results := map[string]*Result{}
 
+	// The process table is scanned once for all pools
+	var procs *processTable
+	var procsErr error
+	scanned := false
+
 	for _, poolCfg := range cfg.PHPFpm.Pools {
 		result := &Result{
 			Timestamp: time.Now(),
@@ -77,37 +96,10 @@ func GetMetrics(ctx context.Context, cfg *config.Config) (map[string]*Result, er
 			continue
 		}
 
-		dialCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
-		logging.L().Debug("ElasticPHP-agent Dialing FastCGI", "scheme", scheme, "address", address, "status_path", path)
-		client, err := fcgx.DialContext(dialCtx, scheme, address)
-		cancel()
-		if err != nil {
-			logging.L().Debug("ElasticPHP-agent failed to dial FastCGI", "error", err)
-			continue
-		}
-		defer client.Close()
-
-		env := map[string]string{
-			"SCRIPT_FILENAME": path,
-			"SCRIPT_NAME":     path,
-			"SERVER_SOFTWARE": "elasticphp-agent",
-			"REMOTE_ADDR":     "127.0.0.1",
-			"QUERY_STRING":    "json&full",
-		}
-		logging.L().Debug("ElasticPHP-agent Sending FCGI request", "env", env)
-
-		resp, err := client.Get(ctx, env)
-		if err != nil {
-			logging.L().Debug("ElasticPHP-agent fcgi GET failed", "error", err)
-			continue
-		}
-		defer resp.Body.Close()
-
-		var pool Pool
-		err = fcgx.ReadJSON(resp, &pool)
-
+		logging.L().Debug("ElasticPHP-agent Requesting FPM status", "scheme", scheme, "address", address, "status_path", path, "status_format", poolCfg.StatusFormat)
+		pool, err := fetchPool(ctx, poolCfg, scheme, address, path)
 		if err != nil {
-			logging.L().Error("ElasticPHP-agent failed to parse FPM JSON: %v", slog.Any("err", err))
+			logging.L().Debug("ElasticPHP-agent failed to fetch FPM status", "address", address, "error", err)
 			continue
 		}
 
@@ -125,6 +117,14 @@ func GetMetrics(ctx context.Context, cfg *config.Config) (map[string]*Result, er
 			}
 		}
 
+		pool.Restarts = trackRestart(poolCfg, pool, result.Global["error_log"], time.Now())
+
+		if socketStats, err := GetSocketStats(poolCfg.Socket, pool.Config["listen.backlog"]); err == nil {
+			pool.Socket = socketStats
+		} else {
+			logging.L().Debug("ElasticPHP-agent failed to read kernel socket stats", "socket", poolCfg.Socket, "error", err)
+		}
+
 		// Process counting and CPU/mem parsing from actual process list
 		var totalCPU, totalMem float64
 		var count int
@@ -140,8 +140,7 @@ func GetMetrics(ctx context.Context, cfg *config.Config) (map[string]*Result, er
 			}
 
 			// CPU/memory calculation (exclude status and opcache requests)
-			if !strings.HasPrefix(proc.RequestURI, poolCfg.StatusPath) &&
-				!strings.HasPrefix(proc.RequestURI, "/opcache-status-") {
+			if !isAgentRequest(proc.RequestURI, path) {
 
 				totalCPU += float64(proc.LastRequestCPU)
 				totalMem += float64(proc.LastRequestMemory)
@@ -149,16 +148,35 @@ func GetMetrics(ctx context.Context, cfg *config.Config) (map[string]*Result, er
 			}
 		}
 
-		// Recalculate process counts from actual process list
-		pool.ActiveProcesses = activeCount
-		pool.IdleProcesses = idleCount
-		pool.TotalProcesses = int64(len(pool.Processes))
+		// Recalculate process counts from actual process list. The OpenMetrics
+		// status has no process list, so its own counts are kept.
+		if pool.StatusFormat == StatusFormatJSON {
+			pool.ActiveProcesses = activeCount
+			pool.IdleProcesses = idleCount
+			pool.TotalProcesses = int64(len(pool.Processes))
+		}
 
 		if count > 0 {
 			pool.ProcessesCpu = ptr(totalCPU / float64(count))
 			pool.ProcessesMemory = ptr(totalMem / float64(count))
 		}
 
+		if len(pool.Processes) > 0 {
+			if !scanned {
+				procs, procsErr = scanProcessTable()
+				scanned = true
+			}
+			if procsErr != nil {
+				logging.L().Debug("ElasticPHP-agent failed to scan processes", "error", procsErr)
+			} else if resources, err := collectPoolResources(PoolKey(poolCfg), pool, procs); err == nil {
+				pool.Resources = resources
+			} else {
+				logging.L().Debug("ElasticPHP-agent failed to read pool resource usage", "socket", poolCfg.Socket, "error", err)
+			}
+			pool.Lifecycle = analyzeLifecycle(cfg.PHPFpm, pool)
+			logStuckWorkers(poolCfg, pool.Name, pool.Lifecycle, cfg.PHPFpm.StuckLogInterval, time.Now())
+		}
+
 		phpStatus, err := GetPHPStats(ctx, poolCfg)
 		if err == nil && phpStatus != nil {
 			pool.PhpInfo = *phpStatus
@@ -166,15 +184,38 @@ func GetMetrics(ctx context.Context, cfg *config.Config) (map[string]*Result, er
 			logging.L().Debug("ElasticPHP-agent failed to get PHP info", "error", err)
 		}
 
-		opcacheStatus, err := GetOpcacheStatus(ctx, poolCfg)
-		if err == nil && opcacheStatus != nil {
-			pool.OpcacheStatus = *opcacheStatus
+		if isHTTPScheme(scheme) {
+			// Script injection and probes need direct FastCGI access to the pool
+			pool.DisabledFeatures = map[string]string{
+				"opcache": ErrFastCGIRequired.Error(),
+				"apcu":    ErrFastCGIRequired.Error(),
+			}
+			if poolCfg.PingPath != "" {
+				pool.DisabledFeatures["ping"] = ErrFastCGIRequired.Error()
+			}
 		} else {
-			logging.L().Debug("ElasticPHP-agent failed to get Opcache info", "error", err)
+			opcacheStatus, err := GetOpcacheStatus(ctx, poolCfg)
+			if err == nil && opcacheStatus != nil {
+				pool.OpcacheStatus = *opcacheStatus
+			} else {
+				logging.L().Debug("ElasticPHP-agent failed to get Opcache info", "error", err)
+			}
+
+			apcuStatus, err := GetApcuStatus(ctx, poolCfg)
+			if err == nil && apcuStatus != nil {
+				pool.ApcuStatus = *apcuStatus
+			} else {
+				logging.L().Debug("ElasticPHP-agent failed to get APCu info", "error", err)
+			}
 		}
 
+		pool.Ping = GetPingStats(poolCfg)
+		pool.HotEndpoints = GetHotEndpoints(poolCfg, pool.Name)
+		pool.MemoryLeaks = GetLeakReport(poolCfg, pool.Name)
+
+		result.Identity = NewPoolIdentity(poolCfg, pool.Name)
 		result.Pools[pool.Name] = pool
-		results[poolCfg.Socket] = result
+		results[PoolKey(poolCfg)] = result
 	}
 
 	return results, nil
@@ -186,7 +227,81 @@ func GetMetricsForPool(ctx context.Context, pool config.FPMPoolConfig) (*Result,
 		return nil, fmt.Errorf("invalid FPM socket address: %w", err)
 	}
 
-	client, err := fcgx.DialContext(ctx, scheme, address)
+	poolData, err := fetchPool(ctx, pool, scheme, address, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recalculate process counts from actual process list
+	if poolData.StatusFormat == StatusFormatJSON {
+		var activeCount, idleCount int64
+		for _, proc := range poolData.Processes {
+			switch strings.ToLower(proc.State) {
+			case "running", "reading headers", "info", "finishing", "ending":
+				activeCount++
+			case "idle":
+				idleCount++
+			}
+		}
+
+		poolData.ActiveProcesses = activeCount
+		poolData.IdleProcesses = idleCount
+		poolData.TotalProcesses = int64(len(poolData.Processes))
+	}
+
+	return &Result{
+		Timestamp: time.Now(),
+		Identity:  NewPoolIdentity(pool, poolData.Name),
+		Pools:     map[string]Pool{poolData.Name: poolData},
+	}, nil
+}
+
+// fetchPool requests the pool status in the configured format. Pools that do
+// not answer with OpenMetrics fall back to the JSON status.
+func fetchPool(ctx context.Context, poolCfg config.FPMPoolConfig, scheme, address, path string) (Pool, error) {
+	if resolveStatusFormat(poolCfg) == StatusFormatOpenMetrics {
+		body, err := fetchStatus(ctx, poolCfg, scheme, address, path, "openmetrics")
+		if err == nil {
+			var native []NativeMetric
+			var pool Pool
+			if native, err = ParseOpenMetrics(body); err == nil {
+				if pool, err = poolFromOpenMetrics(native, poolCfg); err == nil {
+					pool.StatusFormat = StatusFormatOpenMetrics
+					return pool, nil
+				}
+			}
+		}
+		logging.L().Debug("ElasticPHP-agent OpenMetrics status unavailable, falling back to JSON", "address", address, "error", err)
+	}
+
+	body, err := fetchStatus(ctx, poolCfg, scheme, address, path, "json&full")
+	if err != nil {
+		return Pool{}, err
+	}
+
+	var pool Pool
+	if err := json.Unmarshal(body, &pool); err != nil {
+		return Pool{}, fmt.Errorf("failed to parse FPM JSON: %w", err)
+	}
+	redactProcesses(&pool)
+	pool.StatusFormat = StatusFormatJSON
+
+	return pool, nil
+}
+
+// fetchStatus performs a single request against the status path, over FastCGI
+// or through the web server for http(s) pools, and returns the response body
+// without headers.
+func fetchStatus(ctx context.Context, poolCfg config.FPMPoolConfig, scheme, address, path, query string) ([]byte, error) {
+	if isHTTPScheme(scheme) {
+		httpCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		return fetchHTTPStatus(httpCtx, poolCfg, address, path, query)
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	client, err := fcgx.DialContext(dialCtx, scheme, address)
+	cancel()
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial FastCGI: %w", err)
 	}
@@ -197,41 +312,24 @@ func GetMetricsForPool(ctx context.Context, pool config.FPMPoolConfig) (*Result,
 		"SCRIPT_NAME":     path,
 		"SERVER_SOFTWARE": "elasticphp-agent",
 		"REMOTE_ADDR":     "127.0.0.1",
-		"QUERY_STRING":    "json&full",
+		"SERVER_PROTOCOL": "HTTP/1.1",
+		"QUERY_STRING":    query,
 	}
 
 	resp, err := client.Get(ctx, env)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var poolData Pool
-	err = fcgx.ReadJSON(resp, &poolData)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse FPM JSON: %w", err)
+		return nil, fmt.Errorf("fcgi GET failed: %w", err)
 	}
 
-	// Recalculate process counts from actual process list
-	var activeCount, idleCount int64
-	for _, proc := range poolData.Processes {
-		switch strings.ToLower(proc.State) {
-		case "running", "reading headers", "info", "finishing", "ending":
-			activeCount++
-		case "idle":
-			idleCount++
-		}
-	}
-
-	poolData.ActiveProcesses = activeCount
-	poolData.IdleProcesses = idleCount
-	poolData.TotalProcesses = int64(len(poolData.Processes))
+	return fcgx.ReadBody(resp)
+}
 
-	return &Result{
-		Timestamp: time.Now(),
-		Pools:     map[string]Pool{poolData.Name: poolData},
-	}, nil
+// isAgentRequest reports whether a worker is serving one of the agent's own
+// status, OPcache or APCu requests.
+func isAgentRequest(uri, statusPath string) bool {
+	return (statusPath != "" && strings.HasPrefix(uri, statusPath)) ||
+		strings.HasPrefix(uri, "/opcache-status-") ||
+		strings.HasPrefix(uri, "/elasticphp-apcu-status")
 }
 
 func ptr[T any](v T) *T {
@@ -248,5 +346,15 @@ func ParseAddress(addr string, path string) (scheme, address, scriptPath string,
 	if strings.HasPrefix(addr, "/") {
 		return "unix", addr, path, nil
 	}
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		u, err := url.Parse(addr)
+		if err != nil || u.Host == "" {
+			return "", "", "", fmt.Errorf("invalid status URL: %s", addr)
+		}
+		if u.Path != "" && u.Path != "/" {
+			path = u.Path
+		}
+		return u.Scheme, u.Scheme + "://" + u.Host, path, nil
+	}
 	return "", "", "", fmt.Errorf("unsupported socket format: %s", addr)
 }
@@ -2,6 +2,9 @@ package phpfpm
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"net/http/fcgi"
 	"strings"
 	"testing"
 	"time"
@@ -344,9 +347,33 @@ func TestParseAddress(t *testing.T) {
 			expectedScriptPath: "/status",
 			expectError:        false,
 		},
+		{
+			name:               "http status url",
+			addr:               "http://example.com",
+			path:               "/status",
+			expectedScheme:     "http",
+			expectedAddress:    "http://example.com",
+			expectedScriptPath: "/status",
+			expectError:        false,
+		},
+		{
+			name:               "https status url with path",
+			addr:               "https://web.internal:8443/fpm-status",
+			path:               "/status",
+			expectedScheme:     "https",
+			expectedAddress:    "https://web.internal:8443",
+			expectedScriptPath: "/fpm-status",
+			expectError:        false,
+		},
+		{
+			name:        "http url without host",
+			addr:        "http:///fpm-status",
+			path:        "/status",
+			expectError: true,
+		},
 		{
 			name:        "unsupported protocol",
-			addr:        "http://example.com",
+			addr:        "ftp://example.com",
 			path:        "/status",
 			expectError: true,
 		},
@@ -710,3 +737,262 @@ func TestPool_ProcessStateRecognition(t *testing.T) {
 		})
 	}
 }
+
+const jsonStatusFixture = `{
+	"pool": "www",
+	"process manager": "dynamic",
+	"start time": 1700000000,
+	"start since": 120,
+	"accepted conn": 42,
+	"listen queue": 0,
+	"max listen queue": 3,
+	"listen queue len": 511,
+	"idle processes": 1,
+	"active processes": 1,
+	"total processes": 2,
+	"max active processes": 2,
+	"max children reached": 0,
+	"slow requests": 1,
+	"processes": [
+		{"pid": 10, "state": "Running", "requests": 5, "request uri": "/status?json&full"},
+		{"pid": 11, "state": "Idle", "requests": 7, "request uri": "/index.php"}
+	]
+}`
+
+const openMetricsStatusFixture = `# HELP phpfpm_up Could pool www using a dynamic PM on PHP-FPM be reached?
+# TYPE phpfpm_up gauge
+phpfpm_up 1
+
+# HELP phpfpm_start_since The number of seconds since FPM has started.
+# TYPE phpfpm_start_since counter
+phpfpm_start_since 120
+
+# HELP phpfpm_accepted_connections The number of requests accepted by the pool.
+# TYPE phpfpm_accepted_connections counter
+phpfpm_accepted_connections 42
+
+# HELP phpfpm_listen_queue The number of requests in the queue of pending connections.
+# TYPE phpfpm_listen_queue gauge
+phpfpm_listen_queue 0
+
+# HELP phpfpm_listen_queue_length The size of the socket queue of pending connections.
+# TYPE phpfpm_listen_queue_length gauge
+phpfpm_listen_queue_length 511
+
+# HELP phpfpm_idle_processes The number of idle processes.
+# TYPE phpfpm_idle_processes gauge
+phpfpm_idle_processes 3
+
+# HELP phpfpm_active_processes The number of active processes.
+# TYPE phpfpm_active_processes gauge
+phpfpm_active_processes 1
+
+# HELP phpfpm_total_processes The number of idle + active processes.
+# TYPE phpfpm_total_processes gauge
+phpfpm_total_processes 4
+
+# HELP phpfpm_slow_requests The number of requests that exceeded your 'request_slowlog_timeout' value.
+# TYPE phpfpm_slow_requests counter
+phpfpm_slow_requests 1
+
+# HELP phpfpm_memory_peak The memory usage peak since FPM has started.
+# TYPE phpfpm_memory_peak gauge
+phpfpm_memory_peak 2097152
+# EOF
+`
+
+// startFakeFPM serves the status fixtures over FastCGI. Legacy servers ignore
+// the openmetrics query and answer with FPM's plain text status, like PHP < 8.1.
+func startFakeFPM(t *testing.T, legacy bool) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go fcgi.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.RawQuery == "openmetrics" && legacy:
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("pool:                 www\nprocess manager:      dynamic\n"))
+		case r.URL.RawQuery == "openmetrics":
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+			_, _ = w.Write([]byte(openMetricsStatusFixture))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(jsonStatusFixture))
+		}
+	}))
+
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestParseOpenMetrics(t *testing.T) {
+	native, err := ParseOpenMetrics([]byte(openMetricsStatusFixture))
+	if err != nil {
+		t.Fatalf("ParseOpenMetrics failed: %v", err)
+	}
+
+	if len(native) != 10 {
+		t.Fatalf("Expected 10 native samples, got %d", len(native))
+	}
+
+	if native[0].Name != "phpfpm_up" || native[0].Type != "gauge" || native[0].Value != 1 {
+		t.Errorf("Unexpected first sample: %+v", native[0])
+	}
+	if native[1].Type != "counter" {
+		t.Errorf("Expected phpfpm_start_since to be a counter, got %s", native[1].Type)
+	}
+}
+
+func TestParseOpenMetrics_Labels(t *testing.T) {
+	body := "# TYPE phpfpm_custom gauge\nphpfpm_custom{a=\"x\",b=\"quote \\\" here\"} 2.5\n# EOF\n"
+
+	native, err := ParseOpenMetrics([]byte(body))
+	if err != nil {
+		t.Fatalf("ParseOpenMetrics failed: %v", err)
+	}
+
+	if native[0].Labels["a"] != "x" || native[0].Labels["b"] != `quote " here` {
+		t.Errorf("Unexpected labels: %v", native[0].Labels)
+	}
+	if names := native[0].LabelNames(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("Unexpected label names: %v", names)
+	}
+	if native[0].Value != 2.5 {
+		t.Errorf("Expected value 2.5, got %f", native[0].Value)
+	}
+}
+
+func TestParseOpenMetrics_RejectsOtherFormats(t *testing.T) {
+	for name, body := range map[string]string{
+		"json":  jsonStatusFixture,
+		"plain": "pool:                 www\nprocess manager:      dynamic\n",
+		"empty": "",
+		"noeof": "# TYPE phpfpm_up gauge\nphpfpm_up 1\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseOpenMetrics([]byte(body)); err == nil {
+				t.Errorf("Expected %s body to be rejected", name)
+			}
+		})
+	}
+}
+
+func TestPoolFromOpenMetrics(t *testing.T) {
+	native, err := ParseOpenMetrics([]byte(openMetricsStatusFixture))
+	if err != nil {
+		t.Fatalf("ParseOpenMetrics failed: %v", err)
+	}
+
+	pool, err := poolFromOpenMetrics(native, config.FPMPoolConfig{Name: "configured"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if pool.Name != "www" {
+		t.Errorf("Expected pool name www, got %q", pool.Name)
+	}
+	if pool.ProcessManager != "dynamic" {
+		t.Errorf("Expected process manager dynamic, got %q", pool.ProcessManager)
+	}
+	if pool.AcceptedConnections != 42 || pool.StartSince != 120 || pool.ListenQueueLength != 511 {
+		t.Errorf("Unexpected pool counters: %+v", pool)
+	}
+	if pool.IdleProcesses != 3 || pool.ActiveProcesses != 1 || pool.TotalProcesses != 4 {
+		t.Errorf("Unexpected process counts: idle=%d active=%d total=%d", pool.IdleProcesses, pool.ActiveProcesses, pool.TotalProcesses)
+	}
+	if pool.MemoryPeak != 2097152 {
+		t.Errorf("Expected memory peak 2097152, got %d", pool.MemoryPeak)
+	}
+	if len(pool.NativeMetrics) != len(native) {
+		t.Errorf("Expected native metrics to be kept on the pool")
+	}
+
+	// A changed help text falls back to the configured name, then the socket
+	for i := range native {
+		native[i].Help = "Could not tell the pool"
+	}
+	if pool, _ := poolFromOpenMetrics(native, config.FPMPoolConfig{Name: "api", Socket: "unix:///run/php/api.sock"}); pool.Name != "api" {
+		t.Errorf("Expected the configured pool name, got %q", pool.Name)
+	}
+	if pool, _ := poolFromOpenMetrics(native, config.FPMPoolConfig{Socket: "unix:///run/php/api.sock"}); pool.Name != "unix:///run/php/api.sock" {
+		t.Errorf("Expected the socket as pool name, got %q", pool.Name)
+	}
+	if _, err := poolFromOpenMetrics(native, config.FPMPoolConfig{}); err == nil {
+		t.Errorf("Expected an error without any pool name")
+	}
+}
+
+func TestResolveStatusFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"", StatusFormatJSON},
+		{"json", StatusFormatJSON},
+		{"openmetrics", StatusFormatOpenMetrics},
+		{"OpenMetrics", StatusFormatOpenMetrics},
+		// Unknown binary versions try OpenMetrics and rely on the JSON fallback
+		{"auto", StatusFormatOpenMetrics},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := resolveStatusFormat(config.FPMPoolConfig{StatusFormat: tt.format})
+			if got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetMetricsForPool_StatusFormats(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	tests := []struct {
+		name           string
+		format         string
+		legacy         bool
+		expectedFormat string
+		expectedTotal  int64
+	}{
+		{"json", StatusFormatJSON, false, StatusFormatJSON, 2},
+		{"openmetrics", StatusFormatOpenMetrics, false, StatusFormatOpenMetrics, 4},
+		{"openmetrics falls back to json", StatusFormatOpenMetrics, true, StatusFormatJSON, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socket := startFakeFPM(t, tt.legacy)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			result, err := GetMetricsForPool(ctx, config.FPMPoolConfig{
+				StatusSocket: socket,
+				StatusPath:   "/status",
+				StatusFormat: tt.format,
+			})
+			if err != nil {
+				t.Fatalf("GetMetricsForPool failed: %v", err)
+			}
+
+			pool, ok := result.Pools["www"]
+			if !ok {
+				t.Fatalf("Expected pool www in result, got %v", result.Pools)
+			}
+			if pool.StatusFormat != tt.expectedFormat {
+				t.Errorf("Expected status format %s, got %s", tt.expectedFormat, pool.StatusFormat)
+			}
+			if pool.TotalProcesses != tt.expectedTotal {
+				t.Errorf("Expected %d total processes, got %d", tt.expectedTotal, pool.TotalProcesses)
+			}
+			if pool.AcceptedConnections != 42 {
+				t.Errorf("Expected 42 accepted connections, got %d", pool.AcceptedConnections)
+			}
+		})
+	}
+}
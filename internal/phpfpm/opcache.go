@@ -53,6 +53,9 @@ exit;`
 	if err != nil {
 		return nil, fmt.Errorf("invalid socket: %w", err)
 	}
+	if isHTTPScheme(scheme) {
+		return nil, fmt.Errorf("OPcache status %w", ErrFastCGIRequired)
+	}
 
 	client, err := fcgx.DialContext(ctx, scheme, address)
 	if err != nil {
@@ -0,0 +1,298 @@
+package phpfpm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+const (
+	StatusFormatJSON        = "json"
+	StatusFormatOpenMetrics = "openmetrics"
+	StatusFormatAuto        = "auto"
+)
+
+// NativeMetric is a single sample from FPM's own OpenMetrics status output.
+type NativeMetric struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Help   string            `json:"help,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+var (
+	openMetricsPoolPattern = regexp.MustCompile(`Could pool (\S+) using a (\S+) PM`)
+	phpVersionPattern      = regexp.MustCompile(`PHP (\d+)\.(\d+)`)
+
+	fpmVersionCache     = make(map[string][2]int)
+	fpmVersionCacheLock sync.Mutex
+)
+
+// resolveStatusFormat turns the configured status format into the one to
+// request. "auto" picks OpenMetrics when the FPM binary is PHP 8.1 or newer and
+// JSON otherwise; unknown versions try OpenMetrics and rely on the JSON fallback.
+func resolveStatusFormat(cfg config.FPMPoolConfig) string {
+	switch strings.ToLower(cfg.StatusFormat) {
+	case StatusFormatOpenMetrics:
+		return StatusFormatOpenMetrics
+	case StatusFormatAuto:
+		major, minor, ok := fpmVersion(cfg.Binary)
+		if !ok || major > 8 || (major == 8 && minor >= 1) {
+			return StatusFormatOpenMetrics
+		}
+		return StatusFormatJSON
+	default:
+		return StatusFormatJSON
+	}
+}
+
+func fpmVersion(binary string) (int, int, bool) {
+	if binary == "" {
+		return 0, 0, false
+	}
+
+	fpmVersionCacheLock.Lock()
+	defer fpmVersionCacheLock.Unlock()
+
+	if v, ok := fpmVersionCache[binary]; ok {
+		return v[0], v[1], v[0] > 0
+	}
+
+	var version [2]int
+	if out, err := getPHPVersion(binary); err == nil {
+		if match := phpVersionPattern.FindStringSubmatch(out); match != nil {
+			version[0], _ = strconv.Atoi(match[1])
+			version[1], _ = strconv.Atoi(match[2])
+		}
+	}
+	fpmVersionCache[binary] = version
+
+	return version[0], version[1], version[0] > 0
+}
+
+// ParseOpenMetrics parses the body of an `?openmetrics` FPM status request. It
+// returns an error when the body is not OpenMetrics, which is what FPM versions
+// before 8.1 return since they ignore the unknown query parameter.
+func ParseOpenMetrics(body []byte) ([]NativeMetric, error) {
+	help := map[string]string{}
+	types := map[string]string{}
+	var samples []NativeMetric
+	eof := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) >= 2 && fields[1] == "EOF" {
+				eof = true
+				break
+			}
+			if len(fields) >= 3 {
+				switch fields[1] {
+				case "HELP":
+					if len(fields) == 4 {
+						help[fields[2]] = fields[3]
+					}
+				case "TYPE":
+					if len(fields) == 4 {
+						types[fields[2]] = fields[3]
+					}
+				}
+			}
+			continue
+		}
+
+		sample, err := parseOpenMetricsSample(line)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan openmetrics output: %w", err)
+	}
+	if !eof || len(samples) == 0 {
+		return nil, fmt.Errorf("response is not openmetrics output")
+	}
+
+	for i := range samples {
+		family := openMetricsFamily(samples[i].Name, types)
+		samples[i].Type = types[family]
+		if samples[i].Type == "" {
+			samples[i].Type = "unknown"
+		}
+		samples[i].Help = help[family]
+	}
+
+	return samples, nil
+}
+
+func openMetricsFamily(name string, types map[string]string) string {
+	if _, ok := types[name]; ok {
+		return name
+	}
+	for _, suffix := range []string{"_total", "_created"} {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != name {
+			if _, ok := types[trimmed]; ok {
+				return trimmed
+			}
+		}
+	}
+	return name
+}
+
+func parseOpenMetricsSample(line string) (NativeMetric, error) {
+	sample := NativeMetric{}
+
+	nameEnd := strings.IndexAny(line, "{ ")
+	if nameEnd <= 0 {
+		return sample, fmt.Errorf("invalid openmetrics sample: %q", line)
+	}
+	sample.Name = line[:nameEnd]
+	rest := line[nameEnd:]
+
+	if strings.HasPrefix(rest, "{") {
+		end := strings.LastIndex(rest, "}")
+		if end == -1 {
+			return sample, fmt.Errorf("unterminated label set: %q", line)
+		}
+		labels, err := parseOpenMetricsLabels(rest[1:end])
+		if err != nil {
+			return sample, err
+		}
+		sample.Labels = labels
+		rest = rest[end+1:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sample, fmt.Errorf("missing value in openmetrics sample: %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value in openmetrics sample %q: %w", line, err)
+	}
+	sample.Value = value
+
+	return sample, nil
+}
+
+func parseOpenMetricsLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for len(strings.TrimSpace(s)) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.Index(s, "=")
+		if eq <= 0 || len(s) < eq+2 || s[eq+1] != '"' {
+			return nil, fmt.Errorf("invalid label set: %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+
+		var value strings.Builder
+		i := eq + 2
+		closed := false
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			if c == '"' {
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return nil, fmt.Errorf("unterminated label value: %q", s)
+		}
+
+		labels[key] = value.String()
+		s = s[i+1:]
+	}
+	return labels, nil
+}
+
+// poolFromOpenMetrics maps the native FPM series onto the Pool fields that the
+// JSON status would have filled. Per-process data is not part of the
+// OpenMetrics output, so Processes stays empty. The pool name comes from the
+// phpfpm_up help text, which FPM doesn't promise to keep, so the configured
+// name or socket stands in when it doesn't match.
+func poolFromOpenMetrics(native []NativeMetric, poolCfg config.FPMPoolConfig) (Pool, error) {
+	pool := Pool{NativeMetrics: native}
+
+	for _, m := range native {
+		v := int64(m.Value)
+		switch strings.TrimSuffix(m.Name, "_total") {
+		case "phpfpm_up":
+			if match := openMetricsPoolPattern.FindStringSubmatch(m.Help); match != nil {
+				pool.Name = match[1]
+				pool.ProcessManager = match[2]
+			}
+		case "phpfpm_start_since":
+			pool.StartSince = v
+		case "phpfpm_accepted_connections":
+			pool.AcceptedConnections = v
+		case "phpfpm_listen_queue":
+			pool.ListenQueue = v
+		case "phpfpm_max_listen_queue":
+			pool.MaxListenQueue = v
+		case "phpfpm_listen_queue_length":
+			pool.ListenQueueLength = v
+		case "phpfpm_idle_processes":
+			pool.IdleProcesses = v
+		case "phpfpm_active_processes":
+			pool.ActiveProcesses = v
+		case "phpfpm_total_processes":
+			pool.TotalProcesses = v
+		case "phpfpm_max_active_processes":
+			pool.MaxActiveProcesses = v
+		case "phpfpm_max_children_reached":
+			pool.MaxChildrenReached = v
+		case "phpfpm_slow_requests":
+			pool.SlowRequests = v
+		case "phpfpm_memory_peak":
+			pool.MemoryPeak = v
+		}
+	}
+
+	if pool.Name == "" {
+		pool.Name = poolCfg.Name
+	}
+	if pool.Name == "" {
+		pool.Name = poolCfg.Socket
+	}
+	if pool.Name == "" {
+		return Pool{}, fmt.Errorf("no pool name in the OpenMetrics status and none configured")
+	}
+	return pool, nil
+}
+
+// LabelNames returns the sorted label names of a native sample.
+func (m NativeMetric) LabelNames() []string {
+	names := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
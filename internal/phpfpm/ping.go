@@ -0,0 +1,186 @@
+package phpfpm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+	"github.com/elasticphphq/fcgx"
+)
+
+// PingLatencyBuckets are the upper bounds, in seconds, of the ping latency histogram.
+var PingLatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+var (
+	pingStats     = make(map[string]*PingStats)
+	pingStatsLock sync.Mutex
+)
+
+type PingStats struct {
+	Up          bool      `json:"up"`
+	LastLatency float64   `json:"last_latency_seconds"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastCheck   time.Time `json:"last_check"`
+	Failures    uint64    `json:"failures"`
+	Count       uint64    `json:"count"`
+	Sum         float64   `json:"sum"`
+	// Buckets holds cumulative counts of successful pings per upper bound.
+	Buckets map[float64]uint64 `json:"-"`
+}
+
+// Ping requests the pool's ping.path over FastCGI on the pool's own listen
+// socket, so the round trip goes through the same workers as real traffic.
+func Ping(ctx context.Context, cfg config.FPMPoolConfig) (time.Duration, error) {
+	socket := cfg.Socket
+	if socket == "" {
+		socket = cfg.StatusSocket
+	}
+
+	scheme, address, path, err := ParseAddress(socket, cfg.PingPath)
+	if err != nil {
+		return 0, fmt.Errorf("invalid socket: %w", err)
+	}
+	if isHTTPScheme(scheme) {
+		return 0, fmt.Errorf("ping %w", ErrFastCGIRequired)
+	}
+
+	expected := cfg.PingResponse
+	if expected == "" {
+		expected = "pong"
+	}
+
+	start := time.Now()
+
+	client, err := fcgx.DialContext(ctx, scheme, address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to dial FPM: %w", err)
+	}
+	defer client.Close()
+
+	env := map[string]string{
+		"SCRIPT_FILENAME": path,
+		"SCRIPT_NAME":     path,
+		"SERVER_SOFTWARE": "elasticphp-agent",
+		"REMOTE_ADDR":     "127.0.0.1",
+		"SERVER_PROTOCOL": "HTTP/1.1",
+	}
+
+	resp, err := client.Get(ctx, env)
+	if err != nil {
+		return 0, fmt.Errorf("fcgi GET failed: %w", err)
+	}
+
+	body, err := fcgx.ReadBody(resp)
+	latency := time.Since(start)
+	if err != nil {
+		return latency, fmt.Errorf("failed to read ping response: %w", err)
+	}
+
+	if got := strings.TrimSpace(string(body)); got != expected {
+		return latency, fmt.Errorf("unexpected ping response %q, expected %q", got, expected)
+	}
+
+	return latency, nil
+}
+
+// StartPingProbes runs a ping probe per pool with a ping path on its own
+// schedule, independent of status scrapes, until ctx is cancelled.
+func StartPingProbes(ctx context.Context, cfg *config.Config) {
+	for _, pool := range cfg.PHPFpm.Pools {
+		if pool.PingPath == "" {
+			continue
+		}
+		if isHTTPPool(pool) {
+			logging.L().Info("ElasticPHP-agent FPM ping probe disabled", "status_socket", pool.StatusSocket, "reason", "ping "+ErrFastCGIRequired.Error())
+			continue
+		}
+
+		interval := pool.PingInterval
+		if interval == 0 {
+			interval = cfg.PHPFpm.PingInterval
+		}
+		if interval <= 0 {
+			continue
+		}
+
+		go func(poolCfg config.FPMPoolConfig) {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					timeout := poolCfg.Timeout
+					if timeout == 0 {
+						timeout = 2 * time.Second
+					}
+
+					pingCtx, cancel := context.WithTimeout(ctx, timeout)
+					latency, err := Ping(pingCtx, poolCfg)
+					cancel()
+
+					if err != nil {
+						logging.L().Debug("ElasticPHP-agent FPM ping failed", "socket", poolCfg.Socket, "ping_path", poolCfg.PingPath, "error", err)
+					}
+					recordPing(PoolKey(poolCfg), latency, err)
+				}
+			}
+		}(pool)
+	}
+}
+
+func recordPing(key string, latency time.Duration, err error) {
+	pingStatsLock.Lock()
+	defer pingStatsLock.Unlock()
+
+	stats, ok := pingStats[key]
+	if !ok {
+		stats = &PingStats{Buckets: make(map[float64]uint64, len(PingLatencyBuckets))}
+		pingStats[key] = stats
+	}
+
+	stats.LastCheck = time.Now()
+	stats.LastLatency = latency.Seconds()
+
+	if err != nil {
+		stats.Up = false
+		stats.LastError = err.Error()
+		stats.Failures++
+		return
+	}
+
+	stats.Up = true
+	stats.LastError = ""
+	stats.Count++
+	stats.Sum += latency.Seconds()
+	for _, bound := range PingLatencyBuckets {
+		if latency.Seconds() <= bound {
+			stats.Buckets[bound]++
+		}
+	}
+}
+
+// GetPingStats returns a copy of the probe results for a pool, or nil when no
+// probe has run for it yet.
+func GetPingStats(poolCfg config.FPMPoolConfig) *PingStats {
+	pingStatsLock.Lock()
+	defer pingStatsLock.Unlock()
+
+	stats, ok := pingStats[PoolKey(poolCfg)]
+	if !ok {
+		return nil
+	}
+
+	out := *stats
+	out.Buckets = make(map[float64]uint64, len(stats.Buckets))
+	for k, v := range stats.Buckets {
+		out.Buckets[k] = v
+	}
+	return &out
+}
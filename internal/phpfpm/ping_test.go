@@ -0,0 +1,162 @@
+package phpfpm
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+)
+
+func startFakePing(t *testing.T, response string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go fcgi.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(response))
+	}))
+
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		expected    string
+		expectError bool
+	}{
+		{"default pong", "pong", "", false},
+		{"custom response", "alive", "alive", false},
+		{"trailing newline", "pong\n", "", false},
+		{"unexpected response", "File not found.", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socket := startFakePing(t, tt.response)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			latency, err := Ping(ctx, config.FPMPoolConfig{
+				Socket:       socket,
+				PingPath:     "/ping",
+				PingResponse: tt.expected,
+			})
+
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error for response %q", tt.response)
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if latency <= 0 {
+				t.Errorf("Expected a positive latency, got %v", latency)
+			}
+		})
+	}
+}
+
+func TestPing_InvalidSocket(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := Ping(ctx, config.FPMPoolConfig{Socket: "invalid://socket", PingPath: "/ping"}); err == nil {
+		t.Errorf("Expected error for invalid socket")
+	}
+
+	if _, err := Ping(ctx, config.FPMPoolConfig{Socket: "unix:///nonexistent/ping.sock", PingPath: "/ping"}); err == nil {
+		t.Errorf("Expected error for non-existent socket")
+	}
+}
+
+func TestRecordPing(t *testing.T) {
+	poolCfg := config.FPMPoolConfig{Socket: "tcp://record-ping-test"}
+	key := PoolKey(poolCfg)
+
+	if GetPingStats(poolCfg) != nil {
+		t.Fatalf("Expected no stats before the first probe")
+	}
+
+	recordPing(key, 3*time.Millisecond, nil)
+	recordPing(key, 200*time.Millisecond, nil)
+	recordPing(key, 0, context.DeadlineExceeded)
+
+	stats := GetPingStats(poolCfg)
+	if stats == nil {
+		t.Fatalf("Expected stats after probes")
+	}
+
+	if stats.Up {
+		t.Errorf("Expected Up to be false after a failed probe")
+	}
+	if stats.Failures != 1 {
+		t.Errorf("Expected 1 failure, got %d", stats.Failures)
+	}
+	if stats.Count != 2 {
+		t.Errorf("Expected 2 successful probes, got %d", stats.Count)
+	}
+	if stats.Buckets[0.0025] != 0 || stats.Buckets[0.005] != 1 || stats.Buckets[0.25] != 2 {
+		t.Errorf("Unexpected cumulative buckets: %v", stats.Buckets)
+	}
+	if stats.LastError == "" {
+		t.Errorf("Expected last error to be recorded")
+	}
+
+	// Pools sharing a socket keep their own stats
+	if GetPingStats(config.FPMPoolConfig{Name: "other", Socket: "tcp://record-ping-test"}) != nil {
+		t.Errorf("Expected no stats for another pool on the same socket")
+	}
+
+	// The returned stats must be a copy
+	stats.Buckets[0.005] = 100
+	if GetPingStats(poolCfg).Buckets[0.005] != 1 {
+		t.Errorf("Expected GetPingStats to return a copy")
+	}
+}
+
+func TestStartPingProbes(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	socket := startFakePing(t, "pong")
+	cfg := &config.Config{
+		PHPFpm: config.FPMConfig{
+			PingInterval: 10 * time.Millisecond,
+			Pools: []config.FPMPoolConfig{
+				{Socket: socket, PingPath: "/ping"},
+				{Socket: "tcp://no-ping-path"},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	StartPingProbes(ctx, cfg)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats := GetPingStats(cfg.PHPFpm.Pools[0]); stats != nil && stats.Count > 0 {
+			if !stats.Up {
+				t.Errorf("Expected pool to be up, last error: %s", stats.LastError)
+			}
+			if GetPingStats(cfg.PHPFpm.Pools[1]) != nil {
+				t.Errorf("Expected pools without a ping path to be skipped")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Expected the probe to record a result")
+}
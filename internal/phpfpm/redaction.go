@@ -0,0 +1,14 @@
+package phpfpm
+
+import (
+	"github.com/elasticphphq/agent/internal/redact"
+)
+
+// redactProcesses scrubs the per-process request data right after the status
+// is decoded, so nothing derived from it sees the raw URIs.
+func redactProcesses(pool *Pool) {
+	r := redact.Shared()
+	for i := range pool.Processes {
+		pool.Processes[i].RequestURI = r.URI(pool.Processes[i].RequestURI)
+	}
+}
@@ -0,0 +1,55 @@
+package phpfpm
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"testing"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/redact"
+)
+
+func TestGetMetricsForPool_RedactsProcesses(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go fcgi.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pool":"www","processes":[
+			{"pid":1,"state":"Running","request uri":"/password/reset/8f14e45fceea167a5a36dedd4bea2543?email=jane@example.com"},
+			{"pid":2,"state":"Running","request uri":"/orders/1234?page=2"}
+		]}`))
+	}))
+
+	socket := "tcp://" + listener.Addr().String()
+	poolCfg := config.FPMPoolConfig{Socket: socket, StatusSocket: socket, StatusPath: "/status"}
+
+	result, err := GetMetricsForPool(context.Background(), poolCfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	procs := result.Pools["www"].Processes
+	if procs[0].RequestURI != "/password/reset/:token" || procs[1].RequestURI != "/orders/:id" {
+		t.Errorf("Expected URIs to be redacted with the defaults, got %q and %q", procs[0].RequestURI, procs[1].RequestURI)
+	}
+
+	cfg := redact.Default()
+	cfg.AllowQueryKeys = []string{"page"}
+	if err := redact.Configure(cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer redact.Configure(redact.Default())
+
+	result, err = GetMetricsForPool(context.Background(), poolCfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := result.Pools["www"].Processes[1].RequestURI; got != "/orders/:id?page=2" {
+		t.Errorf("Expected allow-listed query keys to be kept, got %q", got)
+	}
+}
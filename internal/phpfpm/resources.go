@@ -0,0 +1,406 @@
+package phpfpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/elasticphphq/agent/internal/cgroup"
+)
+
+const (
+	ResourceSourceProc   = "proc"
+	ResourceSourceCgroup = "cgroup"
+
+	// userHZ is the fixed clock tick rate /proc/<pid>/stat reports CPU time in.
+	userHZ = 100
+)
+
+var (
+	resourceTracker     = map[string]*poolCounters{}
+	masterPools         = map[procID]string{} // Pool each master is counted in
+	resourceTrackerLock sync.Mutex
+
+	// cgroup lookups are variables so tests can fake a per-pool cgroup.
+	cgroupProcessPath = cgroup.ProcessPath
+	cgroupProcs       = cgroup.Procs
+	cgroupReadPath    = cgroup.ReadPath
+)
+
+// PoolResources is the resource usage of a pool's master, workers and their
+// children. Counters survive worker recycling: usage of exited processes is
+// kept, so totals only reset when the agent restarts.
+type PoolResources struct {
+	Source                 string  `json:"source"`           // proc or cgroup
+	Cgroup                 string  `json:"cgroup,omitempty"` // Set when read from a per-pool cgroup
+	MasterPID              int     `json:"master_pid,omitempty"`
+	MasterCounted          bool    `json:"master_counted"` // The master's usage is counted in one pool only
+	Processes              int     `json:"processes"`
+	CPUSeconds             float64 `json:"cpu_seconds"`
+	RSSBytes               uint64  `json:"rss_bytes"` // memory.current when read from a cgroup
+	ReadBytes              uint64  `json:"read_bytes"`
+	WriteBytes             uint64  `json:"write_bytes"`
+	VoluntaryCtxSwitches   uint64  `json:"voluntary_ctx_switches"`
+	InvoluntaryCtxSwitches uint64  `json:"involuntary_ctx_switches"`
+}
+
+// procCounters are the cumulative counters of one process or a sum of them.
+type procCounters struct {
+	cpuSeconds  float64
+	readBytes   uint64
+	writeBytes  uint64
+	voluntary   uint64
+	involuntary uint64
+}
+
+func (c *procCounters) add(o procCounters) {
+	c.cpuSeconds += o.cpuSeconds
+	c.readBytes += o.readBytes
+	c.writeBytes += o.writeBytes
+	c.voluntary += o.voluntary
+	c.involuntary += o.involuntary
+}
+
+type procSample struct {
+	startTime uint64
+	rss       uint64
+	counters  procCounters
+}
+
+// procID tells a process apart from a later one reusing its PID.
+type procID struct {
+	pid       int
+	startTime uint64
+}
+
+type poolCounters struct {
+	procs   map[procID]procCounters
+	retired procCounters
+	source  string // Chosen on the pool's first collection
+	cgroup  string
+}
+
+// processTable is one scan of procRoot, shared by the pools of a collection.
+type processTable struct {
+	ppids    map[int]int
+	children map[int][]int
+}
+
+// scanProcessTable maps every PID in procRoot to its parent and children.
+func scanProcessTable() (*processTable, error) {
+	ppids, err := scanParents()
+	if err != nil {
+		return nil, err
+	}
+
+	procs := &processTable{ppids: ppids, children: map[int][]int{}}
+	for pid, ppid := range ppids {
+		procs.children[ppid] = append(procs.children[ppid], pid)
+	}
+
+	// Forget the pools of masters that exited
+	resourceTrackerLock.Lock()
+	for id := range masterPools {
+		if _, ok := ppids[id.pid]; !ok {
+			delete(masterPools, id)
+		}
+	}
+	resourceTrackerLock.Unlock()
+	return procs, nil
+}
+
+// collectPoolResources aggregates /proc stats over the pool's workers and
+// their descendants. A master serves every pool of its FPM instance, so its
+// usage is counted in the first pool that reads it, for as long as it runs.
+// When the workers live in a cgroup of their own on the pool's first
+// collection, CPU, memory and I/O are read from that cgroup from then on.
+func collectPoolResources(key string, pool Pool, procs *processTable) (*PoolResources, error) {
+	if len(pool.Processes) == 0 {
+		return nil, fmt.Errorf("no worker processes in the pool status")
+	}
+
+	ppids, children := procs.ppids, procs.children
+	res := &PoolResources{Source: ResourceSourceProc}
+	tree := map[int]bool{}
+
+	// Only the pool's own workers are walked, the master's other children
+	// belong to other pools. Workers must look like FPM processes, a status
+	// PID from another PID namespace may belong to an unrelated process here.
+	queue := make([]int, 0, len(pool.Processes))
+	for _, proc := range pool.Processes {
+		if tree[proc.PID] {
+			continue
+		}
+		if comm, _, err := readStat(proc.PID); err == nil && fpmNamePattern.MatchString(comm) {
+			tree[proc.PID] = true
+			queue = append(queue, proc.PID)
+		}
+	}
+	if len(queue) > 0 {
+		if comm, _, err := readStat(ppids[queue[0]]); err == nil && fpmNamePattern.MatchString(comm) {
+			res.MasterPID = ppids[queue[0]]
+		}
+	}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			if !tree[child] {
+				tree[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	if res.MasterPID > 0 {
+		if sample, err := readProcSample(res.MasterPID); err == nil && claimMaster(procID{res.MasterPID, sample.startTime}, key) {
+			res.MasterCounted = true
+			tree[res.MasterPID] = true
+		}
+	}
+
+	current := map[procID]procCounters{}
+	for pid := range tree {
+		sample, err := readProcSample(pid)
+		if err != nil {
+			// The process exited since the scan
+			continue
+		}
+		res.Processes++
+		res.RSSBytes += sample.rss
+		current[procID{pid, sample.startTime}] = sample.counters
+	}
+	if res.Processes == 0 {
+		return nil, fmt.Errorf("no pool processes found in %s", procRoot)
+	}
+
+	totals := trackPoolCounters(key, current)
+	res.CPUSeconds = totals.cpuSeconds
+	res.ReadBytes = totals.readBytes
+	res.WriteBytes = totals.writeBytes
+	res.VoluntaryCtxSwitches = totals.voluntary
+	res.InvoluntaryCtxSwitches = totals.involuntary
+
+	// Switching between the cgroup and the /proc totals would make the
+	// counters jump, so the source is kept once chosen
+	var stats *cgroup.Stats
+	source, path, pinned := poolSource(key)
+	if !pinned {
+		source = ResourceSourceProc
+		if p, ok := poolCgroup(pool, tree, res.MasterPID); ok {
+			if s, err := cgroupReadPath(p); err == nil && s.CPU != nil && s.Memory != nil {
+				source, path, stats = ResourceSourceCgroup, p, s
+			}
+		}
+		pinPoolSource(key, source, path)
+	}
+	if source == ResourceSourceCgroup {
+		if stats == nil {
+			var err error
+			if stats, err = cgroupReadPath(path); err != nil {
+				return nil, fmt.Errorf("failed to read pool cgroup %s: %w", path, err)
+			}
+			if stats.CPU == nil || stats.Memory == nil {
+				return nil, fmt.Errorf("no CPU or memory stats in pool cgroup %s", path)
+			}
+		}
+		res.Source = ResourceSourceCgroup
+		res.Cgroup = path
+		res.CPUSeconds = stats.CPU.UsageSeconds
+		res.RSSBytes = stats.Memory.Current
+		if stats.IO != nil {
+			res.ReadBytes = stats.IO.ReadBytes
+			res.WriteBytes = stats.IO.WriteBytes
+		}
+	}
+
+	return res, nil
+}
+
+// claimMaster tells whether the master's usage is counted in the pool. The
+// first pool to claim a master keeps it until the master exits, so its
+// lifetime counters never move to another pool.
+func claimMaster(master procID, key string) bool {
+	resourceTrackerLock.Lock()
+	defer resourceTrackerLock.Unlock()
+
+	owner, ok := masterPools[master]
+	if !ok {
+		masterPools[master] = key
+		return true
+	}
+	return owner == key
+}
+
+// poolSource returns the accounting source chosen for the pool, if any.
+func poolSource(key string) (string, string, bool) {
+	resourceTrackerLock.Lock()
+	defer resourceTrackerLock.Unlock()
+
+	pc, ok := resourceTracker[key]
+	if !ok || pc.source == "" {
+		return "", "", false
+	}
+	return pc.source, pc.cgroup, true
+}
+
+func pinPoolSource(key, source, path string) {
+	resourceTrackerLock.Lock()
+	defer resourceTrackerLock.Unlock()
+
+	if pc, ok := resourceTracker[key]; ok {
+		pc.source, pc.cgroup = source, path
+	}
+}
+
+// poolCgroup returns the v2 cgroup of the pool's workers when it holds nothing
+// but the pool's process tree and master, e.g. a pool run as its own systemd
+// service.
+func poolCgroup(pool Pool, tree map[int]bool, master int) (string, bool) {
+	var path string
+	for _, proc := range pool.Processes {
+		p, err := cgroupProcessPath(proc.PID)
+		if err != nil || (path != "" && p != path) {
+			return "", false
+		}
+		path = p
+	}
+	if path == "" || path == "/" {
+		return "", false
+	}
+
+	members, err := cgroupProcs(path)
+	if err != nil || len(members) == 0 {
+		return "", false
+	}
+	for _, pid := range members {
+		if !tree[pid] && pid != master {
+			return "", false
+		}
+	}
+	return path, true
+}
+
+// trackPoolCounters folds the counters of processes that are gone into the
+// pool's retired totals and returns the monotonic pool totals.
+func trackPoolCounters(key string, current map[procID]procCounters) procCounters {
+	resourceTrackerLock.Lock()
+	defer resourceTrackerLock.Unlock()
+
+	pc, ok := resourceTracker[key]
+	if !ok {
+		pc = &poolCounters{}
+		resourceTracker[key] = pc
+	}
+
+	for id, counters := range pc.procs {
+		if _, ok := current[id]; !ok {
+			pc.retired.add(counters)
+		}
+	}
+	pc.procs = current
+
+	totals := pc.retired
+	for _, counters := range current {
+		totals.add(counters)
+	}
+	return totals
+}
+
+// scanParents maps every PID in procRoot to its parent PID.
+func scanParents() (map[int]int, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	ppids := make(map[int]int, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		_, fields, err := readStat(pid)
+		if err != nil {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err == nil {
+			ppids[pid] = ppid
+		}
+	}
+	return ppids, nil
+}
+
+// readStat returns the command name from /proc/<pid>/stat and the fields after
+// it, so index 0 is the state and index 1 the parent PID.
+func readStat(pid int) (string, []string, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The command name is in parentheses and may contain spaces
+	stat := string(data)
+	start, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if start == -1 || end < start {
+		return "", nil, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return "", nil, fmt.Errorf("short stat for pid %d", pid)
+	}
+	return stat[start+1 : end], fields, nil
+}
+
+func readProcSample(pid int) (*procSample, error) {
+	_, fields, err := readStat(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := &procSample{}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	sample.counters.cpuSeconds = float64(utime+stime) / userHZ
+	sample.startTime, _ = strconv.ParseUint(fields[19], 10, 64)
+
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+	if status, err := readProcKeyValues(filepath.Join(dir, "status")); err == nil {
+		sample.rss = status["VmRSS"] * 1024
+		sample.counters.voluntary = status["voluntary_ctxt_switches"]
+		sample.counters.involuntary = status["nonvoluntary_ctxt_switches"]
+	}
+	// io needs the same user or CAP_SYS_PTRACE, it is skipped otherwise
+	if io, err := readProcKeyValues(filepath.Join(dir, "io")); err == nil {
+		sample.counters.readBytes = io["read_bytes"]
+		sample.counters.writeBytes = io["write_bytes"]
+	}
+
+	return sample, nil
+}
+
+// readProcKeyValues parses "Key: value [unit]" files such as status and io.
+func readProcKeyValues(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]uint64{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			values[strings.TrimSpace(key)] = v
+		}
+	}
+	return values, nil
+}
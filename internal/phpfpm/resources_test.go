@@ -0,0 +1,235 @@
+package phpfpm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elasticphphq/agent/internal/cgroup"
+)
+
+type fakeProc struct {
+	comm         string
+	ppid         int
+	utime, stime uint64
+	start        uint64
+	rssKB        uint64
+	readBytes    uint64
+}
+
+// writeProcs writes stat, status and io files for fake processes below a
+// temporary proc root and points procRoot at it.
+func writeProcs(t *testing.T, root string, procs map[int]fakeProc) {
+	t.Helper()
+
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatalf("Failed to reset %s: %v", root, err)
+	}
+	for pid, p := range procs {
+		dir := filepath.Join(root, fmt.Sprint(pid))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		files := map[string]string{
+			"stat":   fmt.Sprintf("%d (%s) S %d 0 0 0 -1 0 0 0 0 0 %d %d 0 0 20 0 1 0 %d 0 0\n", pid, p.comm, p.ppid, p.utime, p.stime, p.start),
+			"status": fmt.Sprintf("Name:\t%s\nVmRSS:\t%d kB\nvoluntary_ctxt_switches:\t10\nnonvoluntary_ctxt_switches:\t2\n", p.comm, p.rssKB),
+			"io":     fmt.Sprintf("rchar: 100\nwchar: 100\nread_bytes: %d\nwrite_bytes: 4096\n", p.readBytes),
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	original := procRoot
+	procRoot = root
+	t.Cleanup(func() { procRoot = original })
+}
+
+// resetResourceTracking starts the test without pools or masters tracked by
+// earlier tests.
+func resetResourceTracking(t *testing.T) {
+	t.Helper()
+
+	tracker, masters := resourceTracker, masterPools
+	resourceTracker, masterPools = map[string]*poolCounters{}, map[procID]string{}
+	t.Cleanup(func() { resourceTracker, masterPools = tracker, masters })
+}
+
+func noPoolCgroup(t *testing.T) {
+	t.Helper()
+
+	original := cgroupProcessPath
+	cgroupProcessPath = func(int) (string, error) { return "/", nil }
+	t.Cleanup(func() { cgroupProcessPath = original })
+}
+
+func scanProcs(t *testing.T) *processTable {
+	t.Helper()
+
+	procs, err := scanProcessTable()
+	if err != nil {
+		t.Fatalf("Failed to scan processes: %v", err)
+	}
+	return procs
+}
+
+func TestCollectPoolResources_ProcessTree(t *testing.T) {
+	resetResourceTracking(t)
+	noPoolCgroup(t)
+	root := filepath.Join(t.TempDir(), "proc")
+
+	procs := map[int]fakeProc{
+		1:  {comm: "php-fpm8.3", ppid: 0, utime: 100, start: 1, rssKB: 1024},
+		10: {comm: "php-fpm8.3", ppid: 1, utime: 150, stime: 50, start: 10, rssKB: 2048, readBytes: 512},
+		11: {comm: "php-fpm8.3", ppid: 1, utime: 100, start: 11, rssKB: 2048},
+		12: {comm: "php-fpm8.3", ppid: 1, utime: 900, start: 12, rssKB: 4096}, // worker of another pool
+		20: {comm: "convert", ppid: 10, utime: 300, start: 20, rssKB: 8192},
+		30: {comm: "bash", ppid: 0, utime: 5000, start: 30, rssKB: 100},
+	}
+	writeProcs(t, root, procs)
+
+	key := "resources-tree"
+	pool := Pool{Name: "www", Processes: []PoolProcess{{PID: 10}, {PID: 11}}}
+
+	table := scanProcs(t)
+	res, err := collectPoolResources(key, pool, table)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res.Source != ResourceSourceProc || res.MasterPID != 1 || !res.MasterCounted {
+		t.Errorf("Expected proc source with master 1, got %+v", res)
+	}
+	if res.Processes != 4 {
+		t.Errorf("Expected master, two workers and a child, got %d processes", res.Processes)
+	}
+	// (100 + 200 + 100 + 300) ticks
+	if res.CPUSeconds != 7 {
+		t.Errorf("Expected 7 CPU seconds, got %v", res.CPUSeconds)
+	}
+	if res.RSSBytes != (1024+2048+2048+8192)*1024 {
+		t.Errorf("Unexpected RSS %d", res.RSSBytes)
+	}
+	if res.ReadBytes != 512 || res.WriteBytes != 4*4096 || res.VoluntaryCtxSwitches != 40 || res.InvoluntaryCtxSwitches != 8 {
+		t.Errorf("Unexpected I/O or context switch totals %+v", res)
+	}
+
+	// The other pool of the same master doesn't count it again
+	other, err := collectPoolResources("resources-tree-other", Pool{Name: "api", Processes: []PoolProcess{{PID: 12}}}, table)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if other.MasterPID != 1 || other.MasterCounted || other.Processes != 1 || other.CPUSeconds != 9 {
+		t.Errorf("Expected the other pool's worker without the master, got %+v", other)
+	}
+
+	// The master stays with its pool when the other pool is read first
+	other, err = collectPoolResources("resources-tree-other", Pool{Name: "api", Processes: []PoolProcess{{PID: 12}}}, scanProcs(t))
+	if err != nil || other.MasterCounted || other.CPUSeconds != 9 {
+		t.Errorf("Expected the master to stay with its first pool, got %+v, %v", other, err)
+	}
+
+	// Worker 11 is recycled and replaced by 13, its usage must not be lost
+	delete(procs, 11)
+	procs[13] = fakeProc{comm: "php-fpm8.3", ppid: 1, utime: 10, start: 40, rssKB: 2048}
+	writeProcs(t, root, procs)
+
+	res, err = collectPoolResources(key, Pool{Name: "www", Processes: []PoolProcess{{PID: 10}, {PID: 13}}}, scanProcs(t))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res.CPUSeconds != 7.1 {
+		t.Errorf("Expected CPU time of the recycled worker to be kept, got %v", res.CPUSeconds)
+	}
+}
+
+func TestCollectPoolResources_ForeignPIDs(t *testing.T) {
+	resetResourceTracking(t)
+	noPoolCgroup(t)
+	writeProcs(t, filepath.Join(t.TempDir(), "proc"), map[int]fakeProc{
+		10: {comm: "nginx", ppid: 1, utime: 100, start: 10},
+	})
+
+	// Status PIDs from another PID namespace must not match unrelated processes
+	if _, err := collectPoolResources("resources-foreign", Pool{Processes: []PoolProcess{{PID: 10}}}, scanProcs(t)); err == nil {
+		t.Errorf("Expected an error when no pool process is found")
+	}
+}
+
+func TestCollectPoolResources_Cgroup(t *testing.T) {
+	resetResourceTracking(t)
+	writeProcs(t, filepath.Join(t.TempDir(), "proc"), map[int]fakeProc{
+		1:  {comm: "php-fpm8.3", ppid: 0, utime: 100, start: 1, rssKB: 1024},
+		10: {comm: "php-fpm8.3", ppid: 1, utime: 100, start: 10, rssKB: 2048},
+	})
+
+	origPath, origProcs, origRead := cgroupProcessPath, cgroupProcs, cgroupReadPath
+	t.Cleanup(func() { cgroupProcessPath, cgroupProcs, cgroupReadPath = origPath, origProcs, origRead })
+
+	members := []int{1, 10}
+	cgroupProcessPath = func(int) (string, error) { return "/system.slice/php-fpm-www.service", nil }
+	cgroupProcs = func(string) ([]int, error) { return members, nil }
+	cgroupReadPath = func(path string) (*cgroup.Stats, error) {
+		return &cgroup.Stats{
+			CPU:    &cgroup.CPUStats{UsageSeconds: 42},
+			Memory: &cgroup.MemoryStats{Current: 1 << 20},
+			IO:     &cgroup.IOStats{ReadBytes: 10, WriteBytes: 20},
+		}, nil
+	}
+
+	pool := Pool{Processes: []PoolProcess{{PID: 10}}}
+	res, err := collectPoolResources("resources-cgroup", pool, scanProcs(t))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res.Source != ResourceSourceCgroup || res.Cgroup != "/system.slice/php-fpm-www.service" {
+		t.Fatalf("Expected the per-pool cgroup to be used, got %+v", res)
+	}
+	if res.CPUSeconds != 42 || res.RSSBytes != 1<<20 || res.ReadBytes != 10 || res.WriteBytes != 20 {
+		t.Errorf("Expected cgroup accounting, got %+v", res)
+	}
+	if res.VoluntaryCtxSwitches != 20 {
+		t.Errorf("Expected context switches from /proc, got %d", res.VoluntaryCtxSwitches)
+	}
+
+	// The master in the cgroup may be counted in another pool
+	if res, _ := collectPoolResources("resources-cgroup-master", pool, scanProcs(t)); res.Source != ResourceSourceCgroup || res.MasterCounted {
+		t.Errorf("Expected the per-pool cgroup without counting the master, got %+v", res)
+	}
+
+	// A cgroup shared with other processes does not describe the pool
+	members = []int{1, 10, 99}
+	if res, _ := collectPoolResources("resources-cgroup-shared", pool, scanProcs(t)); res.Source != ResourceSourceProc {
+		t.Errorf("Expected /proc accounting for a shared cgroup, got %+v", res)
+	}
+
+	// The source chosen on the first collection is kept
+	members = []int{1, 10}
+	if res, _ := collectPoolResources("resources-cgroup-shared", pool, scanProcs(t)); res.Source != ResourceSourceProc {
+		t.Errorf("Expected the pool to stay on /proc accounting, got %+v", res)
+	}
+	cgroupReadPath = func(string) (*cgroup.Stats, error) { return nil, errors.New("unreadable") }
+	if res, err := collectPoolResources("resources-cgroup", pool, scanProcs(t)); err == nil {
+		t.Errorf("Expected an error when the pool's cgroup is unreadable, got %+v", res)
+	}
+
+	if res, _ := collectPoolResources("resources-cgroup-unreadable", pool, scanProcs(t)); res.Source != ResourceSourceProc {
+		t.Errorf("Expected /proc accounting when the cgroup is unreadable, got %+v", res)
+	}
+}
+
+func TestScanProcessTable_ForgetsExitedMasters(t *testing.T) {
+	resetResourceTracking(t)
+	root := filepath.Join(t.TempDir(), "proc")
+	writeProcs(t, root, map[int]fakeProc{1: {comm: "php-fpm8.3", start: 1}})
+
+	masterPools[procID{1, 1}] = "www"
+	masterPools[procID{2, 5}] = "api"
+	scanProcs(t)
+	if _, ok := masterPools[procID{2, 5}]; ok || masterPools[procID{1, 1}] != "www" {
+		t.Errorf("Expected only the running master to stay claimed, got %v", masterPools)
+	}
+}
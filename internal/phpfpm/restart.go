@@ -0,0 +1,190 @@
+package phpfpm
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+)
+
+const (
+	RestartReasonConfigChange = "config_change"
+	RestartReasonReload       = "reload"
+	RestartReasonRestart      = "restart"
+	RestartReasonCrash        = "crash"
+	RestartReasonUnknown      = "unknown"
+)
+
+// restartStartJitter absorbs the rounding of start times derived from
+// "start since" when the status output has no absolute start time.
+const restartStartJitter = 2 * time.Second
+
+// errorLogTailSize bounds how much of the FPM error log is read to infer why a
+// pool restarted.
+const errorLogTailSize = 256 * 1024
+
+var (
+	restartStats     = make(map[string]*RestartStats)
+	restartStatsLock sync.Mutex
+)
+
+type RestartStats struct {
+	StartTime   time.Time `json:"start_time"`
+	Restarts    uint64    `json:"restarts"`
+	LastRestart time.Time `json:"last_restart,omitempty"`
+	LastReason  string    `json:"last_reason,omitempty"`
+	// accepted is the last accepted conn counter, a drop means it was reset.
+	accepted int64
+}
+
+// trackRestart compares the pool's start time and counters with the previous
+// collection and records a restart when the FPM master started again or the
+// counters were reset. errorLog is FPM's global error_log, used with the config
+// file's modification time to infer the reason.
+func trackRestart(poolCfg config.FPMPoolConfig, pool Pool, errorLog string, now time.Time) *RestartStats {
+	start := poolStartTime(pool, now)
+	key := PoolKey(poolCfg)
+
+	restartStatsLock.Lock()
+	defer restartStatsLock.Unlock()
+
+	stats, ok := restartStats[key]
+	if !ok {
+		stats = &RestartStats{StartTime: start, accepted: pool.AcceptedConnections}
+		restartStats[key] = stats
+		out := *stats
+		return &out
+	}
+
+	previous := stats.StartTime
+	restarted := start.Sub(previous) > restartStartJitter || pool.AcceptedConnections < stats.accepted
+	stats.accepted = pool.AcceptedConnections
+
+	if restarted {
+		stats.Restarts++
+		stats.LastRestart = start
+		stats.LastReason = inferRestartReason(poolCfg.ConfigPath, errorLog, previous)
+		stats.StartTime = start
+
+		logging.L().Info("ElasticPHP-agent pool restarted",
+			"pool", pool.Name,
+			"socket", poolCfg.Socket,
+			"reason", stats.LastReason,
+			"previous_start", previous,
+			"start", start,
+			"restarts", stats.Restarts,
+		)
+	}
+
+	out := *stats
+	return &out
+}
+
+func poolStartTime(pool Pool, now time.Time) time.Time {
+	if pool.StartTime > 0 {
+		return time.Unix(pool.StartTime, 0)
+	}
+	return now.Add(-time.Duration(pool.StartSince) * time.Second).Truncate(time.Second)
+}
+
+// inferRestartReason looks at what happened since the previous start. A master
+// that came up without logging a shutdown crashed, a config file edited after
+// the previous start points at a config change, and otherwise the error log
+// tells a reload (SIGUSR2) apart from a stop and start.
+func inferRestartReason(configPath, errorLog string, since time.Time) string {
+	logReason := errorLogRestartReason(errorLog, since)
+	if logReason == RestartReasonCrash {
+		return RestartReasonCrash
+	}
+
+	if configPath != "" {
+		if info, err := os.Stat(configPath); err == nil && info.ModTime().After(since) {
+			return RestartReasonConfigChange
+		}
+	}
+
+	if logReason != "" {
+		return logReason
+	}
+	return RestartReasonUnknown
+}
+
+// errorLogRestartReason scans the tail of the FPM error log for master
+// lifecycle messages logged after since. It returns an empty string when the
+// log is unreadable or has nothing to say.
+func errorLogRestartReason(errorLog string, since time.Time) string {
+	if errorLog == "" || !strings.HasPrefix(errorLog, "/") {
+		return ""
+	}
+
+	f, err := os.Open(errorLog)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > errorLogTailSize {
+		if _, err := f.Seek(-errorLogTailSize, io.SeekEnd); err != nil {
+			return ""
+		}
+	}
+
+	var reloaded, stopped, started bool
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		ts, ok := parseErrorLogTime(line)
+		if !ok || ts.Before(since.Add(-restartStartJitter)) {
+			continue
+		}
+
+		switch {
+		case strings.Contains(line, "Reloading in progress"):
+			reloaded = true
+		case strings.Contains(line, "exiting, bye-bye"), strings.Contains(line, "Terminating"):
+			stopped = true
+		case strings.Contains(line, "ready to handle connections"):
+			started = true
+		}
+	}
+
+	switch {
+	case reloaded:
+		return RestartReasonReload
+	case stopped:
+		return RestartReasonRestart
+	case started:
+		return RestartReasonCrash
+	default:
+		return ""
+	}
+}
+
+// parseErrorLogTime reads the "[19-Oct-2026 10:00:00]" prefix of an FPM error
+// log line, with or without microseconds.
+func parseErrorLogTime(line string) (time.Time, bool) {
+	if !strings.HasPrefix(line, "[") {
+		return time.Time{}, false
+	}
+	end := strings.Index(line, "]")
+	if end == -1 {
+		return time.Time{}, false
+	}
+
+	value := line[1:end]
+	if dot := strings.Index(value, "."); dot != -1 {
+		value = value[:dot]
+	}
+
+	ts, err := time.ParseInLocation("02-Jan-2006 15:04:05", value, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
@@ -0,0 +1,173 @@
+package phpfpm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/logging"
+)
+
+func TestTrackRestart(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	poolCfg := config.FPMPoolConfig{Socket: "unix:///run/php/track-restart.sock"}
+	now := time.Unix(1700001000, 0)
+
+	stats := trackRestart(poolCfg, Pool{Name: "www", StartTime: 1700000000, AcceptedConnections: 100}, "", now)
+	if stats.Restarts != 0 || !stats.LastRestart.IsZero() {
+		t.Fatalf("Expected no restart on first observation, got %+v", stats)
+	}
+
+	stats = trackRestart(poolCfg, Pool{Name: "www", StartTime: 1700000000, AcceptedConnections: 150}, "", now.Add(10*time.Second))
+	if stats.Restarts != 0 {
+		t.Fatalf("Expected no restart for an unchanged start time, got %+v", stats)
+	}
+
+	stats = trackRestart(poolCfg, Pool{Name: "www", StartTime: 1700001005, AcceptedConnections: 3}, "", now.Add(20*time.Second))
+	if stats.Restarts != 1 {
+		t.Fatalf("Expected 1 restart, got %+v", stats)
+	}
+	if stats.LastRestart.Unix() != 1700001005 {
+		t.Errorf("Expected last restart at the new start time, got %v", stats.LastRestart)
+	}
+	if stats.LastReason != RestartReasonUnknown {
+		t.Errorf("Expected unknown reason without config or log, got %q", stats.LastReason)
+	}
+
+	// A counter reset without a new start time still counts as a restart
+	stats = trackRestart(poolCfg, Pool{Name: "www", StartTime: 1700001005, AcceptedConnections: 1}, "", now.Add(30*time.Second))
+	if stats.Restarts != 2 {
+		t.Errorf("Expected a counter reset to count as a restart, got %+v", stats)
+	}
+
+	// Other pools on the same socket are tracked separately
+	api := config.FPMPoolConfig{Name: "api", Socket: poolCfg.Socket}
+	stats = trackRestart(api, Pool{Name: "api", StartTime: 1700001005}, "", now.Add(30*time.Second))
+	if stats.Restarts != 0 {
+		t.Errorf("Expected pools to be tracked separately, got %+v", stats)
+	}
+}
+
+func TestTrackRestart_StartSince(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	poolCfg := config.FPMPoolConfig{Socket: "tcp://track-restart-since"}
+	now := time.Unix(1700000000, 0)
+
+	trackRestart(poolCfg, Pool{Name: "www", StartSince: 100}, "", now)
+
+	// Jitter in start times derived from "start since" is not a restart
+	stats := trackRestart(poolCfg, Pool{Name: "www", StartSince: 111}, "", now.Add(10*time.Second))
+	if stats.Restarts != 0 {
+		t.Errorf("Expected no restart within the jitter, got %+v", stats)
+	}
+
+	stats = trackRestart(poolCfg, Pool{Name: "www", StartSince: 5}, "", now.Add(20*time.Second))
+	if stats.Restarts != 1 {
+		t.Errorf("Expected a restart when start since drops, got %+v", stats)
+	}
+}
+
+func writeErrorLog(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "php-fpm.log")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write error log: %v", err)
+	}
+	return path
+}
+
+func TestInferRestartReason(t *testing.T) {
+	since := time.Date(2026, 10, 19, 10, 0, 0, 0, time.Local)
+	before := "[19-Oct-2026 09:00:00] NOTICE: Reloading in progress ..."
+
+	reloadLog := writeErrorLog(t,
+		before,
+		"[19-Oct-2026 10:05:00] NOTICE: Reloading in progress ...",
+		"[19-Oct-2026 10:05:00] NOTICE: using inherited socket fd=7, \"/run/php/www.sock\"",
+		"[19-Oct-2026 10:05:00] NOTICE: ready to handle connections",
+	)
+	restartLog := writeErrorLog(t,
+		"[19-Oct-2026 10:05:00.123456] NOTICE: Terminating ...",
+		"[19-Oct-2026 10:05:00.234567] NOTICE: exiting, bye-bye!",
+		"[19-Oct-2026 10:05:01.000000] NOTICE: fpm is running, pid 42",
+		"[19-Oct-2026 10:05:01.000100] NOTICE: ready to handle connections",
+	)
+	crashLog := writeErrorLog(t,
+		before,
+		"[19-Oct-2026 10:05:01] NOTICE: fpm is running, pid 42",
+		"[19-Oct-2026 10:05:01] NOTICE: ready to handle connections",
+	)
+	quietLog := writeErrorLog(t, before)
+
+	configPath := filepath.Join(t.TempDir(), "php-fpm.conf")
+	if err := os.WriteFile(configPath, []byte("[global]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.Chtimes(configPath, since.Add(time.Minute), since.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to set config mtime: %v", err)
+	}
+	oldConfigPath := filepath.Join(t.TempDir(), "old-php-fpm.conf")
+	if err := os.WriteFile(oldConfigPath, []byte("[global]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.Chtimes(oldConfigPath, since.Add(-time.Hour), since.Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to set config mtime: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		configPath string
+		errorLog   string
+		expected   string
+	}{
+		{"reload signal", oldConfigPath, reloadLog, RestartReasonReload},
+		{"stop and start", oldConfigPath, restartLog, RestartReasonRestart},
+		{"crash", oldConfigPath, crashLog, RestartReasonCrash},
+		{"crash wins over config change", configPath, crashLog, RestartReasonCrash},
+		{"config change with reload", configPath, reloadLog, RestartReasonConfigChange},
+		{"config change without log", configPath, "", RestartReasonConfigChange},
+		{"nothing after previous start", oldConfigPath, quietLog, RestartReasonUnknown},
+		{"relative error log", "", "log/php-fpm.log", RestartReasonUnknown},
+		{"missing error log", "", "/nonexistent/php-fpm.log", RestartReasonUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inferRestartReason(tt.configPath, tt.errorLog, since); got != tt.expected {
+				t.Errorf("Expected reason %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseErrorLogTime(t *testing.T) {
+	expected := time.Date(2026, 10, 19, 10, 5, 0, 0, time.Local)
+
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{"[19-Oct-2026 10:05:00] NOTICE: ready to handle connections", true},
+		{"[19-Oct-2026 10:05:00.123456] NOTICE: ready to handle connections", true},
+		{"NOTICE: ready to handle connections", false},
+		{"[pool www] child 42 started", false},
+		{"[19-Oct-2026 10:05:00", false},
+	}
+
+	for _, tt := range tests {
+		ts, ok := parseErrorLogTime(tt.line)
+		if ok != tt.ok {
+			t.Errorf("parseErrorLogTime(%q) ok = %v, expected %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && !ts.Equal(expected) {
+			t.Errorf("parseErrorLogTime(%q) = %v, expected %v", tt.line, ts, expected)
+		}
+	}
+}
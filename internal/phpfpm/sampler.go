@@ -0,0 +1,317 @@
+package phpfpm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+const (
+	// samplerBucketSize is the resolution of the sliding windows.
+	samplerBucketSize = 10 * time.Second
+	// samplerMaxGap caps the time credited to a sample after missed polls, so a
+	// stalled collector does not attribute minutes to whatever ran last.
+	samplerMaxGap = 10 * time.Second
+	// samplerMaxKeys bounds the endpoints tracked per bucket, the rest are
+	// accounted as "other".
+	samplerMaxKeys = 1000
+
+	defaultSamplerTopN = 10
+)
+
+var defaultSamplerWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+var (
+	uuidSegment    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	numericSegment = regexp.MustCompile(`^\d+$`)
+	hashSegment    = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+var (
+	sampler     *Sampler
+	samplerLock sync.RWMutex
+)
+
+type normalizeRule struct {
+	match   *regexp.Regexp
+	replace string
+}
+
+// EndpointKey identifies a normalised endpoint.
+type EndpointKey struct {
+	Method string `json:"method"`
+	URI    string `json:"uri"`
+	Script string `json:"script"`
+}
+
+type EndpointUsage struct {
+	EndpointKey
+	WorkerSeconds float64 `json:"worker_seconds"`
+	Share         float64 `json:"share"`
+}
+
+// EndpointWindow ranks endpoints by the worker time they used within Window.
+type EndpointWindow struct {
+	Window      string          `json:"window"`
+	Duration    time.Duration   `json:"-"`
+	BusySeconds float64         `json:"busy_worker_seconds"`
+	Top         []EndpointUsage `json:"top"`
+}
+
+// Sampler accumulates worker-seconds per normalised endpoint from successive
+// snapshots of the full status, turning status polls into a low-overhead
+// profile of where pool capacity goes.
+type Sampler struct {
+	mu      sync.Mutex
+	rules   []normalizeRule
+	windows []time.Duration
+	topN    int
+	pools   map[string]*sampledPool
+}
+
+type sampledPool struct {
+	last    time.Time
+	buckets []*sampleBucket
+}
+
+type sampleBucket struct {
+	start     time.Time
+	busy      float64
+	endpoints map[EndpointKey]float64
+}
+
+func NewSampler(cfg config.SamplerConfig) (*Sampler, error) {
+	s := &Sampler{
+		windows: cfg.Windows,
+		topN:    cfg.TopN,
+		pools:   make(map[string]*sampledPool),
+	}
+	if len(s.windows) == 0 {
+		s.windows = defaultSamplerWindows
+	}
+	if s.topN <= 0 {
+		s.topN = defaultSamplerTopN
+	}
+
+	for _, rule := range cfg.Rules {
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sampler rule %q: %w", rule.Match, err)
+		}
+		s.rules = append(s.rules, normalizeRule{match: re, replace: rule.Replace})
+	}
+
+	return s, nil
+}
+
+// ConfigureSampler installs the sampler fed by ObserveSample and read by
+// GetHotEndpoints. A disabled config removes it.
+func ConfigureSampler(cfg config.SamplerConfig) error {
+	var s *Sampler
+	if cfg.Enabled {
+		var err error
+		if s, err = NewSampler(cfg); err != nil {
+			return err
+		}
+	}
+
+	samplerLock.Lock()
+	sampler = s
+	samplerLock.Unlock()
+	return nil
+}
+
+// ObserveSample feeds a pool snapshot into the configured sampler.
+func ObserveSample(poolCfg config.FPMPoolConfig, result *Result) {
+	samplerLock.RLock()
+	s := sampler
+	samplerLock.RUnlock()
+
+	if s == nil || result == nil {
+		return
+	}
+	for _, pool := range result.Pools {
+		s.Observe(PoolKey(poolCfg)+"|"+pool.Name, pool, poolCfg.StatusPath, result.Timestamp)
+	}
+}
+
+// GetHotEndpoints returns the top endpoints per window for a pool, or nil when
+// sampling is disabled.
+func GetHotEndpoints(poolCfg config.FPMPoolConfig, poolName string) []EndpointWindow {
+	samplerLock.RLock()
+	s := sampler
+	samplerLock.RUnlock()
+
+	if s == nil {
+		return nil
+	}
+	return s.Top(PoolKey(poolCfg)+"|"+poolName, time.Now())
+}
+
+// Observe credits the time since the pool's previous snapshot to every busy
+// worker's endpoint.
+func (s *Sampler) Observe(key string, pool Pool, statusPath string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp, ok := s.pools[key]
+	if !ok {
+		sp = &sampledPool{}
+		s.pools[key] = sp
+	}
+
+	previous := sp.last
+	sp.last = now
+	if previous.IsZero() || !now.After(previous) {
+		return
+	}
+
+	elapsed := now.Sub(previous)
+	if elapsed > samplerMaxGap {
+		elapsed = samplerMaxGap
+	}
+
+	bucket := sp.bucket(now)
+	for _, proc := range pool.Processes {
+		if strings.EqualFold(proc.State, "idle") || isAgentRequest(proc.RequestURI, statusPath) {
+			continue
+		}
+
+		endpoint := EndpointKey{
+			Method: proc.RequestMethod,
+			URI:    s.normalize(proc.RequestURI),
+			Script: proc.Script,
+		}
+		if _, ok := bucket.endpoints[endpoint]; !ok && len(bucket.endpoints) >= samplerMaxKeys {
+			endpoint = EndpointKey{URI: "other"}
+		}
+
+		bucket.busy += elapsed.Seconds()
+		bucket.endpoints[endpoint] += elapsed.Seconds()
+	}
+
+	sp.prune(now.Add(-s.longestWindow()))
+}
+
+// Top ranks endpoints within each configured window.
+func (s *Sampler) Top(key string, now time.Time) []EndpointWindow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp, ok := s.pools[key]
+	if !ok {
+		return nil
+	}
+
+	windows := make([]EndpointWindow, 0, len(s.windows))
+	for _, window := range s.windows {
+		ew := EndpointWindow{Window: formatWindow(window), Duration: window}
+		totals := map[EndpointKey]float64{}
+
+		since := now.Add(-window)
+		for _, b := range sp.buckets {
+			if b.start.Add(samplerBucketSize).Before(since) {
+				continue
+			}
+			ew.BusySeconds += b.busy
+			for endpoint, seconds := range b.endpoints {
+				totals[endpoint] += seconds
+			}
+		}
+
+		for endpoint, seconds := range totals {
+			usage := EndpointUsage{EndpointKey: endpoint, WorkerSeconds: seconds}
+			if ew.BusySeconds > 0 {
+				usage.Share = seconds / ew.BusySeconds
+			}
+			ew.Top = append(ew.Top, usage)
+		}
+		sort.Slice(ew.Top, func(i, j int) bool {
+			if ew.Top[i].WorkerSeconds != ew.Top[j].WorkerSeconds {
+				return ew.Top[i].WorkerSeconds > ew.Top[j].WorkerSeconds
+			}
+			return ew.Top[i].URI < ew.Top[j].URI
+		})
+		if len(ew.Top) > s.topN {
+			ew.Top = ew.Top[:s.topN]
+		}
+
+		windows = append(windows, ew)
+	}
+
+	return windows
+}
+
+func (s *Sampler) normalize(uri string) string {
+	if i := strings.IndexByte(uri, '?'); i != -1 {
+		uri = uri[:i]
+	}
+
+	// Collapse the identifiers that make most URIs unique
+	segments := strings.Split(uri, "/")
+	for i, segment := range segments {
+		switch {
+		case uuidSegment.MatchString(segment):
+			segments[i] = ":uuid"
+		case numericSegment.MatchString(segment):
+			segments[i] = ":id"
+		case hashSegment.MatchString(segment):
+			segments[i] = ":hash"
+		}
+	}
+	uri = strings.Join(segments, "/")
+
+	for _, rule := range s.rules {
+		uri = rule.match.ReplaceAllString(uri, rule.replace)
+	}
+	if uri == "" {
+		return "/"
+	}
+	return uri
+}
+
+func (s *Sampler) longestWindow() time.Duration {
+	longest := time.Duration(0)
+	for _, w := range s.windows {
+		if w > longest {
+			longest = w
+		}
+	}
+	return longest
+}
+
+func (sp *sampledPool) bucket(now time.Time) *sampleBucket {
+	start := now.Truncate(samplerBucketSize)
+	if n := len(sp.buckets); n > 0 && sp.buckets[n-1].start.Equal(start) {
+		return sp.buckets[n-1]
+	}
+
+	b := &sampleBucket{start: start, endpoints: make(map[EndpointKey]float64)}
+	sp.buckets = append(sp.buckets, b)
+	return b
+}
+
+func (sp *sampledPool) prune(before time.Time) {
+	i := 0
+	for i < len(sp.buckets) && sp.buckets[i].start.Add(samplerBucketSize).Before(before) {
+		i++
+	}
+	sp.buckets = sp.buckets[i:]
+}
+
+// formatWindow renders a window as a short label value such as "5m" or "1h".
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
@@ -0,0 +1,185 @@
+package phpfpm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+func samplerSnapshot(procs ...PoolProcess) Pool {
+	return Pool{Name: "www", Processes: procs}
+}
+
+func TestSampler_Normalize(t *testing.T) {
+	s, err := NewSampler(config.SamplerConfig{
+		Rules: []config.NormalizeRule{
+			{Match: `^/api/v\d+/`, Replace: "/api/:version/"},
+			{Match: `^/(en|de|fr)/`, Replace: "/:locale/"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		"":                   "/",
+		"/":                  "/",
+		"/index.php?page=2":  "/index.php",
+		"/users/42":          "/users/:id",
+		"/users/42/orders/7": "/users/:id/orders/:id",
+		"/files/550e8400-e29b-41d4-a716-446655440000/download": "/files/:uuid/download",
+		"/assets/d41d8cd98f00b204e9800998ecf8427e.js":          "/assets/d41d8cd98f00b204e9800998ecf8427e.js",
+		"/cache/d41d8cd98f00b204e9800998ecf8427e":              "/cache/:hash",
+		"/api/v2/products/12":                                  "/api/:version/products/:id",
+		"/de/checkout":                                         "/:locale/checkout",
+	}
+
+	for input, expected := range tests {
+		if got := s.normalize(input); got != expected {
+			t.Errorf("normalize(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestNewSampler_InvalidRule(t *testing.T) {
+	if _, err := NewSampler(config.SamplerConfig{Rules: []config.NormalizeRule{{Match: "("}}}); err == nil {
+		t.Errorf("Expected error for an invalid rule")
+	}
+}
+
+func TestSampler_Top(t *testing.T) {
+	s, err := NewSampler(config.SamplerConfig{Windows: []time.Duration{time.Minute, 5 * time.Minute}, TopN: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	key := "unix:///run/php/www.sock|www"
+
+	// Four minutes of a slow report endpoint, then a minute dominated by checkout
+	for i := 0; i <= 240; i++ {
+		s.Observe(key, samplerSnapshot(
+			PoolProcess{PID: 1, State: "Running", RequestMethod: "GET", RequestURI: "/reports/1?format=csv", Script: "/var/www/index.php"},
+			PoolProcess{PID: 2, State: "Idle", RequestURI: "/ignored"},
+			PoolProcess{PID: 3, State: "Running", RequestURI: "/status?json&full"},
+		), "/status", start.Add(time.Duration(i)*time.Second))
+	}
+	for i := 241; i <= 300; i++ {
+		s.Observe(key, samplerSnapshot(
+			PoolProcess{PID: 1, State: "Running", RequestMethod: "POST", RequestURI: "/checkout", Script: "/var/www/index.php"},
+			PoolProcess{PID: 2, State: "Running", RequestMethod: "POST", RequestURI: "/checkout", Script: "/var/www/index.php"},
+			PoolProcess{PID: 3, State: "Reading headers", RequestMethod: "GET", RequestURI: "/health"},
+		), "/status", start.Add(time.Duration(i)*time.Second))
+	}
+
+	windows := s.Top(key, start.Add(300*time.Second))
+	if len(windows) != 2 {
+		t.Fatalf("Expected 2 windows, got %d", len(windows))
+	}
+
+	oneMinute := windows[0]
+	if oneMinute.Window != "1m" {
+		t.Errorf("Expected window label '1m', got %q", oneMinute.Window)
+	}
+	if len(oneMinute.Top) != 2 {
+		t.Fatalf("Expected top 2 endpoints, got %+v", oneMinute.Top)
+	}
+	if oneMinute.Top[0].URI != "/checkout" || oneMinute.Top[0].Method != "POST" {
+		t.Errorf("Expected checkout to lead the last minute, got %+v", oneMinute.Top[0])
+	}
+	if oneMinute.Top[0].Share < 0.6 {
+		t.Errorf("Expected checkout to use most of the busy time, got share %v", oneMinute.Top[0].Share)
+	}
+
+	fiveMinutes := windows[1]
+	if fiveMinutes.Top[0].URI != "/reports/:id" {
+		t.Errorf("Expected reports to lead the five minute window, got %+v", fiveMinutes.Top[0])
+	}
+	if fiveMinutes.BusySeconds != 240+3*60 {
+		t.Errorf("Expected 420 busy worker-seconds, got %v", fiveMinutes.BusySeconds)
+	}
+	for _, usage := range fiveMinutes.Top {
+		if usage.URI == "/status" || usage.URI == "/ignored" {
+			t.Errorf("Expected agent and idle requests to be excluded, got %+v", usage)
+		}
+	}
+
+	if s.Top("unknown", start) != nil {
+		t.Errorf("Expected no windows for an unknown pool")
+	}
+}
+
+func TestSampler_GapsAndPruning(t *testing.T) {
+	s, err := NewSampler(config.SamplerConfig{Windows: []time.Duration{time.Minute}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	busy := samplerSnapshot(PoolProcess{PID: 1, State: "Running", RequestURI: "/slow"})
+
+	s.Observe("pool", busy, "", start)
+	s.Observe("pool", busy, "", start.Add(time.Hour))
+
+	windows := s.Top("pool", start.Add(time.Hour))
+	if windows[0].BusySeconds != samplerMaxGap.Seconds() {
+		t.Errorf("Expected a long gap to be capped at %v, got %v", samplerMaxGap, windows[0].BusySeconds)
+	}
+
+	s.Observe("pool", busy, "", start.Add(2*time.Hour))
+	if n := len(s.pools["pool"].buckets); n != 1 {
+		t.Errorf("Expected buckets outside the longest window to be pruned, got %d", n)
+	}
+}
+
+func TestConfigureSampler(t *testing.T) {
+	defer ConfigureSampler(config.SamplerConfig{})
+
+	poolCfg := config.FPMPoolConfig{Socket: "unix:///run/php/configure-sampler.sock", StatusPath: "/status"}
+	busy := &Result{Pools: map[string]Pool{"www": samplerSnapshot(PoolProcess{PID: 1, State: "Running", RequestURI: "/"})}}
+
+	if err := ConfigureSampler(config.SamplerConfig{Enabled: true, Rules: []config.NormalizeRule{{Match: "["}}}); err == nil {
+		t.Errorf("Expected error for an invalid rule")
+	}
+
+	if err := ConfigureSampler(config.SamplerConfig{Enabled: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	busy.Timestamp = time.Now().Add(-time.Second)
+	ObserveSample(poolCfg, busy)
+	busy.Timestamp = time.Now()
+	ObserveSample(poolCfg, busy)
+
+	windows := GetHotEndpoints(poolCfg, "www")
+	if len(windows) != len(defaultSamplerWindows) {
+		t.Fatalf("Expected the default windows, got %+v", windows)
+	}
+	if len(windows[0].Top) != 1 || windows[0].Top[0].URI != "/" {
+		t.Errorf("Expected the sampled endpoint, got %+v", windows[0].Top)
+	}
+
+	if err := ConfigureSampler(config.SamplerConfig{Enabled: false}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if GetHotEndpoints(poolCfg, "www") != nil {
+		t.Errorf("Expected no hot endpoints when sampling is disabled")
+	}
+}
+
+func TestFormatWindow(t *testing.T) {
+	tests := map[time.Duration]string{
+		30 * time.Second: "30s",
+		time.Minute:      "1m",
+		15 * time.Minute: "15m",
+		time.Hour:        "1h",
+		90 * time.Second: "90s",
+	}
+
+	for input, expected := range tests {
+		if got := formatWindow(input); got != expected {
+			t.Errorf("formatWindow(%v) = %q, expected %q", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package redact scrubs sensitive data such as signed URL tokens, emails and
+// identifiers from request URIs and other request-derived strings before the
+// agent exposes them.
+package redact
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+const (
+	ScrubberEmail     = "email"
+	ScrubberUUID      = "uuid"
+	ScrubberNumericID = "numeric_id"
+	ScrubberToken     = "token"
+)
+
+var (
+	// EmailPattern and UUIDPattern are shared with other scrubbers, such as
+	// the normalisation of exception messages, so they match the same values.
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	UUIDPattern  = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+	numericIDPattern = regexp.MustCompile(`^\d+$`)
+	tokenPattern     = regexp.MustCompile(`[A-Za-z0-9_\-]{32,}`)
+
+	shared     = mustNew(Default())
+	sharedLock sync.RWMutex
+)
+
+// Default returns the redaction config used when none is configured: query
+// strings are dropped and all built-in scrubbers are enabled.
+func Default() config.RedactionConfig {
+	return config.RedactionConfig{
+		Enabled:   true,
+		DropQuery: true,
+		Scrubbers: []string{ScrubberEmail, ScrubberUUID, ScrubberNumericID, ScrubberToken},
+	}
+}
+
+type pattern struct {
+	match   *regexp.Regexp
+	replace string
+	// accept optionally filters matches, e.g. to leave long word-only slugs alone
+	accept func(string) bool
+}
+
+type Redactor struct {
+	enabled   bool
+	dropQuery bool
+	allowKeys map[string]bool
+	// patterns apply anywhere in a string, numericIDs only to whole path segments.
+	patterns   []pattern
+	numericIDs bool
+}
+
+func New(cfg config.RedactionConfig) (*Redactor, error) {
+	r := &Redactor{
+		enabled:   cfg.Enabled,
+		dropQuery: cfg.DropQuery,
+		allowKeys: make(map[string]bool, len(cfg.AllowQueryKeys)),
+	}
+	for _, key := range cfg.AllowQueryKeys {
+		r.allowKeys[key] = true
+	}
+
+	for _, name := range cfg.Scrubbers {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case ScrubberEmail:
+			r.patterns = append(r.patterns, pattern{match: EmailPattern, replace: ":email"})
+		case ScrubberUUID:
+			r.patterns = append(r.patterns, pattern{match: UUIDPattern, replace: ":uuid"})
+		case ScrubberToken:
+			r.patterns = append(r.patterns, pattern{match: tokenPattern, replace: ":token", accept: containsDigit})
+		case ScrubberNumericID:
+			r.numericIDs = true
+		default:
+			return nil, fmt.Errorf("unknown redaction scrubber %q", name)
+		}
+	}
+
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p.Match, err)
+		}
+		r.patterns = append(r.patterns, pattern{match: re, replace: p.Replace})
+	}
+
+	return r, nil
+}
+
+func mustNew(cfg config.RedactionConfig) *Redactor {
+	r, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Configure replaces the redactor every collector applies to request data
+// and log messages. Until it is called the safe defaults apply.
+func Configure(cfg config.RedactionConfig) error {
+	r, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	sharedLock.Lock()
+	shared = r
+	sharedLock.Unlock()
+	return nil
+}
+
+// Shared returns the redactor set by Configure.
+func Shared() *Redactor {
+	sharedLock.RLock()
+	defer sharedLock.RUnlock()
+	return shared
+}
+
+// URI redacts a request URI. The fragment is always removed, query parameters
+// outside the allow-list are dropped when configured, and the scrubbers are
+// applied to the path and the remaining query values.
+func (r *Redactor) URI(uri string) string {
+	if r == nil || !r.enabled || uri == "" {
+		return uri
+	}
+
+	if i := strings.IndexByte(uri, '#'); i != -1 {
+		uri = uri[:i]
+	}
+
+	path, rawQuery, hasQuery := strings.Cut(uri, "?")
+	path = r.path(path)
+	if !hasQuery {
+		return path
+	}
+
+	query := r.query(rawQuery)
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+// Text applies the scrubbers to free-form text such as log lines.
+func (r *Redactor) Text(s string) string {
+	if r == nil || !r.enabled {
+		return s
+	}
+	for _, p := range r.patterns {
+		if p.accept == nil {
+			s = p.match.ReplaceAllString(s, p.replace)
+			continue
+		}
+		s = p.match.ReplaceAllStringFunc(s, func(m string) string {
+			if p.accept(m) {
+				return p.replace
+			}
+			return m
+		})
+	}
+	return s
+}
+
+func (r *Redactor) path(path string) string {
+	// Scrub what a reader would see, e.g. "%40" in an email address
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+
+	if r.numericIDs {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if numericIDPattern.MatchString(segment) {
+				segments[i] = ":id"
+			}
+		}
+		path = strings.Join(segments, "/")
+	}
+	return r.Text(path)
+}
+
+func (r *Redactor) query(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Unparseable queries cannot be filtered by key
+		if r.dropQuery {
+			return ""
+		}
+		return r.Text(rawQuery)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if r.dropQuery && !r.allowKeys[key] {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range values[key] {
+			if value == "" {
+				parts = append(parts, url.QueryEscape(key))
+				continue
+			}
+			parts = append(parts, url.QueryEscape(key)+"="+queryEscape(r.Text(value)))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// queryEscape escapes a query value but keeps the ":" of placeholders readable.
+func queryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "%3A", ":")
+}
+
+func containsDigit(s string) bool {
+	return strings.ContainsAny(s, "0123456789")
+}
@@ -0,0 +1,128 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/elasticphphq/agent/internal/config"
+)
+
+func TestRedactor_URI_Defaults(t *testing.T) {
+	r, err := New(Default())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		"":                               "",
+		"/":                              "/",
+		"/index.php":                     "/index.php",
+		"/reset?token=abc&email=a@b.com": "/reset",
+		"/users/42/orders/7":             "/users/:id/orders/:id",
+		"/v2/items":                      "/v2/items",
+		"/files/550e8400-e29b-41d4-a716-446655440000":             "/files/:uuid",
+		"/unsubscribe/jane.doe%40example.com":                     "/unsubscribe/:email",
+		"/download/4f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a/file": "/download/:token/file",
+		"/blog/a-very-long-article-slug-without-any-numbers":      "/blog/a-very-long-article-slug-without-any-numbers",
+		"/page#section": "/page",
+	}
+
+	for input, expected := range tests {
+		if got := r.URI(input); got != expected {
+			t.Errorf("URI(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestRedactor_URI_AllowQueryKeys(t *testing.T) {
+	cfg := Default()
+	cfg.AllowQueryKeys = []string{"page", "ref", "json"}
+
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		"/search?q=secret&page=2":                          "/search?page=2",
+		"/signup?ref=jane@example.com&sig=abc":             "/signup?ref=:email",
+		"/status?json&full":                                "/status?json",
+		"/search?page=2&page=3":                            "/search?page=2&page=3",
+		"/search?sig=abc":                                  "/search",
+		"/search?ref=550e8400-e29b-41d4-a716-446655440000": "/search?ref=:uuid",
+	}
+
+	for input, expected := range tests {
+		if got := r.URI(input); got != expected {
+			t.Errorf("URI(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestRedactor_URI_KeepQuery(t *testing.T) {
+	r, err := New(config.RedactionConfig{
+		Enabled:   true,
+		DropQuery: false,
+		Scrubbers: []string{ScrubberEmail},
+		Patterns:  []config.RedactPattern{{Match: `sig=[^&]+`, Replace: "sig=:sig"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := r.URI("/invite/12?to=jane@example.com&b=1"); got != "/invite/12?b=1&to=:email" {
+		t.Errorf("Unexpected redacted URI: %q", got)
+	}
+	if got := r.Text("GET /file?sig=deadbeef&x=1 by jane@example.com"); got != "GET /file?sig=:sig&x=1 by :email" {
+		t.Errorf("Unexpected redacted text: %q", got)
+	}
+	if got := r.URI("/bad?%zz=1"); got != "/bad?%zz=1" {
+		t.Errorf("Expected an unparseable query to be kept and scrubbed, got %q", got)
+	}
+}
+
+func TestRedactor_Disabled(t *testing.T) {
+	r, err := New(config.RedactionConfig{Enabled: false, DropQuery: true, Scrubbers: []string{ScrubberEmail}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	uri := "/reset?email=jane@example.com"
+	if got := r.URI(uri); got != uri {
+		t.Errorf("Expected disabled redaction to keep the URI, got %q", got)
+	}
+
+	var nilRedactor *Redactor
+	if got := nilRedactor.URI(uri); got != uri {
+		t.Errorf("Expected a nil redactor to keep the URI, got %q", got)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	if _, err := New(config.RedactionConfig{Scrubbers: []string{"phone"}}); err == nil {
+		t.Errorf("Expected error for an unknown scrubber")
+	}
+	if _, err := New(config.RedactionConfig{Patterns: []config.RedactPattern{{Match: "("}}}); err == nil {
+		t.Errorf("Expected error for an invalid pattern")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure(Default())
+
+	if got := Shared().Text("mail jane@example.com"); got != "mail :email" {
+		t.Errorf("Expected the defaults before Configure, got %q", got)
+	}
+	if err := Configure(config.RedactionConfig{Enabled: true, Scrubbers: []string{ScrubberUUID}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := Shared().Text("mail jane@example.com"); got != "mail jane@example.com" {
+		t.Errorf("Expected the configured scrubbers only, got %q", got)
+	}
+
+	if err := Configure(config.RedactionConfig{Scrubbers: []string{"unknown"}}); err == nil {
+		t.Errorf("Expected error for an invalid config")
+	}
+	if got := Shared().Text("mail jane@example.com"); got != "mail jane@example.com" {
+		t.Errorf("Expected an invalid config to leave the redactor alone, got %q", got)
+	}
+}
@@ -4,18 +4,35 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"github.com/elasticphphq/agent/internal/cgroup"
 	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/laravel"
 	"github.com/elasticphphq/agent/internal/logging"
 	"github.com/elasticphphq/agent/internal/metrics"
+	"github.com/elasticphphq/agent/internal/server"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 	"log/slog"
 	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// detectKubernetes is a variable so tests can fake the pod metadata.
+	detectKubernetes = server.DetectKubernetes
+
+	shadowedLabels     = map[string]bool{} // Constant labels already warned about
+	shadowedLabelsLock sync.Mutex
+)
+
 type PrometheusCollector struct {
 	cfg                     *config.Config
 	upDesc                  *prometheus.Desc
@@ -34,6 +51,43 @@ type PrometheusCollector struct {
 	processesMemoryDesc     *prometheus.Desc
 	memoryPeakDesc          *prometheus.Desc
 
+	// Ping probe metrics
+	pingUpDesc       *prometheus.Desc
+	pingLatencyDesc  *prometheus.Desc
+	pingFailuresDesc *prometheus.Desc
+
+	// Worker lifecycle metrics
+	workerAgeDesc            *prometheus.Desc
+	workersNearRecycleDesc   *prometheus.Desc
+	workersStuckDesc         *prometheus.Desc
+	workersOverTerminateDesc *prometheus.Desc
+
+	// Request sampler metrics
+	endpointWorkerSecondsDesc *prometheus.Desc
+	samplerBusyDesc           *prometheus.Desc
+
+	// Memory leak detection metrics
+	leakSuspectedWorkersDesc *prometheus.Desc
+	leakMaxSlopeDesc         *prometheus.Desc
+	leakScriptGrowthDesc     *prometheus.Desc
+
+	// Pool resource attribution metrics
+	poolResourceProcessesDesc   *prometheus.Desc
+	poolResourceCPUDesc         *prometheus.Desc
+	poolResourceMemoryDesc      *prometheus.Desc
+	poolResourceReadBytesDesc   *prometheus.Desc
+	poolResourceWriteBytesDesc  *prometheus.Desc
+	poolResourceCtxSwitchesDesc *prometheus.Desc
+
+	// Restart tracking metrics
+	restartsDesc    *prometheus.Desc
+	lastRestartDesc *prometheus.Desc
+
+	// Kernel listen socket metrics
+	socketAcceptQueueDesc  *prometheus.Desc
+	socketBacklogLimitDesc *prometheus.Desc
+	socketEstablishedDesc  *prometheus.Desc
+
 	// Opcache metrics
 	opcacheEnabledDesc         *prometheus.Desc
 	opcacheUsedMemoryDesc      *prometheus.Desc
@@ -49,6 +103,17 @@ type PrometheusCollector struct {
 	opcacheManualRestartsDesc  *prometheus.Desc
 	opcacheHitRateDesc         *prometheus.Desc
 
+	// APCu metrics
+	apcuEnabledDesc         *prometheus.Desc
+	apcuHitsDesc            *prometheus.Desc
+	apcuMissesDesc          *prometheus.Desc
+	apcuInsertsDesc         *prometheus.Desc
+	apcuExpungesDesc        *prometheus.Desc
+	apcuEntriesDesc         *prometheus.Desc
+	apcuMemorySizeDesc      *prometheus.Desc
+	apcuMemoryAvailableDesc *prometheus.Desc
+	apcuFragmentationDesc   *prometheus.Desc
+
 	// Pool config metrics
 	// Maximum child processes, limits concurrency and memory use
 	pmMaxChildrenConfigDesc *prometheus.Desc
@@ -78,12 +143,93 @@ type PrometheusCollector struct {
 	cpuLimitDesc      *prometheus.Desc
 	memoryLimitMBDesc *prometheus.Desc
 
+	// Host resource metrics
+	hostCPUSecondsDesc     *prometheus.Desc
+	hostCPUUtilizationDesc *prometheus.Desc
+	hostLoad1Desc          *prometheus.Desc
+	hostLoad5Desc          *prometheus.Desc
+	hostLoad15Desc         *prometheus.Desc
+	hostMemoryTotalDesc    *prometheus.Desc
+	hostMemoryAvailDesc    *prometheus.Desc
+	hostMemoryUsedDesc     *prometheus.Desc
+	hostMemoryCachedDesc   *prometheus.Desc
+	hostMemoryBuffersDesc  *prometheus.Desc
+	hostSwapTotalDesc      *prometheus.Desc
+	hostSwapUsedDesc       *prometheus.Desc
+	hostDiskTotalDesc      *prometheus.Desc
+	hostDiskFreeDesc       *prometheus.Desc
+	hostDiskUsedDesc       *prometheus.Desc
+	hostDiskInodesDesc     *prometheus.Desc
+	hostDiskInodesFreeDesc *prometheus.Desc
+	hostNetRxBytesDesc     *prometheus.Desc
+	hostNetTxBytesDesc     *prometheus.Desc
+	hostNetRxPacketsDesc   *prometheus.Desc
+	hostNetTxPacketsDesc   *prometheus.Desc
+	hostNetRxErrorsDesc    *prometheus.Desc
+	hostNetTxErrorsDesc    *prometheus.Desc
+	hostNetRxDroppedDesc   *prometheus.Desc
+	hostNetTxDroppedDesc   *prometheus.Desc
+
+	// Cgroup metrics
+	cgroupInfoDesc             *prometheus.Desc
+	cgroupCPULimitDesc         *prometheus.Desc
+	cgroupCPUUsageDesc         *prometheus.Desc
+	cgroupCPUPeriodsDesc       *prometheus.Desc
+	cgroupCPUThrottledDesc     *prometheus.Desc
+	cgroupCPUThrottledSecsDesc *prometheus.Desc
+	cgroupMemoryLimitDesc      *prometheus.Desc
+	cgroupMemoryHighDesc       *prometheus.Desc
+	cgroupMemoryUsageDesc      *prometheus.Desc
+	cgroupMemoryPeakDesc       *prometheus.Desc
+	cgroupMemoryEventsDesc     *prometheus.Desc
+	cgroupPressureDesc         *prometheus.Desc
+	cgroupPressureStallDesc    *prometheus.Desc
+
 	// Laravel metrics
 	laravelInfoDesc *prometheus.Desc
+
+	// Laravel Horizon metrics
+	horizonStatusDesc              *prometheus.Desc
+	horizonSupervisorProcessesDesc *prometheus.Desc
+	horizonQueueLengthDesc         *prometheus.Desc
+	horizonQueueProcessesDesc      *prometheus.Desc
+	horizonQueueWaitDesc           *prometheus.Desc
+	horizonJobsDesc                *prometheus.Desc
+	horizonJobsPerMinuteDesc       *prometheus.Desc
+	horizonJobThroughputDesc       *prometheus.Desc
+	horizonJobRuntimeDesc          *prometheus.Desc
+	horizonQueueThroughputDesc     *prometheus.Desc
+	horizonQueueRuntimeDesc        *prometheus.Desc
+
+	// Laravel queue worker metrics
+	queueWorkersDesc           *prometheus.Desc
+	queueWorkerMemoryDesc      *prometheus.Desc
+	queueWorkerMaxMemoryDesc   *prometheus.Desc
+	queueWorkerMemoryLimitDesc *prometheus.Desc
+	queueWorkerMinUptimeDesc   *prometheus.Desc
+	queueWorkerMaxUptimeDesc   *prometheus.Desc
+	queueWorkerRestartsDesc    *prometheus.Desc
+
+	// Laravel scheduler metrics
+	scheduleTasksDesc          *prometheus.Desc
+	scheduleFiringDesc         *prometheus.Desc
+	scheduleLastRunAgeDesc     *prometheus.Desc
+	scheduleMissedRunsDesc     *prometheus.Desc
+	scheduleTaskLastRunAgeDesc *prometheus.Desc
+	scheduleTaskMissedRunsDesc *prometheus.Desc
+	scheduleTaskNextDueDesc    *prometheus.Desc
+
+	// Laravel log metrics
+	logEntriesDesc    *prometheus.Desc
+	logExceptionsDesc *prometheus.Desc
+
+	// Laravel exception group metrics
+	exceptionGroupsDesc    *prometheus.Desc
+	exceptionNewGroupsDesc *prometheus.Desc
 }
 
 func NewPrometheusCollector(cfg *config.Config) *PrometheusCollector {
-	labels := []string{"pool", "socket"}
+	labels := []string{"pool", "socket", "fpm_config", "php_version"}
 	return &PrometheusCollector{
 		cfg: cfg,
 		// FPM Metrics
@@ -103,6 +249,43 @@ func NewPrometheusCollector(cfg *config.Config) *PrometheusCollector {
 		processesMemoryDesc:     prometheus.NewDesc("phpfpm_processes_memory_avg", "Average memory usage across all processes in the pool.", labels, nil),
 		memoryPeakDesc:          prometheus.NewDesc("phpfpm_memory_peak", "Peak memory usage of the pool.", labels, nil),
 
+		// Ping probe metrics
+		pingUpDesc:       prometheus.NewDesc("phpfpm_ping_up", "Whether the last ping.path probe got the expected ping.response (1 for yes, 0 for no).", labels, nil),
+		pingLatencyDesc:  prometheus.NewDesc("phpfpm_ping_latency_seconds", "Round-trip latency of successful ping.path probes.", labels, nil),
+		pingFailuresDesc: prometheus.NewDesc("phpfpm_ping_failures_total", "Number of failed ping.path probes.", labels, nil),
+
+		// Worker lifecycle metrics
+		workerAgeDesc:            prometheus.NewDesc("phpfpm_worker_age_seconds", "Age distribution of the pool's worker processes.", labels, nil),
+		workersNearRecycleDesc:   prometheus.NewDesc("phpfpm_workers_near_recycle", "Workers that have served close to pm.max_requests and will be recycled soon.", labels, nil),
+		workersStuckDesc:         prometheus.NewDesc("phpfpm_workers_stuck", "Workers that stayed in Reading headers or Finishing longer than the stuck threshold.", labels, nil),
+		workersOverTerminateDesc: prometheus.NewDesc("phpfpm_workers_over_terminate_timeout", "Workers whose in-flight request has run longer than request_terminate_timeout.", labels, nil),
+
+		// Request sampler metrics
+		endpointWorkerSecondsDesc: prometheus.NewDesc("phpfpm_endpoint_worker_seconds", "Worker-seconds spent on a normalised endpoint within the window, for the top endpoints of the pool.", []string{"pool", "socket", "fpm_config", "php_version", "window", "method", "uri", "script"}, nil),
+		samplerBusyDesc:           prometheus.NewDesc("phpfpm_sampler_busy_worker_seconds", "Worker-seconds of all sampled in-flight requests within the window.", []string{"pool", "socket", "fpm_config", "php_version", "window"}, nil),
+
+		// Memory leak detection metrics
+		leakSuspectedWorkersDesc: prometheus.NewDesc("phpfpm_memory_leak_suspected_workers", "Workers whose memory grows steadily from request to request.", labels, nil),
+		leakMaxSlopeDesc:         prometheus.NewDesc("phpfpm_memory_growth_max_bytes_per_request", "Steepest per-request memory growth among the pool's tracked workers.", labels, nil),
+		leakScriptGrowthDesc:     prometheus.NewDesc("phpfpm_memory_leak_script_growth_bytes", "Memory growth observed on requests served by a script, for the scripts most associated with growth.", []string{"pool", "socket", "fpm_config", "php_version", "script"}, nil),
+
+		// Pool resource attribution metrics
+		poolResourceProcessesDesc:   prometheus.NewDesc("phpfpm_pool_resource_processes", "Processes attributed to the pool: master, workers and their children.", labels, nil),
+		poolResourceCPUDesc:         prometheus.NewDesc("phpfpm_pool_resource_cpu_seconds_total", "CPU time used by the pool's processes, including recycled workers.", labels, nil),
+		poolResourceMemoryDesc:      prometheus.NewDesc("phpfpm_pool_resource_memory_bytes", "Resident memory of the pool's processes, or the pool cgroup's memory usage.", labels, nil),
+		poolResourceReadBytesDesc:   prometheus.NewDesc("phpfpm_pool_resource_read_bytes_total", "Bytes the pool's processes read from storage.", labels, nil),
+		poolResourceWriteBytesDesc:  prometheus.NewDesc("phpfpm_pool_resource_write_bytes_total", "Bytes the pool's processes wrote to storage.", labels, nil),
+		poolResourceCtxSwitchesDesc: prometheus.NewDesc("phpfpm_pool_resource_context_switches_total", "Context switches of the pool's processes.", []string{"pool", "socket", "fpm_config", "php_version", "type"}, nil),
+
+		// Restart tracking metrics
+		restartsDesc:    prometheus.NewDesc("phpfpm_pool_restarts_total", "Number of FPM master restarts or counter resets observed for the pool since the agent started.", labels, nil),
+		lastRestartDesc: prometheus.NewDesc("phpfpm_pool_last_restart_timestamp_seconds", "Unix timestamp of the last observed restart of the pool.", labels, nil),
+
+		// Kernel listen socket metrics
+		socketAcceptQueueDesc:  prometheus.NewDesc("phpfpm_socket_accept_queue", "Connections waiting in the kernel accept queue of the pool's listen socket.", labels, nil),
+		socketBacklogLimitDesc: prometheus.NewDesc("phpfpm_socket_backlog_limit", "Kernel backlog limit of the pool's listen socket.", labels, nil),
+		socketEstablishedDesc:  prometheus.NewDesc("phpfpm_socket_connections_established", "Established connections on the pool's listen socket.", labels, nil),
+
 		// Opcache metrics
 		opcacheEnabledDesc:         prometheus.NewDesc("phpfpm_opcache_enabled", "Whether opcache is enabled.", labels, nil),
 		opcacheUsedMemoryDesc:      prometheus.NewDesc("phpfpm_opcache_used_memory_bytes", "Amount of used opcache memory in bytes.", labels, nil),
@@ -118,6 +301,17 @@ func NewPrometheusCollector(cfg *config.Config) *PrometheusCollector {
 		opcacheManualRestartsDesc:  prometheus.NewDesc("phpfpm_opcache_manual_restarts_total", "Number of manual restarts in opcache.", labels, nil),
 		opcacheHitRateDesc:         prometheus.NewDesc("phpfpm_opcache_hit_rate", "Opcache hit rate.", labels, nil),
 
+		// APCu metrics
+		apcuEnabledDesc:         prometheus.NewDesc("phpfpm_apcu_enabled", "Whether APCu is loaded and enabled.", labels, nil),
+		apcuHitsDesc:            prometheus.NewDesc("phpfpm_apcu_hits_total", "Total number of APCu cache hits.", labels, nil),
+		apcuMissesDesc:          prometheus.NewDesc("phpfpm_apcu_misses_total", "Total number of APCu cache misses.", labels, nil),
+		apcuInsertsDesc:         prometheus.NewDesc("phpfpm_apcu_inserts_total", "Total number of APCu cache inserts.", labels, nil),
+		apcuExpungesDesc:        prometheus.NewDesc("phpfpm_apcu_expunges_total", "Number of times APCu expunged the cache because it was full.", labels, nil),
+		apcuEntriesDesc:         prometheus.NewDesc("phpfpm_apcu_entries", "Number of entries in the APCu cache.", labels, nil),
+		apcuMemorySizeDesc:      prometheus.NewDesc("phpfpm_apcu_memory_size_bytes", "Total APCu shared memory in bytes.", labels, nil),
+		apcuMemoryAvailableDesc: prometheus.NewDesc("phpfpm_apcu_memory_available_bytes", "Available APCu shared memory in bytes.", labels, nil),
+		apcuFragmentationDesc:   prometheus.NewDesc("phpfpm_apcu_fragmentation_percent", "Percentage of free APCu memory held in fragments smaller than 5MB.", labels, nil),
+
 		// Pool config metrics
 		pmMaxChildrenConfigDesc:           prometheus.NewDesc("phpfpm_pm_max_children_config", "PHP-FPM pool config: max children. Maximum child processes, limits concurrency and memory use.", labels, nil),
 		pmStartServersConfigDesc:          prometheus.NewDesc("phpfpm_pm_start_servers_config", "PHP-FPM pool config: start servers. Number of processes created on startup, affects cold start latency.", labels, nil),
@@ -132,12 +326,93 @@ func NewPrometheusCollector(cfg *config.Config) *PrometheusCollector {
 		rlimitFilesConfigDesc:             prometheus.NewDesc("phpfpm_rlimit_files_config", "PHP-FPM pool config: file descriptors limit per process.", labels, nil),
 
 		// System metrics
-		systemInfoDesc:    prometheus.NewDesc("system_info", "System information", []string{"type", "os", "arch"}, nil),
+		systemInfoDesc:    prometheus.NewDesc("system_info", "System information", []string{"type", "os", "arch", "container_runtime", "container_id", "orchestrator", "cloud_provider", "instance_type", "kernel_version", "hostname"}, nil),
 		cpuLimitDesc:      prometheus.NewDesc("system_cpu_limit", "Logical CPU limit", nil, nil),
 		memoryLimitMBDesc: prometheus.NewDesc("system_memory_limit_mb", "Memory limit in MB", nil, nil),
 
+		// Host resource metrics
+		hostCPUSecondsDesc:     prometheus.NewDesc("system_cpu_seconds_total", "Seconds the host CPUs spent in each mode.", []string{"mode"}, nil),
+		hostCPUUtilizationDesc: prometheus.NewDesc("system_cpu_utilization", "Share of host CPU time spent in each mode since the previous scrape.", []string{"mode"}, nil),
+		hostLoad1Desc:          prometheus.NewDesc("system_load1", "1 minute load average.", nil, nil),
+		hostLoad5Desc:          prometheus.NewDesc("system_load5", "5 minute load average.", nil, nil),
+		hostLoad15Desc:         prometheus.NewDesc("system_load15", "15 minute load average.", nil, nil),
+		hostMemoryTotalDesc:    prometheus.NewDesc("system_memory_total_bytes", "Total host memory in bytes.", nil, nil),
+		hostMemoryAvailDesc:    prometheus.NewDesc("system_memory_available_bytes", "Host memory available for new processes in bytes.", nil, nil),
+		hostMemoryUsedDesc:     prometheus.NewDesc("system_memory_used_bytes", "Used host memory in bytes.", nil, nil),
+		hostMemoryCachedDesc:   prometheus.NewDesc("system_memory_cached_bytes", "Host memory used by the page cache in bytes.", nil, nil),
+		hostMemoryBuffersDesc:  prometheus.NewDesc("system_memory_buffers_bytes", "Host memory used by kernel buffers in bytes.", nil, nil),
+		hostSwapTotalDesc:      prometheus.NewDesc("system_swap_total_bytes", "Total swap space in bytes.", nil, nil),
+		hostSwapUsedDesc:       prometheus.NewDesc("system_swap_used_bytes", "Used swap space in bytes.", nil, nil),
+		hostDiskTotalDesc:      prometheus.NewDesc("system_disk_total_bytes", "Size of the filesystem holding the path in bytes.", []string{"path", "fstype"}, nil),
+		hostDiskFreeDesc:       prometheus.NewDesc("system_disk_free_bytes", "Free space on the filesystem holding the path in bytes.", []string{"path", "fstype"}, nil),
+		hostDiskUsedDesc:       prometheus.NewDesc("system_disk_used_bytes", "Used space on the filesystem holding the path in bytes.", []string{"path", "fstype"}, nil),
+		hostDiskInodesDesc:     prometheus.NewDesc("system_disk_inodes_total", "Inodes of the filesystem holding the path.", []string{"path", "fstype"}, nil),
+		hostDiskInodesFreeDesc: prometheus.NewDesc("system_disk_inodes_free", "Free inodes on the filesystem holding the path.", []string{"path", "fstype"}, nil),
+		hostNetRxBytesDesc:     prometheus.NewDesc("system_network_receive_bytes_total", "Bytes received on the interface.", []string{"interface"}, nil),
+		hostNetTxBytesDesc:     prometheus.NewDesc("system_network_transmit_bytes_total", "Bytes transmitted on the interface.", []string{"interface"}, nil),
+		hostNetRxPacketsDesc:   prometheus.NewDesc("system_network_receive_packets_total", "Packets received on the interface.", []string{"interface"}, nil),
+		hostNetTxPacketsDesc:   prometheus.NewDesc("system_network_transmit_packets_total", "Packets transmitted on the interface.", []string{"interface"}, nil),
+		hostNetRxErrorsDesc:    prometheus.NewDesc("system_network_receive_errors_total", "Receive errors on the interface.", []string{"interface"}, nil),
+		hostNetTxErrorsDesc:    prometheus.NewDesc("system_network_transmit_errors_total", "Transmit errors on the interface.", []string{"interface"}, nil),
+		hostNetRxDroppedDesc:   prometheus.NewDesc("system_network_receive_drop_total", "Received packets dropped on the interface.", []string{"interface"}, nil),
+		hostNetTxDroppedDesc:   prometheus.NewDesc("system_network_transmit_drop_total", "Transmitted packets dropped on the interface.", []string{"interface"}, nil),
+
+		// Cgroup metrics
+		cgroupInfoDesc:             prometheus.NewDesc("cgroup_info", "Cgroup hierarchy version the agent runs in.", []string{"version"}, nil),
+		cgroupCPULimitDesc:         prometheus.NewDesc("cgroup_cpu_limit_cores", "CPU quota of the cgroup in fractional cores.", nil, nil),
+		cgroupCPUUsageDesc:         prometheus.NewDesc("cgroup_cpu_usage_seconds_total", "CPU time consumed by the cgroup.", nil, nil),
+		cgroupCPUPeriodsDesc:       prometheus.NewDesc("cgroup_cpu_periods_total", "Enforcement periods elapsed for the cgroup CPU quota.", nil, nil),
+		cgroupCPUThrottledDesc:     prometheus.NewDesc("cgroup_cpu_throttled_periods_total", "Periods in which the cgroup was throttled for exhausting its CPU quota.", nil, nil),
+		cgroupCPUThrottledSecsDesc: prometheus.NewDesc("cgroup_cpu_throttled_seconds_total", "Time the cgroup was throttled for exhausting its CPU quota.", nil, nil),
+		cgroupMemoryLimitDesc:      prometheus.NewDesc("cgroup_memory_limit_bytes", "Memory limit of the cgroup in bytes.", nil, nil),
+		cgroupMemoryHighDesc:       prometheus.NewDesc("cgroup_memory_high_bytes", "Memory usage above which the cgroup is throttled and reclaimed (memory.high).", nil, nil),
+		cgroupMemoryUsageDesc:      prometheus.NewDesc("cgroup_memory_usage_bytes", "Current memory usage of the cgroup in bytes.", nil, nil),
+		cgroupMemoryPeakDesc:       prometheus.NewDesc("cgroup_memory_peak_bytes", "Peak memory usage of the cgroup in bytes.", nil, nil),
+		cgroupMemoryEventsDesc:     prometheus.NewDesc("cgroup_memory_events_total", "Cgroup memory events such as hitting memory.high or memory.max, OOM and OOM kills.", []string{"event"}, nil),
+		cgroupPressureDesc:         prometheus.NewDesc("cgroup_pressure_ratio", "Share of wall time in which some or all tasks were stalled on the resource, averaged over the window.", []string{"resource", "kind", "window"}, nil),
+		cgroupPressureStallDesc:    prometheus.NewDesc("cgroup_pressure_stall_seconds_total", "Time in which some or all tasks were stalled on the resource.", []string{"resource", "kind"}, nil),
+
 		// Laravel Metrics
 		laravelInfoDesc: prometheus.NewDesc("laravel_app_info", "Basic information about Laravel site", []string{"site", "version", "php_version", "environment", "debug_mode"}, nil),
+
+		// Laravel Horizon metrics
+		horizonStatusDesc:              prometheus.NewDesc("laravel_horizon_status", "Horizon status: running, paused or inactive (1 for the current status).", []string{"site", "status"}, nil),
+		horizonSupervisorProcessesDesc: prometheus.NewDesc("laravel_horizon_supervisor_processes", "Worker processes of a Horizon supervisor per queue.", []string{"site", "supervisor", "connection", "queue"}, nil),
+		horizonQueueLengthDesc:         prometheus.NewDesc("laravel_horizon_queue_length", "Jobs ready to run on a queue worked by Horizon.", []string{"site", "connection", "queue"}, nil),
+		horizonQueueProcessesDesc:      prometheus.NewDesc("laravel_horizon_queue_processes", "Horizon worker processes working a queue.", []string{"site", "connection", "queue"}, nil),
+		horizonQueueWaitDesc:           prometheus.NewDesc("laravel_horizon_queue_wait_seconds", "Estimated time for Horizon to clear a queue.", []string{"site", "connection", "queue"}, nil),
+		horizonJobsDesc:                prometheus.NewDesc("laravel_horizon_jobs", "Jobs Horizon keeps track of: recent, pending, completed, failed and recent_failed.", []string{"site", "type"}, nil),
+		horizonJobsPerMinuteDesc:       prometheus.NewDesc("laravel_horizon_jobs_per_minute", "Jobs processed per minute since Horizon's last metrics snapshot.", []string{"site"}, nil),
+		horizonJobThroughputDesc:       prometheus.NewDesc("laravel_horizon_job_throughput", "Jobs of a class processed in Horizon's latest metrics snapshot.", []string{"site", "job"}, nil),
+		horizonJobRuntimeDesc:          prometheus.NewDesc("laravel_horizon_job_runtime_seconds", "Average runtime of a job class in Horizon's latest metrics snapshot.", []string{"site", "job"}, nil),
+		horizonQueueThroughputDesc:     prometheus.NewDesc("laravel_horizon_queue_throughput", "Jobs of a queue processed in Horizon's latest metrics snapshot.", []string{"site", "queue"}, nil),
+		horizonQueueRuntimeDesc:        prometheus.NewDesc("laravel_horizon_queue_runtime_seconds", "Average job runtime of a queue in Horizon's latest metrics snapshot.", []string{"site", "queue"}, nil),
+
+		// Laravel queue worker metrics
+		queueWorkersDesc:           prometheus.NewDesc("laravel_queue_workers", "Running queue:work, queue:listen and horizon:work processes processing the queue.", []string{"site", "connection", "queue"}, nil),
+		queueWorkerMemoryDesc:      prometheus.NewDesc("laravel_queue_worker_memory_bytes", "Resident memory of the queue's worker processes.", []string{"site", "connection", "queue"}, nil),
+		queueWorkerMaxMemoryDesc:   prometheus.NewDesc("laravel_queue_worker_max_memory_bytes", "Resident memory of the queue's largest worker process.", []string{"site", "connection", "queue"}, nil),
+		queueWorkerMemoryLimitDesc: prometheus.NewDesc("laravel_queue_worker_memory_limit_bytes", "Lowest --memory limit of the queue's workers, above which a worker exits.", []string{"site", "connection", "queue"}, nil),
+		queueWorkerMinUptimeDesc:   prometheus.NewDesc("laravel_queue_worker_min_uptime_seconds", "Uptime of the queue's most recently started worker.", []string{"site", "connection", "queue"}, nil),
+		queueWorkerMaxUptimeDesc:   prometheus.NewDesc("laravel_queue_worker_max_uptime_seconds", "Uptime of the queue's oldest worker.", []string{"site", "connection", "queue"}, nil),
+		queueWorkerRestartsDesc:    prometheus.NewDesc("laravel_queue_worker_restarts_total", "Queue workers that exited since the agent started.", []string{"site", "connection", "queue"}, nil),
+
+		// Laravel scheduler metrics
+		scheduleTasksDesc:          prometheus.NewDesc("laravel_schedule_tasks", "Scheduled tasks running in the app's environment.", []string{"site"}, nil),
+		scheduleFiringDesc:         prometheus.NewDesc("laravel_schedule_firing", "Whether schedule:run touched the heartbeat file within the last minute and tolerance.", []string{"site"}, nil),
+		scheduleLastRunAgeDesc:     prometheus.NewDesc("laravel_schedule_last_run_age_seconds", "Seconds since schedule:run last touched the heartbeat file.", []string{"site"}, nil),
+		scheduleMissedRunsDesc:     prometheus.NewDesc("laravel_schedule_missed_runs", "Due runs of all scheduled tasks missed since their last run.", []string{"site"}, nil),
+		scheduleTaskLastRunAgeDesc: prometheus.NewDesc("laravel_schedule_task_last_run_age_seconds", "Seconds since the scheduled task last ran.", []string{"site", "task"}, nil),
+		scheduleTaskMissedRunsDesc: prometheus.NewDesc("laravel_schedule_task_missed_runs", "Due runs of the scheduled task missed since its last run.", []string{"site", "task"}, nil),
+		scheduleTaskNextDueDesc:    prometheus.NewDesc("laravel_schedule_task_next_due_seconds", "Seconds until the scheduled task is due again.", []string{"site", "task"}, nil),
+
+		// Laravel log metrics
+		logEntriesDesc:    prometheus.NewDesc("laravel_log_entries_total", "Log entries written since the agent started.", []string{"site", "channel", "level"}, nil),
+		logExceptionsDesc: prometheus.NewDesc("laravel_log_exceptions_total", "Logged exceptions since the agent started by class.", []string{"site", "class"}, nil),
+
+		// Laravel exception group metrics
+		exceptionGroupsDesc:    prometheus.NewDesc("laravel_exception_groups", "Logged exceptions told apart by class, normalised message and top app frames.", []string{"site"}, nil),
+		exceptionNewGroupsDesc: prometheus.NewDesc("laravel_exception_new_groups", "Exception groups first seen within exceptions_new_window.", []string{"site"}, nil),
 	}
 }
 
@@ -159,6 +434,43 @@ func (pc *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- pc.processesMemoryDesc
 	ch <- pc.memoryPeakDesc
 
+	// Ping probe metrics
+	ch <- pc.pingUpDesc
+	ch <- pc.pingLatencyDesc
+	ch <- pc.pingFailuresDesc
+
+	// Worker lifecycle metrics
+	ch <- pc.workerAgeDesc
+	ch <- pc.workersNearRecycleDesc
+	ch <- pc.workersStuckDesc
+	ch <- pc.workersOverTerminateDesc
+
+	// Request sampler metrics
+	ch <- pc.endpointWorkerSecondsDesc
+	ch <- pc.samplerBusyDesc
+
+	// Memory leak detection metrics
+	ch <- pc.leakSuspectedWorkersDesc
+	ch <- pc.leakMaxSlopeDesc
+	ch <- pc.leakScriptGrowthDesc
+
+	// Pool resource attribution metrics
+	ch <- pc.poolResourceProcessesDesc
+	ch <- pc.poolResourceCPUDesc
+	ch <- pc.poolResourceMemoryDesc
+	ch <- pc.poolResourceReadBytesDesc
+	ch <- pc.poolResourceWriteBytesDesc
+	ch <- pc.poolResourceCtxSwitchesDesc
+
+	// Restart tracking metrics
+	ch <- pc.restartsDesc
+	ch <- pc.lastRestartDesc
+
+	// Kernel listen socket metrics
+	ch <- pc.socketAcceptQueueDesc
+	ch <- pc.socketBacklogLimitDesc
+	ch <- pc.socketEstablishedDesc
+
 	// Opcache metrics
 	ch <- pc.opcacheEnabledDesc
 	ch <- pc.opcacheUsedMemoryDesc
@@ -174,6 +486,17 @@ func (pc *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- pc.opcacheManualRestartsDesc
 	ch <- pc.opcacheHitRateDesc
 
+	// APCu metrics
+	ch <- pc.apcuEnabledDesc
+	ch <- pc.apcuHitsDesc
+	ch <- pc.apcuMissesDesc
+	ch <- pc.apcuInsertsDesc
+	ch <- pc.apcuExpungesDesc
+	ch <- pc.apcuEntriesDesc
+	ch <- pc.apcuMemorySizeDesc
+	ch <- pc.apcuMemoryAvailableDesc
+	ch <- pc.apcuFragmentationDesc
+
 	// FPM Config
 	ch <- pc.pmMaxChildrenConfigDesc
 	ch <- pc.pmStartServersConfigDesc
@@ -192,7 +515,88 @@ func (pc *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- pc.cpuLimitDesc
 	ch <- pc.memoryLimitMBDesc
 
+	// Host resource metrics
+	ch <- pc.hostCPUSecondsDesc
+	ch <- pc.hostCPUUtilizationDesc
+	ch <- pc.hostLoad1Desc
+	ch <- pc.hostLoad5Desc
+	ch <- pc.hostLoad15Desc
+	ch <- pc.hostMemoryTotalDesc
+	ch <- pc.hostMemoryAvailDesc
+	ch <- pc.hostMemoryUsedDesc
+	ch <- pc.hostMemoryCachedDesc
+	ch <- pc.hostMemoryBuffersDesc
+	ch <- pc.hostSwapTotalDesc
+	ch <- pc.hostSwapUsedDesc
+	ch <- pc.hostDiskTotalDesc
+	ch <- pc.hostDiskFreeDesc
+	ch <- pc.hostDiskUsedDesc
+	ch <- pc.hostDiskInodesDesc
+	ch <- pc.hostDiskInodesFreeDesc
+	ch <- pc.hostNetRxBytesDesc
+	ch <- pc.hostNetTxBytesDesc
+	ch <- pc.hostNetRxPacketsDesc
+	ch <- pc.hostNetTxPacketsDesc
+	ch <- pc.hostNetRxErrorsDesc
+	ch <- pc.hostNetTxErrorsDesc
+	ch <- pc.hostNetRxDroppedDesc
+	ch <- pc.hostNetTxDroppedDesc
+
+	// Cgroup metrics
+	ch <- pc.cgroupInfoDesc
+	ch <- pc.cgroupCPULimitDesc
+	ch <- pc.cgroupCPUUsageDesc
+	ch <- pc.cgroupCPUPeriodsDesc
+	ch <- pc.cgroupCPUThrottledDesc
+	ch <- pc.cgroupCPUThrottledSecsDesc
+	ch <- pc.cgroupMemoryLimitDesc
+	ch <- pc.cgroupMemoryHighDesc
+	ch <- pc.cgroupMemoryUsageDesc
+	ch <- pc.cgroupMemoryPeakDesc
+	ch <- pc.cgroupMemoryEventsDesc
+	ch <- pc.cgroupPressureDesc
+	ch <- pc.cgroupPressureStallDesc
+
 	ch <- pc.laravelInfoDesc
+
+	// Laravel Horizon metrics
+	ch <- pc.horizonStatusDesc
+	ch <- pc.horizonSupervisorProcessesDesc
+	ch <- pc.horizonQueueLengthDesc
+	ch <- pc.horizonQueueProcessesDesc
+	ch <- pc.horizonQueueWaitDesc
+	ch <- pc.horizonJobsDesc
+	ch <- pc.horizonJobsPerMinuteDesc
+	ch <- pc.horizonJobThroughputDesc
+	ch <- pc.horizonJobRuntimeDesc
+	ch <- pc.horizonQueueThroughputDesc
+	ch <- pc.horizonQueueRuntimeDesc
+
+	// Laravel queue worker metrics
+	ch <- pc.queueWorkersDesc
+	ch <- pc.queueWorkerMemoryDesc
+	ch <- pc.queueWorkerMaxMemoryDesc
+	ch <- pc.queueWorkerMemoryLimitDesc
+	ch <- pc.queueWorkerMinUptimeDesc
+	ch <- pc.queueWorkerMaxUptimeDesc
+	ch <- pc.queueWorkerRestartsDesc
+
+	// Laravel scheduler metrics
+	ch <- pc.scheduleTasksDesc
+	ch <- pc.scheduleFiringDesc
+	ch <- pc.scheduleLastRunAgeDesc
+	ch <- pc.scheduleMissedRunsDesc
+	ch <- pc.scheduleTaskLastRunAgeDesc
+	ch <- pc.scheduleTaskMissedRunsDesc
+	ch <- pc.scheduleTaskNextDueDesc
+
+	// Laravel log metrics
+	ch <- pc.logEntriesDesc
+	ch <- pc.logExceptionsDesc
+
+	// Laravel exception group metrics
+	ch <- pc.exceptionGroupsDesc
+	ch <- pc.exceptionNewGroupsDesc
 }
 
 func parseConfigValue(val string) (float64, bool) {
@@ -213,7 +617,7 @@ func (pc *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
 
 	m, err := metrics.GetMetrics(ctx, pc.cfg)
 	if err != nil {
-		ch <- prometheus.MustNewConstMetric(pc.upDesc, prometheus.GaugeValue, 0, "unknown", "unknown")
+		ch <- prometheus.MustNewConstMetric(pc.upDesc, prometheus.GaugeValue, 0, "unknown", "unknown", "", "")
 		ch <- prometheus.MustNewConstMetric(
 			prometheus.NewDesc("phpfpm_scrape_failures", "The number of failures scraping from PHP-FPM.", nil, nil),
 			prometheus.CounterValue, 1)
@@ -222,16 +626,112 @@ func (pc *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
 
 	if m.Server != nil {
 		nodeType := string(m.Server.NodeType)
-		ch <- prometheus.MustNewConstMetric(pc.systemInfoDesc, prometheus.GaugeValue, 1, nodeType, m.Server.OS, m.Server.Architecture)
+		ch <- prometheus.MustNewConstMetric(pc.systemInfoDesc, prometheus.GaugeValue, 1, nodeType, m.Server.OS, m.Server.Architecture,
+			m.Server.ContainerRuntime, m.Server.ContainerID, m.Server.Orchestrator, m.Server.CloudProvider, m.Server.InstanceType, m.Server.KernelVersion, m.Server.Hostname)
 		ch <- prometheus.MustNewConstMetric(pc.cpuLimitDesc, prometheus.GaugeValue, float64(m.Server.CPULimit))
 		ch <- prometheus.MustNewConstMetric(pc.memoryLimitMBDesc, prometheus.GaugeValue, float64(m.Server.MemoryLimitMB))
 	}
 
+	if host := m.Host; host != nil {
+		for mode, seconds := range host.CPUSeconds {
+			ch <- prometheus.MustNewConstMetric(pc.hostCPUSecondsDesc, prometheus.CounterValue, seconds, mode)
+		}
+		for mode, share := range host.CPUUtilization {
+			ch <- prometheus.MustNewConstMetric(pc.hostCPUUtilizationDesc, prometheus.GaugeValue, share, mode)
+		}
+		ch <- prometheus.MustNewConstMetric(pc.hostLoad1Desc, prometheus.GaugeValue, host.Load1)
+		ch <- prometheus.MustNewConstMetric(pc.hostLoad5Desc, prometheus.GaugeValue, host.Load5)
+		ch <- prometheus.MustNewConstMetric(pc.hostLoad15Desc, prometheus.GaugeValue, host.Load15)
+		if host.Memory != nil {
+			ch <- prometheus.MustNewConstMetric(pc.hostMemoryTotalDesc, prometheus.GaugeValue, float64(host.Memory.Total))
+			ch <- prometheus.MustNewConstMetric(pc.hostMemoryAvailDesc, prometheus.GaugeValue, float64(host.Memory.Available))
+			ch <- prometheus.MustNewConstMetric(pc.hostMemoryUsedDesc, prometheus.GaugeValue, float64(host.Memory.Used))
+			ch <- prometheus.MustNewConstMetric(pc.hostMemoryCachedDesc, prometheus.GaugeValue, float64(host.Memory.Cached))
+			ch <- prometheus.MustNewConstMetric(pc.hostMemoryBuffersDesc, prometheus.GaugeValue, float64(host.Memory.Buffers))
+		}
+		if host.Swap != nil {
+			ch <- prometheus.MustNewConstMetric(pc.hostSwapTotalDesc, prometheus.GaugeValue, float64(host.Swap.Total))
+			ch <- prometheus.MustNewConstMetric(pc.hostSwapUsedDesc, prometheus.GaugeValue, float64(host.Swap.Used))
+		}
+		for _, d := range host.Disks {
+			ch <- prometheus.MustNewConstMetric(pc.hostDiskTotalDesc, prometheus.GaugeValue, float64(d.Total), d.Path, d.Fstype)
+			ch <- prometheus.MustNewConstMetric(pc.hostDiskFreeDesc, prometheus.GaugeValue, float64(d.Free), d.Path, d.Fstype)
+			ch <- prometheus.MustNewConstMetric(pc.hostDiskUsedDesc, prometheus.GaugeValue, float64(d.Used), d.Path, d.Fstype)
+			ch <- prometheus.MustNewConstMetric(pc.hostDiskInodesDesc, prometheus.GaugeValue, float64(d.InodesTotal), d.Path, d.Fstype)
+			ch <- prometheus.MustNewConstMetric(pc.hostDiskInodesFreeDesc, prometheus.GaugeValue, float64(d.InodesFree), d.Path, d.Fstype)
+		}
+		for _, n := range host.Network {
+			ch <- prometheus.MustNewConstMetric(pc.hostNetRxBytesDesc, prometheus.CounterValue, float64(n.RxBytes), n.Interface)
+			ch <- prometheus.MustNewConstMetric(pc.hostNetTxBytesDesc, prometheus.CounterValue, float64(n.TxBytes), n.Interface)
+			ch <- prometheus.MustNewConstMetric(pc.hostNetRxPacketsDesc, prometheus.CounterValue, float64(n.RxPackets), n.Interface)
+			ch <- prometheus.MustNewConstMetric(pc.hostNetTxPacketsDesc, prometheus.CounterValue, float64(n.TxPackets), n.Interface)
+			ch <- prometheus.MustNewConstMetric(pc.hostNetRxErrorsDesc, prometheus.CounterValue, float64(n.RxErrors), n.Interface)
+			ch <- prometheus.MustNewConstMetric(pc.hostNetTxErrorsDesc, prometheus.CounterValue, float64(n.TxErrors), n.Interface)
+			ch <- prometheus.MustNewConstMetric(pc.hostNetRxDroppedDesc, prometheus.CounterValue, float64(n.RxDropped), n.Interface)
+			ch <- prometheus.MustNewConstMetric(pc.hostNetTxDroppedDesc, prometheus.CounterValue, float64(n.TxDropped), n.Interface)
+		}
+	}
+
+	if cg := m.Cgroup; cg != nil {
+		ch <- prometheus.MustNewConstMetric(pc.cgroupInfoDesc, prometheus.GaugeValue, 1, strconv.Itoa(cg.Version))
+		if cpu := cg.CPU; cpu != nil {
+			if cpu.Limit > 0 {
+				ch <- prometheus.MustNewConstMetric(pc.cgroupCPULimitDesc, prometheus.GaugeValue, cpu.Limit)
+			}
+			ch <- prometheus.MustNewConstMetric(pc.cgroupCPUUsageDesc, prometheus.CounterValue, cpu.UsageSeconds)
+			ch <- prometheus.MustNewConstMetric(pc.cgroupCPUPeriodsDesc, prometheus.CounterValue, float64(cpu.Periods))
+			ch <- prometheus.MustNewConstMetric(pc.cgroupCPUThrottledDesc, prometheus.CounterValue, float64(cpu.ThrottledPeriods))
+			ch <- prometheus.MustNewConstMetric(pc.cgroupCPUThrottledSecsDesc, prometheus.CounterValue, cpu.ThrottledSeconds)
+		}
+		if mem := cg.Memory; mem != nil {
+			if mem.Limit > 0 {
+				ch <- prometheus.MustNewConstMetric(pc.cgroupMemoryLimitDesc, prometheus.GaugeValue, float64(mem.Limit))
+			}
+			if mem.High > 0 {
+				ch <- prometheus.MustNewConstMetric(pc.cgroupMemoryHighDesc, prometheus.GaugeValue, float64(mem.High))
+			}
+			ch <- prometheus.MustNewConstMetric(pc.cgroupMemoryUsageDesc, prometheus.GaugeValue, float64(mem.Current))
+			if mem.Peak > 0 {
+				ch <- prometheus.MustNewConstMetric(pc.cgroupMemoryPeakDesc, prometheus.GaugeValue, float64(mem.Peak))
+			}
+			for event, count := range mem.Events {
+				ch <- prometheus.MustNewConstMetric(pc.cgroupMemoryEventsDesc, prometheus.CounterValue, float64(count), event)
+			}
+		}
+		for resource, p := range cg.Pressure {
+			for kind, line := range map[string]*cgroup.PressureLine{"some": p.Some, "full": p.Full} {
+				if line == nil {
+					continue
+				}
+				ch <- prometheus.MustNewConstMetric(pc.cgroupPressureDesc, prometheus.GaugeValue, line.Avg10/100, resource, kind, "10s")
+				ch <- prometheus.MustNewConstMetric(pc.cgroupPressureDesc, prometheus.GaugeValue, line.Avg60/100, resource, kind, "60s")
+				ch <- prometheus.MustNewConstMetric(pc.cgroupPressureDesc, prometheus.GaugeValue, line.Avg300/100, resource, kind, "300s")
+				ch <- prometheus.MustNewConstMetric(pc.cgroupPressureStallDesc, prometheus.CounterValue, line.TotalSeconds, resource, kind)
+			}
+		}
+	}
+
 	for site, lm := range m.Laravel {
 		if lm == nil {
 			continue
 		}
 
+		if lm.Horizon != nil {
+			pc.collectHorizon(ch, site, lm.Horizon)
+		}
+		if lm.Workers != nil {
+			pc.collectWorkers(ch, site, lm.Workers)
+		}
+		if lm.Schedule != nil {
+			pc.collectSchedule(ch, site, lm.Schedule, time.Now())
+		}
+		if lm.Logs != nil {
+			pc.collectLogs(ch, site, lm.Logs)
+		}
+		if lm.Exceptions != nil {
+			pc.collectExceptions(ch, site, lm.Exceptions)
+		}
+
 		info := lm
 
 		if lm.Info != nil {
@@ -382,130 +882,400 @@ func (pc *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 
 	if m.Fpm == nil {
-		ch <- prometheus.MustNewConstMetric(pc.upDesc, prometheus.GaugeValue, 0, "unknown", "unknown")
+		ch <- prometheus.MustNewConstMetric(pc.upDesc, prometheus.GaugeValue, 0, "unknown", "unknown", "", "")
 		return
 	}
 	if len(m.Fpm) == 0 {
-		ch <- prometheus.MustNewConstMetric(pc.upDesc, prometheus.GaugeValue, 0, "none", "none")
+		ch <- prometheus.MustNewConstMetric(pc.upDesc, prometheus.GaugeValue, 0, "none", "none", "", "")
 		return
 	}
-	for socket, pools := range m.Fpm {
+	for _, pools := range m.Fpm {
+		socket := pools.Identity.Socket
 		if socket == "" {
 			socket = "unknown"
 		}
+		fpmConfig, phpVersion := pools.Identity.ConfigPath, pools.Identity.PHPVersion
 
 		for poolName, pool := range pools.Pools {
+			if pools.Identity.Pool != "" {
+				poolName = pools.Identity.Pool
+			}
 			up := 1.0
 
-			ch <- prometheus.MustNewConstMetric(pc.upDesc, prometheus.GaugeValue, up, poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.acceptedConnectionsDesc, prometheus.CounterValue, float64(pool.AcceptedConnections), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.startSinceDesc, prometheus.GaugeValue, float64(pool.StartSince), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.listenQueueDesc, prometheus.GaugeValue, float64(pool.ListenQueue), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.maxListenQueueDesc, prometheus.GaugeValue, float64(pool.MaxListenQueue), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.listenQueueLengthDesc, prometheus.GaugeValue, float64(pool.ListenQueueLength), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.idleProcessesDesc, prometheus.GaugeValue, float64(pool.IdleProcesses), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.activeProcessesDesc, prometheus.GaugeValue, float64(pool.ActiveProcesses), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.totalProcessesDesc, prometheus.GaugeValue, float64(pool.TotalProcesses), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.maxActiveProcessesDesc, prometheus.GaugeValue, float64(pool.MaxActiveProcesses), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.maxChildrenReachedDesc, prometheus.CounterValue, float64(pool.MaxChildrenReached), poolName, socket)
-			ch <- prometheus.MustNewConstMetric(pc.slowRequestsDesc, prometheus.CounterValue, float64(pool.SlowRequests), poolName, socket)
+			ch <- prometheus.MustNewConstMetric(pc.upDesc, prometheus.GaugeValue, up, poolName, socket, fpmConfig, phpVersion)
+
+			// Pool identity with the user-defined labels, for joins on pool and socket
+			infoNames := []string{"pool", "socket", "fpm_config", "php_version"}
+			infoValues := []string{poolName, socket, fpmConfig, phpVersion}
+			for _, name := range customLabelNames(pools.Identity.Labels) {
+				infoNames = append(infoNames, name)
+				infoValues = append(infoValues, pools.Identity.Labels[name])
+			}
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc("phpfpm_pool_info", "Identity of the pool with its FPM master config, PHP version and configured labels.", infoNames, nil),
+				prometheus.GaugeValue, 1, infoValues...)
+			ch <- prometheus.MustNewConstMetric(pc.acceptedConnectionsDesc, prometheus.CounterValue, float64(pool.AcceptedConnections), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.startSinceDesc, prometheus.GaugeValue, float64(pool.StartSince), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.listenQueueDesc, prometheus.GaugeValue, float64(pool.ListenQueue), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.maxListenQueueDesc, prometheus.GaugeValue, float64(pool.MaxListenQueue), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.listenQueueLengthDesc, prometheus.GaugeValue, float64(pool.ListenQueueLength), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.idleProcessesDesc, prometheus.GaugeValue, float64(pool.IdleProcesses), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.activeProcessesDesc, prometheus.GaugeValue, float64(pool.ActiveProcesses), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.totalProcessesDesc, prometheus.GaugeValue, float64(pool.TotalProcesses), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.maxActiveProcessesDesc, prometheus.GaugeValue, float64(pool.MaxActiveProcesses), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.maxChildrenReachedDesc, prometheus.CounterValue, float64(pool.MaxChildrenReached), poolName, socket, fpmConfig, phpVersion)
+			ch <- prometheus.MustNewConstMetric(pc.slowRequestsDesc, prometheus.CounterValue, float64(pool.SlowRequests), poolName, socket, fpmConfig, phpVersion)
 
 			// --- New pool metrics ---
-			ch <- prometheus.MustNewConstMetric(pc.memoryPeakDesc, prometheus.GaugeValue, float64(pool.MemoryPeak), poolName, socket)
+			ch <- prometheus.MustNewConstMetric(pc.memoryPeakDesc, prometheus.GaugeValue, float64(pool.MemoryPeak), poolName, socket, fpmConfig, phpVersion)
 			if pool.ProcessesCpu != nil {
-				ch <- prometheus.MustNewConstMetric(pc.processesCpuDesc, prometheus.GaugeValue, *pool.ProcessesCpu, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.processesCpuDesc, prometheus.GaugeValue, *pool.ProcessesCpu, poolName, socket, fpmConfig, phpVersion)
 			}
 			if pool.ProcessesMemory != nil {
-				ch <- prometheus.MustNewConstMetric(pc.processesMemoryDesc, prometheus.GaugeValue, *pool.ProcessesMemory, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.processesMemoryDesc, prometheus.GaugeValue, *pool.ProcessesMemory, poolName, socket, fpmConfig, phpVersion)
+			}
+
+			// Ping probe metrics
+			if pool.Ping != nil {
+				ch <- prometheus.MustNewConstMetric(pc.pingUpDesc, prometheus.GaugeValue, boolToFloat(pool.Ping.Up), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstHistogram(pc.pingLatencyDesc, pool.Ping.Count, pool.Ping.Sum, pool.Ping.Buckets, poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.pingFailuresDesc, prometheus.CounterValue, float64(pool.Ping.Failures), poolName, socket, fpmConfig, phpVersion)
+			}
+
+			// Worker lifecycle metrics
+			if lc := pool.Lifecycle; lc != nil {
+				ch <- prometheus.MustNewConstHistogram(pc.workerAgeDesc, lc.AgeCount, lc.AgeSum, lc.AgeBuckets, poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.workersNearRecycleDesc, prometheus.GaugeValue, float64(lc.NearRecycle), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.workersStuckDesc, prometheus.GaugeValue, float64(lc.Stuck), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.workersOverTerminateDesc, prometheus.GaugeValue, float64(lc.OverTerminateTimeout), poolName, socket, fpmConfig, phpVersion)
+			}
+
+			// Request sampler metrics, bounded to the top N per window
+			for _, window := range pool.HotEndpoints {
+				ch <- prometheus.MustNewConstMetric(pc.samplerBusyDesc, prometheus.GaugeValue, window.BusySeconds, poolName, socket, fpmConfig, phpVersion, window.Window)
+				for _, endpoint := range window.Top {
+					ch <- prometheus.MustNewConstMetric(pc.endpointWorkerSecondsDesc, prometheus.GaugeValue, endpoint.WorkerSeconds,
+						poolName, socket, fpmConfig, phpVersion, window.Window, endpoint.Method, endpoint.URI, endpoint.Script)
+				}
+			}
+
+			// Pool resource attribution metrics
+			if res := pool.Resources; res != nil {
+				ch <- prometheus.MustNewConstMetric(pc.poolResourceProcessesDesc, prometheus.GaugeValue, float64(res.Processes), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.poolResourceCPUDesc, prometheus.CounterValue, res.CPUSeconds, poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.poolResourceMemoryDesc, prometheus.GaugeValue, float64(res.RSSBytes), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.poolResourceReadBytesDesc, prometheus.CounterValue, float64(res.ReadBytes), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.poolResourceWriteBytesDesc, prometheus.CounterValue, float64(res.WriteBytes), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.poolResourceCtxSwitchesDesc, prometheus.CounterValue, float64(res.VoluntaryCtxSwitches), poolName, socket, fpmConfig, phpVersion, "voluntary")
+				ch <- prometheus.MustNewConstMetric(pc.poolResourceCtxSwitchesDesc, prometheus.CounterValue, float64(res.InvoluntaryCtxSwitches), poolName, socket, fpmConfig, phpVersion, "involuntary")
+			}
+
+			// Memory leak detection metrics
+			if leaks := pool.MemoryLeaks; leaks != nil {
+				ch <- prometheus.MustNewConstMetric(pc.leakSuspectedWorkersDesc, prometheus.GaugeValue, float64(leaks.SuspectedWorkers), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.leakMaxSlopeDesc, prometheus.GaugeValue, leaks.MaxSlope, poolName, socket, fpmConfig, phpVersion)
+				for _, script := range leaks.TopScripts {
+					ch <- prometheus.MustNewConstMetric(pc.leakScriptGrowthDesc, prometheus.GaugeValue, script.GrowthBytes, poolName, socket, fpmConfig, phpVersion, script.Script)
+				}
+			}
+
+			// Restart tracking metrics
+			if pool.Restarts != nil {
+				ch <- prometheus.MustNewConstMetric(pc.restartsDesc, prometheus.CounterValue, float64(pool.Restarts.Restarts), poolName, socket, fpmConfig, phpVersion)
+				if !pool.Restarts.LastRestart.IsZero() {
+					ch <- prometheus.MustNewConstMetric(pc.lastRestartDesc, prometheus.GaugeValue, float64(pool.Restarts.LastRestart.Unix()), poolName, socket, fpmConfig, phpVersion)
+				}
+			}
+
+			// Kernel listen socket metrics
+			if pool.Socket != nil {
+				ch <- prometheus.MustNewConstMetric(pc.socketAcceptQueueDesc, prometheus.GaugeValue, float64(pool.Socket.AcceptQueue), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.socketBacklogLimitDesc, prometheus.GaugeValue, float64(pool.Socket.BacklogLimit), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.socketEstablishedDesc, prometheus.GaugeValue, float64(pool.Socket.Established), poolName, socket, fpmConfig, phpVersion)
+			}
+
+			// Native FPM OpenMetrics series, relabelled with pool and socket
+			for _, native := range pool.NativeMetrics {
+				names := []string{"pool", "socket", "fpm_config", "php_version"}
+				values := []string{poolName, socket, fpmConfig, phpVersion}
+				for _, name := range native.LabelNames() {
+					if name == "pool" || name == "socket" || name == "fpm_config" || name == "php_version" {
+						continue
+					}
+					names = append(names, name)
+					values = append(values, native.Labels[name])
+				}
+				metricName := "phpfpm_native_" + strings.TrimPrefix(native.Name, "phpfpm_")
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(metricName, "Native PHP-FPM OpenMetrics series "+native.Name+".", names, nil),
+					nativeValueType(native.Type), native.Value, values...)
 			}
 
 			// --- Per-process metrics ---
 			for _, proc := range pool.Processes {
-				labels := []string{poolName, socket, strconv.Itoa(proc.PID)}
+				labels := []string{poolName, socket, fpmConfig, phpVersion, strconv.Itoa(proc.PID)}
 
 				// Process state as labeled metric (e.g. Idle, Running)
 				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc("phpfpm_process_state", "The state of the process (Idle, Running, ...).", []string{"pool", "socket", "pid", "state"}, nil),
-					prometheus.GaugeValue, 1, poolName, socket, strconv.Itoa(proc.PID), proc.State)
+					prometheus.NewDesc("phpfpm_process_state", "The state of the process (Idle, Running, ...).", []string{"pool", "socket", "fpm_config", "php_version", "pid", "state"}, nil),
+					prometheus.GaugeValue, 1, poolName, socket, fpmConfig, phpVersion, strconv.Itoa(proc.PID), proc.State)
 
 				// Process request count
 				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc("phpfpm_process_requests", "The number of requests the process has served.", []string{"pool", "socket", "pid"}, nil),
+					prometheus.NewDesc("phpfpm_process_requests", "The number of requests the process has served.", []string{"pool", "socket", "fpm_config", "php_version", "pid"}, nil),
 					prometheus.CounterValue, float64(proc.Requests), labels...)
 
 				// Last request duration
 				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc("phpfpm_process_request_duration", "The duration in microseconds of the last request.", []string{"pool", "socket", "pid"}, nil),
+					prometheus.NewDesc("phpfpm_process_request_duration", "The duration in microseconds of the last request.", []string{"pool", "socket", "fpm_config", "php_version", "pid"}, nil),
 					prometheus.GaugeValue, float64(proc.RequestDuration), labels...)
 
 				// Last request memory
 				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc("phpfpm_process_last_request_memory", "The max amount of memory the last request consumed.", []string{"pool", "socket", "pid"}, nil),
+					prometheus.NewDesc("phpfpm_process_last_request_memory", "The max amount of memory the last request consumed.", []string{"pool", "socket", "fpm_config", "php_version", "pid"}, nil),
 					prometheus.GaugeValue, float64(proc.LastRequestMemory), labels...)
 
 				// Last request CPU
 				ch <- prometheus.MustNewConstMetric(
-					prometheus.NewDesc("phpfpm_process_last_request_cpu", "The %cpu the last request consumed.", []string{"pool", "socket", "pid"}, nil),
+					prometheus.NewDesc("phpfpm_process_last_request_cpu", "The %cpu the last request consumed.", []string{"pool", "socket", "fpm_config", "php_version", "pid"}, nil),
 					prometheus.GaugeValue, proc.LastRequestCPU, labels...)
 			}
 
 			// Opcache metrics
-			ch <- prometheus.MustNewConstMetric(pc.opcacheEnabledDesc, prometheus.GaugeValue, boolToFloat(pool.OpcacheStatus.Enabled), poolName, socket)
+			ch <- prometheus.MustNewConstMetric(pc.opcacheEnabledDesc, prometheus.GaugeValue, boolToFloat(pool.OpcacheStatus.Enabled), poolName, socket, fpmConfig, phpVersion)
 			if pool.OpcacheStatus.Enabled {
-				ch <- prometheus.MustNewConstMetric(pc.opcacheUsedMemoryDesc, prometheus.GaugeValue, float64(pool.OpcacheStatus.MemoryUsage.UsedMemory), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheFreeMemoryDesc, prometheus.GaugeValue, float64(pool.OpcacheStatus.MemoryUsage.FreeMemory), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheWastedMemoryDesc, prometheus.GaugeValue, float64(pool.OpcacheStatus.MemoryUsage.WastedMemory), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheWastedPercentDesc, prometheus.GaugeValue, pool.OpcacheStatus.MemoryUsage.CurrentWastedPct, poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheCachedScriptsDesc, prometheus.GaugeValue, float64(pool.OpcacheStatus.Statistics.NumCachedScripts), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheHitsDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.Hits), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheMissesDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.Misses), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheBlacklistMissesDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.BlacklistMisses), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheOomRestartsDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.OomRestarts), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheHashRestartsDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.HashRestarts), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheManualRestartsDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.ManualRestarts), poolName, socket)
-				ch <- prometheus.MustNewConstMetric(pc.opcacheHitRateDesc, prometheus.GaugeValue, pool.OpcacheStatus.Statistics.HitRate, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheUsedMemoryDesc, prometheus.GaugeValue, float64(pool.OpcacheStatus.MemoryUsage.UsedMemory), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheFreeMemoryDesc, prometheus.GaugeValue, float64(pool.OpcacheStatus.MemoryUsage.FreeMemory), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheWastedMemoryDesc, prometheus.GaugeValue, float64(pool.OpcacheStatus.MemoryUsage.WastedMemory), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheWastedPercentDesc, prometheus.GaugeValue, pool.OpcacheStatus.MemoryUsage.CurrentWastedPct, poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheCachedScriptsDesc, prometheus.GaugeValue, float64(pool.OpcacheStatus.Statistics.NumCachedScripts), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheHitsDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.Hits), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheMissesDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.Misses), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheBlacklistMissesDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.BlacklistMisses), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheOomRestartsDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.OomRestarts), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheHashRestartsDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.HashRestarts), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheManualRestartsDesc, prometheus.CounterValue, float64(pool.OpcacheStatus.Statistics.ManualRestarts), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.opcacheHitRateDesc, prometheus.GaugeValue, pool.OpcacheStatus.Statistics.HitRate, poolName, socket, fpmConfig, phpVersion)
+			}
+
+			// APCu metrics
+			ch <- prometheus.MustNewConstMetric(pc.apcuEnabledDesc, prometheus.GaugeValue, boolToFloat(pool.ApcuStatus.Enabled), poolName, socket, fpmConfig, phpVersion)
+			if pool.ApcuStatus.Enabled {
+				ch <- prometheus.MustNewConstMetric(pc.apcuHitsDesc, prometheus.CounterValue, float64(pool.ApcuStatus.Cache.NumHits), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.apcuMissesDesc, prometheus.CounterValue, float64(pool.ApcuStatus.Cache.NumMisses), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.apcuInsertsDesc, prometheus.CounterValue, float64(pool.ApcuStatus.Cache.NumInserts), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.apcuExpungesDesc, prometheus.CounterValue, float64(pool.ApcuStatus.Cache.Expunges), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.apcuEntriesDesc, prometheus.GaugeValue, float64(pool.ApcuStatus.Cache.NumEntries), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.apcuMemorySizeDesc, prometheus.GaugeValue, float64(pool.ApcuStatus.MemorySize()), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.apcuMemoryAvailableDesc, prometheus.GaugeValue, float64(pool.ApcuStatus.Memory.AvailMem), poolName, socket, fpmConfig, phpVersion)
+				ch <- prometheus.MustNewConstMetric(pc.apcuFragmentationDesc, prometheus.GaugeValue, pool.ApcuStatus.Memory.Fragmentation, poolName, socket, fpmConfig, phpVersion)
 			}
 
 			// Pool config metrics
 			cfg := pool.Config
 
 			if v, ok := parseConfigValue(cfg["pm.max_children"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.pmMaxChildrenConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.pmMaxChildrenConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["pm.start_servers"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.pmStartServersConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.pmStartServersConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["pm.min_spare_servers"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.pmMinSpareServersConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.pmMinSpareServersConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["pm.max_spare_servers"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.pmMaxSpareServersConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.pmMaxSpareServersConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["pm.max_requests"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.pmMaxRequestsConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.pmMaxRequestsConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["pm.max_spawn_rate"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.pmMaxSpawnRateConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.pmMaxSpawnRateConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["pm.process_idle_timeout"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.pmProcessIdleTimeoutConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.pmProcessIdleTimeoutConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["request_slowlog_timeout"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.requestSlowlogTimeoutConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.requestSlowlogTimeoutConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["request_terminate_timeout"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.requestTerminateTimeoutConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.requestTerminateTimeoutConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["rlimit_core"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.rlimitCoreConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.rlimitCoreConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 			if v, ok := parseConfigValue(cfg["rlimit_files"]); ok {
-				ch <- prometheus.MustNewConstMetric(pc.rlimitFilesConfigDesc, prometheus.GaugeValue, v, poolName, socket)
+				ch <- prometheus.MustNewConstMetric(pc.rlimitFilesConfigDesc, prometheus.GaugeValue, v, poolName, socket, fpmConfig, phpVersion)
 			}
 		}
 	}
 }
 
+// customLabelNames returns the sorted user-defined label names that are valid
+// Prometheus label names and do not shadow the pool identity labels.
+func customLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		switch name {
+		case "pool", "socket", "fpm_config", "php_version":
+			continue
+		}
+		if strings.HasPrefix(name, "__") || !labelNamePattern.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (pc *PrometheusCollector) collectHorizon(ch chan<- prometheus.Metric, site string, h *laravel.HorizonMetrics) {
+	for _, status := range []string{laravel.HorizonRunning, laravel.HorizonPaused, laravel.HorizonInactive} {
+		ch <- prometheus.MustNewConstMetric(pc.horizonStatusDesc, prometheus.GaugeValue, boolToFloat(h.Status == status), site, status)
+	}
+	for _, s := range h.Supervisors {
+		for key, n := range s.Processes {
+			conn, queue, _ := strings.Cut(key, ":")
+			ch <- prometheus.MustNewConstMetric(pc.horizonSupervisorProcessesDesc, prometheus.GaugeValue, float64(n), site, s.Name, conn, queue)
+		}
+	}
+	for _, q := range h.Queues {
+		ch <- prometheus.MustNewConstMetric(pc.horizonQueueProcessesDesc, prometheus.GaugeValue, float64(q.Processes), site, q.Connection, q.Queue)
+		if q.Length != nil {
+			ch <- prometheus.MustNewConstMetric(pc.horizonQueueLengthDesc, prometheus.GaugeValue, float64(*q.Length), site, q.Connection, q.Queue)
+		}
+		if q.WaitSeconds != nil {
+			ch <- prometheus.MustNewConstMetric(pc.horizonQueueWaitDesc, prometheus.GaugeValue, *q.WaitSeconds, site, q.Connection, q.Queue)
+		}
+	}
+
+	for kind, n := range map[string]int{
+		"recent":        h.Jobs.Recent,
+		"pending":       h.Jobs.Pending,
+		"completed":     h.Jobs.Completed,
+		"failed":        h.Jobs.Failed,
+		"recent_failed": h.Jobs.RecentFailed,
+	} {
+		ch <- prometheus.MustNewConstMetric(pc.horizonJobsDesc, prometheus.GaugeValue, float64(n), site, kind)
+	}
+	if h.JobsPerMinute != nil {
+		ch <- prometheus.MustNewConstMetric(pc.horizonJobsPerMinuteDesc, prometheus.GaugeValue, *h.JobsPerMinute, site)
+	}
+
+	for _, s := range h.JobStats {
+		ch <- prometheus.MustNewConstMetric(pc.horizonJobThroughputDesc, prometheus.GaugeValue, s.Throughput, site, s.Name)
+		ch <- prometheus.MustNewConstMetric(pc.horizonJobRuntimeDesc, prometheus.GaugeValue, s.RuntimeSeconds, site, s.Name)
+	}
+	for _, s := range h.QueueStats {
+		ch <- prometheus.MustNewConstMetric(pc.horizonQueueThroughputDesc, prometheus.GaugeValue, s.Throughput, site, s.Name)
+		ch <- prometheus.MustNewConstMetric(pc.horizonQueueRuntimeDesc, prometheus.GaugeValue, s.RuntimeSeconds, site, s.Name)
+	}
+}
+
+func (pc *PrometheusCollector) collectWorkers(ch chan<- prometheus.Metric, site string, w *laravel.QueueWorkers) {
+	for _, g := range w.Queues {
+		ch <- prometheus.MustNewConstMetric(pc.queueWorkersDesc, prometheus.GaugeValue, float64(g.Workers), site, g.Connection, g.Queue)
+		ch <- prometheus.MustNewConstMetric(pc.queueWorkerRestartsDesc, prometheus.CounterValue, float64(g.Restarts), site, g.Connection, g.Queue)
+		if g.Workers == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(pc.queueWorkerMemoryDesc, prometheus.GaugeValue, float64(g.RSSBytes), site, g.Connection, g.Queue)
+		ch <- prometheus.MustNewConstMetric(pc.queueWorkerMaxMemoryDesc, prometheus.GaugeValue, float64(g.MaxRSSBytes), site, g.Connection, g.Queue)
+		ch <- prometheus.MustNewConstMetric(pc.queueWorkerMemoryLimitDesc, prometheus.GaugeValue, float64(g.MemoryLimitBytes), site, g.Connection, g.Queue)
+		ch <- prometheus.MustNewConstMetric(pc.queueWorkerMinUptimeDesc, prometheus.GaugeValue, g.MinUptimeSeconds, site, g.Connection, g.Queue)
+		ch <- prometheus.MustNewConstMetric(pc.queueWorkerMaxUptimeDesc, prometheus.GaugeValue, g.MaxUptimeSeconds, site, g.Connection, g.Queue)
+	}
+}
+
+func (pc *PrometheusCollector) collectSchedule(ch chan<- prometheus.Metric, site string, s *laravel.ScheduleMetrics, now time.Time) {
+	ch <- prometheus.MustNewConstMetric(pc.scheduleTasksDesc, prometheus.GaugeValue, float64(len(s.Tasks)), site)
+	ch <- prometheus.MustNewConstMetric(pc.scheduleMissedRunsDesc, prometheus.GaugeValue, float64(s.MissedRuns), site)
+	if s.Heartbeat != nil && s.Firing != nil {
+		ch <- prometheus.MustNewConstMetric(pc.scheduleFiringDesc, prometheus.GaugeValue, boolToFloat(*s.Firing), site)
+		ch <- prometheus.MustNewConstMetric(pc.scheduleLastRunAgeDesc, prometheus.GaugeValue, now.Sub(*s.Heartbeat).Seconds(), site)
+	}
+
+	for _, task := range s.Tasks {
+		if task.LastRun != nil {
+			ch <- prometheus.MustNewConstMetric(pc.scheduleTaskLastRunAgeDesc, prometheus.GaugeValue, now.Sub(*task.LastRun).Seconds(), site, task.Name)
+		}
+		if task.MissedRuns != nil {
+			ch <- prometheus.MustNewConstMetric(pc.scheduleTaskMissedRunsDesc, prometheus.GaugeValue, float64(*task.MissedRuns), site, task.Name)
+		}
+		if task.NextDue != nil {
+			ch <- prometheus.MustNewConstMetric(pc.scheduleTaskNextDueDesc, prometheus.GaugeValue, task.NextDue.Sub(now).Seconds(), site, task.Name)
+		}
+	}
+}
+
+func (pc *PrometheusCollector) collectLogs(ch chan<- prometheus.Metric, site string, l *laravel.LogMetrics) {
+	for _, e := range l.Entries {
+		ch <- prometheus.MustNewConstMetric(pc.logEntriesDesc, prometheus.CounterValue, float64(e.Count), site, e.Channel, e.Level)
+	}
+	for class, n := range l.Exceptions {
+		ch <- prometheus.MustNewConstMetric(pc.logExceptionsDesc, prometheus.CounterValue, float64(n), site, class)
+	}
+}
+
+func (pc *PrometheusCollector) collectExceptions(ch chan<- prometheus.Metric, site string, e *laravel.ExceptionMetrics) {
+	ch <- prometheus.MustNewConstMetric(pc.exceptionGroupsDesc, prometheus.GaugeValue, float64(len(e.Groups)), site)
+	ch <- prometheus.MustNewConstMetric(pc.exceptionNewGroupsDesc, prometheus.GaugeValue, float64(e.New), site)
+}
+
+// constLabels returns the pod metadata labels added to every series. Labels
+// that a pool already sets itself would make the registry reject the scrape,
+// so the pool's value wins and the constant label is dropped.
+func constLabels(cfg *config.Config, meta *server.KubernetesMetadata) prometheus.Labels {
+	labels := prometheus.Labels(meta.ConstLabels(cfg.Kubernetes.ConstLabels))
+	for _, pool := range cfg.PHPFpm.Pools {
+		for name := range pool.Labels {
+			if _, ok := labels[name]; ok {
+				warnShadowedLabel(name, pool.Name)
+				delete(labels, name)
+			}
+		}
+	}
+	return labels
+}
+
+// warnShadowedLabel logs a constant label dropped for a pool label once, the
+// labels are built on every scrape.
+func warnShadowedLabel(name, pool string) {
+	shadowedLabelsLock.Lock()
+	defer shadowedLabelsLock.Unlock()
+
+	if shadowedLabels[name] {
+		return
+	}
+	shadowedLabels[name] = true
+	logging.L().Warn("ElasticPHP-agent Constant label shadowed by pool label", slog.String("label", name), slog.String("pool", pool))
+}
+
+// podLabelsGatherer gathers the collector with the pod metadata labels read
+// on every scrape, the kubelet updates labels and annotations of a running
+// pod in place.
+func podLabelsGatherer(cfg *config.Config, collector prometheus.Collector) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		registry := prometheus.NewRegistry()
+		labels := constLabels(cfg, detectKubernetes(cfg.Kubernetes.PodInfoPath))
+		if err := prometheus.WrapRegistererWith(labels, registry).Register(collector); err != nil {
+			return nil, err
+		}
+		return registry.Gather()
+	})
+}
+
+func nativeValueType(metricType string) prometheus.ValueType {
+	switch metricType {
+	case "counter":
+		return prometheus.CounterValue
+	case "gauge":
+		return prometheus.GaugeValue
+	default:
+		return prometheus.UntypedValue
+	}
+}
+
 func boolToFloat(b bool) float64 {
 	if b {
 		return 1
@@ -516,11 +1286,17 @@ func boolToFloat(b bool) float64 {
 func StartPrometheusServer(cfg *config.Config) {
 	mux := http.NewServeMux()
 
-	registry := prometheus.NewRegistry()
+	var gatherer prometheus.Gatherer
 	collector := NewPrometheusCollector(cfg)
-	registry.MustRegister(collector)
+	if cfg.Kubernetes.Enabled && len(cfg.Kubernetes.ConstLabels) > 0 {
+		gatherer = podLabelsGatherer(cfg, collector)
+	} else {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(collector)
+		gatherer = registry
+	}
 
-	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
 
 	if cfg.Monitor.EnableJson {
 		mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
@@ -538,13 +1314,13 @@ func StartPrometheusServer(cfg *config.Config) {
 		})
 	}
 
-	server := &http.Server{
+	srv := &http.Server{
 		Addr:    cfg.Monitor.ListenAddr,
 		Handler: mux,
 	}
 
 	logging.L().Debug("ElasticPHP-agent Prometheus metrics server listening", slog.Any("addr", cfg.Monitor.ListenAddr))
-	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logging.L().Error("ElasticPHP-agent Failed to start Prometheus server", slog.Any("err", err))
 	}
 }
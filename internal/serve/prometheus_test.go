@@ -2,14 +2,19 @@ package serve
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"net/http/fcgi"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/elasticphphq/agent/internal/config"
+	"github.com/elasticphphq/agent/internal/laravel"
 	"github.com/elasticphphq/agent/internal/logging"
+	"github.com/elasticphphq/agent/internal/server"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	dto "github.com/prometheus/client_model/go"
@@ -50,7 +55,7 @@ func TestPrometheusCollector_Describe(t *testing.T) {
 	cfg := &config.Config{}
 	collector := NewPrometheusCollector(cfg)
 
-	ch := make(chan *prometheus.Desc, 100)
+	ch := make(chan *prometheus.Desc, 200)
 	collector.Describe(ch)
 	close(ch)
 
@@ -316,7 +321,7 @@ func TestPrometheusCollector_MetricNames(t *testing.T) {
 	}
 
 	// Get all descriptors
-	ch := make(chan *prometheus.Desc, 100)
+	ch := make(chan *prometheus.Desc, 200)
 	collector.Describe(ch)
 	close(ch)
 
@@ -588,6 +593,15 @@ func TestPrometheusCollector_Collect_SystemMetrics(t *testing.T) {
 				t.Errorf("Expected metric %s to have values", name)
 			}
 		}
+		if name == "system_info" {
+			labels := map[string]string{}
+			for _, pair := range mf.GetMetric()[0].GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if _, ok := labels["container_runtime"]; !ok || labels["hostname"] == "" {
+				t.Errorf("Expected runtime environment labels on system_info, got %v", labels)
+			}
+		}
 	}
 
 	if !foundSystemMetrics {
@@ -634,3 +648,373 @@ func TestPrometheusCollector_Collect_ErrorRecovery(t *testing.T) {
 		}
 	}
 }
+
+func TestPrometheusCollector_Collect_PoolIdentity(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go fcgi.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pool":"www","processes":[]}`))
+	}))
+
+	socket := "tcp://" + listener.Addr().String()
+	cfg := &config.Config{
+		PHPFpm: config.FPMConfig{
+			Enabled: true,
+			Pools: []config.FPMPoolConfig{{
+				Name:         "checkout",
+				Labels:       map[string]string{"team": "payments", "bad-name": "x", "pool": "shadowed"},
+				Socket:       socket,
+				StatusSocket: socket,
+				StatusPath:   "/status",
+				ConfigPath:   "/etc/php/8.3/fpm/php-fpm.conf",
+			}},
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewPrometheusCollector(cfg))
+
+	metricFamilies, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	labelsOf := func(m *dto.Metric) map[string]string {
+		labels := map[string]string{}
+		for _, pair := range m.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		return labels
+	}
+
+	found := map[string]map[string]string{}
+	for _, mf := range metricFamilies {
+		if mf.GetName() == "phpfpm_up" || mf.GetName() == "phpfpm_pool_info" {
+			found[mf.GetName()] = labelsOf(mf.GetMetric()[0])
+		}
+	}
+
+	up, ok := found["phpfpm_up"]
+	if !ok {
+		t.Fatalf("Expected phpfpm_up to be collected")
+	}
+	if up["pool"] != "checkout" || up["socket"] != socket || up["fpm_config"] != "/etc/php/8.3/fpm/php-fpm.conf" {
+		t.Errorf("Expected identity labels on phpfpm_up, got %v", up)
+	}
+	if _, ok := up["php_version"]; !ok {
+		t.Errorf("Expected a php_version label on phpfpm_up, got %v", up)
+	}
+
+	info, ok := found["phpfpm_pool_info"]
+	if !ok {
+		t.Fatalf("Expected phpfpm_pool_info to be collected")
+	}
+	if info["team"] != "payments" || info["pool"] != "checkout" {
+		t.Errorf("Expected configured labels on phpfpm_pool_info, got %v", info)
+	}
+	if _, ok := info["bad-name"]; ok {
+		t.Errorf("Expected invalid label names to be skipped, got %v", info)
+	}
+}
+
+func TestCustomLabelNames(t *testing.T) {
+	names := customLabelNames(map[string]string{
+		"team":        "payments",
+		"env":         "prod",
+		"socket":      "shadowed",
+		"php_version": "shadowed",
+		"__reserved":  "x",
+		"1st":         "x",
+		"bad-name":    "x",
+	})
+
+	if strings.Join(names, ",") != "env,team" {
+		t.Errorf("Expected [env team], got %v", names)
+	}
+}
+
+func TestConstLabels(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	cfg := &config.Config{
+		PHPFpm: config.FPMConfig{
+			Enabled: false,
+			Pools:   []config.FPMPoolConfig{{Name: "www", Labels: map[string]string{"namespace": "legacy"}}},
+		},
+		Kubernetes: config.KubernetesConfig{Enabled: true, ConstLabels: []string{"pod", "namespace", "label:app"}},
+	}
+	meta := &server.KubernetesMetadata{PodName: "shop-web-0", Namespace: "shop-prod", Labels: map[string]string{"app": "shop"}}
+
+	labels := constLabels(cfg, meta)
+	if len(labels) != 2 || labels["pod"] != "shop-web-0" || labels["label_app"] != "shop" {
+		t.Errorf("Expected pod and app labels without the namespace shadowed by a pool label, got %v", labels)
+	}
+
+	registry := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(labels, registry).MustRegister(NewPrometheusCollector(cfg))
+	metricFamilies, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	if len(metricFamilies) == 0 {
+		t.Fatalf("Expected metrics to be collected")
+	}
+	for _, mf := range metricFamilies {
+		for _, m := range mf.GetMetric() {
+			found := false
+			for _, pair := range m.GetLabel() {
+				if pair.GetName() == "pod" && pair.GetValue() == "shop-web-0" {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected the pod label on %s", mf.GetName())
+			}
+		}
+	}
+}
+
+func TestPodLabelsGatherer(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	original := detectKubernetes
+	t.Cleanup(func() { detectKubernetes = original })
+	meta := &server.KubernetesMetadata{PodName: "shop-web-0", Labels: map[string]string{"version": "v1"}}
+	detectKubernetes = func(string) *server.KubernetesMetadata { return meta }
+
+	cfg := &config.Config{
+		PHPFpm:     config.FPMConfig{Enabled: false},
+		Kubernetes: config.KubernetesConfig{Enabled: true, ConstLabels: []string{"pod", "label:version"}},
+	}
+	gatherer := podLabelsGatherer(cfg, NewPrometheusCollector(cfg))
+
+	version := func() string {
+		t.Helper()
+		metricFamilies, err := gatherer.Gather()
+		if err != nil || len(metricFamilies) == 0 {
+			t.Fatalf("Failed to gather metrics: %v", err)
+		}
+		for _, pair := range metricFamilies[0].GetMetric()[0].GetLabel() {
+			if pair.GetName() == "label_version" {
+				return pair.GetValue()
+			}
+		}
+		return ""
+	}
+
+	if v := version(); v != "v1" {
+		t.Errorf("Expected label_version v1, got %q", v)
+	}
+	// The kubelet updated the pod labels in place
+	meta = &server.KubernetesMetadata{PodName: "shop-web-0", Labels: map[string]string{"version": "v2"}}
+	if v := version(); v != "v2" {
+		t.Errorf("Expected the updated label_version v2, got %q", v)
+	}
+}
+
+func TestPrometheusCollector_Collect_HostMetrics(t *testing.T) {
+	logging.Init(config.LoggingBlock{Level: "error", Format: "text"})
+
+	gather := func(enabled bool) map[string]bool {
+		cfg := &config.Config{
+			PHPFpm: config.FPMConfig{Enabled: false},
+			Host:   config.HostConfig{Enabled: enabled, Paths: []string{t.TempDir()}},
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(NewPrometheusCollector(cfg))
+
+		metricFamilies, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("Failed to gather metrics: %v", err)
+		}
+
+		names := map[string]bool{}
+		for _, mf := range metricFamilies {
+			names[mf.GetName()] = true
+		}
+		return names
+	}
+
+	names := gather(true)
+	for _, name := range []string{"system_load1", "system_memory_total_bytes", "system_cpu_seconds_total", "system_disk_total_bytes"} {
+		if !names[name] {
+			t.Errorf("Expected %s with the host collector enabled", name)
+		}
+	}
+
+	if names := gather(false); names["system_load1"] {
+		t.Errorf("Expected no host metrics with the host collector disabled")
+	}
+}
+
+func TestPrometheusCollector_CollectHorizon(t *testing.T) {
+	length, wait, perMinute := 6, 1.5, 20.0
+	h := &laravel.HorizonMetrics{
+		Status: laravel.HorizonPaused,
+		Supervisors: []laravel.HorizonSupervisor{
+			{Name: "host-1:supervisor-1", Processes: map[string]int{"redis:default": 3}},
+		},
+		Queues: []laravel.HorizonQueue{
+			{Connection: "redis", Queue: "default", Processes: 3, Length: &length, WaitSeconds: &wait},
+		},
+		Jobs:          laravel.HorizonJobCounts{Recent: 10, Failed: 2},
+		JobsPerMinute: &perMinute,
+		JobStats:      []laravel.HorizonStat{{Name: `App\Jobs\Send`, Throughput: 12, RuntimeSeconds: 0.25}},
+	}
+
+	pc := NewPrometheusCollector(&config.Config{})
+	values := collectedValues(t, func(ch chan<- prometheus.Metric) { pc.collectHorizon(ch, "App", h) })
+
+	expected := map[string]float64{
+		"laravel_horizon_status,paused":                                          1,
+		"laravel_horizon_status,running":                                         0,
+		"laravel_horizon_supervisor_processes,redis,default,host-1:supervisor-1": 3,
+		"laravel_horizon_queue_length,redis,default":                             6,
+		"laravel_horizon_queue_wait_seconds,redis,default":                       1.5,
+		"laravel_horizon_jobs,recent":                                            10,
+		"laravel_horizon_jobs,failed":                                            2,
+		"laravel_horizon_jobs_per_minute":                                        20,
+		`laravel_horizon_job_runtime_seconds,App\Jobs\Send`:                      0.25,
+	}
+	for key, value := range expected {
+		if got, ok := values[key]; !ok || got != value {
+			t.Errorf("Expected %s = %v, got %v (present: %v)", key, value, got, ok)
+		}
+	}
+}
+
+func TestPrometheusCollector_CollectWorkers(t *testing.T) {
+	w := &laravel.QueueWorkers{Queues: []laravel.WorkerGroup{
+		{Connection: "redis", Queue: "default", Workers: 2, RSSBytes: 300, MaxRSSBytes: 200, MemoryLimitBytes: 128 << 20, MinUptimeSeconds: 60, MaxUptimeSeconds: 3600, Restarts: 4},
+		{Connection: "redis", Queue: "gone", Restarts: 1},
+	}}
+
+	pc := NewPrometheusCollector(&config.Config{})
+	values := collectedValues(t, func(ch chan<- prometheus.Metric) { pc.collectWorkers(ch, "App", w) })
+
+	expected := map[string]float64{
+		"laravel_queue_workers,redis,default":                   2,
+		"laravel_queue_worker_memory_bytes,redis,default":       300,
+		"laravel_queue_worker_max_memory_bytes,redis,default":   200,
+		"laravel_queue_worker_memory_limit_bytes,redis,default": 128 << 20,
+		"laravel_queue_worker_min_uptime_seconds,redis,default": 60,
+		"laravel_queue_worker_max_uptime_seconds,redis,default": 3600,
+		"laravel_queue_worker_restarts_total,redis,default":     4,
+		"laravel_queue_workers,redis,gone":                      0,
+		"laravel_queue_worker_restarts_total,redis,gone":        1,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestPrometheusCollector_CollectSchedule(t *testing.T) {
+	now := time.Date(2025, 3, 12, 10, 17, 30, 0, time.UTC)
+	heartbeat, lastRun, nextDue := now.Add(-10*time.Minute), now.Add(-90*time.Second), now.Add(30*time.Second)
+	firing, missed := false, 9
+	s := &laravel.ScheduleMetrics{
+		Heartbeat:  &heartbeat,
+		Firing:     &firing,
+		MissedRuns: 9,
+		Tasks: []laravel.ScheduledTask{
+			{Name: "php artisan inspire", LastRun: &lastRun, MissedRuns: &missed, NextDue: &nextDue},
+			{Name: "Closure", Error: "invalid expression"},
+		},
+	}
+
+	pc := NewPrometheusCollector(&config.Config{})
+	values := collectedValues(t, func(ch chan<- prometheus.Metric) { pc.collectSchedule(ch, "App", s, now) })
+
+	expected := map[string]float64{
+		"laravel_schedule_tasks":                                         2,
+		"laravel_schedule_missed_runs":                                   9,
+		"laravel_schedule_firing":                                        0,
+		"laravel_schedule_last_run_age_seconds":                          600,
+		"laravel_schedule_task_last_run_age_seconds,php artisan inspire": 90,
+		"laravel_schedule_task_missed_runs,php artisan inspire":          9,
+		"laravel_schedule_task_next_due_seconds,php artisan inspire":     30,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestPrometheusCollector_Logs(t *testing.T) {
+	l := &laravel.LogMetrics{
+		Entries: []laravel.LogCount{
+			{Channel: "production", Level: "error", Count: 3},
+			{Channel: "production", Level: "info", Count: 12},
+		},
+		Exceptions: map[string]uint64{`App\Exceptions\PaymentFailed`: 2},
+	}
+
+	pc := NewPrometheusCollector(&config.Config{})
+	values := collectedValues(t, func(ch chan<- prometheus.Metric) { pc.collectLogs(ch, "App", l) })
+
+	expected := map[string]float64{
+		"laravel_log_entries_total,production,error":                3,
+		"laravel_log_entries_total,production,info":                 12,
+		`laravel_log_exceptions_total,App\Exceptions\PaymentFailed`: 2,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestPrometheusCollector_Exceptions(t *testing.T) {
+	e := &laravel.ExceptionMetrics{
+		Groups: []laravel.ExceptionGroup{{Fingerprint: "a", Count: 4}, {Fingerprint: "b", Count: 1}, {Fingerprint: "c", Count: 9}},
+		New:    1,
+	}
+
+	pc := NewPrometheusCollector(&config.Config{})
+	values := collectedValues(t, func(ch chan<- prometheus.Metric) { pc.collectExceptions(ch, "App", e) })
+
+	expected := map[string]float64{
+		"laravel_exception_groups":     3,
+		"laravel_exception_new_groups": 1,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+// collectedValues runs a collect function and returns the metric values by
+// metric name and label values other than the site, comma separated.
+func collectedValues(t *testing.T, collect func(ch chan<- prometheus.Metric)) map[string]float64 {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric, 100)
+	collect(ch)
+	close(ch)
+
+	values := map[string]float64{}
+	for metric := range ch {
+		m := &dto.Metric{}
+		if err := metric.Write(m); err != nil {
+			t.Fatalf("Failed to write metric: %v", err)
+		}
+		desc := metric.Desc().String()
+		key := desc[strings.Index(desc, `"`)+1:]
+		key = key[:strings.Index(key, `"`)]
+		for _, l := range m.GetLabel() {
+			if l.GetName() != "site" {
+				key += "," + l.GetValue()
+			}
+		}
+		if m.GetCounter() != nil {
+			values[key] = m.GetCounter().GetValue()
+		} else {
+			values[key] = m.GetGauge().GetValue()
+		}
+	}
+	return values
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	RuntimeDocker     = "docker"
+	RuntimePodman     = "podman"
+	RuntimeContainerd = "containerd"
+	RuntimeCRIO       = "cri-o"
+	RuntimeLXC        = "lxc"
+
+	OrchestratorKubernetes = "kubernetes"
+	OrchestratorECS        = "ecs"
+	OrchestratorFargate    = "fargate"
+	OrchestratorNomad      = "nomad"
+
+	CloudAWS          = "aws"
+	CloudGCP          = "gcp"
+	CloudAzure        = "azure"
+	CloudDigitalOcean = "digitalocean"
+	CloudHetzner      = "hetzner"
+	CloudVultr        = "vultr"
+	CloudAlibaba      = "alibaba"
+	CloudOracle       = "oracle"
+	CloudOpenStack    = "openstack"
+	CloudScaleway     = "scaleway"
+
+	// azureAssetTag is the chassis asset tag of every Azure VM.
+	azureAssetTag = "7783-7084-3265-9085-8269-3286-77"
+)
+
+var (
+	// fsRoot and getenv are variables so detection can run against fixtures.
+	fsRoot     = "/"
+	getenv     = os.Getenv
+	osHostname = os.Hostname
+
+	containerPatterns = []struct {
+		pattern *regexp.Regexp
+		runtime string
+	}{
+		{regexp.MustCompile(`/docker/containers/([0-9a-f]{64})/`), RuntimeDocker},
+		{regexp.MustCompile(`docker[-/]([0-9a-f]{64})`), RuntimeDocker},
+		{regexp.MustCompile(`libpod[-/]([0-9a-f]{64})`), RuntimePodman},
+		{regexp.MustCompile(`cri-containerd[-:]([0-9a-f]{64})`), RuntimeContainerd},
+		{regexp.MustCompile(`/io\.containerd\.[^/]+/sandboxes/[0-9a-f]{64}/`), RuntimeContainerd},
+		{regexp.MustCompile(`crio-([0-9a-f]{64})`), RuntimeCRIO},
+		{regexp.MustCompile(`/overlay-containers/([0-9a-f]{64})/`), RuntimeCRIO}, // Podman is told apart by .containerenv
+		{regexp.MustCompile(`(?:/lxc/|lxc\.payload[./])([^/\s]+)`), RuntimeLXC},
+		{regexp.MustCompile(`/ecs/[^/]+/([0-9a-f]{64})`), ""},
+	}
+	containerEnvID    = regexp.MustCompile(`(?m)^id="([0-9a-f]{64})"`)
+	kubernetesPattern = regexp.MustCompile(`kubepods|/var/lib/kubelet/pods/`)
+
+	cloudVendors = []struct {
+		file, match, provider string
+	}{
+		{"sys_vendor", "amazon ec2", CloudAWS},
+		{"bios_vendor", "amazon ec2", CloudAWS},
+		{"sys_vendor", "google", CloudGCP},
+		{"product_name", "google compute engine", CloudGCP},
+		{"chassis_asset_tag", azureAssetTag, CloudAzure},
+		{"sys_vendor", "digitalocean", CloudDigitalOcean},
+		{"sys_vendor", "hetzner", CloudHetzner},
+		{"sys_vendor", "vultr", CloudVultr},
+		{"sys_vendor", "alibaba cloud", CloudAlibaba},
+		{"chassis_asset_tag", "oraclecloud.com", CloudOracle},
+		{"product_name", "openstack", CloudOpenStack},
+		{"sys_vendor", "scaleway", CloudScaleway},
+	}
+	hypervisorVendors = []string{"kvm", "qemu", "vmware", "virtualbox", "xen", "bochs", "parallels", "bhyve", "virtual machine"}
+)
+
+// detectEnvironment fills in the container runtime, orchestrator, cloud and
+// kernel details of info and derives the node type from them. Everything is
+// read from local files and the environment, no metadata endpoint is queried.
+func detectEnvironment(info *SystemInfo) {
+	info.ContainerRuntime, info.ContainerID = detectContainer()
+	info.Orchestrator = detectOrchestrator()
+	info.CloudProvider, info.InstanceType = detectCloud()
+	if info.Orchestrator == OrchestratorECS || info.Orchestrator == OrchestratorFargate {
+		info.CloudProvider = CloudAWS
+	}
+	info.KernelVersion = readTrimmed("proc/sys/kernel/osrelease")
+	info.Hostname = readTrimmed("proc/sys/kernel/hostname")
+	if info.Hostname == "" {
+		info.Hostname, _ = osHostname()
+	}
+
+	switch {
+	case info.Orchestrator == OrchestratorKubernetes:
+		info.NodeType = NodeKubernetes
+	case info.ContainerRuntime == RuntimeDocker:
+		info.NodeType = NodeDocker
+	case info.ContainerRuntime != "" || info.Orchestrator != "":
+		info.NodeType = NodeContainer
+	case isVirtualMachine(info):
+		info.NodeType = NodeVM
+	default:
+		info.NodeType = NodePhysical
+	}
+}
+
+// detectContainer returns the container runtime and, when it is known, the
+// container ID. Marker files are checked first, then the cgroup paths, which
+// are hidden by cgroup namespaces on v2, and finally the sources of the
+// hostname and resolv.conf bind mounts every runtime sets up.
+func detectContainer() (runtime, id string) {
+	runtime = strings.ToLower(getenv("container"))
+	if runtime == "" {
+		runtime = readEnviron("proc/1/environ", "container")
+	}
+	if data, err := os.ReadFile(hostPath("run/.containerenv")); err == nil {
+		if runtime == "" {
+			runtime = RuntimePodman
+		}
+		if m := containerEnvID.FindSubmatch(data); m != nil {
+			id = string(m[1])
+		}
+	}
+	if runtime == "" && fileExists(".dockerenv") {
+		runtime = RuntimeDocker
+	}
+
+	lines := readLines("proc/self/cgroup")
+	lines = append(lines, readLines("proc/1/cgroup")...)
+	for _, line := range readLines("proc/self/mountinfo") {
+		// Only the root of mounts on the runtime managed files is relevant,
+		// the host itself sees the mounts of all running containers
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		switch fields[4] {
+		case "/etc/hostname", "/etc/hosts", "/etc/resolv.conf":
+			lines = append(lines, fields[3])
+		}
+	}
+
+	for _, line := range lines {
+		for _, p := range containerPatterns {
+			m := p.pattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			if runtime == "" {
+				runtime = p.runtime
+			}
+			if id == "" && len(m) > 1 {
+				id = m[1]
+			}
+		}
+	}
+	return runtime, id
+}
+
+func detectOrchestrator() string {
+	if getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return OrchestratorKubernetes
+	}
+	if env := getenv("AWS_EXECUTION_ENV"); env == "AWS_ECS_FARGATE" {
+		return OrchestratorFargate
+	} else if strings.HasPrefix(env, "AWS_ECS") || getenv("ECS_CONTAINER_METADATA_URI_V4") != "" || getenv("ECS_CONTAINER_METADATA_URI") != "" {
+		return OrchestratorECS
+	}
+	if getenv("NOMAD_ALLOC_ID") != "" {
+		return OrchestratorNomad
+	}
+	// The service env vars are not injected with enableServiceLinks off, the
+	// pod cgroup and the kubelet managed /etc/hosts give it away anyway
+	for _, line := range readLines("proc/self/cgroup") {
+		if kubernetesPattern.MatchString(line) {
+			return OrchestratorKubernetes
+		}
+	}
+	for _, line := range readLines("proc/self/mountinfo") {
+		if fields := strings.Fields(line); len(fields) >= 5 && kubernetesPattern.MatchString(fields[3]) {
+			return OrchestratorKubernetes
+		}
+	}
+	return ""
+}
+
+// detectCloud identifies the cloud provider from the SMBIOS data in
+// /sys/class/dmi/id. Only AWS exposes the instance type there.
+func detectCloud() (provider, instanceType string) {
+	dmi := map[string]string{}
+	for _, name := range []string{"sys_vendor", "product_name", "bios_vendor", "chassis_asset_tag"} {
+		dmi[name] = readTrimmed(filepath.Join("sys/class/dmi/id", name))
+	}
+
+	for _, v := range cloudVendors {
+		if strings.Contains(strings.ToLower(dmi[v.file]), v.match) {
+			provider = v.provider
+			break
+		}
+	}
+	// Xen based EC2 instances only show up in the hypervisor UUID
+	if provider == "" && strings.HasPrefix(strings.ToLower(readTrimmed("sys/hypervisor/uuid")), "ec2") {
+		provider = CloudAWS
+	}
+
+	if provider == CloudAWS && strings.EqualFold(dmi["sys_vendor"], "amazon ec2") {
+		instanceType = dmi["product_name"]
+	}
+	return provider, instanceType
+}
+
+func isVirtualMachine(info *SystemInfo) bool {
+	if info.CloudProvider != "" {
+		return !strings.HasSuffix(info.InstanceType, ".metal")
+	}
+	if readTrimmed("sys/hypervisor/type") != "" {
+		return true
+	}
+
+	for _, name := range []string{"sys_vendor", "product_name"} {
+		val := strings.ToLower(readTrimmed(filepath.Join("sys/class/dmi/id", name)))
+		for _, vendor := range hypervisorVendors {
+			if strings.Contains(val, vendor) {
+				return true
+			}
+		}
+	}
+
+	for _, line := range readLines("proc/cpuinfo") {
+		if strings.HasPrefix(line, "flags") {
+			return strings.Contains(line+" ", " hypervisor ")
+		}
+	}
+	return false
+}
+
+func hostPath(path string) string {
+	return filepath.Join(fsRoot, path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(hostPath(path))
+	return err == nil
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(hostPath(path))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readLines(path string) []string {
+	f, err := os.Open(hostPath(path))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// readEnviron returns a variable from a NUL separated environ file, which is
+// only readable for processes of the same user.
+func readEnviron(path, name string) string {
+	data, err := os.ReadFile(hostPath(path))
+	if err != nil {
+		return ""
+	}
+	for _, kv := range bytes.Split(data, []byte{0}) {
+		if k, v, ok := strings.Cut(string(kv), "="); ok && k == name {
+			return strings.ToLower(v)
+		}
+	}
+	return ""
+}
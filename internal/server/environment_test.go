@@ -0,0 +1,263 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testContainerID  = "3f4e8a1b9c2d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f"
+	otherContainerID = "a1b2c3d4e5f60718293a4b5c6d7e8f9011223344556677889900aabbccddeeff"
+)
+
+// useFSRoot points environment detection at a fixture root without any
+// environment variables set.
+func useFSRoot(t *testing.T, dir string) {
+	t.Helper()
+
+	origRoot, origGetenv := fsRoot, getenv
+	fsRoot = dir
+	getenv = func(string) string { return "" }
+	t.Cleanup(func() {
+		fsRoot, getenv = origRoot, origGetenv
+	})
+}
+
+func writeFSFixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create fixture dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write fixture: %v", err)
+		}
+	}
+	return dir
+}
+
+func mountinfo(root, mountPoint string) string {
+	return "612 590 254:1 " + root + " " + mountPoint + " rw,relatime - ext4 /dev/vda1 rw\n"
+}
+
+func TestDetectEnvironment(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		env      map[string]string
+		expected SystemInfo
+	}{
+		{
+			name: "docker on cgroup v1",
+			files: map[string]string{
+				".dockerenv":       "",
+				"proc/self/cgroup": "12:memory:/docker/" + testContainerID + "\n0::/\n",
+			},
+			expected: SystemInfo{NodeType: NodeDocker, ContainerRuntime: RuntimeDocker, ContainerID: testContainerID},
+		},
+		{
+			name: "docker on cgroup v2 with a cgroup namespace",
+			files: map[string]string{
+				".dockerenv":       "",
+				"proc/self/cgroup": "0::/\n",
+				"proc/self/mountinfo": mountinfo("/", "/") +
+					mountinfo("/var/lib/docker/containers/"+testContainerID+"/resolv.conf", "/etc/resolv.conf") +
+					mountinfo("/var/lib/docker/containers/"+testContainerID+"/hostname", "/etc/hostname"),
+			},
+			expected: SystemInfo{NodeType: NodeDocker, ContainerRuntime: RuntimeDocker, ContainerID: testContainerID},
+		},
+		{
+			name: "podman",
+			files: map[string]string{
+				"run/.containerenv":   "engine=\"podman-4.9.3\"\nname=\"web\"\nid=\"" + testContainerID + "\"\n",
+				"proc/self/mountinfo": mountinfo("/containers/storage/overlay-containers/"+otherContainerID+"/userdata/hostname", "/etc/hostname"),
+			},
+			expected: SystemInfo{NodeType: NodeContainer, ContainerRuntime: RuntimePodman, ContainerID: testContainerID},
+		},
+		{
+			name: "containerd in a kubernetes pod without service links",
+			files: map[string]string{
+				"proc/self/cgroup": "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1.slice/cri-containerd-" + testContainerID + ".scope\n",
+			},
+			expected: SystemInfo{NodeType: NodeKubernetes, ContainerRuntime: RuntimeContainerd, ContainerID: testContainerID, Orchestrator: OrchestratorKubernetes},
+		},
+		{
+			name: "cri-o in a kubernetes pod on cgroup v2",
+			files: map[string]string{
+				"proc/self/cgroup": "0::/\n",
+				"proc/self/mountinfo": mountinfo("/var/lib/kubelet/pods/7c1a/etc-hosts", "/etc/hosts") +
+					mountinfo("/containers/storage/overlay-containers/"+testContainerID+"/userdata/hostname", "/etc/hostname"),
+			},
+			env:      map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"},
+			expected: SystemInfo{NodeType: NodeKubernetes, ContainerRuntime: RuntimeCRIO, ContainerID: testContainerID, Orchestrator: OrchestratorKubernetes},
+		},
+		{
+			name: "lxc",
+			files: map[string]string{
+				"proc/1/environ":   "container=lxc\x00TERM=linux\x00",
+				"proc/self/cgroup": "0::/lxc.payload.web01/system.slice/php8.3-fpm.service\n",
+			},
+			expected: SystemInfo{NodeType: NodeContainer, ContainerRuntime: RuntimeLXC, ContainerID: "web01"},
+		},
+		{
+			name: "ecs on ec2",
+			files: map[string]string{
+				".dockerenv":                    "",
+				"proc/self/cgroup":              "4:memory:/ecs/5a9c0c2e/" + testContainerID + "\n",
+				"sys/class/dmi/id/sys_vendor":   "Amazon EC2\n",
+				"sys/class/dmi/id/product_name": "m5.large\n",
+			},
+			env:      map[string]string{"ECS_CONTAINER_METADATA_URI_V4": "http://169.254.170.2/v4/abc"},
+			expected: SystemInfo{NodeType: NodeDocker, ContainerRuntime: RuntimeDocker, ContainerID: testContainerID, Orchestrator: OrchestratorECS, CloudProvider: CloudAWS, InstanceType: "m5.large"},
+		},
+		{
+			name:     "fargate",
+			files:    map[string]string{"proc/self/cgroup": "0::/ecs/5a9c0c2e/5a9c0c2e-1234567890\n"},
+			env:      map[string]string{"AWS_EXECUTION_ENV": "AWS_ECS_FARGATE"},
+			expected: SystemInfo{NodeType: NodeContainer, Orchestrator: OrchestratorFargate, CloudProvider: CloudAWS},
+		},
+		{
+			name:     "nomad",
+			files:    map[string]string{".dockerenv": ""},
+			env:      map[string]string{"NOMAD_ALLOC_ID": "5b3d"},
+			expected: SystemInfo{NodeType: NodeDocker, ContainerRuntime: RuntimeDocker, Orchestrator: OrchestratorNomad},
+		},
+		{
+			name: "ec2 instance",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "Amazon EC2\n",
+				"sys/class/dmi/id/product_name": "m6i.xlarge\n",
+			},
+			expected: SystemInfo{NodeType: NodeVM, CloudProvider: CloudAWS, InstanceType: "m6i.xlarge"},
+		},
+		{
+			name: "ec2 bare metal",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "Amazon EC2\n",
+				"sys/class/dmi/id/product_name": "m5.metal\n",
+			},
+			expected: SystemInfo{NodeType: NodePhysical, CloudProvider: CloudAWS, InstanceType: "m5.metal"},
+		},
+		{
+			name: "ec2 on xen",
+			files: map[string]string{
+				"sys/class/dmi/id/product_name": "HVM domU\n",
+				"sys/hypervisor/uuid":           "ec2e1916-9099-7caf-fd21-012345abcdef\n",
+			},
+			expected: SystemInfo{NodeType: NodeVM, CloudProvider: CloudAWS},
+		},
+		{
+			name: "azure",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":        "Microsoft Corporation\n",
+				"sys/class/dmi/id/product_name":      "Virtual Machine\n",
+				"sys/class/dmi/id/chassis_asset_tag": "7783-7084-3265-9085-8269-3286-77\n",
+			},
+			expected: SystemInfo{NodeType: NodeVM, CloudProvider: CloudAzure},
+		},
+		{
+			name: "gcp",
+			files: map[string]string{
+				"sys/class/dmi/id/sys_vendor":   "Google\n",
+				"sys/class/dmi/id/product_name": "Google Compute Engine\n",
+			},
+			expected: SystemInfo{NodeType: NodeVM, CloudProvider: CloudGCP},
+		},
+		{
+			name:     "hetzner",
+			files:    map[string]string{"sys/class/dmi/id/sys_vendor": "Hetzner\n"},
+			expected: SystemInfo{NodeType: NodeVM, CloudProvider: CloudHetzner},
+		},
+		{
+			name:     "plain kvm guest",
+			files:    map[string]string{"sys/class/dmi/id/product_name": "Standard PC (Q35 + ICH9, 2009)\n", "sys/class/dmi/id/sys_vendor": "QEMU\n"},
+			expected: SystemInfo{NodeType: NodeVM},
+		},
+		{
+			name:     "hypervisor cpu flag",
+			files:    map[string]string{"proc/cpuinfo": "processor\t: 0\nflags\t\t: fpu vme de pse hypervisor lahf_lm\n"},
+			expected: SystemInfo{NodeType: NodeVM},
+		},
+		{
+			name: "physical host running containers",
+			files: map[string]string{
+				"proc/cpuinfo":     "processor\t: 0\nflags\t\t: fpu vme de pse lahf_lm\n",
+				"proc/self/cgroup": "0::/system.slice/elasticphp-agent.service\n",
+				"proc/1/cgroup":    "0::/init.scope\n",
+				"proc/self/mountinfo": mountinfo("/", "/") +
+					mountinfo("/", "/var/lib/docker/containers/"+testContainerID+"/mounts/shm"),
+			},
+			expected: SystemInfo{NodeType: NodePhysical},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFSRoot(t, writeFSFixture(t, tt.files))
+			getenv = func(key string) string { return tt.env[key] }
+
+			var info SystemInfo
+			detectEnvironment(&info)
+
+			if info.NodeType != tt.expected.NodeType {
+				t.Errorf("Expected node type %s, got %s", tt.expected.NodeType, info.NodeType)
+			}
+			if info.ContainerRuntime != tt.expected.ContainerRuntime || info.ContainerID != tt.expected.ContainerID {
+				t.Errorf("Expected runtime %q with ID %q, got %q with ID %q",
+					tt.expected.ContainerRuntime, tt.expected.ContainerID, info.ContainerRuntime, info.ContainerID)
+			}
+			if info.Orchestrator != tt.expected.Orchestrator {
+				t.Errorf("Expected orchestrator %q, got %q", tt.expected.Orchestrator, info.Orchestrator)
+			}
+			if info.CloudProvider != tt.expected.CloudProvider || info.InstanceType != tt.expected.InstanceType {
+				t.Errorf("Expected cloud %q/%q, got %q/%q",
+					tt.expected.CloudProvider, tt.expected.InstanceType, info.CloudProvider, info.InstanceType)
+			}
+		})
+	}
+}
+
+func TestDetectEnvironment_KernelAndHostname(t *testing.T) {
+	useFSRoot(t, writeFSFixture(t, map[string]string{
+		"proc/sys/kernel/osrelease": "6.8.0-45-generic\n",
+		"proc/sys/kernel/hostname":  "web-7d9f\n",
+	}))
+
+	var info SystemInfo
+	detectEnvironment(&info)
+	if info.KernelVersion != "6.8.0-45-generic" || info.Hostname != "web-7d9f" {
+		t.Errorf("Expected kernel and hostname from /proc, got %q and %q", info.KernelVersion, info.Hostname)
+	}
+
+	// Without /proc the hostname comes from the hostname syscall
+	useFSRoot(t, t.TempDir())
+	origHostname := osHostname
+	osHostname = func() (string, error) { return "fallback", nil }
+	t.Cleanup(func() { osHostname = origHostname })
+
+	info = SystemInfo{}
+	detectEnvironment(&info)
+	if info.KernelVersion != "" || info.Hostname != "fallback" {
+		t.Errorf("Expected no kernel version and the fallback hostname, got %q and %q", info.KernelVersion, info.Hostname)
+	}
+}
+
+func TestReadEnviron(t *testing.T) {
+	useFSRoot(t, writeFSFixture(t, map[string]string{
+		"proc/1/environ": "PATH=/usr/bin\x00container=PODMAN\x00container_uuid=abc\x00",
+	}))
+
+	if v := readEnviron("proc/1/environ", "container"); v != "podman" {
+		t.Errorf("Expected podman, got %q", v)
+	}
+	if v := readEnviron("proc/1/environ", "missing"); v != "" {
+		t.Errorf("Expected an empty value for a missing variable, got %q", v)
+	}
+	if v := readEnviron("proc/2/environ", "container"); v != "" {
+		t.Errorf("Expected an empty value for an unreadable file, got %q", v)
+	}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+const DefaultCPUSampleInterval = 15 * time.Second
+
+var (
+	hostCPUMu      sync.Mutex
+	cpuUtilSampled map[string]float64
+
+	// cpuTimes is a variable so tests can fake the CPU counters.
+	cpuTimes = cpu.Times
+)
+
+// HostMetrics is a per-scrape snapshot of host resource usage, so FPM
+// saturation can be lined up with CPU steal, load or swapping.
+type HostMetrics struct {
+	CPUSeconds     map[string]float64 `json:"cpu_seconds"`               // Cumulative CPU time by mode
+	CPUUtilization map[string]float64 `json:"cpu_utilization,omitempty"` // Share of CPU time by mode over the CPU sampler's latest interval
+	Load1          float64            `json:"load1"`
+	Load5          float64            `json:"load5"`
+	Load15         float64            `json:"load15"`
+	Memory         *MemoryUsage       `json:"memory,omitempty"`
+	Swap           *SwapUsage         `json:"swap,omitempty"`
+	Disks          []DiskUsage        `json:"disks,omitempty"`
+	Network        []NetworkTotals    `json:"network,omitempty"`
+}
+
+type MemoryUsage struct {
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"`
+	Used      uint64 `json:"used"`
+	Cached    uint64 `json:"cached"`
+	Buffers   uint64 `json:"buffers"`
+}
+
+type SwapUsage struct {
+	Total uint64 `json:"total"`
+	Used  uint64 `json:"used"`
+	Free  uint64 `json:"free"`
+}
+
+type DiskUsage struct {
+	Path        string `json:"path"`
+	Fstype      string `json:"fstype"`
+	Total       uint64 `json:"total"`
+	Free        uint64 `json:"free"`
+	Used        uint64 `json:"used"`
+	InodesTotal uint64 `json:"inodes_total"`
+	InodesFree  uint64 `json:"inodes_free"`
+}
+
+type NetworkTotals struct {
+	Interface string `json:"interface"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	TxErrors  uint64 `json:"tx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
+
+type HostMetricsData struct {
+	Host   *HostMetrics
+	Errors map[string]string
+}
+
+// CollectHost reads host CPU, load, memory, swap and network totals, and the
+// disk usage of the given paths. Failing sources are reported in Errors and
+// left out of the snapshot.
+func CollectHost(paths []string) *HostMetricsData {
+	host := &HostMetrics{}
+	errors := make(map[string]string)
+
+	if times, err := cpuTimes(false); err != nil {
+		errors["host_cpu"] = err.Error()
+	} else if len(times) > 0 {
+		host.CPUSeconds = cpuModes(times[0])
+		hostCPUMu.Lock()
+		host.CPUUtilization = cpuUtilSampled
+		hostCPUMu.Unlock()
+	}
+
+	if avg, err := load.Avg(); err != nil {
+		errors["host_load"] = err.Error()
+	} else {
+		host.Load1, host.Load5, host.Load15 = avg.Load1, avg.Load5, avg.Load15
+	}
+
+	if vm, err := mem.VirtualMemory(); err != nil {
+		errors["host_memory"] = err.Error()
+	} else {
+		host.Memory = &MemoryUsage{
+			Total:     vm.Total,
+			Available: vm.Available,
+			Used:      vm.Used,
+			Cached:    vm.Cached,
+			Buffers:   vm.Buffers,
+		}
+	}
+
+	if swap, err := mem.SwapMemory(); err != nil {
+		errors["host_swap"] = err.Error()
+	} else {
+		host.Swap = &SwapUsage{Total: swap.Total, Used: swap.Used, Free: swap.Free}
+	}
+
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		usage, err := disk.Usage(path)
+		if err != nil {
+			errors["host_disk_"+path] = err.Error()
+			continue
+		}
+		host.Disks = append(host.Disks, DiskUsage{
+			Path:        path,
+			Fstype:      usage.Fstype,
+			Total:       usage.Total,
+			Free:        usage.Free,
+			Used:        usage.Used,
+			InodesTotal: usage.InodesTotal,
+			InodesFree:  usage.InodesFree,
+		})
+	}
+
+	if counters, err := net.IOCounters(true); err != nil {
+		errors["host_network"] = err.Error()
+	} else {
+		for _, c := range counters {
+			if c.Name == "lo" {
+				continue
+			}
+			host.Network = append(host.Network, NetworkTotals{
+				Interface: c.Name,
+				RxBytes:   c.BytesRecv,
+				TxBytes:   c.BytesSent,
+				RxPackets: c.PacketsRecv,
+				TxPackets: c.PacketsSent,
+				RxErrors:  c.Errin,
+				TxErrors:  c.Errout,
+				RxDropped: c.Dropin,
+				TxDropped: c.Dropout,
+			})
+		}
+		sort.Slice(host.Network, func(i, j int) bool {
+			return host.Network[i].Interface < host.Network[j].Interface
+		})
+	}
+
+	return &HostMetricsData{Host: host, Errors: errors}
+}
+
+// cpuModes maps CPU times to modes. Guest time is already part of user and
+// nice time on Linux, so it is not listed separately.
+func cpuModes(t cpu.TimesStat) map[string]float64 {
+	return map[string]float64{
+		"user":    t.User,
+		"nice":    t.Nice,
+		"system":  t.System,
+		"idle":    t.Idle,
+		"iowait":  t.Iowait,
+		"irq":     t.Irq,
+		"softirq": t.Softirq,
+		"steal":   t.Steal,
+	}
+}
+
+// StartCPUSampler samples the host CPU times every interval until ctx is
+// cancelled. The utilisation CollectHost reports is over the latest interval,
+// so it doesn't depend on how often and by how many callers it is collected.
+func StartCPUSampler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCPUSampleInterval
+	}
+
+	go func() {
+		var prev *cpu.TimesStat
+		sample := func() {
+			times, err := cpuTimes(false)
+			if err != nil || len(times) == 0 {
+				return
+			}
+			util := cpuUtilization(prev, times[0])
+			prev = &times[0]
+
+			hostCPUMu.Lock()
+			cpuUtilSampled = util
+			hostCPUMu.Unlock()
+		}
+
+		sample()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sample()
+			}
+		}
+	}()
+}
+
+// cpuUtilization returns the share of each mode in the CPU time elapsed
+// between two samples, or nil without a previous sample and after counter
+// resets.
+func cpuUtilization(prev *cpu.TimesStat, t cpu.TimesStat) map[string]float64 {
+	if prev == nil {
+		return nil
+	}
+
+	current, previous := cpuModes(t), cpuModes(*prev)
+	var total float64
+	deltas := make(map[string]float64, len(current))
+	for mode, seconds := range current {
+		delta := seconds - previous[mode]
+		if delta < 0 {
+			return nil
+		}
+		deltas[mode] = delta
+		total += delta
+	}
+	if total == 0 {
+		return nil
+	}
+
+	for mode, delta := range deltas {
+		deltas[mode] = delta / total
+	}
+	return deltas
+}
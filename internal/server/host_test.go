@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+func TestCollectHost(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("host collection is only verified on Linux")
+	}
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+
+	data := CollectHost([]string{dir, dir, "", missing})
+	if data == nil || data.Host == nil {
+		t.Fatalf("Expected host metrics")
+	}
+	host := data.Host
+
+	if host.Memory == nil || host.Memory.Total == 0 {
+		t.Errorf("Expected memory totals, got %+v", host.Memory)
+	}
+	if _, ok := host.CPUSeconds["idle"]; !ok {
+		t.Errorf("Expected idle CPU seconds, got %v", host.CPUSeconds)
+	}
+
+	if len(host.Disks) != 1 || host.Disks[0].Path != dir || host.Disks[0].Total == 0 {
+		t.Errorf("Expected disk usage for the temp dir only, got %+v", host.Disks)
+	}
+	if _, ok := data.Errors["host_disk_"+missing]; !ok {
+		t.Errorf("Expected an error for the missing path, got %v", data.Errors)
+	}
+
+	for _, n := range host.Network {
+		if n.Interface == "lo" {
+			t.Errorf("Expected the loopback interface to be skipped")
+		}
+	}
+}
+
+func TestCPUUtilization(t *testing.T) {
+	first := cpu.TimesStat{User: 10, System: 5, Idle: 85}
+	if u := cpuUtilization(nil, first); u != nil {
+		t.Errorf("Expected no utilisation without a previous sample, got %v", u)
+	}
+
+	second := cpu.TimesStat{User: 16, System: 7, Idle: 95, Steal: 2}
+	u := cpuUtilization(&first, second)
+	expected := map[string]float64{"user": 0.3, "system": 0.1, "idle": 0.5, "steal": 0.1}
+	for mode, share := range expected {
+		if math.Abs(u[mode]-share) > 1e-9 {
+			t.Errorf("Expected %s share %v, got %v", mode, share, u[mode])
+		}
+	}
+
+	// Counters going backwards, e.g. after a CPU went offline, are not a valid delta
+	if u := cpuUtilization(&second, cpu.TimesStat{User: 1, System: 7, Idle: 95, Steal: 2}); u != nil {
+		t.Errorf("Expected no utilisation after a counter reset, got %v", u)
+	}
+}
+
+func TestStartCPUSampler(t *testing.T) {
+	original := cpuTimes
+	t.Cleanup(func() {
+		cpuTimes = original
+		hostCPUMu.Lock()
+		cpuUtilSampled = nil
+		hostCPUMu.Unlock()
+	})
+
+	var mu sync.Mutex
+	user := 0.0
+	cpuTimes = func(bool) ([]cpu.TimesStat, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		user += 10
+		return []cpu.TimesStat{{User: user, Idle: user}}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartCPUSampler(ctx, time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		hostCPUMu.Lock()
+		u := cpuUtilSampled
+		hostCPUMu.Unlock()
+		if u != nil {
+			if u["user"] != 0.5 || u["idle"] != 0.5 {
+				t.Errorf("Expected the utilisation between samples, got %v", u)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the sampler to record the utilisation")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Collecting doesn't move the sampler's window
+	for range 3 {
+		if got := CollectHost(nil).Host.CPUUtilization; got["user"] != 0.5 {
+			t.Errorf("Expected the sampled utilisation, got %v", got)
+		}
+	}
+}
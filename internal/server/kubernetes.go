@@ -0,0 +1,127 @@
+package server
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const serviceAccountNamespace = "var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// KubernetesMetadata identifies the pod the agent runs in. It is read from the
+// downward API: POD_NAME, POD_NAMESPACE, NODE_NAME, POD_IP and POD_UID env vars
+// and the name, namespace, uid, labels and annotations files of a downward API
+// volume.
+type KubernetesMetadata struct {
+	PodName     string            `json:"pod_name,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	NodeName    string            `json:"node_name,omitempty"`
+	PodIP       string            `json:"pod_ip,omitempty"`
+	PodUID      string            `json:"pod_uid,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// DetectKubernetes returns the pod metadata, or nil outside of Kubernetes.
+// Files are re-read on every call, the kubelet updates labels and annotations
+// of a running pod in place.
+func DetectKubernetes(podInfoPath string) *KubernetesMetadata {
+	if detectOrchestrator() != OrchestratorKubernetes {
+		return nil
+	}
+
+	meta := &KubernetesMetadata{
+		PodName:   firstNonEmpty(getenv("POD_NAME"), readPodInfo(podInfoPath, "name")),
+		Namespace: firstNonEmpty(getenv("POD_NAMESPACE"), readPodInfo(podInfoPath, "namespace"), readTrimmed(serviceAccountNamespace)),
+		NodeName:  getenv("NODE_NAME"),
+		PodIP:     getenv("POD_IP"),
+		PodUID:    firstNonEmpty(getenv("POD_UID"), readPodInfo(podInfoPath, "uid")),
+	}
+	if meta.PodName == "" {
+		// The hostname of a pod is its name unless spec.hostname is set
+		meta.PodName = readTrimmed("proc/sys/kernel/hostname")
+	}
+
+	if podInfoPath != "" {
+		meta.Labels = parsePodInfoMap(readLines(filepath.Join(podInfoPath, "labels")))
+		meta.Annotations = parsePodInfoMap(readLines(filepath.Join(podInfoPath, "annotations")))
+		// Holds the whole manifest, far too big to pass along
+		delete(meta.Annotations, "kubectl.kubernetes.io/last-applied-configuration")
+	}
+
+	return meta
+}
+
+// ConstLabels resolves the configured keys to Prometheus label names and
+// values. Keys are pod, namespace, node, label:<name> or annotation:<name>;
+// pod labels and annotations become label_<name> and annotation_<name> with
+// invalid characters replaced, like kube-state-metrics does. Keys without a
+// value are skipped.
+func (m *KubernetesMetadata) ConstLabels(keys []string) map[string]string {
+	labels := map[string]string{}
+	if m == nil {
+		return labels
+	}
+
+	for _, key := range keys {
+		var name, value string
+		switch {
+		case key == "pod":
+			name, value = "pod", m.PodName
+		case key == "namespace":
+			name, value = "namespace", m.Namespace
+		case key == "node":
+			name, value = "node", m.NodeName
+		case strings.HasPrefix(key, "label:"):
+			k := strings.TrimPrefix(key, "label:")
+			name, value = "label_"+invalidLabelChars.ReplaceAllString(k, "_"), m.Labels[k]
+		case strings.HasPrefix(key, "annotation:"):
+			k := strings.TrimPrefix(key, "annotation:")
+			name, value = "annotation_"+invalidLabelChars.ReplaceAllString(k, "_"), m.Annotations[k]
+		}
+		if name != "" && value != "" {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+func readPodInfo(podInfoPath, name string) string {
+	if podInfoPath == "" {
+		return ""
+	}
+	return readTrimmed(filepath.Join(podInfoPath, name))
+}
+
+// parsePodInfoMap parses the key="value" lines of the downward API labels and
+// annotations files. Values are quoted with Go escaping.
+func parsePodInfoMap(lines []string) map[string]string {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	values := map[string]string{}
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || key == "" {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		values[key] = value
+	}
+	return values
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestDetectKubernetes(t *testing.T) {
+	useFSRoot(t, writeFSFixture(t, map[string]string{
+		"etc/podinfo/labels":      "app.kubernetes.io/name=\"shop\"\npod-template-hash=\"7d9f8c\"\n",
+		"etc/podinfo/annotations": "team=\"payments\"\nnote=\"line one\\nline two\"\nkubectl.kubernetes.io/last-applied-configuration=\"{}\"\n",
+		"etc/podinfo/uid":         "0b6c2f2e-4b4e-4d8e-9d62-2f1c7a3f9e10\n",
+		"var/run/secrets/kubernetes.io/serviceaccount/namespace": "shop-prod",
+		"proc/sys/kernel/hostname":                               "shop-web-7d9f8c-x2kqp\n",
+	}))
+	env := map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "NODE_NAME": "node-a"}
+	getenv = func(key string) string { return env[key] }
+
+	meta := DetectKubernetes("/etc/podinfo")
+	if meta == nil {
+		t.Fatalf("Expected pod metadata")
+	}
+	if meta.PodName != "shop-web-7d9f8c-x2kqp" || meta.Namespace != "shop-prod" || meta.NodeName != "node-a" {
+		t.Errorf("Expected name from the hostname, namespace from the service account and node from the env, got %+v", meta)
+	}
+	if meta.PodUID != "0b6c2f2e-4b4e-4d8e-9d62-2f1c7a3f9e10" {
+		t.Errorf("Expected the UID from the downward API volume, got %q", meta.PodUID)
+	}
+	if meta.Labels["app.kubernetes.io/name"] != "shop" || len(meta.Labels) != 2 {
+		t.Errorf("Unexpected labels %v", meta.Labels)
+	}
+	if meta.Annotations["note"] != "line one\nline two" {
+		t.Errorf("Expected quoted annotation values to be unescaped, got %q", meta.Annotations["note"])
+	}
+	if _, ok := meta.Annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+		t.Errorf("Expected the last applied configuration to be dropped")
+	}
+
+	// Env vars take precedence over files
+	env["POD_NAME"], env["POD_NAMESPACE"] = "shop-web-0", "shop-staging"
+	meta = DetectKubernetes("/etc/podinfo")
+	if meta.PodName != "shop-web-0" || meta.Namespace != "shop-staging" {
+		t.Errorf("Expected pod name and namespace from the env, got %+v", meta)
+	}
+
+	if meta := DetectKubernetes(""); meta == nil || meta.Labels != nil {
+		t.Errorf("Expected metadata without labels when no volume is mounted, got %+v", meta)
+	}
+
+	delete(env, "KUBERNETES_SERVICE_HOST")
+	if meta := DetectKubernetes("/etc/podinfo"); meta != nil {
+		t.Errorf("Expected no metadata outside of Kubernetes, got %+v", meta)
+	}
+}
+
+func TestKubernetesMetadata_ConstLabels(t *testing.T) {
+	meta := &KubernetesMetadata{
+		PodName:     "shop-web-0",
+		Namespace:   "shop-prod",
+		Labels:      map[string]string{"app.kubernetes.io/name": "shop"},
+		Annotations: map[string]string{"team": "payments"},
+	}
+
+	labels := meta.ConstLabels([]string{"pod", "namespace", "node", "label:app.kubernetes.io/name", "annotation:team", "label:missing", "unknown"})
+	expected := map[string]string{
+		"pod":                          "shop-web-0",
+		"namespace":                    "shop-prod",
+		"label_app_kubernetes_io_name": "shop",
+		"annotation_team":              "payments",
+	}
+	if len(labels) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, labels)
+	}
+	for name, value := range expected {
+		if labels[name] != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, labels[name])
+		}
+	}
+
+	var none *KubernetesMetadata
+	if labels := none.ConstLabels([]string{"pod"}); len(labels) != 0 {
+		t.Errorf("Expected no labels outside of Kubernetes, got %v", labels)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/elasticphphq/agent/internal/cgroup"
 )
 
 var (
@@ -21,6 +23,7 @@ type NodeType string
 const (
 	NodeKubernetes NodeType = "kubernetes"
 	NodeDocker     NodeType = "docker"
+	NodeContainer  NodeType = "container" // Any other container runtime or orchestrator
 	NodeVM         NodeType = "vm"
 	NodePhysical   NodeType = "physical"
 )
@@ -29,8 +32,16 @@ type SystemInfo struct {
 	NodeType      NodeType
 	OS            string
 	Architecture  string
-	CPULimit      int64 // Logical CPUs (possibly limited)
-	MemoryLimitMB int64 // In MB
+	CPULimit      float64 // CPUs, fractional when limited by a cgroup CPU quota
+	MemoryLimitMB int64   // In MB
+
+	ContainerRuntime string // docker, podman, containerd, cri-o, lxc, ...
+	ContainerID      string
+	Orchestrator     string // kubernetes, ecs, fargate or nomad
+	CloudProvider    string // From DMI data, e.g. aws, gcp or azure
+	InstanceType     string // Only known on AWS
+	KernelVersion    string
+	Hostname         string
 }
 
 type SystemInfoData struct {
@@ -50,10 +61,10 @@ func DetectSystem() *SystemInfoData {
 	defer sysInfoMu.Unlock()
 
 	info := SystemInfo{
-		NodeType:     detectNodeType(),
 		OS:           runtime.GOOS,
 		Architecture: runtime.GOARCH,
 	}
+	detectEnvironment(&info)
 	errors := make(map[string]string)
 
 	cpu, err := detectCPULimit()
@@ -80,52 +91,30 @@ func DetectSystem() *SystemInfoData {
 }
 
 func detectNodeType() NodeType {
-	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
-		return NodeKubernetes
-	}
-	if content, err := os.ReadFile("/proc/1/cgroup"); err == nil && strings.Contains(string(content), "docker") {
-		return NodeDocker
-	}
-	if data, err := os.ReadFile("/sys/class/dmi/id/product_name"); err == nil {
-		val := strings.ToLower(string(data))
-		if strings.Contains(val, "kvm") || strings.Contains(val, "vmware") || strings.Contains(val, "virtualbox") {
-			return NodeVM
-		}
-	}
-	return NodePhysical
+	var info SystemInfo
+	detectEnvironment(&info)
+	return info.NodeType
 }
 
-func detectCPULimit() (int64, error) {
-	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
-		parts := strings.Fields(string(data))
-		if len(parts) == 2 && parts[0] != "max" {
-			quota, _ := strconv.Atoi(parts[0])
-			period, _ := strconv.Atoi(parts[1])
-			if period > 0 && quota > 0 {
-				return int64(quota / period), nil
-			}
-		}
+func detectCPULimit() (float64, error) {
+	if stats, err := cgroup.Read(); err == nil && stats.CPU != nil && stats.CPU.Limit > 0 {
+		return stats.CPU.Limit, nil
 	}
 
 	if runtime.GOOS == "darwin" {
 		if out, err := exec.Command("sysctl", "-n", "hw.logicalcpu").Output(); err == nil {
 			if cpu, err := strconv.Atoi(strings.TrimSpace(string(out))); err == nil {
-				return int64(cpu), nil
+				return float64(cpu), nil
 			}
 		}
 	}
 
-	return int64(runtime.NumCPU()), nil
+	return float64(runtime.NumCPU()), nil
 }
 
 func detectMemoryLimit() (int64, error) {
-	if data, err := os.ReadFile("/sys/fs/cgroup/memory.max"); err == nil {
-		val := strings.TrimSpace(string(data))
-		if val != "max" {
-			if bytes, err := strconv.ParseInt(val, 10, 64); err == nil {
-				return int64(int(bytes / 1024 / 1024)), nil
-			}
-		}
+	if stats, err := cgroup.Read(); err == nil && stats.Memory != nil && stats.Memory.Limit > 0 {
+		return stats.Memory.Limit / 1024 / 1024, nil
 	}
 
 	if runtime.GOOS == "darwin" {
@@ -5,6 +5,8 @@ import (
 	"runtime"
 	"testing"
 	"time"
+
+	"github.com/elasticphphq/agent/internal/cgroup"
 )
 
 func TestSystemInfo_Structure(t *testing.T) {
@@ -29,7 +31,7 @@ func TestSystemInfo_Structure(t *testing.T) {
 	}
 
 	if info.CPULimit != 4 {
-		t.Errorf("Expected CPULimit to be 4, got %d", info.CPULimit)
+		t.Errorf("Expected CPULimit to be 4, got %v", info.CPULimit)
 	}
 
 	if info.MemoryLimitMB != 8192 {
@@ -80,6 +82,10 @@ func TestNodeType_Constants(t *testing.T) {
 	if NodePhysical != "physical" {
 		t.Errorf("Expected NodePhysical to be 'physical', got %s", NodePhysical)
 	}
+
+	if NodeContainer != "container" {
+		t.Errorf("Expected NodeContainer to be 'container', got %s", NodeContainer)
+	}
 }
 
 func TestDetectSystem(t *testing.T) {
@@ -171,7 +177,7 @@ func TestDetectNodeType(t *testing.T) {
 	nodeType = detectNodeType()
 
 	// Should be one of the valid node types
-	validTypes := []NodeType{NodeDocker, NodeVM, NodePhysical}
+	validTypes := []NodeType{NodeDocker, NodeContainer, NodeVM, NodePhysical}
 	found := false
 	for _, validType := range validTypes {
 		if nodeType == validType {
@@ -195,17 +201,19 @@ func TestDetectCPULimit(t *testing.T) {
 
 	// Should return a positive value
 	if cpuLimit <= 0 {
-		t.Errorf("Expected positive CPU limit, got %d", cpuLimit)
+		t.Errorf("Expected positive CPU limit, got %v", cpuLimit)
 	}
 
 	// Should be reasonable (not more than 1000 CPUs)
 	if cpuLimit > 1000 {
-		t.Errorf("CPU limit seems unreasonably high: %d", cpuLimit)
+		t.Errorf("CPU limit seems unreasonably high: %v", cpuLimit)
 	}
 
-	// Should be at least runtime.NumCPU() (fallback case)
-	if cpuLimit < int64(runtime.NumCPU()) {
-		t.Errorf("Expected CPU limit to be at least %d, got %d", runtime.NumCPU(), cpuLimit)
+	// Without a cgroup CPU quota it falls back to runtime.NumCPU()
+	if stats, err := cgroup.Read(); runtime.GOOS == "linux" && (err != nil || stats.CPU == nil || stats.CPU.Limit == 0) {
+		if cpuLimit != float64(runtime.NumCPU()) {
+			t.Errorf("Expected CPU limit to be %d, got %v", runtime.NumCPU(), cpuLimit)
+		}
 	}
 }
 
@@ -256,12 +264,12 @@ func TestDetectCPULimit_EdgeCases(t *testing.T) {
 
 		// Should return a positive value
 		if cpu <= 0 {
-			t.Errorf("detectCPULimit should return positive value, got: %d", cpu)
+			t.Errorf("detectCPULimit should return positive value, got: %v", cpu)
 		}
 
 		// Should not exceed reasonable limits (e.g., 1024 cores)
 		if cpu > 1024 {
-			t.Errorf("detectCPULimit returned unreasonably high value: %d", cpu)
+			t.Errorf("detectCPULimit returned unreasonably high value: %v", cpu)
 		}
 	}
 }
@@ -286,6 +294,7 @@ func TestDetectNodeType_FileSystemConditions(t *testing.T) {
 	validTypes := map[NodeType]bool{
 		NodeKubernetes: true,
 		NodeDocker:     true,
+		NodeContainer:  true,
 		NodeVM:         true,
 		NodePhysical:   true,
 	}
@@ -302,6 +311,8 @@ func TestDetectNodeType_FileSystemConditions(t *testing.T) {
 	}
 
 	// Test with empty Kubernetes environment (should NOT be detected as k8s)
+	// on a filesystem without any container or hypervisor traces
+	useFSRoot(t, t.TempDir())
 	os.Setenv("KUBERNETES_SERVICE_HOST", "")
 	nodeType = detectNodeType()
 	// Empty string means the env var is set but empty, which our code treats as set
@@ -333,7 +344,7 @@ func TestDetectNodeType_EdgeCases(t *testing.T) {
 	nodeType = detectNodeType()
 
 	// Should not panic and should return a valid node type
-	validTypes := []NodeType{NodeDocker, NodeVM, NodePhysical}
+	validTypes := []NodeType{NodeDocker, NodeContainer, NodeVM, NodePhysical}
 	found := false
 	for _, validType := range validTypes {
 		if nodeType == validType {